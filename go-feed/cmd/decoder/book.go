@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+)
+
+// bookView rebuilds each symbol's order book from the ITCH stream for -book.
+// It is safe for the read loop to apply while the print goroutine prints.
+type bookView struct {
+	mu     sync.Mutex
+	books  map[uint16]*orderbook.Book
+	stocks map[uint16]string
+}
+
+func newBookView() *bookView {
+	return &bookView{
+		books:  make(map[uint16]*orderbook.Book),
+		stocks: make(map[uint16]string),
+	}
+}
+
+// book returns locate's book, creating it on first use.
+func (v *bookView) book(locate uint16) *orderbook.Book {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	b, ok := v.books[locate]
+	if !ok {
+		b = orderbook.NewBook(locate, 0)
+		v.books[locate] = b
+	}
+	return b
+}
+
+func (v *bookView) setStock(locate uint16, stock string) {
+	v.mu.Lock()
+	v.stocks[locate] = stock
+	v.mu.Unlock()
+}
+
+// apply updates the book for one message body. Adds rest under their order
+// reference; executions and cancels reduce the order, deletes remove it, and
+// a replace removes the original and rests the new reference in its place.
+// Non-displayed trades (P) leave the book unchanged, as do references the
+// view never saw added.
+func (v *bookView) apply(b []byte) {
+	if len(b) < 11 {
+		return
+	}
+	locate := binary.BigEndian.Uint16(b[1:3])
+	switch b[0] {
+	case 'R':
+		if len(b) >= 39 {
+			v.setStock(locate, readStock(b[11:19]))
+		}
+	case 'A', 'F':
+		if len(b) < 36 {
+			return
+		}
+		v.setStock(locate, readStock(b[24:32]))
+		v.book(locate).AddOrder(&orderbook.Order{
+			ID:     binary.BigEndian.Uint64(b[11:19]),
+			Locate: locate,
+			Side:   orderbook.Side(b[19]),
+			Shares: int32(binary.BigEndian.Uint32(b[20:24])),
+			Price:  itch.Price4ToFloat(binary.BigEndian.Uint32(b[32:36])),
+		})
+	case 'E', 'X':
+		if len(b) < 23 {
+			return
+		}
+		ref := binary.BigEndian.Uint64(b[11:19])
+		v.book(locate).ReduceOrder(ref, int32(binary.BigEndian.Uint32(b[19:23])))
+	case 'D':
+		if len(b) < 19 {
+			return
+		}
+		v.book(locate).RemoveOrder(binary.BigEndian.Uint64(b[11:19]))
+	case 'U':
+		if len(b) < 35 {
+			return
+		}
+		bk := v.book(locate)
+		old := bk.RemoveOrder(binary.BigEndian.Uint64(b[11:19]))
+		if old == nil {
+			return
+		}
+		bk.AddOrder(&orderbook.Order{
+			ID:     binary.BigEndian.Uint64(b[19:27]),
+			Locate: locate,
+			Side:   old.Side,
+			Shares: int32(binary.BigEndian.Uint32(b[27:31])),
+			Price:  itch.Price4ToFloat(binary.BigEndian.Uint32(b[31:35])),
+			MPID:   old.MPID,
+		})
+	}
+}
+
+// top returns the best n levels of each side of locate's book, or ok false when
+// the view has no book for it.
+func (v *bookView) top(locate uint16, n int) (bids, asks []orderbook.DepthLevel, ok bool) {
+	v.mu.Lock()
+	b, ok := v.books[locate]
+	v.mu.Unlock()
+	if !ok {
+		return nil, nil, false
+	}
+	bids, asks = b.TopN(n)
+	return bids, asks, true
+}
+
+// print writes the top n levels of every book, by locate, bids beside asks.
+func (v *bookView) print(w io.Writer, n int) {
+	v.mu.Lock()
+	locates := make([]uint16, 0, len(v.books))
+	for loc := range v.books {
+		locates = append(locates, loc)
+	}
+	stocks := make(map[uint16]string, len(v.stocks))
+	for loc, s := range v.stocks {
+		stocks[loc] = s
+	}
+	v.mu.Unlock()
+	sort.Slice(locates, func(i, j int) bool { return locates[i] < locates[j] })
+
+	for _, loc := range locates {
+		bids, asks, _ := v.top(loc, n)
+		fmt.Fprintf(w, "BOOK     locate=%-3d  stock=%-8s\n", loc, stocks[loc])
+		for i := range max(len(bids), len(asks)) {
+			fmt.Fprintf(w, "         %s  |  %s\n", fmtLevel(bids, i), fmtLevel(asks, i))
+		}
+	}
+}
+
+// fmtLevel formats levels[i] as "orders  shares @ price", or blanks of the
+// same width when that side has fewer levels.
+func fmtLevel(levels []orderbook.DepthLevel, i int) string {
+	if i >= len(levels) {
+		return fmt.Sprintf("%25s", "")
+	}
+	l := levels[i]
+	return fmt.Sprintf("%3d  %7d @ %10s", l.Orders, l.TotalShares,
+		fmtPrice4(itch.Price4(l.Price)))
+}
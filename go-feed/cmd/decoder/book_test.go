@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// TestBookViewTopOfBook feeds a known add/execute/cancel/delete/replace/trade
+// sequence through the view and checks the resulting top of book.
+func TestBookViewTopOfBook(t *testing.T) {
+	v := newBookView()
+	msgs := []itch.Message{
+		{Type: itch.MsgAddOrder, StockLocate: 1, Stock: "NEXO", OrderRef: 1, Side: 'B', Shares: 100, Price: 185.00},
+		{Type: itch.MsgAddOrder, StockLocate: 1, Stock: "NEXO", OrderRef: 2, Side: 'B', Shares: 200, Price: 185.00},
+		{Type: itch.MsgAddOrder, StockLocate: 1, Stock: "NEXO", OrderRef: 3, Side: 'B', Shares: 300, Price: 184.99},
+		{Type: itch.MsgAddOrderMPID, StockLocate: 1, Stock: "NEXO", OrderRef: 4, Side: 'S', Shares: 400, Price: 185.02, MPID: "GSCO"},
+		{Type: itch.MsgAddOrder, StockLocate: 1, Stock: "NEXO", OrderRef: 5, Side: 'S', Shares: 500, Price: 185.03},
+		{Type: itch.MsgOrderExecuted, StockLocate: 1, OrderRef: 1, Shares: 100, MatchNumber: 1}, // fills ref 1
+		{Type: itch.MsgOrderCancel, StockLocate: 1, OrderRef: 2, Shares: 50},                    // 150 left
+		{Type: itch.MsgOrderDelete, StockLocate: 1, OrderRef: 3},
+		{Type: itch.MsgOrderReplace, StockLocate: 1, OrigOrderRef: 4, OrderRef: 6, Shares: 250, Price: 185.01},
+		{Type: itch.MsgTrade, StockLocate: 1, Stock: "NEXO", OrderRef: 0, Side: 'B', Shares: 75, Price: 185.01, MatchNumber: 2},
+		{Type: itch.MsgOrderDelete, StockLocate: 1, OrderRef: 99}, // never added
+	}
+	for i := range msgs {
+		v.apply(itch.EncodeBinary(&msgs[i])[2:])
+	}
+
+	bids, asks, ok := v.top(1, 5)
+	if !ok {
+		t.Fatal("no book for locate 1")
+	}
+	if len(bids) != 1 || bids[0].Price != 185.00 || bids[0].Orders != 1 || bids[0].TotalShares != 150 {
+		t.Errorf("bids = %+v, want one level of 1 order, 150 @ 185.00", bids)
+	}
+	if len(asks) != 2 || asks[0].Price != 185.01 || asks[0].TotalShares != 250 ||
+		asks[1].Price != 185.03 || asks[1].TotalShares != 500 {
+		t.Errorf("asks = %+v, want 250 @ 185.01 then 500 @ 185.03", asks)
+	}
+
+	var sb strings.Builder
+	v.print(&sb, 1)
+	out := sb.String()
+	if !strings.Contains(out, "stock=NEXO") || !strings.Contains(out, "150 @   185.0000") ||
+		!strings.Contains(out, "250 @   185.0100") || strings.Contains(out, "185.0300") {
+		t.Errorf("print(1) =\n%s\nwant NEXO's best bid and ask only", out)
+	}
+}
@@ -7,8 +7,9 @@
 //	decoder -url ws://host:8100/feed     # custom endpoint
 //	decoder -symbols BLITZ,NEXO          # subscribe to specific symbols
 //	decoder -json                        # request JSON format instead (pass-through print)
-//	decoder -stats 10                    # print message rate stats every N seconds
+//	decoder -stats 10                    # print message rate stats (total and per type) every N seconds
 //	decoder -hex                         # also dump raw hex alongside decoded output
+//	decoder -book 5                      # rebuild the book, print the top 5 levels every -book-interval seconds
 package main
 
 import (
@@ -24,6 +25,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
 )
 
 func main() {
@@ -32,10 +34,22 @@ func main() {
 	useJSON := flag.Bool("json", false, "Request JSON format instead of binary")
 	statsInterval := flag.Int("stats", 0, "Print message rate stats every N seconds (0 = off)")
 	showHex := flag.Bool("hex", false, "Print raw hex dump alongside decoded output")
+	bookLevels := flag.Int("book", 0, "Rebuild the order book and print the top N levels instead of each message (0 = off)")
+	bookInterval := flag.Int("book-interval", 5, "Seconds between -book prints")
 	flag.Parse()
 
 	log.SetFlags(log.Ltime | log.Lmicroseconds)
 
+	if *bookLevels > 0 {
+		if *useJSON {
+			log.Fatal("-book needs the binary feed; drop -json")
+		}
+		if *bookInterval <= 0 {
+			log.Fatal("-book-interval must be positive")
+		}
+		books = newBookView()
+	}
+
 	// Connect
 	log.Printf("connecting to %s", *url)
 	conn, _, err := websocket.DefaultDialer.Dial(*url, nil)
@@ -57,19 +71,33 @@ func main() {
 	sendControl(conn, map[string]any{"action": "subscribe", "symbols": symList})
 	log.Printf("subscribed to %s in %s mode", *symbols, format)
 
-	// Stats counter
+	// Stats counter: WebSocket frames, plus decoded ITCH messages by type
 	var msgCount uint64
 	if *statsInterval > 0 {
 		go func() {
 			ticker := time.NewTicker(time.Duration(*statsInterval) * time.Second)
 			defer ticker.Stop()
+			secs := float64(*statsInterval)
 			var last uint64
+			var lastTypes [256]uint64
 			for range ticker.C {
 				cur := atomic.LoadUint64(&msgCount)
 				delta := cur - last
-				rate := float64(delta) / float64(*statsInterval)
-				log.Printf("[stats] %d msgs total | %.1f msgs/sec", cur, rate)
+				rate := float64(delta) / secs
+				types := msgTypes.snapshot()
+				log.Printf("[stats] %d msgs total | %.1f msgs/sec | %s", cur, rate, rates(lastTypes, types, secs))
 				last = cur
+				lastTypes = types
+			}
+		}()
+	}
+
+	if books != nil {
+		go func() {
+			ticker := time.NewTicker(time.Duration(*bookInterval) * time.Second)
+			defer ticker.Stop()
+			for range ticker.C {
+				books.print(os.Stdout, *bookLevels)
 			}
 		}()
 	}
@@ -161,12 +189,24 @@ func decodeBinaryFrames(data []byte, showHex bool) {
 	}
 }
 
+// msgTypes counts every message decodeMessage sees, for -stats.
+var msgTypes typeCounts
+
+// books is the rebuilt order book under -book; decodeMessage feeds it instead
+// of printing each message. nil when -book is off.
+var books *bookView
+
 func decodeMessage(body []byte) {
 	if len(body) == 0 {
 		return
 	}
 
 	msgType := body[0]
+	msgTypes.add(msgType)
+	if books != nil {
+		books.apply(body)
+		return
+	}
 	switch msgType {
 	case 'S':
 		decodeSystemEvent(body)
@@ -188,6 +228,10 @@ func decodeMessage(body []byte) {
 		decodeOrderReplace(body)
 	case 'P':
 		decodeTrade(body)
+	case 'Q':
+		decodeCrossTrade(body)
+	case 'I':
+		decodeNOII(body)
 	default:
 		fmt.Printf("UNKNOWN  type=%c (0x%02x) len=%d\n", msgType, msgType, len(body))
 	}
@@ -201,15 +245,6 @@ func readTimestamp(buf []byte) int64 {
 		int64(buf[3])<<16 | int64(buf[4])<<8 | int64(buf[5])
 }
 
-func fmtTimestamp(nanos int64) string {
-	d := time.Duration(nanos) * time.Nanosecond
-	h := int(d.Hours())
-	m := int(d.Minutes()) % 60
-	s := int(d.Seconds()) % 60
-	us := (nanos / 1000) % 1000000
-	return fmt.Sprintf("%02d:%02d:%02d.%06d", h, m, s, us)
-}
-
 func readStock(buf []byte) string {
 	return strings.TrimRight(string(buf), " ")
 }
@@ -252,7 +287,7 @@ func decodeSystemEvent(b []byte) {
 		name = fmt.Sprintf("0x%02x", event)
 	}
 
-	fmt.Printf("SYSTEM   %s  locate=%d  event=%s\n", fmtTimestamp(ts), locate, name)
+	fmt.Printf("SYSTEM   %s  locate=%d  event=%s\n", itch.FormatTimestamp(ts), locate, name)
 }
 
 // Stock Directory: 39 bytes
@@ -269,7 +304,7 @@ func decodeStockDirectory(b []byte) {
 	lotSize := binary.BigEndian.Uint32(b[21:25])
 
 	fmt.Printf("STOCKDIR %s  locate=%-3d  stock=%-8s  mktCat=%c  finStatus=%c  lotSize=%d\n",
-		fmtTimestamp(ts), locate, stock, mktCat, finStatus, lotSize)
+		itch.FormatTimestamp(ts), locate, stock, mktCat, finStatus, lotSize)
 }
 
 // Stock Trading Action: 25 bytes
@@ -290,7 +325,7 @@ func decodeStockTradingAction(b []byte) {
 	}
 
 	fmt.Printf("TRADING  %s  locate=%-3d  stock=%-8s  state=%s\n",
-		fmtTimestamp(ts), locate, stock, name)
+		itch.FormatTimestamp(ts), locate, stock, name)
 }
 
 // Add Order (no MPID): 36 bytes
@@ -308,7 +343,7 @@ func decodeAddOrder(b []byte) {
 	price := binary.BigEndian.Uint32(b[32:36])
 
 	fmt.Printf("ADD      %s  locate=%-3d  stock=%-8s  ref=%-10d  %4s  %5d @ %s\n",
-		fmtTimestamp(ts), locate, stock, orderRef, fmtSide(side), shares, fmtPrice4(price))
+		itch.FormatTimestamp(ts), locate, stock, orderRef, fmtSide(side), shares, fmtPrice4(price))
 }
 
 // Add Order with MPID: 40 bytes
@@ -327,7 +362,7 @@ func decodeAddOrderMPID(b []byte) {
 	mpid := readStock(b[36:40])
 
 	fmt.Printf("ADD+MPID %s  locate=%-3d  stock=%-8s  ref=%-10d  %4s  %5d @ %s  mpid=%s\n",
-		fmtTimestamp(ts), locate, stock, orderRef, fmtSide(side), shares, fmtPrice4(price), mpid)
+		itch.FormatTimestamp(ts), locate, stock, orderRef, fmtSide(side), shares, fmtPrice4(price), mpid)
 }
 
 // Order Executed: 31 bytes
@@ -343,7 +378,7 @@ func decodeOrderExecuted(b []byte) {
 	matchNum := binary.BigEndian.Uint64(b[23:31])
 
 	fmt.Printf("EXEC     %s  locate=%-3d  ref=%-10d  shares=%5d  match=%d\n",
-		fmtTimestamp(ts), locate, orderRef, shares, matchNum)
+		itch.FormatTimestamp(ts), locate, orderRef, shares, matchNum)
 }
 
 // Order Cancel: 23 bytes
@@ -358,7 +393,7 @@ func decodeOrderCancel(b []byte) {
 	shares := binary.BigEndian.Uint32(b[19:23])
 
 	fmt.Printf("CANCEL   %s  locate=%-3d  ref=%-10d  cancelled=%d\n",
-		fmtTimestamp(ts), locate, orderRef, shares)
+		itch.FormatTimestamp(ts), locate, orderRef, shares)
 }
 
 // Order Delete: 19 bytes
@@ -372,7 +407,7 @@ func decodeOrderDelete(b []byte) {
 	orderRef := binary.BigEndian.Uint64(b[11:19])
 
 	fmt.Printf("DELETE   %s  locate=%-3d  ref=%d\n",
-		fmtTimestamp(ts), locate, orderRef)
+		itch.FormatTimestamp(ts), locate, orderRef)
 }
 
 // Order Replace: 35 bytes
@@ -389,7 +424,7 @@ func decodeOrderReplace(b []byte) {
 	price := binary.BigEndian.Uint32(b[31:35])
 
 	fmt.Printf("REPLACE  %s  locate=%-3d  orig=%-10d  new=%-10d  %5d @ %s\n",
-		fmtTimestamp(ts), locate, origRef, newRef, shares, fmtPrice4(price))
+		itch.FormatTimestamp(ts), locate, origRef, newRef, shares, fmtPrice4(price))
 }
 
 // Trade (Non-Cross): 44 bytes
@@ -408,7 +443,43 @@ func decodeTrade(b []byte) {
 	matchNum := binary.BigEndian.Uint64(b[36:44])
 
 	fmt.Printf("TRADE    %s  locate=%-3d  stock=%-8s  ref=%-10d  %4s  %5d @ %s  match=%d\n",
-		fmtTimestamp(ts), locate, stock, orderRef, fmtSide(side), shares, fmtPrice4(price), matchNum)
+		itch.FormatTimestamp(ts), locate, stock, orderRef, fmtSide(side), shares, fmtPrice4(price), matchNum)
+}
+
+// Cross Trade: 40 bytes
+func decodeCrossTrade(b []byte) {
+	if len(b) < 40 {
+		fmt.Printf("CROSS    truncated (%d bytes)\n", len(b))
+		return
+	}
+	locate := binary.BigEndian.Uint16(b[1:3])
+	ts := readTimestamp(b[5:11])
+	shares := binary.BigEndian.Uint64(b[11:19])
+	stock := readStock(b[19:27])
+	price := binary.BigEndian.Uint32(b[27:31])
+	matchNum := binary.BigEndian.Uint64(b[31:39])
+
+	fmt.Printf("CROSS    %s  locate=%-3d  stock=%-8s  %7d @ %s  match=%d  type=%c\n",
+		itch.FormatTimestamp(ts), locate, stock, shares, fmtPrice4(price), matchNum, b[39])
+}
+
+// Net Order Imbalance Indicator: 50 bytes
+func decodeNOII(b []byte) {
+	if len(b) < 50 {
+		fmt.Printf("NOII     truncated (%d bytes)\n", len(b))
+		return
+	}
+	locate := binary.BigEndian.Uint16(b[1:3])
+	ts := readTimestamp(b[5:11])
+	paired := binary.BigEndian.Uint64(b[11:19])
+	imbalance := binary.BigEndian.Uint64(b[19:27])
+	stock := readStock(b[28:36])
+	far := binary.BigEndian.Uint32(b[36:40])
+	near := binary.BigEndian.Uint32(b[40:44])
+	ref := binary.BigEndian.Uint32(b[44:48])
+
+	fmt.Printf("NOII     %s  locate=%-3d  stock=%-8s  paired=%d  imbalance=%d %c  near=%s  far=%s  ref=%s  type=%c\n",
+		itch.FormatTimestamp(ts), locate, stock, paired, imbalance, b[27], fmtPrice4(near), fmtPrice4(far), fmtPrice4(ref), b[48])
 }
 
 // --- Hex dump ---
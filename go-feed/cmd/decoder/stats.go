@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// typeLabels names the message types reported by -stats, in print order.
+var typeLabels = []struct {
+	typ   byte
+	label string
+}{
+	{'A', "adds"},
+	{'F', "addsMPID"},
+	{'E', "execs"},
+	{'X', "cancels"},
+	{'D', "deletes"},
+	{'U', "replaces"},
+	{'P', "trades"},
+	{'S', "system"},
+	{'R', "stockDir"},
+	{'H', "tradingAction"},
+}
+
+// typeCounts counts decoded ITCH messages by type byte. It is safe for the
+// read loop to add while the stats goroutine snapshots.
+type typeCounts struct {
+	n [256]atomic.Uint64
+}
+
+// add counts one message of type t.
+func (c *typeCounts) add(t byte) {
+	c.n[t].Add(1)
+}
+
+// snapshot returns the current count for every type byte.
+func (c *typeCounts) snapshot() [256]uint64 {
+	var out [256]uint64
+	for i := range c.n {
+		out[i] = c.n[i].Load()
+	}
+	return out
+}
+
+// rates formats the per-type message rates between two snapshots taken secs
+// apart, e.g. "adds 12.0/s trades 1.5/s". Types with no messages in the
+// interval are omitted; unrecognised types are summed as "other".
+func rates(prev, cur [256]uint64, secs float64) string {
+	known := make(map[byte]bool, len(typeLabels))
+	var sb strings.Builder
+	for _, tl := range typeLabels {
+		known[tl.typ] = true
+		if d := cur[tl.typ] - prev[tl.typ]; d > 0 {
+			fmt.Fprintf(&sb, " %s %.1f/s", tl.label, float64(d)/secs)
+		}
+	}
+	var other uint64
+	for t := range cur {
+		if !known[byte(t)] {
+			other += cur[t] - prev[t]
+		}
+	}
+	if other > 0 {
+		fmt.Fprintf(&sb, " other %.1f/s", float64(other)/secs)
+	}
+	return strings.TrimPrefix(sb.String(), " ")
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestTypeCountsKnownMix(t *testing.T) {
+	var c typeCounts
+	mix := map[byte]int{'A': 40, 'F': 10, 'P': 6, 'E': 4, 'D': 12, 'Z': 3}
+	for typ, n := range mix {
+		for range n {
+			c.add(typ)
+		}
+	}
+
+	snap := c.snapshot()
+	for typ, n := range mix {
+		if snap[typ] != uint64(n) {
+			t.Errorf("count[%c] = %d, want %d", typ, snap[typ], n)
+		}
+	}
+	if snap['U'] != 0 {
+		t.Errorf("count[U] = %d, want 0", snap['U'])
+	}
+
+	got := rates([256]uint64{}, snap, 2)
+	want := "adds 20.0/s addsMPID 5.0/s execs 2.0/s deletes 6.0/s trades 3.0/s other 1.5/s"
+	if got != want {
+		t.Errorf("rates = %q, want %q", got, want)
+	}
+
+	prev := snap
+	c.add('P')
+	if got := rates(prev, c.snapshot(), 1); got != "trades 1.0/s" {
+		t.Errorf("interval rates = %q, want %q", got, "trades 1.0/s")
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,17 +17,21 @@ import (
 	"github.com/ndrandal/feed-simulator/go-feed/internal/config"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/logging"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/persist"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/session"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/tape"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/version"
 )
 
 func main() {
 	cfg := config.Load()
+	logging.SetInterval(cfg.LogSampleInterval)
 
 	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
-	log.Println("feed simulator starting")
+	log.Printf("feed simulator %s (%s) starting", version.Version, version.GitCommit())
 
 	// Context with graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -39,83 +45,347 @@ func main() {
 		cancel()
 	}()
 
-	// PRNG
-	rng := engine.NewRNG(cfg.Seed)
-	log.Printf("PRNG seed: %d", cfg.Seed)
+	// Wall clock shared by the runners and session layer
+	clock := engine.RealClock{}
+
+	// PRNG. Resolve a random seed here rather than inside NewRNG so the value
+	// actually used is logged and reported by /api/version.
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := engine.NewRNG(seed)
+	log.Printf("PRNG seed: %d", seed)
 
 	// Symbols
 	syms := symbol.AllSymbols()
 	log.Printf("loaded %d symbols", len(syms))
+	if cfg.NoPersistTrades != "" {
+		skip, err := symbol.Select(syms, cfg.NoPersistTrades)
+		if err != nil {
+			log.Fatalf("invalid no-persist-trades: %v", err)
+		}
+		for _, s := range skip {
+			for i := range syms {
+				if syms[i].LocateCode == s.LocateCode {
+					syms[i].PersistTrades = false
+				}
+			}
+		}
+		log.Printf("not persisting trades for %d symbols", len(skip))
+	}
+	active, err := symbol.Select(syms, cfg.Symbols)
+	if err != nil {
+		log.Fatalf("invalid symbols: %v", err)
+	}
+	if len(active) < len(syms) {
+		log.Printf("running %d of %d symbols", len(active), len(syms))
+	}
 
 	// Market engine
 	market := engine.NewMarketEngine(rng, syms)
+	market.SetMarketWeight(cfg.MarketWeight)
+	tickSchedule, err := symbol.ParseTickSchedule(cfg.TickSchedule)
+	if err != nil {
+		log.Fatalf("invalid tick schedule: %v", err)
+	}
+	market.SetTickSchedule(tickSchedule)
+	rounding, err := symbol.ParseRounding(cfg.PriceRounding)
+	if err != nil {
+		log.Fatalf("invalid price rounding: %v", err)
+	}
+	market.SetRounding(rounding)
+	if cfg.GARCHAlpha > 0 {
+		if err := market.SetGARCH(cfg.GARCHAlpha, cfg.GARCHBeta); err != nil {
+			log.Fatalf("invalid GARCH parameters: %v", err)
+		}
+	}
+	tickJitter, err := engine.ParseTickJitter(cfg.TickJitter)
+	if err != nil {
+		log.Fatalf("invalid tick jitter: %v", err)
+	}
+	for ticker, p := range tickJitter {
+		i := slices.IndexFunc(syms, func(s symbol.Symbol) bool { return s.Ticker == ticker })
+		if i < 0 {
+			log.Fatalf("invalid tick jitter: unknown symbol %s", ticker)
+		}
+		market.SetTickJitter(syms[i].LocateCode, p)
+	}
+
+	timestamps, ok := itch.ParseTimestampFormat(cfg.JSONTimestamps)
+	if !ok {
+		log.Fatalf("invalid json timestamps: %q (want nanos, clock or rfc3339)", cfg.JSONTimestamps)
+	}
 
 	// Order books + simulators
+	priceMode, err := orderbook.ParseTradePriceMode(cfg.TradePrice)
+	if err != nil {
+		log.Fatalf("invalid trade price mode: %v", err)
+	}
+	tradeSide, err := orderbook.ParseTradeSide(cfg.TradeSide)
+	if err != nil {
+		log.Fatalf("invalid trade side: %v", err)
+	}
+	bias := orderbook.AggressorBias{Buy: cfg.AggressorBias, Momentum: cfg.AggressorMomentum}
+	if err := bias.Validate(); err != nil {
+		log.Fatalf("invalid aggressor bias: %v", err)
+	}
+	seedImbalance, err := orderbook.ParseSeedImbalance(cfg.SeedImbalance)
+	if err != nil {
+		log.Fatalf("invalid seed imbalance: %v", err)
+	}
+	byTicker := symbol.ByTicker()
+	for ticker := range seedImbalance {
+		if _, ok := byTicker[ticker]; !ok {
+			log.Fatalf("invalid seed imbalance: unknown symbol %s", ticker)
+		}
+	}
+	mpids := orderbook.DefaultMPIDs()
+	if cfg.MPIDs != "" {
+		mpids = strings.Split(cfg.MPIDs, ",")
+		for i := range mpids {
+			mpids[i] = strings.TrimSpace(mpids[i])
+		}
+	}
+	orderbook.SetOrderIDNamespaces(cfg.OrderIDNamespaces)
 	books := make(map[uint16]*orderbook.Simulator, len(syms))
 	for _, s := range syms {
 		book := orderbook.NewBook(s.LocateCode, s.TickSize)
+		book.SetMaxOrdersPerLevel(cfg.MaxOrdersPerLevel)
 		sim := orderbook.NewSimulator(rng, book, s.LocateCode, s.TickSize)
+		sim.SetTradePriceMode(priceMode)
+		sim.SetTradeSide(tradeSide)
+		sim.SetAggressorBias(bias)
+		sim.SetTickSchedule(tickSchedule)
+		sim.SetRounding(rounding)
+		sim.SetBlockSize(int32(cfg.BlockTradeShares))
+		sim.SetMaxTradeShares(int32(cfg.MaxTradeShares))
+		sim.SetLiquidityFlags(cfg.LiquidityFlags)
+		if err := sim.SetOTRTarget(cfg.OTRTarget, cfg.OTRWindow); err != nil {
+			log.Fatalf("invalid order-to-trade target: %v", err)
+		}
+		if err := sim.SetAttribution(mpids, cfg.MPIDRate); err != nil {
+			log.Fatalf("invalid MPID attribution: %v", err)
+		}
+		if err := sim.SetMarketMakers(cfg.MarketMakers); err != nil {
+			log.Fatalf("invalid market makers: %v", err)
+		}
+		if ratio, ok := seedImbalance[s.Ticker]; ok {
+			if err := sim.SetSeedImbalance(ratio); err != nil {
+				log.Fatalf("invalid seed imbalance for %s: %v", s.Ticker, err)
+			}
+		}
 		books[s.LocateCode] = sim
 	}
 
-	// PostgreSQL
-	store, err := persist.NewStore(ctx, cfg.DatabaseURL)
+	// Archive filename layout, shared by the archiver and the history readers
+	archiveLayout, err := archive.ParseTemplate(cfg.ArchiveTemplate)
 	if err != nil {
-		log.Fatalf("database connection failed: %v", err)
+		log.Fatalf("invalid archive template: %v", err)
 	}
-	defer store.Close(context.Background())
-
-	if err := store.Migrate(ctx); err != nil {
-		log.Fatalf("migration failed: %v", err)
+	archiveCatalog := archive.NewCatalogWithLayout(cfg.ArchiveDir, archiveLayout)
+
+	// With -replay-source archive, trade history (the API's trade reads and
+	// -rebuild-from-trades) is read from the archive files alone.
+	var archiveSource *archive.TradeReader
+	switch cfg.ReplaySource {
+	case "db":
+	case "archive":
+		if cfg.ArchiveDir == "" {
+			log.Fatalf("invalid -replay-source archive: needs -archive-dir")
+		}
+		from, to, err := archive.ParseDayRange(cfg.ReplayFrom, cfg.ReplayTo)
+		if err != nil {
+			log.Fatalf("invalid replay range: %v", err)
+		}
+		archiveSource = archive.NewTradeReader(archive.NewReader(archiveCatalog), from, to)
+		log.Printf("trade history served from the archive in %s", cfg.ArchiveDir)
+	default:
+		log.Fatalf("invalid replay source: %q (want db or archive)", cfg.ReplaySource)
 	}
 
-	// Persistence snapshotter
-	snapshotter := persist.NewSnapshotter(store, market, books, rng, syms)
+	// PostgreSQL, or with -no-db an in-memory reader that serves candles from
+	// this run's trades. Without a database nothing is persisted or restored.
+	var (
+		store       *persist.Store
+		snapshotter *persist.Snapshotter
+		memReader   *persist.InMemoryTradeReader
+		restored    bool
+	)
+	tradeRate := persist.NewTradeRate(clock)
+	if cfg.NoDB {
+		if cfg.RebuildFromTrades || cfg.ArchiveDir != "" && archiveSource == nil {
+			log.Fatalf("invalid -no-db: -rebuild-from-trades and archiving need the database")
+		}
+		memReader = persist.NewInMemoryTradeReader()
+		memReader.SetTradeRate(tradeRate)
+		log.Println("running without a database: state is not persisted")
+	} else {
+		store, err = persist.NewStore(ctx, cfg.DatabaseURL)
+		if err != nil {
+			log.Fatalf("database connection failed: %v", err)
+		}
+		defer store.Close(context.Background())
 
-	// Try to restore state
-	restored, err := snapshotter.Load(ctx)
-	if err != nil {
-		log.Printf("warning: failed to load state: %v", err)
+		if err := store.Migrate(ctx); err != nil {
+			log.Fatalf("migration failed: %v", err)
+		}
+
+		// Persistence snapshotter
+		snapshotter = persist.NewSnapshotter(store, market, books, rng, syms)
+		stateCodec, err := persist.ParseStateCodec(cfg.StateCodec)
+		if err != nil {
+			log.Fatalf("invalid state codec: %v", err)
+		}
+		snapshotter.SetStateCodec(stateCodec)
+		snapshotter.SetJitter(cfg.SnapshotJitter)
+		snapshotter.SetSkipBusy(cfg.SnapshotSkipBusy)
+		snapshotter.SetTradeRate(tradeRate)
+		if cfg.RebuildFromTrades {
+			var rebuild persist.TradeReader = persist.NewPgTradeReader(store.Pool())
+			if archiveSource != nil {
+				rebuild = archiveSource
+			}
+			snapshotter.SetRebuildFromTrades(rebuild)
+		}
+		runID := cfg.RunID
+		if runID == "" {
+			runID = persist.NewRunID()
+		}
+		snapshotter.SetRunID(runID)
+		log.Printf("trade run ID: %s", runID)
+
+		// Try to restore state. A restore replaces the seed with the one that
+		// originally produced the state, if the snapshot recorded it.
+		snapshotter.SetSeed(seed)
+		restored, err = snapshotter.Load(ctx)
+		if err != nil {
+			log.Printf("warning: failed to load state: %v", err)
+		}
 	}
 
 	// If not restored, initialize order books with base prices
 	if !restored {
 		log.Println("initializing order books from base prices...")
-		for _, s := range syms {
+		for _, s := range active {
 			sim := books[s.LocateCode]
 			sim.Initialize(s.BasePrice)
+			sim.WarmUp(s.BasePrice, cfg.WarmupSteps)
 		}
+		if cfg.WarmupSteps > 0 {
+			log.Printf("warmed up order books with %d silent steps each", cfg.WarmupSteps)
+		}
+	}
+
+	// Session open: each symbol's price when the session began, restored from
+	// the snapshot within the same session and re-recorded at every later
+	// session start. The change fields of /api/symbols measure from it, or
+	// from the start of each lookback period when one is set.
+	hours, err := engine.ParseMarketHours(cfg.MarketHours)
+	if err != nil {
+		log.Fatalf("invalid market hours: %v", err)
+	}
+	if cfg.OpeningAuction > 0 && cfg.MarketHours == "" {
+		log.Fatalf("invalid opening auction: -opening-auction needs -market-hours")
+	}
+	if market.RollSession(hours.SessionStart(clock.Now())) {
+		log.Println("recorded session open prices")
+	}
+	go sessionRoller(ctx, clock, market, hours)
+	if cfg.ChangeLookback > 0 {
+		go referenceMarker(ctx, clock, market, cfg.ChangeLookback)
 	}
 
 	// Session manager
-	mgr := session.NewManager(syms, cfg.SendBufferSize)
+	mgr := session.NewManagerWithClock(syms, cfg.SendBufferSize, clock)
+	mgr.SetMaxSubscriptions(cfg.MaxSubscriptions)
+	mgr.SetMaxClients(cfg.MaxClients)
+	mgr.SetMaxFrameSize(cfg.MaxFrameSize)
+	mgr.SetCompression(cfg.Compression, cfg.CompressMin)
+	mgr.SetBufferSizes(cfg.ReadBuffer, cfg.WriteBuffer, cfg.WriteBufferBin)
+	mgr.SetSendWait(cfg.SendWait)
+	mgr.SetDropOldest(cfg.DropOldest)
+	mgr.SetConcatBinary(cfg.BinaryConcat)
+	mgr.SetTimestampFormat(timestamps)
+	mgr.SetResumeBuffer(cfg.ResumeBuffer)
+	mgr.SetValidate(cfg.ValidateMessages)
+	mgr.SetHeartbeat(cfg.Heartbeat)
+	mgr.SetPriceQuotes(cfg.PriceQuotes)
+	if cfg.Chaos {
+		if err := mgr.SetChaos(cfg.ChaosRate, engine.NewRNG(seed)); err != nil {
+			log.Fatalf("invalid chaos rate: %v", err)
+		}
+		log.Printf("CHAOS: reordering and delaying %.2f%% of broadcast messages", cfg.ChaosRate*100)
+	}
+	bookMap := make(map[uint16]*orderbook.Book, len(books))
+	for loc, sim := range books {
+		bookMap[loc] = sim.Book()
+	}
+	mgr.SetBooks(bookMap)
+	go mgr.RunHeartbeats(ctx)
+	go mgr.RunThrottles(ctx)
+
+	// In-memory trade tape, fed from the broadcast path
+	var tradeTape *tape.Tape
+	if cfg.TapeSize > 0 {
+		tradeTape = tape.New(cfg.TapeSize)
+		mgr.SetTape(tradeTape)
+	}
 
 	// Trade persistence workers
 	tradeCh := make(chan tradeRecord, 4096)
-	for i := 0; i < 2; i++ {
-		go tradeWriter(ctx, snapshotter, tradeCh)
+	if memReader != nil {
+		go memoryTradeWriter(ctx, clock, memReader, tradeCh)
+	} else {
+		for i := 0; i < 2; i++ {
+			go tradeWriter(ctx, snapshotter, tradeCh)
+		}
 	}
 
-	// Start symbol runners (29 normal + 1 stress)
-	for _, s := range syms {
+	// Start runners for the selected symbols (29 normal + 1 stress when all
+	// run). Stress controllers are built here so /api/stress can report them.
+	stressCfg := engine.StressConfig{
+		CalmMinMs:   cfg.StressCalmMinMs,
+		CalmMaxMs:   cfg.StressCalmMaxMs,
+		ActiveMinMs: cfg.StressActiveMinMs,
+		ActiveMaxMs: cfg.StressActiveMaxMs,
+		BurstMinMs:  cfg.StressBurstMinMs,
+		BurstMaxMs:  cfg.StressBurstMaxMs,
+	}
+	stressCtrls := make(map[uint16]*engine.StressController)
+	for _, s := range active {
+		gate := engine.NewMarketGate(hours)
+		gate.SetPreOpen(cfg.OpeningAuction)
 		if s.IsStress {
-			go stressRunner(ctx, s, market, books[s.LocateCode], mgr, rng, cfg, tradeCh)
+			ctrl := engine.NewStressControllerWithClock(rng, stressCfg, clock)
+			stressCtrls[s.LocateCode] = ctrl
+			go stressRunner(ctx, clock, s, market, books[s.LocateCode], mgr, gate, ctrl, tradeCh)
 		} else {
-			go symbolRunner(ctx, s, market, books[s.LocateCode], mgr, cfg.TickInterval, tradeCh)
+			go symbolRunner(ctx, clock, s, market, books[s.LocateCode], mgr, gate, cfg.TickInterval, tradeCh)
 		}
 	}
-	log.Printf("started %d symbol runners", len(syms))
+	log.Printf("started %d symbol runners", len(active))
 
-	// Start persister
-	go snapshotter.Run(ctx, cfg.SnapshotInterval)
-	log.Println("started persistence snapshotter")
+	if snapshotter != nil {
+		// Start persister
+		go snapshotter.Run(ctx, cfg.SnapshotInterval)
+		log.Println("started persistence snapshotter")
 
-	// Start trade retention pruner
-	go persist.RunRetention(ctx, store, cfg.TradeRetentionDays)
+		// Start trade retention pruner
+		go persist.RunRetention(ctx, store, cfg.TradeRetentionDays, cfg.TradeRetentionCount)
+	}
 
-	// Start trade archiver (opt-in)
-	if cfg.ArchiveDir != "" {
-		archiver := archive.New(store.Pool(), cfg.ArchiveDir, cfg.ArchiveMaxGB, cfg.ArchiveIntervalHours, cfg.ArchiveAfterHours)
+	// Start trade archiver (opt-in; without a database the archive is only read)
+	var archiver *archive.Archiver
+	if cfg.ArchiveDir != "" && store != nil {
+		archiver = archive.New(store.Pool(), cfg.ArchiveDir, cfg.ArchiveMaxGB, cfg.ArchiveIntervalHours, cfg.ArchiveAfterHours)
+		if err := archiver.SetCodec(cfg.ArchiveCodec); err != nil {
+			log.Fatalf("invalid archive codec: %v", err)
+		}
+		if err := archiver.SetGzipLevel(cfg.ArchiveGzipLevel); err != nil {
+			log.Fatalf("invalid archive gzip level: %v", err)
+		}
+		archiver.SetLayout(archiveLayout)
 		go archiver.Run(ctx)
 	}
 
@@ -126,10 +396,34 @@ func main() {
 
 	// REST API. Trade reads go through archive.History so /api/trades transparently
 	// spans the live retention window and the cold archive (pass-through to live
-	// when archiving is disabled). Also registers /health and /api/history/meta.
-	liveReader := persist.NewPgTradeReader(store.Pool())
-	historyReader := archive.NewHistory(liveReader, archive.NewReader(archive.NewCatalog(cfg.ArchiveDir)), cfg.TradeRetentionDays)
+	// when archiving is disabled), or with -replay-source archive come from the
+	// archive alone. Also registers /health, /api/history/meta and the
+	// /api/archive file listing/download.
+	var liveReader persist.TradeReader = memReader
+	if store != nil {
+		liveReader = persist.NewPgTradeReader(store.Pool())
+	}
+	var historyReader persist.TradeReader = archive.NewHistory(liveReader, archive.NewReader(archiveCatalog), cfg.TradeRetentionDays)
+	if archiveSource != nil {
+		historyReader = archiveSource
+	}
 	apiServer := api.NewServer(historyReader, market, books, mgr, syms)
+	apiServer.SetArchiveCatalog(archiveCatalog)
+	apiServer.SetTape(tradeTape)
+	apiServer.SetSeed(seed)
+	apiServer.SetRNG(rng)
+	if snapshotter == nil {
+		apiServer.SetOriginSeed(seed)
+	} else if origin, ok := snapshotter.Seed(); ok {
+		apiServer.SetOriginSeed(origin)
+	}
+	apiServer.SetStressControllers(stressCtrls)
+	apiServer.SetTradeRate(tradeRate)
+	apiServer.SetTimeouts(cfg.APITimeout, cfg.APICandleTimeout)
+	apiServer.SetMaxCandleBuckets(cfg.MaxCandleBuckets)
+	if archiver != nil {
+		apiServer.SetArchiver(archiver)
+	}
 	apiServer.Register(mux)
 
 	addr := fmt.Sprintf("%s:%d", cfg.Host, cfg.WSPort)
@@ -155,15 +449,22 @@ func main() {
 }
 
 // symbolRunner runs a single normal symbol's tick loop at a fixed interval.
-func symbolRunner(ctx context.Context, sym symbol.Symbol, market *engine.MarketEngine, sim *orderbook.Simulator, mgr *session.Manager, interval time.Duration, tradeCh chan<- tradeRecord) {
-	ticker := time.NewTicker(interval)
+// Between ticks it applies the simulator's admin commands.
+func symbolRunner(ctx context.Context, clock engine.Clock, sym symbol.Symbol, market *engine.MarketEngine, sim *orderbook.Simulator, mgr *session.Manager, gate *engine.MarketGate, interval time.Duration, tradeCh chan<- tradeRecord) {
+	ticker := clock.NewTicker(interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
+		case cmd := <-sim.Commands():
+			runCommand(cmd, sym, sim, mgr)
+		case <-ticker.C():
+			if !marketOpen(clock, sym, market, sim, mgr, gate) {
+				continue
+			}
+
 			// Generate sector shocks (safe to call from multiple goroutines)
 			market.GenerateSectorShocks()
 
@@ -182,43 +483,108 @@ func symbolRunner(ctx context.Context, sym symbol.Symbol, market *engine.MarketE
 			msgs := sim.Step(price, numActions)
 
 			// Enqueue trades for persistence
-			enqueueTrades(tradeCh, sym.LocateCode, msgs)
+			enqueueTrades(tradeCh, sym, msgs)
 
 			// Broadcast to subscribed clients
-			mgr.Broadcast(sym.LocateCode, sym.Ticker, msgs)
+			mgr.BroadcastTick(sym.LocateCode, sym.Ticker, price, msgs)
 		}
 	}
 }
 
-// stressRunner runs the BLITZ stress symbol with variable-rate ticking.
-func stressRunner(ctx context.Context, sym symbol.Symbol, market *engine.MarketEngine, sim *orderbook.Simulator, mgr *session.Manager, rng *engine.RNG, cfg *config.Config, tradeCh chan<- tradeRecord) {
-	stressCfg := engine.StressConfig{
-		CalmMinMs:   cfg.StressCalmMinMs,
-		CalmMaxMs:   cfg.StressCalmMaxMs,
-		ActiveMinMs: cfg.StressActiveMinMs,
-		ActiveMaxMs: cfg.StressActiveMaxMs,
-		BurstMinMs:  cfg.StressBurstMinMs,
-		BurstMaxMs:  cfg.StressBurstMaxMs,
+// marketOpen checks sym's gate, broadcasting the start or end of market
+// hours when the session has just opened or closed, and reports whether the
+// runner should act this tick. In the gate's pre-open window it collects
+// opening auction orders instead, broadcasting imbalance updates, and the
+// open is followed by the opening cross, which sets the engine price.
+// Cross trades are broadcast only, not persisted.
+func marketOpen(clock engine.Clock, sym symbol.Symbol, market *engine.MarketEngine, sim *orderbook.Simulator, mgr *session.Manager, gate *engine.MarketGate) bool {
+	now := clock.Now()
+	open, event := gate.Check(now)
+	if event != 0 {
+		mgr.Broadcast(sym.LocateCode, sym.Ticker, []itch.Message{{
+			Type:        itch.MsgSystemEvent,
+			StockLocate: sym.LocateCode,
+			EventCode:   event,
+		}})
+	}
+	if event == itch.EventStartOfMarket {
+		if cross := sim.CrossAuction(); len(cross) > 0 {
+			market.SetPrice(sym.LocateCode, cross[0].Price)
+			mgr.Broadcast(sym.LocateCode, sym.Ticker, cross)
+		}
+	}
+	if !open {
+		if pre, noii := gate.PreOpen(now); pre {
+			sim.CollectAuction(market.Price(sym.LocateCode), 1)
+			if m, ok := sim.AuctionNOII(); ok && noii {
+				mgr.Broadcast(sym.LocateCode, sym.Ticker, []itch.Message{m})
+			}
+		}
 	}
-	ctrl := engine.NewStressController(rng, stressCfg)
+	return open
+}
 
-	lastPhaseLog := time.Now()
+// referenceMarker re-marks the engine's reference prices every lookback.
+func referenceMarker(ctx context.Context, clock engine.Clock, market *engine.MarketEngine, lookback time.Duration) {
+	ticker := clock.NewTicker(lookback)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-ticker.C():
+			market.MarkReference()
+		}
+	}
+}
+
+// sessionRoller records new session opens as each session begins.
+func sessionRoller(ctx context.Context, clock engine.Clock, market *engine.MarketEngine, hours engine.MarketHours) {
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if market.RollSession(hours.SessionStart(clock.Now())) {
+				log.Println("recorded session open prices")
+			}
+		}
+	}
+}
+
+// runCommand applies an admin command to sim on its runner's goroutine and
+// broadcasts the messages it produces.
+func runCommand(cmd orderbook.Command, sym symbol.Symbol, sim *orderbook.Simulator, mgr *session.Manager) {
+	cmd.Run(sim, func(msgs []itch.Message) {
+		mgr.Broadcast(sym.LocateCode, sym.Ticker, msgs)
+	})
+}
+
+// stressRunner runs the BLITZ stress symbol with variable-rate ticking,
+// applying the simulator's admin commands between ticks.
+func stressRunner(ctx context.Context, clock engine.Clock, sym symbol.Symbol, market *engine.MarketEngine, sim *orderbook.Simulator, mgr *session.Manager, gate *engine.MarketGate, ctrl *engine.StressController, tradeCh chan<- tradeRecord) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case cmd := <-sim.Commands():
+			runCommand(cmd, sym, sim, mgr)
 		default:
 		}
 
 		interval, numActions := ctrl.Tick()
+		if !marketOpen(clock, sym, market, sim, mgr, gate) {
+			clock.Sleep(interval)
+			continue
+		}
 
 		// Log phase changes periodically
-		if time.Since(lastPhaseLog) > 5*time.Second {
-			log.Printf("BLITZ: phase=%s intensity=%.2f interval=%v actions=%d",
-				ctrl.Phase(), ctrl.Intensity(), interval, numActions)
-			lastPhaseLog = time.Now()
-		}
+		logging.SampledLog("blitz-phase", 0, fmt.Sprintf("BLITZ: phase=%s intensity=%.2f interval=%v actions=%d",
+			ctrl.Phase(), ctrl.Intensity(), interval, numActions))
 
 		// Generate sector shocks
 		market.GenerateSectorShocks()
@@ -230,10 +596,10 @@ func stressRunner(ctx context.Context, sym symbol.Symbol, market *engine.MarketE
 		msgs := sim.Step(price, numActions)
 
 		// Enqueue trades for persistence
-		enqueueTrades(tradeCh, sym.LocateCode, msgs)
+		enqueueTrades(tradeCh, sym, msgs)
 
 		// Broadcast
-		mgr.Broadcast(sym.LocateCode, sym.Ticker, msgs)
+		mgr.BroadcastTick(sym.LocateCode, sym.Ticker, price, msgs)
 
 		// Send system event for burst starts
 		if ctrl.Phase() == engine.PhaseBurst && ctrl.Intensity() > 0.9 {
@@ -245,7 +611,7 @@ func stressRunner(ctx context.Context, sym symbol.Symbol, market *engine.MarketE
 			mgr.Broadcast(sym.LocateCode, sym.Ticker, []itch.Message{burstMsg})
 		}
 
-		time.Sleep(interval)
+		clock.Sleep(interval)
 	}
 }
 
@@ -253,7 +619,7 @@ func stressRunner(ctx context.Context, sym symbol.Symbol, market *engine.MarketE
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -272,9 +638,13 @@ type tradeRecord struct {
 	aggressor   byte
 }
 
-// enqueueTrades sends trade messages to the persistence channel.
+// enqueueTrades sends trade messages to the persistence channel, unless sym
+// has trade persistence turned off.
 // Drops silently if the channel buffer is full (back-pressure).
-func enqueueTrades(ch chan<- tradeRecord, locate uint16, msgs []itch.Message) {
+func enqueueTrades(ch chan<- tradeRecord, sym symbol.Symbol, msgs []itch.Message) {
+	if !sym.PersistTrades {
+		return
+	}
 	for i := range msgs {
 		if msgs[i].Type != itch.MsgTrade {
 			continue
@@ -282,10 +652,10 @@ func enqueueTrades(ch chan<- tradeRecord, locate uint16, msgs []itch.Message) {
 		select {
 		case ch <- tradeRecord{
 			matchNumber: msgs[i].MatchNumber,
-			locate:      locate,
+			locate:      sym.LocateCode,
 			price:       msgs[i].Price,
 			shares:      msgs[i].Shares,
-			aggressor:   msgs[i].Side,
+			aggressor:   msgs[i].AggressorSide(),
 		}:
 		default:
 			// buffer full — drop trade rather than block the ticker
@@ -293,6 +663,19 @@ func enqueueTrades(ch chan<- tradeRecord, locate uint16, msgs []itch.Message) {
 	}
 }
 
+// memoryTradeWriter drains the trade channel into the in-memory reader when
+// running without a database.
+func memoryTradeWriter(ctx context.Context, clock engine.Clock, r *persist.InMemoryTradeReader, ch <-chan tradeRecord) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case tr := <-ch:
+			r.Record(tr.locate, clock.Now(), tr.price, tr.shares)
+		}
+	}
+}
+
 // tradeWriter drains the trade channel and writes to the DB.
 func tradeWriter(ctx context.Context, snap *persist.Snapshotter, ch <-chan tradeRecord) {
 	for {
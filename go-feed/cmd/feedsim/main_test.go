@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+)
+
+// TestEnqueueTradesSkipsUnpersisted checks that only trades for symbols with
+// PersistTrades reach the persistence channel.
+func TestEnqueueTradesSkipsUnpersisted(t *testing.T) {
+	syms := symbol.AllSymbols()
+	nexo, blitz := syms[0], syms[27]
+	blitz.PersistTrades = false
+
+	msgs := func(locate uint16) []itch.Message {
+		return []itch.Message{
+			{Type: itch.MsgOrderExecuted, StockLocate: locate, OrderRef: 1, Shares: 100, MatchNumber: 1},
+			{Type: itch.MsgTrade, StockLocate: locate, Side: 'B', Shares: 100, Price: 125, MatchNumber: 1},
+		}
+	}
+	ch := make(chan tradeRecord, 10)
+	enqueueTrades(ch, blitz, msgs(blitz.LocateCode))
+	enqueueTrades(ch, nexo, msgs(nexo.LocateCode))
+	close(ch)
+
+	var got []tradeRecord
+	for tr := range ch {
+		got = append(got, tr)
+	}
+	if len(got) != 1 || got[0].locate != nexo.LocateCode || got[0].matchNumber != 1 {
+		t.Fatalf("enqueued %+v, want NEXO's one trade only", got)
+	}
+}
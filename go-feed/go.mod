@@ -7,6 +7,7 @@ toolchain go1.24.2
 require (
 	github.com/gorilla/websocket v1.5.3
 	github.com/jackc/pgx/v5 v5.7.4
+	github.com/klauspost/compress v1.18.0
 )
 
 require (
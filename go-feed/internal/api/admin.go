@@ -0,0 +1,418 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+)
+
+// setPriceRequest is the body of POST /api/admin/symbols/{ticker}/price.
+type setPriceRequest struct {
+	Price    float64 `json:"price"`
+	Recenter bool    `json:"recenter"`
+}
+
+type setPriceResponse struct {
+	Ticker     string  `json:"ticker"`
+	Price      float64 `json:"price"`
+	Recentered bool    `json:"recentered"`
+}
+
+// handleSetPrice resets a symbol's engine price mid-run and, when requested,
+// clears and reseeds its book around the new price. The symbol's runner
+// applies the recenter between ticks and broadcasts the resulting deletes and
+// adds, so subscribed clients stay in sync.
+func (s *Server) handleSetPrice(w http.ResponseWriter, r *http.Request) {
+	sym := s.resolveTicker(w, r.PathValue("ticker"))
+	if sym == nil {
+		return
+	}
+
+	var req setPriceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	tick := s.market.TickAt(sym.LocateCode, req.Price)
+	if err := validatePrice(req.Price, tick); badRequest(w, err) {
+		return
+	}
+	price := math.Round(req.Price/tick) * tick
+
+	s.market.SetPrice(sym.LocateCode, price)
+
+	recentered := false
+	if sim, ok := s.books[sym.LocateCode]; ok && req.Recenter {
+		_, err := s.runCommand(r.Context(), sim, func(sim *orderbook.Simulator) []itch.Message {
+			return sim.Recenter(price)
+		})
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, "price set but recenter failed: "+err.Error())
+			return
+		}
+		recentered = true
+	}
+
+	writeJSON(w, http.StatusOK, setPriceResponse{Ticker: sym.Ticker, Price: price, Recentered: recentered})
+}
+
+// adminCommandTimeout bounds how long an admin endpoint waits for a symbol's
+// runner to apply a command.
+const adminCommandTimeout = 5 * time.Second
+
+// runCommand has sim's runner apply fn between ticks and broadcast the
+// messages it returns (see orderbook.Simulator.Do), and returns them.
+func (s *Server) runCommand(ctx context.Context, sim *orderbook.Simulator, fn func(*orderbook.Simulator) []itch.Message) ([]itch.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, adminCommandTimeout)
+	defer cancel()
+	msgs, err := sim.Do(ctx, fn)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, errors.New("symbol runner did not respond")
+	}
+	return msgs, err
+}
+
+// validatePrice checks that price is positive and a whole number of ticks
+// (within floating-point tolerance).
+func validatePrice(price, tickSize float64) error {
+	if !(price > 0) || math.IsInf(price, 0) {
+		return fmt.Errorf("invalid price: %v must be positive", price)
+	}
+	ticks := price / tickSize
+	if math.Abs(ticks-math.Round(ticks)) > 1e-6 {
+		return fmt.Errorf("invalid price: %v is not a multiple of tick size %v", price, tickSize)
+	}
+	return nil
+}
+
+// drainRequest is the optional body of POST /api/admin/symbols/{ticker}/drain.
+// An empty body turns drain mode on.
+type drainRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type drainResponse struct {
+	Ticker   string `json:"ticker"`
+	Draining bool   `json:"draining"`
+	Orders   int    `json:"orders"`
+}
+
+// handleDrain switches a symbol's book into (or out of) thin-market mode, in
+// which no new orders are added and the book drains as cancels and trades
+// remove liquidity.
+func (s *Server) handleDrain(w http.ResponseWriter, r *http.Request) {
+	sym := s.resolveTicker(w, r.PathValue("ticker"))
+	if sym == nil {
+		return
+	}
+	sim, ok := s.books[sym.LocateCode]
+	if !ok {
+		writeError(w, http.StatusNotFound, "no order book for symbol: "+sym.Ticker)
+		return
+	}
+
+	var req drainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	on := req.Enabled == nil || *req.Enabled
+
+	sim.SetDrain(on)
+	writeJSON(w, http.StatusOK, drainResponse{Ticker: sym.Ticker, Draining: on, Orders: sim.Book().OrderCount()})
+}
+
+// maxWidenTicks bounds ?ticks= on the widen endpoint; wider than this just
+// empties the book.
+const maxWidenTicks = 1000
+
+type widenResponse struct {
+	Ticker  string  `json:"ticker"`
+	Ticks   int     `json:"ticks"`
+	Deleted int     `json:"deleted"`
+	BestBid float64 `json:"bestBid"`
+	BestAsk float64 `json:"bestAsk"`
+}
+
+// handleWiden blows out a symbol's spread by deleting every order within
+// ?ticks= ticks of the mid. The symbol's runner applies it between ticks and
+// broadcasts the deletes; the book then refills on its own as the simulation
+// runs.
+func (s *Server) handleWiden(w http.ResponseWriter, r *http.Request) {
+	sym := s.resolveTicker(w, r.PathValue("ticker"))
+	if sym == nil {
+		return
+	}
+	sim, ok := s.books[sym.LocateCode]
+	if !ok {
+		writeError(w, http.StatusNotFound, "no order book for symbol: "+sym.Ticker)
+		return
+	}
+	ticks, err := parseIntParam(r, "ticks", 0)
+	if badRequest(w, err) {
+		return
+	}
+	if ticks < 1 || ticks > maxWidenTicks {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid ticks: %d must be in [1, %d]", ticks, maxWidenTicks))
+		return
+	}
+
+	msgs, err := s.runCommand(r.Context(), sim, func(sim *orderbook.Simulator) []itch.Message {
+		return sim.Widen(ticks)
+	})
+	if err != nil {
+		writeError(w, http.StatusServiceUnavailable, "widen failed: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, widenResponse{
+		Ticker:  sym.Ticker,
+		Ticks:   ticks,
+		Deleted: len(msgs),
+		BestBid: sim.Book().BestBid(),
+		BestAsk: sim.Book().BestAsk(),
+	})
+}
+
+// biasRequest is the body of POST /api/admin/symbols/{ticker}/bias. Omitted
+// fields keep their current value.
+type biasRequest struct {
+	Buy      *float64 `json:"buy"`
+	Momentum *float64 `json:"momentum"`
+}
+
+type biasResponse struct {
+	Ticker   string  `json:"ticker"`
+	Buy      float64 `json:"buy"`
+	Momentum float64 `json:"momentum"`
+}
+
+// handleBias sets a symbol's aggressor-side bias: the probability that a
+// trade is buyer-initiated and how strongly that follows the last price move.
+func (s *Server) handleBias(w http.ResponseWriter, r *http.Request) {
+	sym := s.resolveTicker(w, r.PathValue("ticker"))
+	if sym == nil {
+		return
+	}
+	sim, ok := s.books[sym.LocateCode]
+	if !ok {
+		writeError(w, http.StatusNotFound, "no order book for symbol: "+sym.Ticker)
+		return
+	}
+
+	var req biasRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	b := sim.AggressorBias()
+	if req.Buy != nil {
+		b.Buy = *req.Buy
+	}
+	if req.Momentum != nil {
+		b.Momentum = *req.Momentum
+	}
+	if badRequest(w, b.Validate()) {
+		return
+	}
+
+	sim.SetAggressorBias(b)
+	writeJSON(w, http.StatusOK, biasResponse{Ticker: sym.Ticker, Buy: b.Buy, Momentum: b.Momentum})
+}
+
+// sectorVolatilityRequest is the body of
+// POST /api/admin/sectors/{sector}/volatility.
+type sectorVolatilityRequest struct {
+	Multiplier float64 `json:"multiplier"`
+}
+
+type sectorVolatilityResponse struct {
+	Sector     string   `json:"sector"`
+	Multiplier float64  `json:"multiplier"`
+	Symbols    []string `json:"symbols"`
+}
+
+// handleSectorVolatility scales the volatility of every symbol in a sector,
+// on top of each symbol's own multiplier, to stress the sector at once. A
+// multiplier of 1 restores normal volatility.
+func (s *Server) handleSectorVolatility(w http.ResponseWriter, r *http.Request) {
+	sector := symbol.Sector(r.PathValue("sector"))
+
+	var req sectorVolatilityRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	locates, err := s.market.SetSectorVolatilityScale(sector, req.Multiplier)
+	if badRequest(w, err) {
+		return
+	}
+	if len(locates) == 0 {
+		writeError(w, http.StatusNotFound, "no symbols in sector: "+string(sector))
+		return
+	}
+
+	resp := sectorVolatilityResponse{Sector: string(sector), Multiplier: req.Multiplier, Symbols: make([]string, 0, len(locates))}
+	for _, sym := range s.syms {
+		if sym.Sector == sector {
+			resp.Symbols = append(resp.Symbols, sym.Ticker)
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// archiveCursorResetter rewrites the archiver's cursor (archive.Archiver).
+type archiveCursorResetter interface {
+	ResetCursor(ctx context.Context, t time.Time) (time.Time, error)
+}
+
+// SetArchiver enables POST /api/admin/archive/reset against a. Without it
+// (archiving disabled) the endpoint returns 503.
+func (s *Server) SetArchiver(a archiveCursorResetter) {
+	s.archiver = a
+}
+
+// archiveResetRequest is the body of POST /api/admin/archive/reset.
+type archiveResetRequest struct {
+	Cursor time.Time `json:"cursor"`
+}
+
+type archiveResetResponse struct {
+	Cursor time.Time `json:"cursor"`
+}
+
+// handleArchiveReset moves the archive cursor so the archiver reprocesses
+// from the given time's UTC day on its next cycle.
+func (s *Server) handleArchiveReset(w http.ResponseWriter, r *http.Request) {
+	if s.archiver == nil {
+		writeError(w, http.StatusServiceUnavailable, "archiving is disabled")
+		return
+	}
+
+	var req archiveResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+	if req.Cursor.IsZero() {
+		writeError(w, http.StatusBadRequest, "cursor is required, e.g. \"2026-01-02T00:00:00Z\"")
+		return
+	}
+	if req.Cursor.After(s.clock.Now()) {
+		writeError(w, http.StatusBadRequest, "cursor is in the future")
+		return
+	}
+
+	day, err := s.archiver.ResetCursor(r.Context(), req.Cursor)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, archiveResetResponse{Cursor: day})
+}
+
+// verifyFailure is one book that failed its consistency check.
+type verifyFailure struct {
+	Ticker string `json:"ticker"`
+	Error  string `json:"error"`
+}
+
+type verifyResponse struct {
+	OK       bool            `json:"ok"`
+	Books    int             `json:"books"`
+	Failures []verifyFailure `json:"failures"`
+}
+
+// handleVerify runs the consistency self-check on every order book. It
+// answers 200 when all pass and 500, listing the failures, when any does.
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	resp := verifyResponse{Failures: []verifyFailure{}}
+	for _, sym := range s.syms {
+		sim, ok := s.books[sym.LocateCode]
+		if !ok {
+			continue
+		}
+		resp.Books++
+		if err := sim.Book().Verify(); err != nil {
+			resp.Failures = append(resp.Failures, verifyFailure{Ticker: sym.Ticker, Error: err.Error()})
+		}
+	}
+	resp.OK = len(resp.Failures) == 0
+
+	status := http.StatusOK
+	if !resp.OK {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, resp)
+}
+
+type countersResponse struct {
+	OrderID     uint64            `json:"orderId"`
+	MatchNumber uint64            `json:"matchNumber"`
+	Orders      int               `json:"orders"`
+	Books       map[string]int    `json:"books"`
+	OrderIDs    map[string]uint64 `json:"orderIds,omitempty"` // per-symbol counters, when namespaced
+}
+
+// handleCounters reports the orderbook package's order ID and match number
+// counters, the last values handed out, alongside each book's resting order
+// count, for spotting counter drift after a restore. With namespaced order
+// IDs it adds each symbol's own counter.
+func (s *Server) handleCounters(w http.ResponseWriter, r *http.Request) {
+	resp := countersResponse{
+		OrderID:     orderbook.GetOrderIDCounter(),
+		MatchNumber: orderbook.GetMatchCounter(),
+		Books:       make(map[string]int, len(s.books)),
+	}
+	if orderbook.OrderIDNamespaces() {
+		counters := orderbook.GetSymbolOrderIDCounters()
+		resp.OrderIDs = make(map[string]uint64, len(counters))
+		for _, sym := range s.syms {
+			if n, ok := counters[sym.LocateCode]; ok {
+				resp.OrderIDs[sym.Ticker] = n
+			}
+		}
+	}
+	for _, sym := range s.syms {
+		sim, ok := s.books[sym.LocateCode]
+		if !ok {
+			continue
+		}
+		n := sim.Book().OrderCount()
+		resp.Books[sym.Ticker] = n
+		resp.Orders += n
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type rngResponse struct {
+	Seed       int64  `json:"seed"`
+	OriginSeed *int64 `json:"originSeed"`
+	State      string `json:"state"`
+	Inc        string `json:"inc"`
+}
+
+// handleRNG reports the PRNG: this run's seed, the seed that originally
+// produced the (possibly restored) state, null when unknown, and the current
+// state and increment as hex, since they overflow JSON numbers.
+func (s *Server) handleRNG(w http.ResponseWriter, r *http.Request) {
+	if s.rng == nil {
+		writeError(w, http.StatusNotFound, "rng not available")
+		return
+	}
+	state, inc := s.rng.State()
+	writeJSON(w, http.StatusOK, rngResponse{
+		Seed:       s.seed,
+		OriginSeed: s.origin,
+		State:      fmt.Sprintf("%016x", state),
+		Inc:        fmt.Sprintf("%016x", inc),
+	})
+}
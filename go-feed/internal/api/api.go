@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -15,6 +16,7 @@ import (
 	"github.com/ndrandal/feed-simulator/go-feed/internal/persist"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/session"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/tape"
 )
 
 // historyMetaProvider is implemented by readers that can report live/archive
@@ -32,9 +34,30 @@ type Server struct {
 	mgr     *session.Manager
 	syms    []symbol.Symbol
 	byTick  map[string]*symbol.Symbol
+	etag    string // quoted universe hash served on /api/symbols
+	archive *archive.Catalog
+	tape    *tape.Tape
+	seed    int64       // PRNG seed reported by /api/version
+	rng     *engine.RNG // reported by /api/admin/rng (nil = not available)
+	origin  *int64      // seed that produced the restored state (nil = unknown)
 	startAt time.Time
+	clock   engine.Clock // "now" for live (forming) candles
+	stress  map[uint16]*engine.StressController
+	rate    *persist.TradeRate // recent persisted trades for /api/stats (nil = not reported)
+
+	archiver archiveCursorResetter // nil when archiving is disabled
+
+	queryTimeout  time.Duration // deadline for trade, stats and history queries
+	candleTimeout time.Duration // deadline for candle queries
+	maxBuckets    int64         // cap on a candle query's from..to buckets (0 = unlimited)
 }
 
+// Default query deadlines (see SetTimeouts).
+const (
+	defaultQueryTimeout  = 5 * time.Second
+	defaultCandleTimeout = 10 * time.Second
+)
+
 // NewServer creates a new API server.
 func NewServer(reader persist.TradeReader, market *engine.MarketEngine, books map[uint16]*orderbook.Simulator, mgr *session.Manager, syms []symbol.Symbol) *Server {
 	byTick := make(map[string]*symbol.Symbol, len(syms))
@@ -48,8 +71,62 @@ func NewServer(reader persist.TradeReader, market *engine.MarketEngine, books ma
 		mgr:     mgr,
 		syms:    syms,
 		byTick:  byTick,
+		etag:    `"` + symbol.HashSymbols(syms) + `"`,
 		startAt: time.Now(),
+		clock:   engine.RealClock{},
+
+		queryTimeout:  defaultQueryTimeout,
+		candleTimeout: defaultCandleTimeout,
+	}
+}
+
+// SetTimeouts sets the deadlines for the database queries behind the trades,
+// stats and history endpoints (query) and the candles endpoint (candles). A
+// query that runs out of time answers 503. A duration <= 0 keeps that
+// deadline's default.
+func (s *Server) SetTimeouts(query, candles time.Duration) {
+	if query > 0 {
+		s.queryTimeout = query
 	}
+	if candles > 0 {
+		s.candleTimeout = candles
+	}
+}
+
+// SetMaxCandleBuckets caps how many interval buckets a /api/candles from..to
+// range may span; wider ranges are refused with a 400 suggesting a coarser
+// interval. n <= 0 removes the cap.
+func (s *Server) SetMaxCandleBuckets(n int) {
+	s.maxBuckets = int64(max(n, 0))
+}
+
+// SetSeed records the PRNG seed the simulation was started with, for
+// /api/version.
+func (s *Server) SetSeed(seed int64) {
+	s.seed = seed
+}
+
+// SetRNG reports rng's state on /api/admin/rng.
+func (s *Server) SetRNG(rng *engine.RNG) {
+	s.rng = rng
+}
+
+// SetOriginSeed records the PRNG seed that originally produced the
+// simulation's state, which differs from this run's seed after a restore.
+// Unset, /api/admin/rng reports it as unknown.
+func (s *Server) SetOriginSeed(seed int64) {
+	s.origin = &seed
+}
+
+// SetStressControllers records the controllers driving the stress symbols,
+// keyed by locate code, for /api/stress.
+func (s *Server) SetStressControllers(ctrls map[uint16]*engine.StressController) {
+	s.stress = ctrls
+}
+
+// SetTradeRate reports r's rolling trade counts on /api/stats.
+func (s *Server) SetTradeRate(r *persist.TradeRate) {
+	s.rate = r
 }
 
 // Register attaches API routes to the given mux.
@@ -57,11 +134,29 @@ func (s *Server) Register(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/symbols", s.handleSymbols)
 	mux.HandleFunc("GET /api/symbols/{ticker}", s.handleSymbolDetail)
 	mux.HandleFunc("GET /api/book/{ticker}", s.handleBookDepth)
+	mux.HandleFunc("GET /api/book/{ticker}/impact", s.handleBookImpact)
+	mux.HandleFunc("GET /api/books", s.handleAllBooks)
 	mux.HandleFunc("GET /api/trades/{ticker}", s.handleTrades)
+	mux.HandleFunc("GET /api/trade/{matchNumber}", s.handleTradeByMatch)
+	mux.HandleFunc("GET /api/tape/{ticker}", s.handleTape)
 	mux.HandleFunc("GET /api/candles/{ticker}", s.handleCandles)
+	mux.HandleFunc("GET /api/histogram/{ticker}", s.handleHistogram)
 	mux.HandleFunc("GET /api/stats", s.handleStats)
+	mux.HandleFunc("GET /api/stress", s.handleStress)
 	mux.HandleFunc("GET /api/history/meta", s.handleHistoryMeta)
+	mux.HandleFunc("GET /api/archive", s.handleArchiveList)
+	mux.HandleFunc("GET /api/archive/{path...}", s.handleArchiveFile)
 	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /api/version", s.handleVersion)
+	mux.HandleFunc("POST /api/admin/symbols/{ticker}/price", s.handleSetPrice)
+	mux.HandleFunc("POST /api/admin/symbols/{ticker}/drain", s.handleDrain)
+	mux.HandleFunc("POST /api/admin/symbols/{ticker}/bias", s.handleBias)
+	mux.HandleFunc("POST /api/admin/symbols/{ticker}/widen", s.handleWiden)
+	mux.HandleFunc("POST /api/admin/sectors/{sector}/volatility", s.handleSectorVolatility)
+	mux.HandleFunc("POST /api/admin/archive/reset", s.handleArchiveReset)
+	mux.HandleFunc("GET /api/admin/verify", s.handleVerify)
+	mux.HandleFunc("GET /api/admin/counters", s.handleCounters)
+	mux.HandleFunc("GET /api/admin/rng", s.handleRNG)
 }
 
 // writeJSON writes a JSON response with the given status code.
@@ -76,6 +171,32 @@ func writeError(w http.ResponseWriter, status int, msg string) {
 	writeJSON(w, status, map[string]string{"error": msg})
 }
 
+// etagMatches reports whether the request's If-None-Match header matches etag
+// (a quoted entity tag). Handles "*", comma-separated lists, and weak tags.
+func etagMatches(r *http.Request, etag string) bool {
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" {
+		return false
+	}
+	for _, part := range strings.Split(inm, ",") {
+		tag := strings.TrimPrefix(strings.TrimSpace(part), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeQueryError writes a failed reader query: 503 when it ran out of time,
+// 500 otherwise.
+func writeQueryError(w http.ResponseWriter, err error, timeout time.Duration) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeError(w, http.StatusServiceUnavailable, fmt.Sprintf("query timed out after %v", timeout))
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
 // badRequest writes a 400 with the error message and reports whether err was
 // non-nil, so callers can `if badRequest(w, err) { return }`.
 func badRequest(w http.ResponseWriter, err error) bool {
@@ -155,6 +276,48 @@ func parseIntParam(r *http.Request, key string, def int) (int, error) {
 	return n, nil
 }
 
+// parseUintParam parses a non-negative integer query parameter with the same
+// absent/malformed semantics as parseIntParam.
+func parseUintParam(r *http.Request, key string, def uint64) (uint64, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q is not a non-negative integer", key, v)
+	}
+	return n, nil
+}
+
+// parseFloatParam parses a float query parameter with the same absent/malformed
+// semantics as parseIntParam.
+func parseFloatParam(r *http.Request, key string, def float64) (float64, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %q is not a number", key, v)
+	}
+	return f, nil
+}
+
+// parseBoolParam parses a boolean query parameter with the same
+// absent/malformed semantics as parseIntParam; absent means false.
+func parseBoolParam(r *http.Request, key string) (bool, error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %q is not a boolean", key, v)
+	}
+	return b, nil
+}
+
 // parseFill parses the optional `fill` query parameter for candle queries.
 // "zero" enables zero-volume gap filling; "" or "none" disables it; anything
 // else is rejected so typos surface as 400 rather than silently disabling fill.
@@ -169,6 +332,28 @@ func parseFill(r *http.Request) (bool, error) {
 	}
 }
 
+// parseBuckets parses the optional `buckets` query parameter for histograms: a
+// comma-separated list of ascending, positive lower bounds. Absent, it yields
+// persist.DefaultSizeBuckets.
+func parseBuckets(r *http.Request) ([]int32, error) {
+	v := r.URL.Query().Get("buckets")
+	if v == "" {
+		return persist.DefaultSizeBuckets, nil
+	}
+	var buckets []int32
+	for _, part := range strings.Split(v, ",") {
+		n, err := strconv.ParseInt(strings.TrimSpace(part), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid buckets: %q is not an integer", part)
+		}
+		buckets = append(buckets, int32(n))
+	}
+	if err := persist.ValidateSizeBuckets(buckets); err != nil {
+		return nil, fmt.Errorf("invalid buckets: %w", err)
+	}
+	return buckets, nil
+}
+
 // parseTimeParam parses an RFC3339 query parameter. An absent parameter yields
 // nil with no error; a present-but-malformed parameter yields an error so the
 // caller can reject the request with 400 instead of silently ignoring it.
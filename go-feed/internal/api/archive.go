@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/archive"
+)
+
+type archiveFileJSON struct {
+	Path   string `json:"path"`
+	Day    string `json:"day"`
+	Ticker string `json:"ticker,omitempty"`
+	Size   int64  `json:"size"`
+}
+
+// SetArchiveCatalog enables the archive file endpoints over cat. Without it
+// (or with a disabled catalog) the listing is empty and downloads 404.
+func (s *Server) SetArchiveCatalog(cat *archive.Catalog) {
+	s.archive = cat
+}
+
+// handleArchiveList lists the archived trade files, oldest first. Each path
+// can be passed to GET /api/archive/{path} to download the file.
+func (s *Server) handleArchiveList(w http.ResponseWriter, r *http.Request) {
+	out := []archiveFileJSON{}
+	if s.archive != nil {
+		days, err := s.archive.Days()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, df := range days {
+			out = append(out, archiveFileJSON{
+				Path:   df.Rel,
+				Day:    df.Date.Format("2006-01-02"),
+				Ticker: df.Ticker,
+				Size:   df.Size,
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleArchiveFile streams one compressed archive file as-is. The path is
+// resolved through the catalog, which rejects anything outside the archive
+// layout (absolute paths, "..", non-archive files).
+func (s *Server) handleArchiveFile(w http.ResponseWriter, r *http.Request) {
+	if s.archive == nil {
+		writeError(w, http.StatusNotFound, "archive not enabled")
+		return
+	}
+	df, err := s.archive.Lookup(r.PathValue("path"))
+	switch {
+	case errors.Is(err, archive.ErrInvalidPath):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case err != nil:
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	f, err := os.Open(df.Path)
+	if err != nil {
+		writeError(w, http.StatusNotFound, archive.ErrNotFound.Error())
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", df.ContentType())
+	w.Header().Set("Content-Disposition", `attachment; filename="`+path.Base(df.Rel)+`"`)
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
@@ -2,11 +2,20 @@ package api
 
 import (
 	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ndrandal/feed-simulator/go-feed/internal/archive"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/persist"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/version"
 )
 
 type symbolInfo struct {
@@ -15,13 +24,33 @@ type symbolInfo struct {
 	Name       string  `json:"name"`
 	Sector     string  `json:"sector"`
 	Price      float64 `json:"price"`
-	BestBid    float64 `json:"bestBid"`
-	BestAsk    float64 `json:"bestAsk"`
-	Spread     float64 `json:"spread"`
+	// Open is the price when the current session began.
+	Open float64 `json:"open"`
+	// Change and ChangePct are the move since the reference price: session
+	// open, or the start of the current -change-lookback period.
+	Change    float64 `json:"change"`
+	ChangePct float64 `json:"changePct"`
+	BestBid   float64 `json:"bestBid"`
+	BestAsk   float64 `json:"bestAsk"`
+	Spread    float64 `json:"spread"`
+	// LastUpdate is when the symbol last broadcast feed data; the zero time
+	// means it never has since start.
+	LastUpdate time.Time `json:"lastUpdate"`
+	// MsgRate is the symbol's broadcast messages a second over the last ten
+	// seconds.
+	MsgRate float64 `json:"msgRate"`
 }
 
-// handleSymbols returns all symbols with live prices and top-of-book.
+// handleSymbols returns all symbols with live prices and top-of-book. The ETag
+// is the symbol-universe hash, so clients caching the symbol list can revalidate
+// with If-None-Match and get a 304 while the universe is unchanged.
 func (s *Server) handleSymbols(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("ETag", s.etag)
+	if etagMatches(r, s.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	prices := s.market.AllPrices()
 	out := make([]symbolInfo, 0, len(s.syms))
 
@@ -32,7 +61,11 @@ func (s *Server) handleSymbols(w http.ResponseWriter, r *http.Request) {
 			Name:       sym.Name,
 			Sector:     string(sym.Sector),
 			Price:      prices[sym.LocateCode],
+			LastUpdate: s.mgr.LastBroadcast(sym.LocateCode),
+			MsgRate:    s.mgr.MessageRate(sym.LocateCode),
 		}
+		si.Open = s.market.Open(sym.LocateCode)
+		si.Change, si.ChangePct = s.market.Change(sym.LocateCode)
 		if sim, ok := s.books[sym.LocateCode]; ok {
 			book := sim.Book()
 			si.BestBid = book.BestBid()
@@ -60,7 +93,11 @@ func (s *Server) handleSymbolDetail(w http.ResponseWriter, r *http.Request) {
 		Name:       sym.Name,
 		Sector:     string(sym.Sector),
 		Price:      price,
+		LastUpdate: s.mgr.LastBroadcast(sym.LocateCode),
+		MsgRate:    s.mgr.MessageRate(sym.LocateCode),
 	}
+	si.Open = s.market.Open(sym.LocateCode)
+	si.Change, si.ChangePct = s.market.Change(sym.LocateCode)
 	if sim, ok := s.books[sym.LocateCode]; ok {
 		book := sim.Book()
 		si.BestBid = book.BestBid()
@@ -87,7 +124,8 @@ type levelJSON struct {
 	TotalShares int32   `json:"totalShares"`
 }
 
-// handleBookDepth returns the order book depth for a symbol.
+// handleBookDepth returns the order book depth for a symbol. An optional
+// `granularity` (price width) aggregates levels into coarser buckets.
 func (s *Server) handleBookDepth(w http.ResponseWriter, r *http.Request) {
 	ticker := r.PathValue("ticker")
 	sym := s.resolveTicker(w, ticker)
@@ -95,13 +133,22 @@ func (s *Server) handleBookDepth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	granularity, err := parseFloatParam(r, "granularity", 0)
+	if badRequest(w, err) {
+		return
+	}
+	if granularity < 0 {
+		writeError(w, http.StatusBadRequest, "invalid granularity: must be positive")
+		return
+	}
+
 	sim, ok := s.books[sym.LocateCode]
 	if !ok {
 		writeError(w, http.StatusNotFound, "no book for symbol: "+ticker)
 		return
 	}
 
-	snap := sim.Book().Depth()
+	snap := sim.Book().DepthAt(granularity)
 
 	resp := depthResponse{
 		Ticker:   sym.Ticker,
@@ -111,22 +158,114 @@ func (s *Server) handleBookDepth(w http.ResponseWriter, r *http.Request) {
 		Spread:   snap.Spread,
 	}
 
-	resp.Bids = make([]levelJSON, len(snap.Bids))
-	for i, lvl := range snap.Bids {
-		resp.Bids[i] = levelJSON{Price: lvl.Price, Orders: lvl.Orders, TotalShares: lvl.TotalShares}
+	resp.Bids = toLevelJSON(snap.Bids)
+	resp.Asks = toLevelJSON(snap.Asks)
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// impactResponse is the depth an order could take without walking past its
+// limit price.
+type impactResponse struct {
+	Ticker string  `json:"ticker"`
+	Side   string  `json:"side"`
+	Price  float64 `json:"price"`
+	Shares int32   `json:"shares"`
+}
+
+// handleBookImpact returns how many shares a buy (or sell) limited to
+// `price` could fill against the opposite side of the book right now.
+func (s *Server) handleBookImpact(w http.ResponseWriter, r *http.Request) {
+	ticker := r.PathValue("ticker")
+	sym := s.resolveTicker(w, ticker)
+	if sym == nil {
+		return
 	}
-	resp.Asks = make([]levelJSON, len(snap.Asks))
-	for i, lvl := range snap.Asks {
-		resp.Asks[i] = levelJSON{Price: lvl.Price, Orders: lvl.Orders, TotalShares: lvl.TotalShares}
+
+	sideParam := r.URL.Query().Get("side")
+	var side orderbook.Side
+	switch sideParam {
+	case "buy":
+		side = orderbook.SideBuy
+	case "sell":
+		side = orderbook.SideSell
+	default:
+		writeError(w, http.StatusBadRequest, "invalid side: must be buy or sell")
+		return
+	}
+	price, err := parseFloatParam(r, "price", 0)
+	if badRequest(w, err) {
+		return
+	}
+	if price <= 0 {
+		writeError(w, http.StatusBadRequest, "invalid price: must be positive")
+		return
 	}
 
-	writeJSON(w, http.StatusOK, resp)
+	sim, ok := s.books[sym.LocateCode]
+	if !ok {
+		writeError(w, http.StatusNotFound, "no book for symbol: "+ticker)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, impactResponse{
+		Ticker: sym.Ticker,
+		Side:   sideParam,
+		Price:  price,
+		Shares: sim.Book().SharesUpTo(side, price),
+	})
+}
+
+// handleAllBooks returns the top `depth` levels per side (default MaxLevels)
+// of every symbol with a book, in universe order, so a dashboard grid needs
+// one request instead of one per symbol.
+func (s *Server) handleAllBooks(w http.ResponseWriter, r *http.Request) {
+	depth, err := parseIntParam(r, "depth", orderbook.MaxLevels)
+	if badRequest(w, err) {
+		return
+	}
+	if depth < 1 {
+		writeError(w, http.StatusBadRequest, "invalid depth: must be at least 1")
+		return
+	}
+
+	out := []depthResponse{}
+	for _, sym := range s.syms {
+		sim, ok := s.books[sym.LocateCode]
+		if !ok {
+			continue
+		}
+		bids, asks := sim.Book().TopN(depth)
+		resp := depthResponse{Ticker: sym.Ticker, Bids: toLevelJSON(bids), Asks: toLevelJSON(asks)}
+		if len(bids) > 0 {
+			resp.BestBid = bids[0].Price
+		}
+		if len(asks) > 0 {
+			resp.BestAsk = asks[0].Price
+		}
+		if resp.BestBid > 0 && resp.BestAsk > 0 {
+			resp.MidPrice = (resp.BestBid + resp.BestAsk) / 2
+			resp.Spread = resp.BestAsk - resp.BestBid
+		}
+		out = append(out, resp)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// toLevelJSON converts depth levels to their JSON form; never nil.
+func toLevelJSON(levels []orderbook.DepthLevel) []levelJSON {
+	out := make([]levelJSON, len(levels))
+	for i, lvl := range levels {
+		out[i] = levelJSON{Price: lvl.Price, Orders: lvl.Orders, TotalShares: lvl.TotalShares}
+	}
+	return out
 }
 
 // handleTrades returns paginated trades from the database. The {ticker} path
 // value may be a single symbol (fast path), a comma-separated list, or "*" for
 // all symbols; multi-symbol results are ordered newest-first with a ticker
-// tiebreak.
+// tiebreak. An Accept of application/x-ndjson gets one trade per line instead
+// of an array.
 func (s *Server) handleTrades(w http.ResponseWriter, r *http.Request) {
 	ticker := r.PathValue("ticker")
 
@@ -146,11 +285,19 @@ func (s *Server) handleTrades(w http.ResponseWriter, r *http.Request) {
 	if badRequest(w, err) {
 		return
 	}
+	sinceMatch, err := parseUintParam(r, "sinceMatch", 0)
+	if badRequest(w, err) {
+		return
+	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), s.queryTimeout)
 	defer cancel()
 
 	if isMultiTicker(ticker) {
+		if sinceMatch > 0 {
+			writeError(w, http.StatusBadRequest, "sinceMatch requires a single ticker")
+			return
+		}
 		locates, ok := s.resolveTickers(w, ticker)
 		if !ok {
 			return
@@ -163,10 +310,10 @@ func (s *Server) handleTrades(w http.ResponseWriter, r *http.Request) {
 			To:      to,
 		})
 		if err != nil {
-			writeError(w, http.StatusInternalServerError, err.Error())
+			writeQueryError(w, err, s.queryTimeout)
 			return
 		}
-		writeJSON(w, http.StatusOK, trades)
+		writeTrades(w, r, trades)
 		return
 	}
 
@@ -181,18 +328,124 @@ func (s *Server) handleTrades(w http.ResponseWriter, r *http.Request) {
 		Offset:       max(offset, 0),
 		From:         from,
 		To:           to,
+		SinceMatch:   sinceMatch,
 	})
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeQueryError(w, err, s.queryTimeout)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, trades)
+	writeTrades(w, r, trades)
 }
 
-// handleCandles returns OHLCV bars for a symbol.
+// handleTradeByMatch returns the one trade with the given match number, from
+// the live trade store (archived trades are not searched).
+func (s *Server) handleTradeByMatch(w http.ResponseWriter, r *http.Request) {
+	v := r.PathValue("matchNumber")
+	matchNumber, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid match number: "+strconv.Quote(v))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.queryTimeout)
+	defer cancel()
+
+	t, err := s.reader.QueryTradeByMatch(ctx, matchNumber)
+	if errors.Is(err, persist.ErrTradeNotFound) {
+		writeError(w, http.StatusNotFound, "no trade with match number "+v)
+		return
+	}
+	if err != nil {
+		writeQueryError(w, err, s.queryTimeout)
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
+
+// writeTrades writes trades as a JSON array or, when the request accepts
+// application/x-ndjson, as NDJSON: one trade object per line, encoded as it
+// is written rather than buffered whole.
+func writeTrades(w http.ResponseWriter, r *http.Request, trades []persist.Trade) {
+	if !strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		writeJSON(w, http.StatusOK, trades)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	for i := range trades {
+		if err := enc.Encode(&trades[i]); err != nil {
+			return
+		}
+	}
+}
+
+type intervalErrorResponse struct {
+	Error     string   `json:"error"`
+	Nearest   string   `json:"nearest,omitempty"`
+	Supported []string `json:"supported"`
+}
+
+// writeIntervalError writes a 400 naming the supported candle intervals and,
+// when there is one, the nearest match.
+func writeIntervalError(w http.ResponseWriter, err *persist.IntervalError) {
+	writeJSON(w, http.StatusBadRequest, intervalErrorResponse{
+		Error:     err.Error(),
+		Nearest:   err.Nearest,
+		Supported: persist.SupportedIntervals(),
+	})
+}
+
+type rangeErrorResponse struct {
+	Error      string `json:"error"`
+	Buckets    int64  `json:"buckets"`
+	MaxBuckets int64  `json:"maxBuckets"`
+	Suggested  string `json:"suggested,omitempty"`
+}
+
+// writeRangeError writes a 400 for a candle range spanning more buckets than
+// allowed, suggesting the finest interval that fits, if any does.
+func writeRangeError(w http.ResponseWriter, interval string, buckets, maxBuckets int64, suggested string) {
+	msg := fmt.Sprintf("range spans %d %s candles, more than the %d allowed", buckets, interval, maxBuckets)
+	if suggested != "" {
+		msg += "; use a coarser interval such as " + suggested
+	} else {
+		msg += "; narrow from and to"
+	}
+	writeJSON(w, http.StatusBadRequest, rangeErrorResponse{
+		Error:      msg,
+		Buckets:    buckets,
+		MaxBuckets: maxBuckets,
+		Suggested:  suggested,
+	})
+}
+
+// candleBuckets returns how many interval buckets [from, to] touches.
+func candleBuckets(from, to time.Time, interval string) int64 {
+	secs, _ := persist.IntervalSeconds(interval)
+	if secs <= 0 || to.Before(from) {
+		return 0
+	}
+	return int64(to.Sub(from)/(time.Duration(secs)*time.Second)) + 1
+}
+
+// suggestInterval returns the finest supported interval whose buckets over
+// [from, to] fit in maxBuckets, or "" when none does.
+func suggestInterval(from, to time.Time, maxBuckets int64) string {
+	for _, iv := range persist.SupportedIntervals() {
+		if candleBuckets(from, to, iv) <= maxBuckets {
+			return iv
+		}
+	}
+	return ""
+}
+
+// handleCandles returns OHLCV bars for a symbol, as JSON or, for
+// /api/candles/{ticker}.csv or an Accept of text/csv, as CSV.
 func (s *Server) handleCandles(w http.ResponseWriter, r *http.Request) {
-	ticker := r.PathValue("ticker")
+	ticker, asCSV := strings.CutSuffix(r.PathValue("ticker"), ".csv")
+	asCSV = asCSV || strings.Contains(r.Header.Get("Accept"), "text/csv")
 	sym := s.resolveTicker(w, ticker)
 	if sym == nil {
 		return
@@ -202,7 +455,7 @@ func (s *Server) handleCandles(w http.ResponseWriter, r *http.Request) {
 	if interval == "" {
 		interval = "1m"
 	} else if !persist.ValidInterval(interval) {
-		writeError(w, http.StatusBadRequest, "invalid interval: "+interval)
+		writeIntervalError(w, persist.NewIntervalError(interval))
 		return
 	}
 
@@ -227,10 +480,25 @@ func (s *Server) handleCandles(w http.ResponseWriter, r *http.Request) {
 	if badRequest(w, err) {
 		return
 	}
+	live, err := parseBoolParam(r, "live")
+	if badRequest(w, err) {
+		return
+	}
+
+	if from != nil && s.maxBuckets > 0 {
+		end := s.clock.Now()
+		if to != nil {
+			end = *to
+		}
+		if n := candleBuckets(*from, end, interval); n > s.maxBuckets {
+			writeRangeError(w, interval, n, s.maxBuckets, suggestInterval(*from, end, s.maxBuckets))
+			return
+		}
+	}
 
 	clamped := persist.ClampLimit(limit)
 
-	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), s.candleTimeout)
 	defer cancel()
 
 	candles, err := s.reader.QueryCandles(ctx, persist.CandleFilter{
@@ -242,10 +510,22 @@ func (s *Server) handleCandles(w http.ResponseWriter, r *http.Request) {
 		Before:       before,
 		Fill:         fill,
 	})
+	var ivErr *persist.IntervalError
+	if errors.As(err, &ivErr) {
+		writeIntervalError(w, ivErr)
+		return
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeQueryError(w, err, s.candleTimeout)
+		return
+	}
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if live && before == nil {
+		candles = s.withLiveCandle(candles, sym.LocateCode, interval, to, clamped)
+	}
 
 	// A full page implies older buckets may remain: expose the oldest bucket as
 	// the cursor for the next (older) page via ?before=.
@@ -254,9 +534,74 @@ func (s *Server) handleCandles(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Next-Cursor", oldest.UTC().Format(time.RFC3339))
 	}
 
+	if asCSV {
+		writeCandlesCSV(w, candles)
+		return
+	}
 	writeJSON(w, http.StatusOK, candles)
 }
 
+// writeCandlesCSV writes candles as CSV: a t,o,h,l,c,v,n header, then one row
+// per bar with its bucket time in RFC 3339.
+func writeCandlesCSV(w http.ResponseWriter, candles []persist.Candle) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"t", "o", "h", "l", "c", "v", "n"})
+	for _, c := range candles {
+		cw.Write([]string{
+			c.Bucket.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(c.Open, 'f', -1, 64),
+			strconv.FormatFloat(c.High, 'f', -1, 64),
+			strconv.FormatFloat(c.Low, 'f', -1, 64),
+			strconv.FormatFloat(c.Close, 'f', -1, 64),
+			strconv.FormatInt(c.Volume, 10),
+			strconv.FormatInt(c.Count, 10),
+		})
+	}
+	cw.Flush()
+}
+
+type histogramResponse struct {
+	Ticker  string                    `json:"ticker"`
+	Field   string                    `json:"field"`
+	Buckets []persist.HistogramBucket `json:"buckets"`
+}
+
+// handleHistogram returns the distribution of a symbol's trade sizes from the
+// live trade store. field selects what is bucketed; only "shares" (the
+// default) is supported. buckets is an optional comma-separated list of
+// ascending lower bounds, defaulting to persist.DefaultSizeBuckets.
+func (s *Server) handleHistogram(w http.ResponseWriter, r *http.Request) {
+	sym := s.resolveTicker(w, r.PathValue("ticker"))
+	if sym == nil {
+		return
+	}
+
+	field := r.URL.Query().Get("field")
+	if field == "" {
+		field = "shares"
+	} else if field != "shares" {
+		writeError(w, http.StatusBadRequest, "unsupported histogram field: "+strconv.Quote(field)+" (want \"shares\")")
+		return
+	}
+
+	buckets, err := parseBuckets(r)
+	if badRequest(w, err) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.queryTimeout)
+	defer cancel()
+
+	hist, err := s.reader.QueryTradeSizeHistogram(ctx, sym.LocateCode, buckets)
+	if err != nil {
+		writeQueryError(w, err, s.queryTimeout)
+		return
+	}
+	writeJSON(w, http.StatusOK, histogramResponse{Ticker: sym.Ticker, Field: field, Buckets: hist})
+}
+
 type statsResponse struct {
 	Uptime        string  `json:"uptime"`
 	Clients       int     `json:"clients"`
@@ -264,16 +609,19 @@ type statsResponse struct {
 	TotalOrders   int     `json:"totalOrders"`
 	TotalTrades   int64   `json:"totalTrades"`
 	TotalVolume   int64   `json:"totalVolume"`
+	TradesPerMin  *int64  `json:"tradesPerMin,omitempty"`
+	TradesPer5Min *int64  `json:"tradesPer5Min,omitempty"`
 	DBSizeBytes   int64   `json:"dbSizeBytes"`
 	DBTradesBytes int64   `json:"dbTradesBytes"`
 	DBIndexBytes  int64   `json:"dbIndexBytes"`
 	DBPctOf2GB    float64 `json:"dbPctOf2GB"`
 	DBBudgetBytes int64   `json:"dbBudgetBytes"`
+	UniverseHash  string  `json:"universeHash"`
 }
 
 // handleStats returns runtime and aggregate statistics.
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), s.queryTimeout)
 	defer cancel()
 
 	var totalOrders int
@@ -283,7 +631,7 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 
 	ts, err := s.reader.QueryTradeStats(ctx)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeQueryError(w, err, s.queryTimeout)
 		return
 	}
 
@@ -295,6 +643,11 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		TotalTrades:   ts.TotalTrades,
 		TotalVolume:   ts.TotalVolume,
 		DBBudgetBytes: persist.SizeBudgetBytes,
+		UniverseHash:  strings.Trim(s.etag, `"`),
+	}
+	if s.rate != nil {
+		perMin, per5Min := s.rate.Count(time.Minute), s.rate.Count(5*time.Minute)
+		resp.TradesPerMin, resp.TradesPer5Min = &perMin, &per5Min
 	}
 
 	// DB size is best-effort: a size-query failure should not 500 the stats.
@@ -308,6 +661,37 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, resp)
 }
 
+type stressInfo struct {
+	Ticker     string  `json:"ticker"`
+	Locate     uint16  `json:"locate"`
+	Phase      string  `json:"phase"`
+	Intensity  float64 `json:"intensity"`
+	IntervalMs int64   `json:"intervalMs"`
+	Actions    int     `json:"actions"`
+}
+
+// handleStress reports the current phase, intensity, tick interval and
+// actions per tick of each stress symbol's controller, in universe order.
+func (s *Server) handleStress(w http.ResponseWriter, r *http.Request) {
+	out := []stressInfo{}
+	for _, sym := range s.syms {
+		ctrl, ok := s.stress[sym.LocateCode]
+		if !ok {
+			continue
+		}
+		st := ctrl.State()
+		out = append(out, stressInfo{
+			Ticker:     sym.Ticker,
+			Locate:     sym.LocateCode,
+			Phase:      st.Phase.String(),
+			Intensity:  st.Intensity,
+			IntervalMs: st.Interval.Milliseconds(),
+			Actions:    st.Actions,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
 // handleHistoryMeta reports the available history: the live retention window and
 // the archived (disk-limited) date span. Degrades to archive-disabled when the
 // reader has no history layer.
@@ -318,11 +702,11 @@ func (s *Server) handleHistoryMeta(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(r.Context(), s.queryTimeout)
 	defer cancel()
 	meta, err := prov.HistoryMeta(ctx)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeQueryError(w, err, s.queryTimeout)
 		return
 	}
 	writeJSON(w, http.StatusOK, meta)
@@ -355,3 +739,24 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, resp)
 }
+
+type versionResponse struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit"`
+	GoVersion  string `json:"goVersion"`
+	Seed       int64  `json:"seed"`
+	PriceModel string `json:"priceModel"`
+}
+
+// handleVersion reports what is deployed: the build version and commit
+// stamped at link time, the Go runtime, and the seed and price model the
+// simulation is running with.
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, versionResponse{
+		Version:    version.Version,
+		Commit:     version.GitCommit(),
+		GoVersion:  runtime.Version(),
+		Seed:       s.seed,
+		PriceModel: s.market.Model(),
+	})
+}
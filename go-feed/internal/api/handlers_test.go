@@ -4,19 +4,27 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ndrandal/feed-simulator/go-feed/internal/archive"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/persist"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/session"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/tape"
 )
 
 // --- stub TradeReader ---
@@ -30,19 +38,27 @@ type stubTradeReader struct {
 	statsErr   error
 	dbSize     persist.DBSize
 	dbSizeErr  error
+	histogram  []persist.HistogramBucket
+	slow       bool // trade and candle queries block until their context ends
 
 	// capture filter args for assertions
 	lastTradeFilter  persist.TradeFilter
 	lastMultiFilter  persist.MultiTradeFilter
 	lastCandleFilter persist.CandleFilter
+	lastMatch        uint64
+	lastBuckets      []int32
 }
 
 func (s *stubTradeReader) QueryDBSize(_ context.Context) (persist.DBSize, error) {
 	return s.dbSize, s.dbSizeErr
 }
 
-func (s *stubTradeReader) QueryTrades(_ context.Context, f persist.TradeFilter) ([]persist.Trade, error) {
+func (s *stubTradeReader) QueryTrades(ctx context.Context, f persist.TradeFilter) ([]persist.Trade, error) {
 	s.lastTradeFilter = f
+	if s.slow {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
 	return s.trades, s.tradesErr
 }
 
@@ -51,11 +67,33 @@ func (s *stubTradeReader) QueryTradesMulti(_ context.Context, f persist.MultiTra
 	return s.trades, s.tradesErr
 }
 
-func (s *stubTradeReader) QueryCandles(_ context.Context, f persist.CandleFilter) ([]persist.Candle, error) {
+func (s *stubTradeReader) QueryTradeByMatch(_ context.Context, matchNumber uint64) (persist.Trade, error) {
+	s.lastMatch = matchNumber
+	if s.tradesErr != nil {
+		return persist.Trade{}, s.tradesErr
+	}
+	for _, t := range s.trades {
+		if uint64(t.MatchNumber) == matchNumber {
+			return t, nil
+		}
+	}
+	return persist.Trade{}, persist.ErrTradeNotFound
+}
+
+func (s *stubTradeReader) QueryCandles(ctx context.Context, f persist.CandleFilter) ([]persist.Candle, error) {
 	s.lastCandleFilter = f
+	if s.slow {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
 	return s.candles, s.candlesErr
 }
 
+func (s *stubTradeReader) QueryTradeSizeHistogram(_ context.Context, _ uint16, buckets []int32) ([]persist.HistogramBucket, error) {
+	s.lastBuckets = buckets
+	return s.histogram, s.tradesErr
+}
+
 func (s *stubTradeReader) QueryTradeStats(_ context.Context) (persist.TradeStats, error) {
 	return s.stats, s.statsErr
 }
@@ -85,6 +123,42 @@ func newTestServer(stub *stubTradeReader) (*Server, *http.ServeMux) {
 	return srv, mux
 }
 
+// runSymbol stands in for locate's symbol runner until the test ends: it
+// applies the simulator's admin commands, broadcasting their messages, and
+// with step set ticks the simulator between them.
+func runSymbol(t *testing.T, srv *Server, locate uint16, step bool) {
+	t.Helper()
+	sim := srv.books[locate]
+	var ticker string
+	for _, s := range srv.syms {
+		if s.LocateCode == locate {
+			ticker = s.Ticker
+		}
+	}
+	tick := time.NewTicker(time.Millisecond)
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			case cmd := <-sim.Commands():
+				cmd.Run(sim, func(msgs []itch.Message) { srv.mgr.Broadcast(locate, ticker, msgs) })
+			case <-tick.C:
+				if step {
+					srv.mgr.Broadcast(locate, ticker, sim.Step(srv.market.Price(locate), 3))
+				}
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-done
+		tick.Stop()
+	})
+}
+
 func mustDecodeJSON(t *testing.T, resp *http.Response, v any) {
 	t.Helper()
 	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
@@ -119,6 +193,158 @@ func TestHandleSymbols(t *testing.T) {
 	}
 }
 
+func TestHandleSymbolsLastUpdate(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	before := time.Now()
+	srv.mgr.Broadcast(1, "NEXO", []itch.Message{
+		{Type: itch.MsgAddOrder, OrderRef: 1, Side: 'B', Shares: 100, Price: 185},
+	})
+
+	req := httptest.NewRequest("GET", "/api/symbols", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var out []symbolInfo
+	mustDecodeJSON(t, w.Result(), &out)
+
+	for _, si := range out {
+		switch {
+		case si.Ticker == "NEXO":
+			if si.LastUpdate.Before(before) || si.LastUpdate.After(time.Now()) {
+				t.Errorf("NEXO lastUpdate = %v, want just after %v", si.LastUpdate, before)
+			}
+		case !si.LastUpdate.IsZero():
+			t.Errorf("%s never broadcast but lastUpdate = %v", si.Ticker, si.LastUpdate)
+		}
+	}
+}
+
+func TestHandleSymbolsMsgRate(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	for i := range 20 {
+		srv.mgr.Broadcast(1, "NEXO", []itch.Message{
+			{Type: itch.MsgAddOrder, OrderRef: uint64(i + 1), Side: 'B', Shares: 100, Price: 185},
+			{Type: itch.MsgOrderDelete, OrderRef: uint64(i + 1)},
+		})
+	}
+
+	req := httptest.NewRequest("GET", "/api/symbols", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var out []symbolInfo
+	mustDecodeJSON(t, w.Result(), &out)
+
+	for _, si := range out {
+		switch {
+		case si.Ticker == "NEXO":
+			if si.MsgRate <= 0 {
+				t.Errorf("NEXO msgRate = %v after 40 messages, want > 0", si.MsgRate)
+			}
+		case si.MsgRate != 0:
+			t.Errorf("%s never broadcast but msgRate = %v", si.Ticker, si.MsgRate)
+		}
+	}
+}
+
+func TestHandleStress(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	clock := engine.NewFakeClock(time.Date(2026, 6, 18, 14, 0, 0, 0, time.UTC))
+	ctrl := engine.NewStressControllerWithClock(engine.NewRNG(7), engine.DefaultStressConfig(), clock)
+	for range 50 {
+		ctrl.Tick()
+		clock.Advance(10 * time.Second)
+	}
+	srv.SetStressControllers(map[uint16]*engine.StressController{28: ctrl})
+
+	req := httptest.NewRequest("GET", "/api/stress", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var out []stressInfo
+	mustDecodeJSON(t, w.Result(), &out)
+
+	want := ctrl.State()
+	if len(out) != 1 || out[0].Ticker != "BLITZ" {
+		t.Fatalf("got %+v, want one BLITZ entry", out)
+	}
+	got := out[0]
+	if got.Phase != want.Phase.String() || got.Intensity != want.Intensity {
+		t.Errorf("phase/intensity = %s/%v, want %s/%v", got.Phase, got.Intensity, want.Phase, want.Intensity)
+	}
+	if got.IntervalMs != want.Interval.Milliseconds() || got.Actions != want.Actions {
+		t.Errorf("interval/actions = %dms/%d, want %v/%d", got.IntervalMs, got.Actions, want.Interval, want.Actions)
+	}
+}
+
+func TestHandleAllBooks(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	req := httptest.NewRequest("GET", "/api/books?depth=3", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var out []depthResponse
+	mustDecodeJSON(t, w.Result(), &out)
+
+	if len(out) != len(srv.books) {
+		t.Fatalf("got %d books, want one per initialized book (%d)", len(out), len(srv.books))
+	}
+	nexo := out[0]
+	if nexo.Ticker != "NEXO" || len(nexo.Bids) != 3 || len(nexo.Asks) != 3 {
+		t.Fatalf("NEXO = %s with %d bids, %d asks; want 3 levels per side", nexo.Ticker, len(nexo.Bids), len(nexo.Asks))
+	}
+	book := srv.books[1].Book()
+	if nexo.BestBid != book.BestBid() || nexo.BestAsk != book.BestAsk() || nexo.Bids[0].Price != book.BestBid() {
+		t.Errorf("NEXO best = %v/%v, want %v/%v", nexo.BestBid, nexo.BestAsk, book.BestBid(), book.BestAsk())
+	}
+
+	for _, q := range []string{"?depth=0", "?depth=abc"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/books"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, w.Code)
+		}
+	}
+}
+
+func TestHandleSymbolsETagNotModified(t *testing.T) {
+	_, mux := newTestServer(&stubTradeReader{})
+	req := httptest.NewRequest("GET", "/api/symbols", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	etag := w.Header().Get("ETag")
+	if etag != `"`+symbol.UniverseHash()+`"` {
+		t.Fatalf("ETag = %q, want quoted UniverseHash %q", etag, symbol.UniverseHash())
+	}
+
+	req = httptest.NewRequest("GET", "/api/symbols", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", w.Code)
+	}
+	if got := w.Header().Get("ETag"); got != etag {
+		t.Fatalf("304 ETag = %q, want %q", got, etag)
+	}
+	if w.Body.Len() != 0 {
+		t.Fatalf("304 should have no body, got %q", w.Body.String())
+	}
+
+	// A stale tag gets the full list.
+	req = httptest.NewRequest("GET", "/api/symbols", nil)
+	req.Header.Set("If-None-Match", `"stale"`)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("stale ETag: expected 200, got %d", w.Code)
+	}
+}
+
 func TestHandleSymbolDetail(t *testing.T) {
 	_, mux := newTestServer(&stubTradeReader{})
 	req := httptest.NewRequest("GET", "/api/symbols/NEXO", nil)
@@ -143,6 +369,362 @@ func TestHandleSymbolDetail(t *testing.T) {
 	}
 }
 
+func TestHandleSymbolsChange(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	srv.market.SetPrice(1, 190.00) // NEXO opened at its 185.00 base price
+
+	req := httptest.NewRequest("GET", "/api/symbols/NEXO", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var out symbolInfo
+	mustDecodeJSON(t, w.Result(), &out)
+	if math.Abs(out.Change-5.00) > 1e-9 {
+		t.Errorf("change = %v, want 5.00", out.Change)
+	}
+	if want := out.Change / (out.Price - out.Change) * 100; math.Abs(out.ChangePct-want) > 1e-9 {
+		t.Errorf("changePct = %v, want %v", out.ChangePct, want)
+	}
+
+	srv.market.MarkReference()
+	req = httptest.NewRequest("GET", "/api/symbols", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var all []map[string]any
+	mustDecodeJSON(t, w.Result(), &all)
+	for _, si := range all {
+		if _, ok := si["changePct"]; !ok {
+			t.Fatalf("%v has no changePct field", si["ticker"])
+		}
+		if si["change"] != 0.0 {
+			t.Errorf("%v change = %v after re-marking the reference, want 0", si["ticker"], si["change"])
+		}
+	}
+}
+
+func TestHandleSetPrice(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	runSymbol(t, srv, 1, false)
+	body := strings.NewReader(`{"price":200.50,"recenter":true}`)
+	req := httptest.NewRequest("POST", "/api/admin/symbols/NEXO/price", body)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/symbols/NEXO", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var out symbolInfo
+	mustDecodeJSON(t, w.Result(), &out)
+	if out.Price != 200.50 {
+		t.Errorf("price = %v, want 200.50", out.Price)
+	}
+	// Recentered book straddles the new price.
+	if out.BestBid >= 200.50 || out.BestAsk <= 200.50 || out.BestAsk-out.BestBid > 0.03 {
+		t.Errorf("book not recentered: bid %v ask %v", out.BestBid, out.BestAsk)
+	}
+}
+
+func TestHandleSetPriceValidation(t *testing.T) {
+	for _, body := range []string{`{"price":0}`, `{"price":-5}`, `{"price":100.005}`, `not json`} {
+		_, mux := newTestServer(&stubTradeReader{})
+		req := httptest.NewRequest("POST", "/api/admin/symbols/NEXO/price", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestHandleDrain(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	sim := srv.books[1]
+
+	req := httptest.NewRequest("POST", "/api/admin/symbols/NEXO/drain", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var out drainResponse
+	mustDecodeJSON(t, w.Result(), &out)
+	if !out.Draining || !sim.Draining() || out.Orders != sim.Book().OrderCount() {
+		t.Fatalf("response = %+v, simulator draining = %v", out, sim.Draining())
+	}
+
+	req = httptest.NewRequest("POST", "/api/admin/symbols/NEXO/drain", strings.NewReader(`{"enabled":false}`))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || sim.Draining() {
+		t.Fatalf("disable: status %d, draining = %v", w.Code, sim.Draining())
+	}
+
+	// QBIT has no book in the test server.
+	req = httptest.NewRequest("POST", "/api/admin/symbols/QBIT/drain", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("bookless symbol: expected 404, got %d", w.Code)
+	}
+}
+
+func TestHandleBias(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+
+	req := httptest.NewRequest("POST", "/api/admin/symbols/NEXO/bias", strings.NewReader(`{"buy":0.8}`))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if b := srv.books[1].AggressorBias(); b.Buy != 0.8 || b.Momentum != 0 {
+		t.Errorf("bias = %+v, want buy 0.8", b)
+	}
+
+	for _, body := range []string{`{"buy":1.5}`, `{"momentum":-0.1}`, `nope`} {
+		req = httptest.NewRequest("POST", "/api/admin/symbols/NEXO/bias", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", body, w.Code)
+		}
+	}
+	if b := srv.books[1].AggressorBias(); b.Buy != 0.8 {
+		t.Errorf("rejected request changed bias to %+v", b)
+	}
+}
+
+func TestHandleVerify(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+
+	req := httptest.NewRequest("GET", "/api/admin/verify", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp verifyResponse
+	mustDecodeJSON(t, w.Result(), &resp)
+	if !resp.OK || resp.Books != 1 || len(resp.Failures) != 0 {
+		t.Fatalf("response = %+v, want one book passing", resp)
+	}
+
+	bids := srv.books[1].Book().Bids
+	bids[0], bids[1] = bids[1], bids[0]
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("corrupted book: expected 500, got %d", w.Code)
+	}
+	mustDecodeJSON(t, w.Result(), &resp)
+	if resp.OK || len(resp.Failures) != 1 || resp.Failures[0].Ticker != "NEXO" {
+		t.Fatalf("response = %+v, want NEXO failing", resp)
+	}
+}
+
+func TestHandleWiden(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	runSymbol(t, srv, 1, false)
+	book := srv.books[1].Book()
+	orders := book.OrderCount()
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/symbols/NEXO/widen?ticks=5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var out widenResponse
+	mustDecodeJSON(t, w.Result(), &out)
+	if out.Deleted == 0 || book.OrderCount() != orders-out.Deleted {
+		t.Errorf("deleted %d of %d orders, book now has %d", out.Deleted, orders, book.OrderCount())
+	}
+	if out.BestAsk-out.BestBid < 5*0.01-1e-9 || out.BestBid != book.BestBid() || out.BestAsk != book.BestAsk() {
+		t.Errorf("response = %+v, want the touch at least 5 ticks apart", out)
+	}
+
+	for _, path := range []string{"/api/admin/symbols/NEXO/widen", "/api/admin/symbols/NEXO/widen?ticks=0", "/api/admin/symbols/NEXO/widen?ticks=x"} {
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+}
+
+// TestAdminCommandsDuringSteps recenters and widens a symbol with market
+// makers while its runner keeps stepping the simulator. The runner applies
+// both between ticks, so the race detector sees no conflicting access.
+func TestAdminCommandsDuringSteps(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	if err := srv.books[1].SetMarketMakers(2); err != nil {
+		t.Fatal(err)
+	}
+	runSymbol(t, srv, 1, true)
+
+	errs := make(chan string, 20)
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			body := strings.NewReader(fmt.Sprintf(`{"price":%.2f,"recenter":true}`, 180+float64(i)))
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/symbols/NEXO/price", body))
+			if w.Code != http.StatusOK {
+				errs <- fmt.Sprintf("price: %d %s", w.Code, w.Body)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("POST", "/api/admin/symbols/NEXO/widen?ticks=3", nil))
+			if w.Code != http.StatusOK {
+				errs <- fmt.Sprintf("widen: %d %s", w.Code, w.Body)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for e := range errs {
+		t.Error(e)
+	}
+	if err := srv.books[1].Book().Verify(); err != nil {
+		t.Errorf("book inconsistent: %v", err)
+	}
+}
+
+func TestHandleCounters(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	orderbook.SetOrderIDCounter(5000)
+	orderbook.SetMatchCounter(700)
+
+	req := httptest.NewRequest("GET", "/api/admin/counters", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp countersResponse
+	mustDecodeJSON(t, w.Result(), &resp)
+	orders := srv.books[1].Book().OrderCount()
+	if resp.OrderID != 5000 || resp.MatchNumber != 700 {
+		t.Errorf("counters = %d, %d; want 5000, 700", resp.OrderID, resp.MatchNumber)
+	}
+	if len(resp.Books) != 1 || resp.Books["NEXO"] != orders || resp.Orders != orders {
+		t.Errorf("books = %v (total %d), want NEXO with %d orders", resp.Books, resp.Orders, orders)
+	}
+
+	// Counters follow the package state.
+	orderbook.NextOrderID()
+	orderbook.NextMatchNumber()
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	mustDecodeJSON(t, w.Result(), &resp)
+	if resp.OrderID != 5001 || resp.MatchNumber != 701 {
+		t.Errorf("after one of each, counters = %d, %d; want 5001, 701", resp.OrderID, resp.MatchNumber)
+	}
+}
+
+func TestHandleTradeByMatch(t *testing.T) {
+	now := time.Date(2026, 1, 2, 14, 30, 0, 0, time.UTC)
+	stub := &stubTradeReader{trades: []persist.Trade{
+		{MatchNumber: 41, Ticker: "NEXO", Price: 185.1, Shares: 100, Aggressor: "B", ExecutedAt: now},
+		{MatchNumber: 42, Ticker: "QBIT", Price: 42.5, Shares: 300, Aggressor: "S", ExecutedAt: now},
+	}}
+	_, mux := newTestServer(stub)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/trade/42", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var got persist.Trade
+	mustDecodeJSON(t, w.Result(), &got)
+	if got.MatchNumber != 42 || got.Ticker != "QBIT" || got.Shares != 300 || stub.lastMatch != 42 {
+		t.Errorf("trade = %+v (queried %d), want QBIT match 42", got, stub.lastMatch)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/trade/43", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("absent match: expected 404, got %d: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/trade/abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad match number: expected 400, got %d", w.Code)
+	}
+}
+
+func TestHandleTape(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+
+	req := httptest.NewRequest("GET", "/api/tape/NEXO", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("tape disabled: expected 404, got %d", w.Code)
+	}
+
+	tp := tape.New(10)
+	srv.mgr.SetTape(tp)
+	srv.SetTape(tp)
+	for match := uint64(1); match <= 4; match++ {
+		srv.mgr.Broadcast(1, "NEXO", []itch.Message{
+			{Type: itch.MsgTrade, Side: 'B', Shares: 100, Price: 185, MatchNumber: match},
+		})
+	}
+
+	req = httptest.NewRequest("GET", "/api/tape/NEXO?limit=2", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var out tapeJSON
+	mustDecodeJSON(t, w.Result(), &out)
+	if out.Ticker != "NEXO" || out.Count != 4 || len(out.Trades) != 2 {
+		t.Fatalf("tape = %+v, want NEXO count 4 with 2 trades", out)
+	}
+	if out.Trades[0].MatchNumber != 4 || out.Trades[1].MatchNumber != 3 {
+		t.Errorf("trades = %+v, want matches 4, 3", out.Trades)
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	srv.SetSeed(42)
+
+	req := httptest.NewRequest("GET", "/api/version", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+
+	var out map[string]any
+	mustDecodeJSON(t, w.Result(), &out)
+	for _, key := range []string{"version", "commit", "goVersion", "seed", "priceModel"} {
+		if _, ok := out[key]; !ok {
+			t.Errorf("missing %q in %v", key, out)
+		}
+	}
+	if out["goVersion"] != runtime.Version() {
+		t.Errorf("goVersion = %v, want %s", out["goVersion"], runtime.Version())
+	}
+	if out["seed"] != float64(42) || out["priceModel"] != engine.PriceModelGBM {
+		t.Errorf("seed = %v, priceModel = %v", out["seed"], out["priceModel"])
+	}
+}
+
 func TestHandleSymbolDetailNotFound(t *testing.T) {
 	_, mux := newTestServer(&stubTradeReader{})
 	req := httptest.NewRequest("GET", "/api/symbols/ZZZZ", nil)
@@ -174,9 +756,86 @@ func TestHandleBookDepth(t *testing.T) {
 	var out map[string]any
 	mustDecodeJSON(t, w.Result(), &out)
 
-	for _, key := range []string{"bids", "asks", "midPrice", "spread"} {
-		if _, ok := out[key]; !ok {
-			t.Errorf("missing key %q in depth response", key)
+	for _, key := range []string{"bids", "asks", "midPrice", "spread"} {
+		if _, ok := out[key]; !ok {
+			t.Errorf("missing key %q in depth response", key)
+		}
+	}
+}
+
+func TestHandleBookImpact(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	asks := srv.books[1].Book().Depth().Asks
+	if len(asks) < 3 {
+		t.Fatalf("NEXO book has %d ask levels, want at least 3", len(asks))
+	}
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/book/NEXO/impact?side=buy&price=%.2f", asks[1].Price), nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var out impactResponse
+	mustDecodeJSON(t, w.Result(), &out)
+	if want := asks[0].TotalShares + asks[1].TotalShares; out.Shares != want || out.Side != "buy" {
+		t.Errorf("impact = %+v, want %d shares from the two best ask levels", out, want)
+	}
+
+	for _, url := range []string{
+		"/api/book/NEXO/impact?side=up&price=185",
+		"/api/book/NEXO/impact?side=buy",
+		"/api/book/NEXO/impact?side=sell&price=abc",
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", url, w.Code)
+		}
+	}
+}
+
+func TestHandleBookDepthGranularity(t *testing.T) {
+	_, mux := newTestServer(&stubTradeReader{})
+
+	get := func(url string) depthResponse {
+		t.Helper()
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d", url, w.Code)
+		}
+		var out depthResponse
+		mustDecodeJSON(t, w.Result(), &out)
+		return out
+	}
+
+	raw := get("/api/book/NEXO")
+	coarse := get("/api/book/NEXO?granularity=0.05")
+	if len(coarse.Bids) >= len(raw.Bids) {
+		t.Fatalf("granularity=0.05 bids = %d levels, want fewer than raw %d", len(coarse.Bids), len(raw.Bids))
+	}
+	var rawShares, coarseShares int32
+	for _, l := range raw.Bids {
+		rawShares += l.TotalShares
+	}
+	for _, l := range coarse.Bids {
+		coarseShares += l.TotalShares
+	}
+	if rawShares != coarseShares {
+		t.Fatalf("bucketed bid shares = %d, want %d (sum preserved)", coarseShares, rawShares)
+	}
+}
+
+func TestHandleBookDepthBadGranularity(t *testing.T) {
+	_, mux := newTestServer(&stubTradeReader{})
+	for _, q := range []string{"abc", "-0.05"} {
+		req := httptest.NewRequest("GET", "/api/book/NEXO?granularity="+q, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("granularity=%s: expected 400, got %d", q, w.Code)
 		}
 	}
 }
@@ -216,6 +875,41 @@ func TestHandleTrades(t *testing.T) {
 	}
 }
 
+func TestHandleTradesNDJSON(t *testing.T) {
+	stub := &stubTradeReader{
+		trades: []persist.Trade{
+			{MatchNumber: 1, Ticker: "NEXO", Price: 185.50, Shares: 100, Aggressor: "B", ExecutedAt: time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC)},
+			{MatchNumber: 2, Ticker: "NEXO", Price: 185.60, Shares: 200, Aggressor: "S", ExecutedAt: time.Date(2026, 1, 2, 15, 0, 1, 0, time.UTC)},
+		},
+	}
+	_, mux := newTestServer(stub)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/trades/NEXO", nil))
+	var array []json.RawMessage
+	mustDecodeJSON(t, w.Result(), &array)
+
+	req := httptest.NewRequest("GET", "/api/trades/NEXO", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("Content-Type = %q, want application/x-ndjson", ct)
+	}
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != len(array) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(array), w.Body)
+	}
+	for i, line := range lines {
+		if line != string(array[i]) {
+			t.Errorf("line %d = %s, want %s", i, line, array[i])
+		}
+	}
+}
+
 func TestHandleTradesNotFound(t *testing.T) {
 	_, mux := newTestServer(&stubTradeReader{})
 	req := httptest.NewRequest("GET", "/api/trades/ZZZZ", nil)
@@ -249,6 +943,38 @@ func TestHandleTradesParams(t *testing.T) {
 	}
 }
 
+func TestHandleTradesSinceMatch(t *testing.T) {
+	stub := &stubTradeReader{trades: []persist.Trade{}}
+	_, mux := newTestServer(stub)
+	req := httptest.NewRequest("GET", "/api/trades/NEXO?sinceMatch=12345&limit=50", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if stub.lastTradeFilter.SinceMatch != 12345 {
+		t.Errorf("expected sinceMatch=12345, got %d", stub.lastTradeFilter.SinceMatch)
+	}
+	if stub.lastTradeFilter.Limit != 50 {
+		t.Errorf("expected limit=50, got %d", stub.lastTradeFilter.Limit)
+	}
+}
+
+func TestHandleTradesSinceMatchRejected(t *testing.T) {
+	for _, path := range []string{"/api/trades/NEXO?sinceMatch=-1", "/api/trades/NEXO?sinceMatch=abc", "/api/trades/*?sinceMatch=5"} {
+		stub := &stubTradeReader{trades: []persist.Trade{}}
+		_, mux := newTestServer(stub)
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+}
+
 func TestHandleTradesDBError(t *testing.T) {
 	stub := &stubTradeReader{tradesErr: errors.New("db connection lost")}
 	_, mux := newTestServer(stub)
@@ -353,6 +1079,40 @@ func TestHandleCandles(t *testing.T) {
 	}
 }
 
+func TestHandleCandlesCSV(t *testing.T) {
+	stub := &stubTradeReader{
+		candles: []persist.Candle{
+			{Bucket: time.Date(2026, 1, 2, 15, 4, 0, 0, time.UTC), Open: 185.0, High: 186.25, Low: 184.0, Close: 185.5, Volume: 1000, Count: 10},
+		},
+	}
+	_, mux := newTestServer(stub)
+	const want = "t,o,h,l,c,v,n\n2026-01-02T15:04:00Z,185,186.25,184,185.5,1000,10\n"
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/api/candles/NEXO.csv?interval=1m", nil),
+		func() *http.Request {
+			r := httptest.NewRequest("GET", "/api/candles/NEXO?interval=1m", nil)
+			r.Header.Set("Accept", "text/csv")
+			return r
+		}(),
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", req.URL, w.Code, w.Body)
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+			t.Errorf("%s: Content-Type = %q, want text/csv", req.URL, ct)
+		}
+		if w.Body.String() != want {
+			t.Errorf("%s: body\n%s\nwant\n%s", req.URL, w.Body, want)
+		}
+	}
+	if stub.lastCandleFilter.SymbolLocate != 1 {
+		t.Errorf("queried locate %d, want NEXO (1)", stub.lastCandleFilter.SymbolLocate)
+	}
+}
+
 func TestHandleCandlesNotFound(t *testing.T) {
 	_, mux := newTestServer(&stubTradeReader{})
 	req := httptest.NewRequest("GET", "/api/candles/ZZZZ", nil)
@@ -408,6 +1168,25 @@ func TestHandleCandlesDBError(t *testing.T) {
 	}
 }
 
+func TestHandleCandlesUnsupportedIntervalSuggests(t *testing.T) {
+	_, mux := newTestServer(&stubTradeReader{})
+	req := httptest.NewRequest("GET", "/api/candles/NEXO?interval=7m", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	var body intervalErrorResponse
+	mustDecodeJSON(t, w.Result(), &body)
+	if body.Nearest != "5m" || !strings.Contains(body.Error, "5m") {
+		t.Errorf("error = %q, nearest = %q, want a 5m suggestion", body.Error, body.Nearest)
+	}
+	if !slices.Equal(body.Supported, persist.SupportedIntervals()) {
+		t.Errorf("supported = %v, want %v", body.Supported, persist.SupportedIntervals())
+	}
+}
+
 func TestHandleCandlesPaginationParams(t *testing.T) {
 	stub := &stubTradeReader{candles: []persist.Candle{}}
 	_, mux := newTestServer(stub)
@@ -438,6 +1217,65 @@ func TestHandleCandlesBadFill(t *testing.T) {
 	}
 }
 
+// TestHandleCandlesLive checks that ?live=true puts the forming bar for the
+// current interval, built from the tape and flagged partial, ahead of the
+// completed buckets, and that without it only completed buckets appear.
+func TestHandleCandlesLive(t *testing.T) {
+	now := time.Date(2026, 6, 18, 14, 30, 45, 0, time.UTC)
+	done := []persist.Candle{
+		{Bucket: now.Add(-time.Minute).Truncate(time.Minute), Open: 184, High: 185, Low: 183, Close: 184.5, Volume: 300, Count: 3},
+	}
+	srv, mux := newTestServer(&stubTradeReader{candles: done})
+	srv.clock = engine.NewFakeClock(now)
+	tp := tape.New(10)
+	srv.SetTape(tp)
+	for i, price := range []float64{185.10, 185.40, 184.90, 185.20} {
+		// 14:29:55, then 14:30:05, :15 and :25
+		tp.Record(1, now.Add(time.Duration(i-5)*10*time.Second), []itch.Message{
+			{Type: itch.MsgTrade, Stock: "NEXO", Side: 'B', Shares: 100, Price: price, MatchNumber: uint64(i + 1)},
+		})
+	}
+
+	get := func(url string) []persist.Candle {
+		t.Helper()
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", url, w.Code, w.Body.String())
+		}
+		var out []persist.Candle
+		mustDecodeJSON(t, w.Result(), &out)
+		return out
+	}
+
+	if out := get("/api/candles/NEXO?interval=1m"); len(out) != 1 || out[0].Partial {
+		t.Fatalf("without live: %+v, want only the completed bucket", out)
+	}
+
+	out := get("/api/candles/NEXO?interval=1m&live=true")
+	if len(out) != 2 {
+		t.Fatalf("live: got %d candles, want partial + completed: %+v", len(out), out)
+	}
+	// The 14:29:55 print belongs to the completed bucket.
+	bar := out[0]
+	want := persist.Candle{Bucket: now.Truncate(time.Minute), Open: 185.40, High: 185.40, Low: 184.90, Close: 185.20, Volume: 300, Count: 3, Partial: true}
+	if !bar.Bucket.Equal(want.Bucket) || bar.Open != want.Open || bar.High != want.High || bar.Low != want.Low ||
+		bar.Close != want.Close || bar.Volume != want.Volume || bar.Count != want.Count || !bar.Partial {
+		t.Fatalf("live bar = %+v, want %+v", bar, want)
+	}
+	if out[1].Partial || !out[1].Bucket.Equal(done[0].Bucket) {
+		t.Fatalf("second candle = %+v, want the completed bucket", out[1])
+	}
+
+	req := httptest.NewRequest("GET", "/api/candles/NEXO?live=maybe", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("bad live: expected 400, got %d", w.Code)
+	}
+}
+
 func TestHandleCandlesNextCursor(t *testing.T) {
 	oldest := time.Date(2025, 1, 15, 10, 28, 0, 0, time.UTC)
 	// A full page (len == limit) sets the cursor to the oldest bucket.
@@ -487,6 +1325,9 @@ func TestHandleStats(t *testing.T) {
 		}
 	}
 
+	if out["universeHash"] != symbol.UniverseHash() {
+		t.Errorf("expected universeHash=%s, got %v", symbol.UniverseHash(), out["universeHash"])
+	}
 	if out["totalTrades"] != float64(42) {
 		t.Errorf("expected totalTrades=42, got %v", out["totalTrades"])
 	}
@@ -495,6 +1336,56 @@ func TestHandleStats(t *testing.T) {
 	}
 }
 
+func TestHandleStatsTradeRate(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	get := func() map[string]any {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/stats", nil))
+		var out map[string]any
+		mustDecodeJSON(t, w.Result(), &out)
+		return out
+	}
+	if out := get(); out["tradesPerMin"] != nil || out["tradesPer5Min"] != nil {
+		t.Errorf("without a trade rate: tradesPerMin %v, tradesPer5Min %v, want absent", out["tradesPerMin"], out["tradesPer5Min"])
+	}
+
+	clock := engine.NewFakeClock(time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC))
+	rate := persist.NewTradeRate(clock)
+	srv.SetTradeRate(rate)
+	rate.Add(30)
+	clock.Advance(2 * time.Minute)
+	rate.Add(12)
+	if out := get(); out["tradesPerMin"] != float64(12) || out["tradesPer5Min"] != float64(42) {
+		t.Errorf("tradesPerMin %v, tradesPer5Min %v, want 12 and 42", out["tradesPerMin"], out["tradesPer5Min"])
+	}
+}
+
+func TestQueryTimeoutAnswers503(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{slow: true})
+	srv.SetTimeouts(10*time.Millisecond, 20*time.Millisecond)
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"/api/trades/NEXO", "timed out after 10ms"},
+		{"/api/candles/NEXO?interval=1m", "timed out after 20ms"},
+	} {
+		start := time.Now()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", tc.path, nil))
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s: took %v, want the configured deadline", tc.path, elapsed)
+		}
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected 503, got %d", tc.path, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), tc.want) {
+			t.Errorf("%s: body %s, want %q", tc.path, w.Body, tc.want)
+		}
+	}
+}
+
 func TestHandleStatsDBSize(t *testing.T) {
 	stub := &stubTradeReader{
 		stats:  persist.TradeStats{TotalTrades: 5, TotalVolume: 50},
@@ -595,6 +1486,112 @@ func TestHandleHistoryMetaWithHistory(t *testing.T) {
 	}
 }
 
+// newArchiveTestServer serves an archive dir holding two day-files, a stray
+// non-archive file, and a secret file beside (outside) the archive root.
+func newArchiveTestServer(t *testing.T) *http.ServeMux {
+	t.Helper()
+	root := t.TempDir()
+	dir := filepath.Join(root, "archive")
+	tradesDir := filepath.Join(dir, "trades", "2026", "06")
+	if err := os.MkdirAll(tradesDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, day := range []string{"16", "18"} {
+		if err := os.WriteFile(filepath.Join(tradesDir, day+".jsonl.gz"), []byte("gz-"+day), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tradesDir, "notes.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secret.jsonl.gz"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv, mux := newTestServer(&stubTradeReader{})
+	srv.SetArchiveCatalog(archive.NewCatalog(dir))
+	return mux
+}
+
+func TestHandleArchiveList(t *testing.T) {
+	mux := newArchiveTestServer(t)
+	req := httptest.NewRequest("GET", "/api/archive", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var out []archiveFileJSON
+	mustDecodeJSON(t, w.Result(), &out)
+	if len(out) != 2 {
+		t.Fatalf("expected 2 archive files, got %+v", out)
+	}
+	if out[0].Path != "2026/06/16.jsonl.gz" || out[0].Day != "2026-06-16" || out[0].Size != 5 {
+		t.Errorf("out[0] = %+v", out[0])
+	}
+	if out[1].Path != "2026/06/18.jsonl.gz" {
+		t.Errorf("out[1] = %+v", out[1])
+	}
+}
+
+func TestHandleArchiveListDisabled(t *testing.T) {
+	_, mux := newTestServer(&stubTradeReader{})
+	req := httptest.NewRequest("GET", "/api/archive", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Fatalf("expected 200 with empty list, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleArchiveFile(t *testing.T) {
+	mux := newArchiveTestServer(t)
+	req := httptest.NewRequest("GET", "/api/archive/2026/06/18.jsonl.gz", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Body.String() != "gz-18" {
+		t.Errorf("body = %q, want file contents", w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/gzip" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	req = httptest.NewRequest("GET", "/api/archive/2026/06/17.jsonl.gz", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing day: expected 404, got %d", w.Code)
+	}
+}
+
+func TestHandleArchiveFileRejectsTraversal(t *testing.T) {
+	mux := newArchiveTestServer(t)
+	for _, p := range []string{
+		"/api/archive/..%2F..%2Fsecret.jsonl.gz",
+		"/api/archive/2026/06/..%2F..%2F..%2F..%2Fsecret.jsonl.gz",
+		"/api/archive/%2Fetc%2Fpasswd",
+		"/api/archive/2026/06/notes.txt",
+		"/api/archive/2026//06/16.jsonl.gz",
+	} {
+		req := httptest.NewRequest("GET", p, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+
+		if w.Code == http.StatusOK {
+			t.Errorf("%s: served %q, want rejection", p, w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "secret") {
+			t.Errorf("%s: leaked file outside the archive", p)
+		}
+	}
+}
+
 func TestHandleHealth(t *testing.T) {
 	stub := &stubTradeReader{dbSize: persist.DBSize{DatabaseBytes: persist.SizeBudgetBytes / 2}}
 	_, mux := newTestServer(stub)
@@ -806,3 +1803,236 @@ func TestHandleCandlesBadParams(t *testing.T) {
 		}
 	}
 }
+
+// fakeArchiver records the cursor written by the archive reset endpoint.
+type fakeArchiver struct {
+	cursor time.Time
+}
+
+func (f *fakeArchiver) ResetCursor(_ context.Context, t time.Time) (time.Time, error) {
+	f.cursor = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return f.cursor, nil
+}
+
+func TestHandleArchiveReset(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/admin/archive/reset", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(`{"cursor":"2026-01-02T15:04:05Z"}`); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("without an archiver: status %d, want 503", w.Code)
+	}
+
+	fake := &fakeArchiver{}
+	srv.SetArchiver(fake)
+	w := post(`{"cursor":"2026-01-02T15:04:05Z"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body.String())
+	}
+	want := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)
+	if !fake.cursor.Equal(want) {
+		t.Errorf("cursor written = %v, want %v", fake.cursor, want)
+	}
+	var out archiveResetResponse
+	mustDecodeJSON(t, w.Result(), &out)
+	if !out.Cursor.Equal(want) {
+		t.Errorf("response cursor = %v, want %v", out.Cursor, want)
+	}
+
+	for _, body := range []string{`{}`, `{"cursor":"2999-01-01T00:00:00Z"}`, `not json`} {
+		fake.cursor = time.Time{}
+		if w := post(body); w.Code != http.StatusBadRequest || !fake.cursor.IsZero() {
+			t.Errorf("body %s: status %d, cursor %v; want 400 and no write", body, w.Code, fake.cursor)
+		}
+	}
+}
+
+func TestHandleRNG(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+	req := httptest.NewRequest("GET", "/api/admin/rng", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("without an RNG: expected 404, got %d", w.Code)
+	}
+
+	rng := engine.NewRNG(7)
+	srv.SetSeed(7)
+	srv.SetRNG(rng)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp rngResponse
+	mustDecodeJSON(t, w.Result(), &resp)
+	state, inc := rng.State()
+	if resp.Seed != 7 || resp.OriginSeed != nil {
+		t.Errorf("seed = %d, origin %v; want 7, unknown", resp.Seed, resp.OriginSeed)
+	}
+	if resp.State != fmt.Sprintf("%016x", state) || resp.Inc != fmt.Sprintf("%016x", inc) {
+		t.Errorf("state = %s/%s, want %016x/%016x", resp.State, resp.Inc, state, inc)
+	}
+
+	// A restored run reports the seed that first produced its state.
+	srv.SetOriginSeed(42)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	mustDecodeJSON(t, w.Result(), &resp)
+	if resp.OriginSeed == nil || *resp.OriginSeed != 42 {
+		t.Errorf("originSeed = %v, want 42", resp.OriginSeed)
+	}
+}
+
+func TestHandleHistogram(t *testing.T) {
+	stub := &stubTradeReader{histogram: []persist.HistogramBucket{
+		{Min: 1, Max: 100, Count: 12},
+		{Min: 100, Max: 500, Count: 30},
+		{Min: 500, Count: 2},
+	}}
+	_, mux := newTestServer(stub)
+
+	req := httptest.NewRequest("GET", "/api/histogram/NEXO?field=shares&buckets=1,100,500", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp histogramResponse
+	mustDecodeJSON(t, w.Result(), &resp)
+	if resp.Ticker != "NEXO" || resp.Field != "shares" || !slices.Equal(resp.Buckets, stub.histogram) {
+		t.Errorf("histogram = %+v, want NEXO shares %+v", resp, stub.histogram)
+	}
+	if !slices.Equal(stub.lastBuckets, []int32{1, 100, 500}) {
+		t.Errorf("queried buckets %v, want [1 100 500]", stub.lastBuckets)
+	}
+
+	// No buckets requested: the defaults.
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/histogram/NEXO", nil))
+	if w.Code != http.StatusOK || !slices.Equal(stub.lastBuckets, persist.DefaultSizeBuckets) {
+		t.Errorf("default: got %d with buckets %v, want 200 with %v", w.Code, stub.lastBuckets, persist.DefaultSizeBuckets)
+	}
+
+	for _, tt := range []struct {
+		url  string
+		want int
+	}{
+		{"/api/histogram/NEXO?field=price", http.StatusBadRequest},
+		{"/api/histogram/NEXO?buckets=100,1", http.StatusBadRequest},
+		{"/api/histogram/NEXO?buckets=1,x", http.StatusBadRequest},
+		{"/api/histogram/NOPE", http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", tt.url, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: expected %d, got %d", tt.url, tt.want, w.Code)
+		}
+	}
+}
+
+func TestHandleSectorVolatility(t *testing.T) {
+	srv, mux := newTestServer(&stubTradeReader{})
+
+	// realizedVol ticks every symbol n times and returns each one's standard
+	// deviation of log returns.
+	realizedVol := func(n int) map[uint16]float64 {
+		var sum, sumSq [256]float64
+		prev := srv.market.AllPrices()
+		for range n {
+			srv.market.GenerateSectorShocks()
+			for _, sym := range srv.syms {
+				p := srv.market.Tick(sym.LocateCode)
+				r := math.Log(p / prev[sym.LocateCode])
+				sum[sym.LocateCode] += r
+				sumSq[sym.LocateCode] += r * r
+				prev[sym.LocateCode] = p
+			}
+		}
+		out := make(map[uint16]float64)
+		for _, sym := range srv.syms {
+			mean := sum[sym.LocateCode] / float64(n)
+			out[sym.LocateCode] = math.Sqrt(sumSq[sym.LocateCode]/float64(n) - mean*mean)
+		}
+		return out
+	}
+	before := realizedVol(2000)
+
+	req := httptest.NewRequest("POST", "/api/admin/sectors/Tech/volatility", strings.NewReader(`{"multiplier":4}`))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var resp sectorVolatilityResponse
+	mustDecodeJSON(t, w.Result(), &resp)
+	if resp.Sector != "Tech" || resp.Multiplier != 4 || len(resp.Symbols) != 6 || resp.Symbols[0] != "NEXO" {
+		t.Errorf("response = %+v, want the six Tech symbols at 4x", resp)
+	}
+
+	after := realizedVol(2000)
+	for _, sym := range srv.syms {
+		ratio := after[sym.LocateCode] / before[sym.LocateCode]
+		switch {
+		case sym.Sector == symbol.SectorTech && ratio < 2.5:
+			t.Errorf("%s: realized vol rose %.2fx, want about 4x", sym.Ticker, ratio)
+		case sym.Sector != symbol.SectorTech && ratio > 1.5:
+			t.Errorf("%s (%s): realized vol rose %.2fx outside the sector", sym.Ticker, sym.Sector, ratio)
+		}
+	}
+
+	for _, tt := range []struct {
+		url, body string
+		want      int
+	}{
+		{"/api/admin/sectors/Tech/volatility", `{"multiplier":0}`, http.StatusBadRequest},
+		{"/api/admin/sectors/Tech/volatility", `{"multiplier":1000}`, http.StatusBadRequest},
+		{"/api/admin/sectors/Tech/volatility", `nope`, http.StatusBadRequest},
+		{"/api/admin/sectors/Mining/volatility", `{"multiplier":2}`, http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("POST", tt.url, strings.NewReader(tt.body)))
+		if w.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d", tt.url, tt.body, tt.want, w.Code)
+		}
+	}
+	if got := srv.market.VolatilityScale(1); got != 4 {
+		t.Errorf("rejected requests changed NEXO's scale to %v", got)
+	}
+}
+
+func TestHandleCandlesRangeTooWide(t *testing.T) {
+	stub := &stubTradeReader{candles: []persist.Candle{}}
+	srv, mux := newTestServer(stub)
+	srv.SetMaxCandleBuckets(100000)
+
+	req := httptest.NewRequest("GET", "/api/candles/NEXO?interval=1m&from=2025-01-01T00:00:00Z&to=2026-01-01T00:00:00Z", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("a year of 1m candles: expected 400, got %d", w.Code)
+	}
+	var body rangeErrorResponse
+	mustDecodeJSON(t, w.Result(), &body)
+	if body.Buckets != 525601 || body.MaxBuckets != 100000 || body.Suggested != "15m" {
+		t.Errorf("body = %+v, want 525601 buckets over the 100000 cap and a 15m suggestion", body)
+	}
+	if !strings.Contains(body.Error, "coarser interval such as 15m") {
+		t.Errorf("error = %q, want it to suggest 15m", body.Error)
+	}
+	if stub.lastCandleFilter.Interval != "" {
+		t.Error("rejected range still queried the reader")
+	}
+
+	// The same year in daily candles is fine.
+	req = httptest.NewRequest("GET", "/api/candles/NEXO?interval=1d&from=2025-01-01T00:00:00Z&to=2026-01-01T00:00:00Z", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("a year of 1d candles: expected 200, got %d", w.Code)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/persist"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/tape"
+)
+
+type tapeJSON struct {
+	Ticker string       `json:"ticker"`
+	Count  uint64       `json:"count"`
+	Trades []tape.Trade `json:"trades"`
+}
+
+// SetTape enables GET /api/tape/{ticker} over t, the in-memory tape the
+// session manager records broadcast trades on. Without it the endpoint 404s.
+func (s *Server) SetTape(t *tape.Tape) {
+	s.tape = t
+}
+
+// handleTape serves a symbol's most recent trades, newest first, straight from
+// memory, along with the number of trades printed since start.
+func (s *Server) handleTape(w http.ResponseWriter, r *http.Request) {
+	if s.tape == nil {
+		writeError(w, http.StatusNotFound, "tape not enabled")
+		return
+	}
+	sym := s.resolveTicker(w, r.PathValue("ticker"))
+	if sym == nil {
+		return
+	}
+	limit, err := parseIntParam(r, "limit", persist.DefaultLimit)
+	if badRequest(w, err) {
+		return
+	}
+	if limit <= 0 || limit > s.tape.Size() {
+		limit = s.tape.Size()
+	}
+
+	writeJSON(w, http.StatusOK, tapeJSON{
+		Ticker: sym.Ticker,
+		Count:  s.tape.Count(sym.LocateCode),
+		Trades: s.tape.Recent(sym.LocateCode, limit),
+	})
+}
+
+// withLiveCandle puts the forming bar for the current interval at the head of
+// candles (newest first), flagged partial, in place of any database bucket for
+// the same interval. The bar is built from the tape; when the tape no longer
+// reaches back to the bucket start, the database bucket is kept and just
+// flagged partial instead. Nothing is added when the interval has no trades
+// yet, when to ends before it, or without a tape.
+func (s *Server) withLiveCandle(candles []persist.Candle, locate uint16, interval string, to *time.Time, limit int) []persist.Candle {
+	if s.tape == nil {
+		return candles
+	}
+	now := s.clock.Now()
+	bucket, ok := persist.BucketStart(now, interval)
+	if !ok || (to != nil && to.Before(bucket)) {
+		return candles
+	}
+
+	rest := candles
+	var stored *persist.Candle
+	if len(candles) > 0 && candles[0].Bucket.Equal(bucket) {
+		stored, rest = &candles[0], candles[1:]
+	}
+
+	var bar persist.Candle
+	trades, complete := s.tape.Since(locate, bucket)
+	switch {
+	case !complete && stored != nil:
+		bar = *stored
+	case len(trades) > 0:
+		bar = persist.Candle{Bucket: bucket, Open: trades[0].Price, High: trades[0].Price, Low: trades[0].Price}
+		for _, tr := range trades {
+			bar.High = max(bar.High, tr.Price)
+			bar.Low = min(bar.Low, tr.Price)
+			bar.Close = tr.Price
+			bar.Volume += int64(tr.Shares)
+			bar.Count++
+		}
+	default:
+		return candles
+	}
+	bar.Partial = true
+
+	out := append([]persist.Candle{bar}, rest...)
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
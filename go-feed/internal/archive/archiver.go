@@ -6,41 +6,81 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
-	"sort"
 	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// Archiver periodically moves old trades from PostgreSQL to local gzipped NDJSON
-// files, deleting the oldest archives when total size exceeds maxBytes.
+// Archiver periodically moves old trades from PostgreSQL to local compressed
+// NDJSON files (gzip or zstd), deleting the oldest archives when total size exceeds maxBytes.
 type Archiver struct {
 	pool     *pgxpool.Pool
 	dir      string
 	maxBytes int64
 	interval time.Duration
 	maxAge   time.Duration
+
+	codec     *codec
+	gzipLevel int
+	layout    *Layout
 }
 
-// New creates a new Archiver.
+// New creates a new Archiver that writes gzip day-files with the default
+// level and the default layout.
 func New(pool *pgxpool.Pool, dir string, maxGB, intervalHours, afterHours int) *Archiver {
 	return &Archiver{
-		pool:     pool,
-		dir:      dir,
-		maxBytes: int64(maxGB) * 1 << 30,
-		interval: time.Duration(intervalHours) * time.Hour,
-		maxAge:   time.Duration(afterHours) * time.Hour,
+		pool:      pool,
+		dir:       dir,
+		maxBytes:  int64(maxGB) * 1 << 30,
+		interval:  time.Duration(intervalHours) * time.Hour,
+		maxAge:    time.Duration(afterHours) * time.Hour,
+		codec:     gzipCodec,
+		gzipLevel: gzip.DefaultCompression,
+		layout:    defaultLayout,
+	}
+}
+
+// SetGzipLevel sets the compression level (1 = fastest, 9 = smallest) used
+// for new day-files.
+func (a *Archiver) SetGzipLevel(level int) error {
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		return fmt.Errorf("gzip level %d out of range [%d, %d]", level, gzip.BestSpeed, gzip.BestCompression)
 	}
+	a.gzipLevel = level
+	return nil
+}
+
+// SetCodec selects the compression for new day-files: "gzip" (the default,
+// .jsonl.gz) or "zstd" (.jsonl.zst). Files already written keep their codec;
+// readers recognise both.
+func (a *Archiver) SetCodec(name string) error {
+	c, err := parseCodec(name)
+	if err != nil {
+		return err
+	}
+	a.codec = c
+	return nil
+}
+
+// SetLayout sets the filename layout for new day-files. A nil layout restores
+// the default. Readers must use a Catalog built with the same layout.
+func (a *Archiver) SetLayout(l *Layout) {
+	if l == nil {
+		l = defaultLayout
+	}
+	a.layout = l
 }
 
 // Run starts the periodic archive loop. Blocks until ctx is cancelled.
 func (a *Archiver) Run(ctx context.Context) {
-	log.Printf("trade archiver: dir=%s max=%dGB interval=%v age=%v",
-		a.dir, a.maxBytes>>30, a.interval, a.maxAge)
+	log.Printf("trade archiver: dir=%s max=%dGB interval=%v age=%v template=%s codec=%s gzip=%d",
+		a.dir, a.maxBytes>>30, a.interval, a.maxAge, a.layout.Template(), a.codec.name, a.gzipLevel)
 
 	a.cycle(ctx)
 
@@ -125,13 +165,14 @@ func (a *Archiver) startDay(ctx context.Context, cursor time.Time) (time.Time, b
 	return dayUTC(*earliest), true, nil
 }
 
-// archiveDay streams all trades in [day, next) to a single gzipped NDJSON
-// day-file (written atomically via a temp file + rename), then deletes that
-// range from the live table. Rows are streamed straight to the gzip writer, so
-// neither the day nor the window is materialized in memory. Returns the count.
+// archiveDay streams all trades in [day, next) to compressed NDJSON day-files
+// (one file, or one per ticker under a per-symbol layout), each written
+// atomically via a temp file + rename, then deletes that range from the live
+// table. Rows are streamed straight to the compressing writers, so neither the day
+// nor the window is materialized in memory. Returns the count.
 func (a *Archiver) archiveDay(ctx context.Context, day, next time.Time) (int, error) {
 	rows, err := a.pool.Query(ctx,
-		`SELECT match_number, symbol_locate, ticker, price, shares, aggressor, executed_at
+		`SELECT run_id, match_number, symbol_locate, ticker, price, shares, aggressor, executed_at
 		 FROM trades
 		 WHERE executed_at >= $1 AND executed_at < $2
 		 ORDER BY executed_at ASC`,
@@ -140,43 +181,33 @@ func (a *Archiver) archiveDay(ctx context.Context, day, next time.Time) (int, er
 		return 0, fmt.Errorf("query: %w", err)
 	}
 
-	var w *dayWriter
+	sink := newDaySink(a.dir, day, a.layout, a.codec, a.gzipLevel)
 	count := 0
 	for rows.Next() {
 		var d tradeDoc
-		if err := rows.Scan(&d.MatchNumber, &d.SymbolLocate, &d.Ticker, &d.Price, &d.Shares, &d.Aggressor, &d.ExecutedAt); err != nil {
+		if err := rows.Scan(&d.RunID, &d.MatchNumber, &d.SymbolLocate, &d.Ticker, &d.Price, &d.Shares, &d.Aggressor, &d.ExecutedAt); err != nil {
 			rows.Close()
-			if w != nil {
-				w.abort()
-			}
+			sink.abort()
 			return 0, fmt.Errorf("scan: %w", err)
 		}
-		if w == nil {
-			if w, err = newDayWriter(a.dir, day); err != nil {
-				rows.Close()
-				return 0, err
-			}
-		}
-		if err := w.encode(&d); err != nil {
+		if err := sink.encode(&d); err != nil {
 			rows.Close()
-			w.abort()
+			sink.abort()
 			return 0, err
 		}
 		count++
 	}
 	if err := rows.Err(); err != nil {
 		rows.Close()
-		if w != nil {
-			w.abort()
-		}
+		sink.abort()
 		return 0, fmt.Errorf("iterate: %w", err)
 	}
 	rows.Close()
 
-	if w == nil {
+	if count == 0 {
 		return 0, nil // no trades that day
 	}
-	if err := w.commit(); err != nil {
+	if err := sink.commit(); err != nil {
 		return 0, err
 	}
 
@@ -187,18 +218,76 @@ func (a *Archiver) archiveDay(ctx context.Context, day, next time.Time) (int, er
 	return count, nil
 }
 
-// dayWriter streams trades to <dir>/trades/YYYY/MM/DD.jsonl.gz via a temp file
-// that is renamed into place on commit (atomic) or discarded on abort.
+// daySink routes one day's trades to day-writers according to the layout:
+// a single writer for whole-day layouts, or one per ticker when the layout is
+// per-symbol. Writers are opened lazily on the first trade they receive.
+type daySink struct {
+	dir     string
+	day     time.Time
+	layout  *Layout
+	codec   *codec
+	level   int
+	writers map[string]*dayWriter
+	order   []string
+}
+
+func newDaySink(dir string, day time.Time, layout *Layout, c *codec, level int) *daySink {
+	return &daySink{dir: dir, day: day, layout: layout, codec: c, level: level, writers: make(map[string]*dayWriter)}
+}
+
+func (s *daySink) encode(d *tradeDoc) error {
+	key := ""
+	if s.layout.PerSymbol() {
+		key = d.Ticker
+	}
+	w, ok := s.writers[key]
+	if !ok {
+		stem := filepath.Join(s.dir, "trades", s.layout.Path(s.day, key))
+		var err error
+		if w, err = newDayWriter(stem, s.codec, s.level); err != nil {
+			return err
+		}
+		s.writers[key] = w
+		s.order = append(s.order, key)
+	}
+	return w.encode(d)
+}
+
+// commit renames every writer into place. On failure the remaining writers
+// are aborted; files already renamed stay (the day is re-archived in full on
+// the next cycle, since the cursor is not advanced).
+func (s *daySink) commit() error {
+	for i, key := range s.order {
+		if err := s.writers[key].commit(); err != nil {
+			for _, rest := range s.order[i+1:] {
+				s.writers[rest].abort()
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *daySink) abort() {
+	for _, key := range s.order {
+		s.writers[key].abort()
+	}
+}
+
+// dayWriter streams trades to a day-file via a temp file that is renamed into
+// place on commit (atomic) or discarded on abort.
 type dayWriter struct {
+	stem      string // final path without the codec's suffix
+	codec     *codec
 	finalPath string
 	tmpPath   string
 	file      *os.File
-	gz        *gzip.Writer
+	zw        io.WriteCloser
 	enc       *json.Encoder
 }
 
-func newDayWriter(dir string, day time.Time) (*dayWriter, error) {
-	final := filepath.Join(dir, "trades", day.UTC().Format("2006/01/02")+".jsonl.gz")
+func newDayWriter(stem string, c *codec, level int) (*dayWriter, error) {
+	final := stem + c.ext
 	if err := os.MkdirAll(filepath.Dir(final), 0o755); err != nil {
 		return nil, fmt.Errorf("mkdir: %w", err)
 	}
@@ -207,8 +296,13 @@ func newDayWriter(dir string, day time.Time) (*dayWriter, error) {
 	if err != nil {
 		return nil, fmt.Errorf("create: %w", err)
 	}
-	gz := gzip.NewWriter(f)
-	return &dayWriter{finalPath: final, tmpPath: tmp, file: f, gz: gz, enc: json.NewEncoder(gz)}, nil
+	zw, err := c.newWriter(f, level)
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return nil, fmt.Errorf("%s: %w", c.name, err)
+	}
+	return &dayWriter{stem: stem, codec: c, finalPath: final, tmpPath: tmp, file: f, zw: zw, enc: json.NewEncoder(zw)}, nil
 }
 
 func (w *dayWriter) encode(d *tradeDoc) error {
@@ -218,10 +312,13 @@ func (w *dayWriter) encode(d *tradeDoc) error {
 	return nil
 }
 
+// commit renames the file into place, then removes any copy of the same
+// day-file written earlier with another codec, so a re-archived day is not
+// read (or counted by rotate) twice.
 func (w *dayWriter) commit() error {
-	if err := w.gz.Close(); err != nil {
+	if err := w.zw.Close(); err != nil {
 		w.file.Close()
-		return fmt.Errorf("gzip close: %w", err)
+		return fmt.Errorf("%s close: %w", w.codec.name, err)
 	}
 	if err := w.file.Sync(); err != nil {
 		w.file.Close()
@@ -233,17 +330,25 @@ func (w *dayWriter) commit() error {
 	if err := os.Rename(w.tmpPath, w.finalPath); err != nil {
 		return fmt.Errorf("rename: %w", err)
 	}
+	for _, c := range codecs {
+		if c != w.codec {
+			if err := os.Remove(w.stem + c.ext); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("remove %s copy: %w", c.name, err)
+			}
+		}
+	}
 	return nil
 }
 
 func (w *dayWriter) abort() {
-	w.gz.Close()
+	w.zw.Close()
 	w.file.Close()
 	os.Remove(w.tmpPath)
 }
 
 // tradeDoc mirrors the trades table row.
 type tradeDoc struct {
+	RunID        string    `json:"run_id,omitempty"`
 	MatchNumber  int64     `json:"match_number"`
 	SymbolLocate int16     `json:"symbol_locate"`
 	Ticker       string    `json:"ticker"`
@@ -267,19 +372,44 @@ func (a *Archiver) loadCursor(ctx context.Context) (time.Time, error) {
 }
 
 func (a *Archiver) saveCursor(ctx context.Context, t time.Time) {
+	if err := a.writeCursor(ctx, t); err != nil {
+		log.Printf("trade archiver: save cursor: %v", err)
+	}
+}
+
+func (a *Archiver) writeCursor(ctx context.Context, t time.Time) error {
 	_, err := a.pool.Exec(ctx,
 		`INSERT INTO sim_state (key, value_time, updated_at)
 		 VALUES ('archive_cursor', $1, $2)
 		 ON CONFLICT (key) DO UPDATE SET value_time = EXCLUDED.value_time, updated_at = EXCLUDED.updated_at`,
 		t, time.Now())
-	if err != nil {
-		log.Printf("trade archiver: save cursor: %v", err)
+	return err
+}
+
+// ResetCursor rewinds (or advances) the archive cursor so the next cycle
+// starts from t's UTC day, and returns that day. Days already archived are
+// rewritten only if they still have live trades; a day with none keeps its
+// existing file. A cycle already in progress may overwrite the reset when it
+// finishes a day.
+func (a *Archiver) ResetCursor(ctx context.Context, t time.Time) (time.Time, error) {
+	day := dayUTC(t)
+	if err := a.writeCursor(ctx, day); err != nil {
+		return time.Time{}, fmt.Errorf("reset cursor: %w", err)
 	}
+	log.Printf("trade archiver: cursor reset to %s", day.Format("2006-01-02"))
+	return day, nil
 }
 
 // rotate deletes the oldest archive files until total size is under maxBytes.
+// Age comes from the date encoded in each file's path (via the catalog), so
+// templates that don't sort chronologically, e.g. {ticker}/{yyyy}/..., still
+// rotate oldest-first.
 func (a *Archiver) rotate() {
-	root := filepath.Join(a.dir, "trades")
+	days, err := NewCatalogWithLayout(a.dir, a.layout).Days()
+	if err != nil {
+		log.Printf("trade archiver: rotate: %v", err)
+		return
+	}
 
 	type entry struct {
 		path string
@@ -288,25 +418,20 @@ func (a *Archiver) rotate() {
 
 	var files []entry
 	var total int64
-
-	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil || info.IsDir() {
-			return nil
+	for _, df := range days {
+		info, err := os.Stat(df.Path)
+		if err != nil {
+			continue
 		}
-		files = append(files, entry{path: path, size: info.Size()})
+		files = append(files, entry{path: df.Path, size: info.Size()})
 		total += info.Size()
-		return nil
-	})
+	}
 
 	if total <= a.maxBytes {
 		return
 	}
 
-	// Sort oldest first (path is YYYY/MM/DD so lexicographic = chronological).
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].path < files[j].path
-	})
-
+	// Catalog order is oldest first.
 	for _, f := range files {
 		if total <= a.maxBytes {
 			break
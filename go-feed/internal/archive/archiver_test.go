@@ -0,0 +1,205 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustLayout(t *testing.T, tmpl string) *Layout {
+	t.Helper()
+	l, err := ParseTemplate(tmpl)
+	if err != nil {
+		t.Fatalf("ParseTemplate(%q): %v", tmpl, err)
+	}
+	return l
+}
+
+func TestParseTemplateRejectsBadTemplates(t *testing.T) {
+	for _, tmpl := range []string{
+		"{yyyy}/{mm}",                      // missing {dd}
+		"{yyyy}/{mm}/{dd}/{dd}",            // duplicate
+		"{yyyy}/{mm}/{dd}/{sym}",           // unknown placeholder
+		"/{yyyy}/{mm}/{dd}",                // absolute
+		"../{yyyy}/{mm}/{dd}",              // escapes the archive root
+		"{ticker}/{yyyy}{mm}{dd}/{ticker}", // {ticker} twice
+	} {
+		if _, err := ParseTemplate(tmpl); err == nil {
+			t.Errorf("ParseTemplate(%q) succeeded, want error", tmpl)
+		}
+	}
+}
+
+func TestLayoutPathRoundTrip(t *testing.T) {
+	l := mustLayout(t, "{ticker}/{yyyy}-{mm}-{dd}")
+	d := day(2026, 6, 18)
+	rel := l.Path(d, "NEXO")
+	if want := filepath.FromSlash("NEXO/2026-06-18"); rel != want {
+		t.Fatalf("Path = %q, want %q", rel, want)
+	}
+	got, ticker, ok := l.parse(filepath.ToSlash(rel) + zstdCodec.ext)
+	if !ok || !got.Equal(d) || ticker != "NEXO" {
+		t.Errorf("parse(%q) = %v, %q, %v", rel, got, ticker, ok)
+	}
+}
+
+// TestDaySinkPerSymbolTemplate checks that a template referencing {ticker}
+// splits one day's trades into one file per ticker, and that the catalog
+// built with the same layout finds them.
+func TestDaySinkPerSymbolTemplate(t *testing.T) {
+	dir := t.TempDir()
+	layout := mustLayout(t, "{yyyy}/{mm}/{dd}/{ticker}")
+	d := day(2026, 6, 18)
+
+	sink := newDaySink(dir, d, layout, gzipCodec, gzip.DefaultCompression)
+	for i, tk := range []string{"NEXO", "ACME", "NEXO", "ACME", "NEXO"} {
+		locate := int16(1)
+		if tk == "ACME" {
+			locate = 2
+		}
+		dd := doc(int64(i+1), locate, tk, d.Add(time.Duration(i)*time.Minute))
+		if err := sink.encode(&dd); err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+	}
+	if err := sink.commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	for tk, want := range map[string]int{"NEXO": 3, "ACME": 2} {
+		path := filepath.Join(dir, "trades", "2026", "06", "18", tk+gzipCodec.ext)
+		if n := countLines(t, path); n != want {
+			t.Errorf("%s: %d lines, want %d", tk, n, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "trades", "2026", "06", "18"+gzipCodec.ext)); !os.IsNotExist(err) {
+		t.Errorf("whole-day file should not exist under a per-symbol template (err=%v)", err)
+	}
+
+	days, err := NewCatalogWithLayout(dir, layout).Days()
+	if err != nil {
+		t.Fatalf("Days: %v", err)
+	}
+	if len(days) != 2 || days[0].Ticker != "ACME" || days[1].Ticker != "NEXO" {
+		t.Fatalf("catalog = %+v, want ACME and NEXO files for one day", days)
+	}
+	for _, df := range days {
+		if !df.Date.Equal(d) {
+			t.Errorf("%s date = %v, want %v", df.Ticker, df.Date, d)
+		}
+	}
+}
+
+// TestDaySinkHonorsGzipLevel checks the configured level reaches the gzip
+// writer: the header's XFL byte records best-speed (4) versus
+// best-compression (2).
+func TestDaySinkHonorsGzipLevel(t *testing.T) {
+	for level, xfl := range map[int]byte{gzip.BestSpeed: 4, gzip.BestCompression: 2} {
+		dir := t.TempDir()
+		d := day(2026, 6, 18)
+		sink := newDaySink(dir, d, defaultLayout, gzipCodec, level)
+		dd := doc(1, 1, "NEXO", d)
+		if err := sink.encode(&dd); err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if err := sink.commit(); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, "trades", "2026", "06", "18"+gzipCodec.ext))
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if len(raw) < 10 || raw[8] != xfl {
+			t.Errorf("level %d: XFL = %d, want %d", level, raw[8], xfl)
+		}
+	}
+}
+
+func TestSetGzipLevelRange(t *testing.T) {
+	a := New(nil, t.TempDir(), 1, 1, 1)
+	if err := a.SetGzipLevel(0); err == nil {
+		t.Error("level 0 accepted, want error")
+	}
+	if err := a.SetGzipLevel(10); err == nil {
+		t.Error("level 10 accepted, want error")
+	}
+	if err := a.SetGzipLevel(9); err != nil {
+		t.Errorf("level 9: %v", err)
+	}
+}
+
+func TestSetCodec(t *testing.T) {
+	a := New(nil, t.TempDir(), 1, 1, 1)
+	for _, name := range []string{"gzip", "zstd"} {
+		if err := a.SetCodec(name); err != nil || a.codec.name != name {
+			t.Errorf("codec %q: %v", name, err)
+		}
+	}
+	if err := a.SetCodec("lz4"); err == nil {
+		t.Error(`codec "lz4" accepted, want error`)
+	}
+}
+
+// TestDaySinkZstdRoundTrip writes a day as zstd, checks the file carries the
+// zstd suffix and replaced the day's earlier gzip copy, and reads the trades
+// back through the catalog and Reader.
+func TestDaySinkZstdRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	d := day(2026, 6, 18)
+	writeArchiveFixture(t, dir, "2026/06/18", false, doc(1, 1, "NEXO", d))
+
+	sink := newDaySink(dir, d, defaultLayout, zstdCodec, gzip.DefaultCompression)
+	for i := range 3 {
+		dd := doc(int64(i+1), 1, "NEXO", d.Add(time.Duration(i)*time.Minute))
+		if err := sink.encode(&dd); err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+	}
+	if err := sink.commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	stem := filepath.Join(dir, "trades", "2026", "06", "18")
+	if _, err := os.Stat(stem + ".jsonl.zst"); err != nil {
+		t.Fatalf("zstd day-file: %v", err)
+	}
+	if _, err := os.Stat(stem + gzipCodec.ext); !os.IsNotExist(err) {
+		t.Errorf("gzip copy of the day should be removed (err=%v)", err)
+	}
+	days, err := NewCatalog(dir).Days()
+	if err != nil || len(days) != 1 || days[0].Rel != "2026/06/18.jsonl.zst" || days[0].ContentType() != "application/zstd" {
+		t.Fatalf("catalog = %+v, %v; want the one zstd day-file", days, err)
+	}
+
+	got, err := NewReader(NewCatalog(dir)).Read(context.Background(), ReadFilter{SymbolLocate: 1, Limit: 100})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if len(got) != 3 || got[0].MatchNumber != 3 || got[2].MatchNumber != 1 {
+		t.Fatalf("read back %+v, want matches 3, 2, 1", got)
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gunzip %s: %v", path, err)
+	}
+	body, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return strings.Count(string(bytes.TrimSpace(body)), "\n") + 1
+}
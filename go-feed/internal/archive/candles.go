@@ -2,11 +2,9 @@ package archive
 
 import (
 	"bufio"
-	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"sort"
 	"time"
 
@@ -63,21 +61,16 @@ type candleAgg struct {
 // readDayCandles buckets one day-file's matching trades and returns the bars
 // newest-first.
 func (r *Reader) readDayCandles(ctx context.Context, path string, locate uint16, from, to time.Time, secs int, before *time.Time) ([]persist.Candle, error) {
-	file, err := os.Open(path)
+	in, err := openDayFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("open archive %s: %w", path, err)
-	}
-	defer file.Close()
-	gz, err := gzip.NewReader(file)
-	if err != nil {
-		return nil, fmt.Errorf("gunzip %s: %w", path, err)
+		return nil, err
 	}
-	defer gz.Close()
+	defer in.Close()
 
 	step := int64(secs)
 	buckets := map[int64]*candleAgg{}
 
-	sc := bufio.NewScanner(gz)
+	sc := bufio.NewScanner(in)
 	sc.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
 	for n := 0; sc.Scan(); n++ {
 		if n%1024 == 0 {
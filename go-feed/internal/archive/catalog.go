@@ -4,36 +4,62 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 )
 
-// fileExt is the suffix of an archived day-file. Day-files live at
-// <dir>/trades/YYYY/MM/DD.jsonl.gz (the layout written by Archiver.writeBatch).
-const fileExt = ".jsonl.gz"
-
-// dayLayout is the YYYY/MM/DD path stem of a day-file, relative to <dir>/trades.
+// dayLayout is the time layout used to parse the date parts of a day-file path.
 const dayLayout = "2006/01/02"
 
-// DayFile is one archived UTC day of trades.
+// DayFile is one archived UTC day of trades. Under a per-symbol layout a day
+// is split across several DayFiles, one per ticker.
 type DayFile struct {
-	Date time.Time // UTC midnight of the archived day
-	Path string    // absolute path to the .jsonl.gz file
+	Date   time.Time // UTC midnight of the archived day
+	Ticker string    // symbol of a per-symbol file; empty for whole-day files
+	Path   string    // absolute path to the .jsonl.gz or .jsonl.zst file
+	Rel    string    // slash-separated path relative to <dir>/trades
+	Size   int64     // file size in bytes
+}
+
+// ContentType returns the media type of the file as stored (compressed).
+func (df DayFile) ContentType() string {
+	if c := codecOf(df.Rel); c != nil {
+		return c.contentType
+	}
+	return "application/octet-stream"
 }
 
+// Errors returned by Catalog.Lookup.
+var (
+	ErrInvalidPath = errors.New("invalid archive path")
+	ErrNotFound    = errors.New("archive file not found")
+)
+
 // Catalog enumerates and resolves archived trade day-files under an archive
 // directory. It only stats/walks the directory tree — it never decodes files —
 // so it stays cheap and bounded.
 type Catalog struct {
-	dir string // archive root (ARCHIVE_DIR); empty means archiving is disabled
+	dir    string // archive root (ARCHIVE_DIR); empty means archiving is disabled
+	layout *Layout
 }
 
-// NewCatalog returns a Catalog rooted at dir (ARCHIVE_DIR). An empty dir is
-// valid and yields an empty catalog (archiving disabled).
+// NewCatalog returns a Catalog rooted at dir (ARCHIVE_DIR) using the default
+// layout. An empty dir is valid and yields an empty catalog (archiving disabled).
 func NewCatalog(dir string) *Catalog {
-	return &Catalog{dir: dir}
+	return NewCatalogWithLayout(dir, nil)
+}
+
+// NewCatalogWithLayout returns a Catalog that recognises day-files written
+// with layout. A nil layout means the default.
+func NewCatalogWithLayout(dir string, layout *Layout) *Catalog {
+	if layout == nil {
+		layout = defaultLayout
+	}
+	return &Catalog{dir: dir, layout: layout}
 }
 
 // dayOf truncates t to UTC midnight.
@@ -42,7 +68,8 @@ func dayOf(t time.Time) time.Time {
 	return time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.UTC)
 }
 
-// Days returns every archived day-file in ascending date order. A disabled
+// Days returns every archived day-file in ascending date order (ties, which
+// only occur under a per-symbol layout, are ordered by ticker). A disabled
 // (empty) or missing archive directory yields an empty slice and no error.
 func (c *Catalog) Days() ([]DayFile, error) {
 	if c.dir == "" {
@@ -59,20 +86,24 @@ func (c *Catalog) Days() ([]DayFile, error) {
 			}
 			return err
 		}
-		if d.IsDir() || !strings.HasSuffix(path, fileExt) {
+		if d.IsDir() || codecOf(path) == nil {
 			return nil
 		}
 		rel, relErr := filepath.Rel(root, path)
 		if relErr != nil {
 			return nil
 		}
-		stem := strings.TrimSuffix(filepath.ToSlash(rel), fileExt)
-		date, parseErr := time.Parse(dayLayout, stem)
-		if parseErr != nil {
-			// Ignore files that don't match the YYYY/MM/DD layout.
+		slashRel := filepath.ToSlash(rel)
+		date, ticker, ok := c.layout.parse(slashRel)
+		if !ok {
+			// Ignore files that don't match the layout.
 			return nil
 		}
-		days = append(days, DayFile{Date: date.UTC(), Path: path})
+		var size int64
+		if info, infoErr := d.Info(); infoErr == nil {
+			size = info.Size()
+		}
+		days = append(days, DayFile{Date: date, Ticker: ticker, Path: path, Rel: slashRel, Size: size})
 		return nil
 	})
 	if err != nil {
@@ -82,10 +113,45 @@ func (c *Catalog) Days() ([]DayFile, error) {
 		return nil, fmt.Errorf("scan archive: %w", err)
 	}
 
-	sort.Slice(days, func(i, j int) bool { return days[i].Date.Before(days[j].Date) })
+	sort.Slice(days, func(i, j int) bool {
+		if !days[i].Date.Equal(days[j].Date) {
+			return days[i].Date.Before(days[j].Date)
+		}
+		return days[i].Ticker < days[j].Ticker
+	})
 	return days, nil
 }
 
+// Lookup resolves rel, a slash-separated path relative to <dir>/trades as
+// reported in DayFile.Rel, to its day-file. Paths that are absolute, contain
+// ".." or other non-canonical elements, or don't match the layout are
+// rejected with ErrInvalidPath without touching the filesystem, so callers
+// can pass untrusted input; a well-formed path with no file yields ErrNotFound.
+func (c *Catalog) Lookup(rel string) (DayFile, error) {
+	if c.dir == "" {
+		return DayFile{}, ErrNotFound
+	}
+	if rel == "" || path.IsAbs(rel) || path.Clean(rel) != rel || strings.Contains(rel, `\`) {
+		return DayFile{}, ErrInvalidPath
+	}
+	for _, elem := range strings.Split(rel, "/") {
+		if elem == ".." || elem == "." {
+			return DayFile{}, ErrInvalidPath
+		}
+	}
+	date, ticker, ok := c.layout.parse(rel)
+	if !ok {
+		return DayFile{}, ErrInvalidPath
+	}
+
+	full := filepath.Join(c.dir, "trades", filepath.FromSlash(rel))
+	info, err := os.Stat(full)
+	if err != nil || !info.Mode().IsRegular() {
+		return DayFile{}, ErrNotFound
+	}
+	return DayFile{Date: date, Ticker: ticker, Path: full, Rel: rel, Size: info.Size()}, nil
+}
+
 // Bounds returns the earliest and latest archived day. ok is false when the
 // catalog is empty.
 func (c *Catalog) Bounds() (min, max time.Time, ok bool, err error) {
@@ -1,6 +1,7 @@
 package archive
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -12,7 +13,7 @@ import (
 func writeFixture(t *testing.T, dir string, days ...string) {
 	t.Helper()
 	for _, day := range days {
-		path := filepath.Join(dir, "trades", filepath.FromSlash(day)+fileExt)
+		path := filepath.Join(dir, "trades", filepath.FromSlash(day)+gzipCodec.ext)
 		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 			t.Fatalf("mkdir: %v", err)
 		}
@@ -128,3 +129,26 @@ func TestCatalogDisabledAndMissing(t *testing.T) {
 		t.Error("missing archive dir should have no bounds")
 	}
 }
+
+func TestCatalogLookup(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, dir, "2026/06/16")
+	c := NewCatalog(dir)
+
+	df, err := c.Lookup("2026/06/16.jsonl.gz")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if !df.Date.Equal(day(2026, 6, 16)) || df.Rel != "2026/06/16.jsonl.gz" {
+		t.Errorf("Lookup = %+v", df)
+	}
+
+	if _, err := c.Lookup("2026/06/17.jsonl.gz"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing day: err = %v, want ErrNotFound", err)
+	}
+	for _, rel := range []string{"", "/2026/06/16.jsonl.gz", "../2026/06/16.jsonl.gz", "2026/06/../06/16.jsonl.gz", "2026/./06/16.jsonl.gz", "2026/06/README.txt"} {
+		if _, err := c.Lookup(rel); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Lookup(%q): err = %v, want ErrInvalidPath", rel, err)
+		}
+	}
+}
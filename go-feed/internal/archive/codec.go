@@ -0,0 +1,114 @@
+package archive
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// codec is a day-file compression. The codec a file was written with is
+// recognised from its suffix, so an archive may mix codecs after the
+// configured one changes and readers decode each file accordingly.
+type codec struct {
+	name        string
+	ext         string // day-file suffix, e.g. .jsonl.gz
+	contentType string // media type of the raw file
+
+	// newWriter compresses to w; gzipLevel applies to gzip only.
+	newWriter func(w io.Writer, gzipLevel int) (io.WriteCloser, error)
+	// newReader decompresses r, including files of several concatenated
+	// streams (appended across archive cycles).
+	newReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	gzipCodec = &codec{
+		name:        "gzip",
+		ext:         ".jsonl.gz",
+		contentType: "application/gzip",
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r) // multistream by default
+		},
+	}
+	zstdCodec = &codec{
+		name:        "zstd",
+		ext:         ".jsonl.zst",
+		contentType: "application/zstd",
+		newWriter: func(w io.Writer, _ int) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			// One goroutine: day-files are streamed line by line, and
+			// concurrent readers would each spin up a pool otherwise.
+			zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+			if err != nil {
+				return nil, err
+			}
+			return zr.IOReadCloser(), nil
+		},
+	}
+)
+
+// codecs lists every codec readers recognise.
+var codecs = []*codec{gzipCodec, zstdCodec}
+
+// parseCodec returns the codec named name; empty means gzip.
+func parseCodec(name string) (*codec, error) {
+	if name == "" {
+		return gzipCodec, nil
+	}
+	for _, c := range codecs {
+		if c.name == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown archive codec %q (want gzip or zstd)", name)
+}
+
+// codecOf returns the codec a day-file was written with, judged by its
+// suffix, or nil when path is not a day-file.
+func codecOf(path string) *codec {
+	for _, c := range codecs {
+		if strings.HasSuffix(path, c.ext) {
+			return c
+		}
+	}
+	return nil
+}
+
+// dayFileReader is an open day-file, decompressed.
+type dayFileReader struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (r *dayFileReader) Close() error {
+	r.ReadCloser.Close()
+	return r.file.Close()
+}
+
+// openDayFile opens the day-file at path and decompresses it with the codec
+// its suffix names.
+func openDayFile(path string) (io.ReadCloser, error) {
+	c := codecOf(path)
+	if c == nil {
+		return nil, fmt.Errorf("open archive %s: not an archive day-file", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open archive %s: %w", path, err)
+	}
+	zr, err := c.newReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s decode %s: %w", c.name, path, err)
+	}
+	return &dayFileReader{ReadCloser: zr, file: file}, nil
+}
@@ -2,7 +2,6 @@ package archive
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"github.com/ndrandal/feed-simulator/go-feed/internal/persist"
@@ -55,7 +54,7 @@ func (h *History) QueryCandles(ctx context.Context, f persist.CandleFilter) ([]p
 	}
 	secs, ok := persist.IntervalSeconds(f.Interval)
 	if !ok {
-		return nil, fmt.Errorf("unsupported interval: %s", f.Interval)
+		return nil, persist.NewIntervalError(f.Interval)
 	}
 	limit := persist.ClampLimit(f.Limit)
 
@@ -146,9 +145,10 @@ func (h *History) HistoryMeta(ctx context.Context) (Meta, error) {
 
 // QueryTrades returns trades for one symbol newest-first, spanning the live/cold
 // boundary as needed. Limit/offset apply to the merged sequence. When the page
-// is satisfied entirely from live, the archive is not touched.
+// is satisfied entirely from live, the archive is not touched. Incremental
+// (SinceMatch) queries are live-only: pollers follow the tail of the feed.
 func (h *History) QueryTrades(ctx context.Context, f persist.TradeFilter) ([]persist.Trade, error) {
-	if !h.archiveActive() {
+	if !h.archiveActive() || f.SinceMatch > 0 {
 		return h.TradeReader.QueryTrades(ctx, f)
 	}
 
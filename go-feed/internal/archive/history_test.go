@@ -48,6 +48,12 @@ func (f *fakeLive) QueryTrades(_ context.Context, flt persist.TradeFilter) ([]pe
 func (f *fakeLive) QueryTradesMulti(context.Context, persist.MultiTradeFilter) ([]persist.Trade, error) {
 	return nil, nil
 }
+func (f *fakeLive) QueryTradeByMatch(context.Context, uint64) (persist.Trade, error) {
+	return persist.Trade{}, persist.ErrTradeNotFound
+}
+func (f *fakeLive) QueryTradeSizeHistogram(_ context.Context, _ uint16, buckets []int32) ([]persist.HistogramBucket, error) {
+	return nil, nil
+}
 func (f *fakeLive) QueryCandles(_ context.Context, flt persist.CandleFilter) ([]persist.Candle, error) {
 	out := []persist.Candle{}
 	for _, c := range f.candles {
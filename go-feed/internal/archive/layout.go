@@ -0,0 +1,133 @@
+package archive
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultTemplate is the archive filename template used when none is
+// configured: one file per UTC day at <dir>/trades/YYYY/MM/DD.jsonl.gz.
+const DefaultTemplate = "{yyyy}/{mm}/{dd}"
+
+// Layout maps an archived (day, ticker) pair to a file path under
+// <dir>/trades and back. It is built from a template whose placeholders are
+// {yyyy}, {mm}, {dd} and, optionally, {ticker}; the codec's suffix
+// (.jsonl.gz or .jsonl.zst) is always appended. A template that references
+// {ticker} partitions each day into one file per symbol.
+type Layout struct {
+	template string
+	pattern  *regexp.Regexp
+}
+
+var placeholderRe = regexp.MustCompile(`\{[a-z]+\}`)
+
+// ParseTemplate validates tmpl and returns its Layout. The template must
+// contain {yyyy}, {mm} and {dd} exactly once each, may contain {ticker} at
+// most once, and must be a relative, slash-separated path.
+func ParseTemplate(tmpl string) (*Layout, error) {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+	if strings.HasPrefix(tmpl, "/") || strings.Contains(tmpl, "..") || strings.Contains(tmpl, `\`) {
+		return nil, fmt.Errorf("archive template %q must be a relative slash-separated path", tmpl)
+	}
+
+	counts := map[string]int{}
+	var expr strings.Builder
+	expr.WriteString("^")
+	last := 0
+	for _, loc := range placeholderRe.FindAllStringIndex(tmpl, -1) {
+		expr.WriteString(regexp.QuoteMeta(tmpl[last:loc[0]]))
+		name := tmpl[loc[0]+1 : loc[1]-1]
+		switch name {
+		case "yyyy":
+			expr.WriteString(`(?P<yyyy>\d{4})`)
+		case "mm":
+			expr.WriteString(`(?P<mm>\d{2})`)
+		case "dd":
+			expr.WriteString(`(?P<dd>\d{2})`)
+		case "ticker":
+			expr.WriteString(`(?P<ticker>[A-Za-z0-9._-]+)`)
+		default:
+			return nil, fmt.Errorf("archive template %q: unknown placeholder {%s}", tmpl, name)
+		}
+		counts[name]++
+		last = loc[1]
+	}
+	expr.WriteString(regexp.QuoteMeta(tmpl[last:]))
+	expr.WriteString("$")
+
+	for _, name := range []string{"yyyy", "mm", "dd"} {
+		if counts[name] != 1 {
+			return nil, fmt.Errorf("archive template %q must contain {%s} exactly once", tmpl, name)
+		}
+	}
+	if counts["ticker"] > 1 {
+		return nil, fmt.Errorf("archive template %q contains {ticker} more than once", tmpl)
+	}
+
+	return &Layout{template: tmpl, pattern: regexp.MustCompile(expr.String())}, nil
+}
+
+// defaultLayout is the parsed DefaultTemplate.
+var defaultLayout = func() *Layout {
+	l, err := ParseTemplate(DefaultTemplate)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}()
+
+// Template returns the template the layout was built from.
+func (l *Layout) Template() string { return l.template }
+
+// PerSymbol reports whether the layout writes one file per ticker.
+func (l *Layout) PerSymbol() bool { return strings.Contains(l.template, "{ticker}") }
+
+// Path returns the file path for day (and ticker, when per-symbol) relative
+// to <dir>/trades, in OS-native separators, without the codec's suffix.
+func (l *Layout) Path(day time.Time, ticker string) string {
+	u := day.UTC()
+	stem := strings.NewReplacer(
+		"{yyyy}", fmt.Sprintf("%04d", u.Year()),
+		"{mm}", fmt.Sprintf("%02d", int(u.Month())),
+		"{dd}", fmt.Sprintf("%02d", u.Day()),
+		"{ticker}", ticker,
+	).Replace(l.template)
+	return filepath.FromSlash(stem)
+}
+
+// parse reverses Path: given a slash-separated path relative to <dir>/trades,
+// it returns the UTC day and ticker (empty for non-per-symbol layouts). ok is
+// false when rel does not match the layout.
+func (l *Layout) parse(rel string) (day time.Time, ticker string, ok bool) {
+	c := codecOf(rel)
+	if c == nil {
+		return time.Time{}, "", false
+	}
+	m := l.pattern.FindStringSubmatch(strings.TrimSuffix(rel, c.ext))
+	if m == nil {
+		return time.Time{}, "", false
+	}
+	var y, mo, d string
+	for i, name := range l.pattern.SubexpNames() {
+		switch name {
+		case "yyyy":
+			y = m[i]
+		case "mm":
+			mo = m[i]
+		case "dd":
+			d = m[i]
+		case "ticker":
+			ticker = m[i]
+		}
+	}
+	date, err := time.Parse(dayLayout, y+"/"+mo+"/"+d)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return date.UTC(), ticker, true
+}
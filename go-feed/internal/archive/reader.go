@@ -2,11 +2,9 @@ package archive
 
 import (
 	"bufio"
-	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"time"
 
 	"github.com/ndrandal/feed-simulator/go-feed/internal/persist"
@@ -17,7 +15,7 @@ import (
 const maxLineBytes = 1 << 20 // 1 MiB
 
 // Reader streams archived trades back from cold day-files via a Catalog. It
-// decodes gzipped NDJSON line-by-line and never holds more than one day's
+// decodes compressed NDJSON line-by-line and never holds more than one day's
 // limit-sized tail (plus the result) in memory, so neither a single day-file
 // nor the whole window is slurped into RAM.
 type Reader struct {
@@ -49,7 +47,7 @@ type ReadFilter struct {
 }
 
 // Read returns up to f.Limit archived trades for the symbol within [From, To],
-// ordered newest-first. Day-files are opened newest-first; each is gzip-streamed
+// ordered newest-first. Day-files are opened newest-first; each is streamed
 // and filtered line-by-line, keeping only the newest f.Limit matches via a tail
 // ring buffer. Respects ctx cancellation.
 func (r *Reader) Read(ctx context.Context, f ReadFilter) ([]persist.Trade, error) {
@@ -91,22 +89,14 @@ func (r *Reader) Read(ctx context.Context, f ReadFilter) ([]persist.Trade, error
 // readDayTail streams one day-file and returns the newest `keep` matching trades
 // (by symbol + [from,to]) as a tail ring buffer, so memory stays O(keep).
 func (r *Reader) readDayTail(ctx context.Context, path string, locate uint16, from, to time.Time, keep int) (*tail, error) {
-	file, err := os.Open(path)
+	in, err := openDayFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("open archive %s: %w", path, err)
-	}
-	defer file.Close()
-
-	gz, err := gzip.NewReader(file)
-	if err != nil {
-		return nil, fmt.Errorf("gunzip %s: %w", path, err)
+		return nil, err
 	}
-	defer gz.Close()
-	// Multistream is on by default, so concatenated gzip members (appended across
-	// archive cycles) decode transparently.
+	defer in.Close()
 
 	t := newTail(keep)
-	sc := bufio.NewScanner(gz)
+	sc := bufio.NewScanner(in)
 	sc.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
 
 	for n := 0; sc.Scan(); n++ {
@@ -133,6 +123,7 @@ func (r *Reader) readDayTail(ctx context.Context, path string, locate uint16, fr
 			continue
 		}
 		t.push(persist.Trade{
+			RunID:       d.RunID,
 			MatchNumber: d.MatchNumber,
 			Ticker:      d.Ticker,
 			Price:       d.Price,
@@ -17,7 +17,7 @@ import (
 // multistream read path).
 func writeArchiveFixture(t *testing.T, dir, day string, append bool, docs ...tradeDoc) {
 	t.Helper()
-	path := filepath.Join(dir, "trades", filepath.FromSlash(day)+fileExt)
+	path := filepath.Join(dir, "trades", filepath.FromSlash(day)+gzipCodec.ext)
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		t.Fatalf("mkdir: %v", err)
 	}
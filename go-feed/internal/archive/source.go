@@ -0,0 +1,303 @@
+package archive
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/persist"
+)
+
+// TradeReader serves every persist.TradeReader query from the archive alone,
+// for replaying and querying history after it has been archived out of the
+// database. Only trades executed within its [from, to] window are visible, so
+// one archive can back replays of different periods. It reads the compressed
+// NDJSON day-files the Archiver writes; there is no live portion, and
+// QueryDBSize reports zero sizes. Parquet is not read, as nothing here
+// writes it.
+type TradeReader struct {
+	r        *Reader
+	from, to time.Time // window bounds; zero = unbounded
+}
+
+// NewTradeReader returns a TradeReader over r's archive limited to trades
+// executed within [from, to]. A zero bound leaves that side open.
+func NewTradeReader(r *Reader, from, to time.Time) *TradeReader {
+	return &TradeReader{r: r, from: from, to: to}
+}
+
+// window narrows the reader's window by a query's optional bounds.
+func (t *TradeReader) window(from, to *time.Time) (lo, hi time.Time) {
+	lo, hi = t.from, t.to
+	if from != nil && (lo.IsZero() || from.After(lo)) {
+		lo = *from
+	}
+	if to != nil && (hi.IsZero() || to.Before(hi)) {
+		hi = *to
+	}
+	return lo, hi
+}
+
+// QueryTrades returns a symbol's archived trades newest-first, or in
+// incremental (SinceMatch) mode those after the match number oldest-first,
+// as PgTradeReader does.
+func (t *TradeReader) QueryTrades(ctx context.Context, f persist.TradeFilter) ([]persist.Trade, error) {
+	limit := persist.ClampLimit(f.Limit)
+	lo, hi := t.window(f.From, f.To)
+	if f.SinceMatch > 0 {
+		var trades []persist.Trade
+		err := t.r.scan(ctx, lo, hi, func(d *tradeDoc) {
+			if uint16(d.SymbolLocate) == f.SymbolLocate && uint64(d.MatchNumber) > f.SinceMatch {
+				trades = append(trades, d.trade())
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		slices.SortFunc(trades, func(a, b persist.Trade) int { return cmp.Compare(a.MatchNumber, b.MatchNumber) })
+		return page(trades, 0, limit), nil
+	}
+
+	offset := max(f.Offset, 0)
+	trades, err := t.r.Read(ctx, ReadFilter{
+		SymbolLocate: f.SymbolLocate,
+		From:         lo,
+		To:           hi,
+		Limit:        min(offset+limit, persist.MaxLimit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page(trades, offset, limit), nil
+}
+
+// QueryTradesMulti returns archived trades across symbols newest-first, with
+// ticker as a stable tiebreak.
+func (t *TradeReader) QueryTradesMulti(ctx context.Context, f persist.MultiTradeFilter) ([]persist.Trade, error) {
+	limit := persist.ClampLimit(f.Limit)
+	offset := max(f.Offset, 0)
+	lo, hi := t.window(f.From, f.To)
+	var merged []persist.Trade
+	for _, loc := range f.Locates {
+		trades, err := t.r.Read(ctx, ReadFilter{
+			SymbolLocate: loc,
+			From:         lo,
+			To:           hi,
+			Limit:        min(offset+limit, persist.MaxLimit),
+		})
+		if err != nil {
+			return nil, err
+		}
+		merged = append(merged, trades...)
+	}
+	slices.SortStableFunc(merged, func(a, b persist.Trade) int {
+		if c := b.ExecutedAt.Compare(a.ExecutedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Ticker, b.Ticker)
+	})
+	return page(merged, offset, limit), nil
+}
+
+// QueryTradeByMatch returns the newest archived trade with the match number,
+// or persist.ErrTradeNotFound.
+func (t *TradeReader) QueryTradeByMatch(ctx context.Context, matchNumber uint64) (persist.Trade, error) {
+	var found *persist.Trade
+	err := t.r.scan(ctx, t.from, t.to, func(d *tradeDoc) {
+		if uint64(d.MatchNumber) == matchNumber && (found == nil || !d.ExecutedAt.Before(found.ExecutedAt)) {
+			tr := d.trade()
+			found = &tr
+		}
+	})
+	if err != nil {
+		return persist.Trade{}, err
+	}
+	if found == nil {
+		return persist.Trade{}, persist.ErrTradeNotFound
+	}
+	return *found, nil
+}
+
+// QueryCandles returns OHLCV bars for a symbol newest-first, built from its
+// archived trades with the same interval, limit, bound, cursor and fill
+// handling as PgTradeReader.
+func (t *TradeReader) QueryCandles(ctx context.Context, f persist.CandleFilter) ([]persist.Candle, error) {
+	secs, ok := persist.IntervalSeconds(f.Interval)
+	if !ok {
+		return nil, persist.NewIntervalError(f.Interval)
+	}
+	limit := persist.ClampLimit(f.Limit)
+	lo, hi := t.window(f.From, f.To)
+	candles, err := t.r.ReadCandles(ctx, f.SymbolLocate, lo, hi, secs, limit, f.Before)
+	if err != nil {
+		return nil, err
+	}
+	if f.Fill {
+		candles = persist.FillCandles(candles, f, secs, limit)
+	}
+	return candles, nil
+}
+
+// QueryTradeSizeHistogram counts a symbol's archived trades by size, with
+// the bucket semantics of PgTradeReader.QueryTradeSizeHistogram.
+func (t *TradeReader) QueryTradeSizeHistogram(ctx context.Context, locate uint16, buckets []int32) ([]persist.HistogramBucket, error) {
+	if err := persist.ValidateSizeBuckets(buckets); err != nil {
+		return nil, err
+	}
+	out := make([]persist.HistogramBucket, len(buckets))
+	for i, lo := range buckets {
+		out[i].Min = lo
+		if i+1 < len(buckets) {
+			out[i].Max = buckets[i+1]
+		}
+	}
+	err := t.r.scan(ctx, t.from, t.to, func(d *tradeDoc) {
+		if uint16(d.SymbolLocate) != locate || d.Shares < buckets[0] {
+			return
+		}
+		// The last bucket whose lower bound the size reaches.
+		i, found := slices.BinarySearch(buckets, d.Shares)
+		if !found {
+			i--
+		}
+		out[i].Count++
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// QueryTradeStats returns the count and volume of the archived trades in the
+// window.
+func (t *TradeReader) QueryTradeStats(ctx context.Context) (persist.TradeStats, error) {
+	var ts persist.TradeStats
+	err := t.r.scan(ctx, t.from, t.to, func(d *tradeDoc) {
+		ts.TotalTrades++
+		ts.TotalVolume += int64(d.Shares)
+	})
+	return ts, err
+}
+
+// QueryDBSize reports zero sizes: there is no database behind the archive.
+func (t *TradeReader) QueryDBSize(context.Context) (persist.DBSize, error) {
+	return persist.DBSize{}, nil
+}
+
+// HistoryMeta reports the archived-data bounds; there is no live retention
+// window.
+func (t *TradeReader) HistoryMeta(context.Context) (Meta, error) {
+	m := Meta{ArchiveEnabled: t.r.Enabled()}
+	min, max, ok, err := t.r.Bounds()
+	if err != nil {
+		return m, err
+	}
+	if ok {
+		m.ArchiveMinDay = &min
+		m.ArchiveMaxDay = &max
+	}
+	return m, nil
+}
+
+// page applies offset and limit to trades.
+func page(trades []persist.Trade, offset, limit int) []persist.Trade {
+	if offset >= len(trades) {
+		return []persist.Trade{}
+	}
+	return trades[offset:min(offset+limit, len(trades))]
+}
+
+// trade converts an archived record to a persist.Trade.
+func (d *tradeDoc) trade() persist.Trade {
+	return persist.Trade{
+		RunID:       d.RunID,
+		MatchNumber: d.MatchNumber,
+		Ticker:      d.Ticker,
+		Price:       d.Price,
+		Shares:      d.Shares,
+		Aggressor:   d.Aggressor,
+		ExecutedAt:  d.ExecutedAt,
+	}
+}
+
+// scan streams every archived trade executed within [from, to] (a zero
+// bound is open) to fn, oldest day first.
+func (r *Reader) scan(ctx context.Context, from, to time.Time, fn func(*tradeDoc)) error {
+	lo, hi := from, to
+	if lo.IsZero() {
+		lo = time.Time{}.AddDate(1, 0, 0)
+	}
+	if hi.IsZero() {
+		hi = time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC)
+	}
+	dayFiles, err := r.cat.Resolve(lo, hi)
+	if err != nil {
+		return err
+	}
+	for _, df := range dayFiles {
+		if err := scanDay(ctx, df.Path, from, to, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanDay streams one day-file's trades within [from, to] to fn.
+func scanDay(ctx context.Context, path string, from, to time.Time, fn func(*tradeDoc)) error {
+	in, err := openDayFile(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	sc := bufio.NewScanner(in)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	for n := 0; sc.Scan(); n++ {
+		if n%1024 == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var d tradeDoc
+		if err := json.Unmarshal(line, &d); err != nil {
+			return fmt.Errorf("decode %s: %w", path, err)
+		}
+		if !from.IsZero() && d.ExecutedAt.Before(from) || !to.IsZero() && d.ExecutedAt.After(to) {
+			continue
+		}
+		fn(&d)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("scan %s: %w", path, err)
+	}
+	return nil
+}
+
+// ParseDayRange parses from and to as YYYY-MM-DD UTC days into the bounds of
+// a TradeReader window covering both days whole. An empty day leaves that
+// side open (a zero time).
+func ParseDayRange(from, to string) (lo, hi time.Time, err error) {
+	if from != "" {
+		if lo, err = time.Parse(time.DateOnly, from); err != nil {
+			return lo, hi, fmt.Errorf("invalid from day %q: want YYYY-MM-DD", from)
+		}
+	}
+	if to != "" {
+		if hi, err = time.Parse(time.DateOnly, to); err != nil {
+			return lo, hi, fmt.Errorf("invalid to day %q: want YYYY-MM-DD", to)
+		}
+		hi = hi.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	if !lo.IsZero() && !hi.IsZero() && lo.After(hi) {
+		return lo, hi, fmt.Errorf("from day %s is after to day %s", from, to)
+	}
+	return lo, hi, nil
+}
@@ -0,0 +1,83 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/persist"
+)
+
+// TestTradeReaderServesDay writes three archived days and checks a reader
+// windowed to the middle one returns exactly that day's trades, as written.
+func TestTradeReaderServesDay(t *testing.T) {
+	dir := t.TempDir()
+	d15 := time.Date(2026, 6, 15, 10, 0, 0, 0, time.UTC)
+	d16 := d15.AddDate(0, 0, 1)
+	d17 := d16.AddDate(0, 0, 1)
+	writeArchiveFixture(t, dir, "2026/06/15", false, tradeDocAt(1, 1, 99, 100, d15))
+	day := []tradeDoc{
+		tradeDocAt(2, 1, 100.00, 100, d16),
+		tradeDocAt(3, 2, 50.00, 700, d16.Add(time.Second)),
+		tradeDocAt(4, 1, 100.50, 300, d16.Add(time.Minute)),
+		tradeDocAt(5, 1, 100.25, 1500, d16.Add(2*time.Minute)),
+	}
+	writeArchiveFixture(t, dir, "2026/06/16", false, day...)
+	writeArchiveFixture(t, dir, "2026/06/17", false, tradeDocAt(6, 1, 101, 100, d17))
+
+	from := time.Date(2026, 6, 16, 0, 0, 0, 0, time.UTC)
+	r := NewTradeReader(NewReader(NewCatalog(dir)), from, from.AddDate(0, 0, 1).Add(-time.Nanosecond))
+	ctx := context.Background()
+
+	got, err := r.QueryTrades(ctx, persist.TradeFilter{SymbolLocate: 1, Limit: 100})
+	if err != nil {
+		t.Fatalf("QueryTrades: %v", err)
+	}
+	want := []tradeDoc{day[3], day[2], day[0]} // locate 1, newest-first
+	if len(got) != len(want) {
+		t.Fatalf("got %d trades, want %d: %+v", len(got), len(want), got)
+	}
+	for i, d := range want {
+		if got[i] != d.trade() {
+			t.Errorf("trade %d = %+v, want %+v", i, got[i], d.trade())
+		}
+	}
+
+	since, _ := r.QueryTrades(ctx, persist.TradeFilter{SymbolLocate: 1, SinceMatch: 2})
+	if len(since) != 2 || since[0].MatchNumber != 4 || since[1].MatchNumber != 5 {
+		t.Errorf("since match 2 = %+v, want matches 4, 5 oldest-first", since)
+	}
+	multi, _ := r.QueryTradesMulti(ctx, persist.MultiTradeFilter{Locates: []uint16{1, 2}, Limit: 2, Offset: 1})
+	if len(multi) != 2 || multi[0].MatchNumber != 4 || multi[1].MatchNumber != 3 {
+		t.Errorf("multi page = %+v, want matches 4, 3", multi)
+	}
+
+	if tr, err := r.QueryTradeByMatch(ctx, 3); err != nil || tr.Ticker != "NEXO" || tr.Shares != 700 {
+		t.Errorf("match 3 = %+v, %v", tr, err)
+	}
+	if _, err := r.QueryTradeByMatch(ctx, 6); !errors.Is(err, persist.ErrTradeNotFound) {
+		t.Errorf("match outside the window: err = %v, want ErrTradeNotFound", err)
+	}
+
+	candles, err := r.QueryCandles(ctx, persist.CandleFilter{SymbolLocate: 1, Interval: "1h"})
+	if err != nil {
+		t.Fatalf("QueryCandles: %v", err)
+	}
+	wantBar := persist.Candle{Bucket: d16, Open: 100.00, High: 100.50, Low: 100.00, Close: 100.25, Volume: 1900, Count: 3}
+	if len(candles) != 1 || candles[0] != wantBar {
+		t.Errorf("candles = %+v, want [%+v]", candles, wantBar)
+	}
+
+	hist, err := r.QueryTradeSizeHistogram(ctx, 1, []int32{1, 200, 1000})
+	if err != nil {
+		t.Fatalf("QueryTradeSizeHistogram: %v", err)
+	}
+	if len(hist) != 3 || hist[0].Count != 1 || hist[1].Count != 1 || hist[2].Count != 1 {
+		t.Errorf("histogram = %+v, want one trade per bucket", hist)
+	}
+
+	if s, _ := r.QueryTradeStats(ctx); s.TotalTrades != 4 || s.TotalVolume != 2600 {
+		t.Errorf("stats = %+v, want the day's 4 trades, 2600 shares", s)
+	}
+}
@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock abstracts wall-clock time so timing-dependent code (symbol runners,
+// the stress controller, message timestamping) can be driven deterministically
+// in tests and replay.
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	NewTicker(d time.Duration) Ticker
+	NewTimer(d time.Duration) Timer
+}
+
+// Ticker is the subset of time.Ticker used by the simulator.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// Timer is the subset of time.Timer used by the simulator.
+type Timer interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock is the default Clock backed by the time package.
+type RealClock struct{}
+
+// Now returns time.Now().
+func (RealClock) Now() time.Time { return time.Now() }
+
+// Sleep calls time.Sleep.
+func (RealClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// NewTicker wraps time.NewTicker.
+func (RealClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct{ t *time.Ticker }
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+func (r realTicker) Stop()               { r.t.Stop() }
+
+// NewTimer wraps time.NewTimer.
+func (RealClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct{ t *time.Timer }
+
+func (r realTimer) C() <-chan time.Time { return r.t.C }
+func (r realTimer) Stop()               { r.t.Stop() }
+
+// FakeClock is a manually-advanced Clock for tests. Time only moves when
+// Advance (or Sleep) is called; tickers and timers fire as the fake time
+// passes their deadlines. It is safe for concurrent use.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock returns a FakeClock starting at start.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the current fake time.
+func (f *FakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Sleep advances the fake time by d instead of blocking, so a loop that
+// sleeps between iterations runs at full speed under a FakeClock.
+func (f *FakeClock) Sleep(d time.Duration) {
+	f.Advance(d)
+}
+
+// NewTicker returns a ticker that fires each time the fake time crosses a
+// multiple of d past its creation. Like time.Ticker, it drops ticks when the
+// reader falls behind.
+func (f *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("engine: non-positive interval for FakeClock.NewTicker")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{c: make(chan time.Time, 1), period: d, next: f.now.Add(d)}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// NewTimer returns a timer that fires once, when the fake time reaches d
+// past its creation.
+func (f *FakeClock) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	t := &fakeTicker{c: make(chan time.Time, 1), period: d, next: f.now.Add(d), once: true}
+	f.tickers = append(f.tickers, t)
+	return t
+}
+
+// Advance moves the fake time forward by d, firing any tickers and timers
+// whose deadlines fall within the advanced span.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+
+	live := f.tickers[:0]
+	for _, t := range f.tickers {
+		if t.stopped() {
+			continue
+		}
+		if !t.next.After(f.now) {
+			select {
+			case t.c <- f.now:
+			default:
+			}
+			if t.once {
+				continue
+			}
+			for !t.next.After(f.now) {
+				t.next = t.next.Add(t.period)
+			}
+		}
+		live = append(live, t)
+	}
+	f.tickers = live
+}
+
+// fakeTicker backs both fake tickers and fake timers; a timer fires once.
+type fakeTicker struct {
+	c      chan time.Time
+	period time.Duration
+	next   time.Time
+	once   bool // a timer: dropped after firing
+
+	mu   sync.Mutex
+	done bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	t.mu.Lock()
+	t.done = true
+	t.mu.Unlock()
+}
+
+func (t *fakeTicker) stopped() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.done
+}
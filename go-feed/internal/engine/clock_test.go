@@ -0,0 +1,81 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockAdvance(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clk := NewFakeClock(start)
+	clk.Advance(5 * time.Second)
+	if got := clk.Now(); !got.Equal(start.Add(5 * time.Second)) {
+		t.Fatalf("Now = %v, want %v", got, start.Add(5*time.Second))
+	}
+	clk.Sleep(time.Second)
+	if got := clk.Now(); !got.Equal(start.Add(6 * time.Second)) {
+		t.Fatalf("Sleep did not advance fake time: Now = %v", got)
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	clk := NewFakeClock(time.Unix(0, 0))
+	tk := clk.NewTicker(100 * time.Millisecond)
+
+	clk.Advance(50 * time.Millisecond)
+	select {
+	case <-tk.C():
+		t.Fatal("ticker fired before its period elapsed")
+	default:
+	}
+
+	clk.Advance(50 * time.Millisecond)
+	select {
+	case <-tk.C():
+	default:
+		t.Fatal("ticker did not fire after its period elapsed")
+	}
+
+	tk.Stop()
+	clk.Advance(time.Second)
+	select {
+	case <-tk.C():
+		t.Fatal("stopped ticker fired")
+	default:
+	}
+}
+
+func TestFakeClockTimer(t *testing.T) {
+	clk := NewFakeClock(time.Unix(0, 0))
+	tm := clk.NewTimer(100 * time.Millisecond)
+
+	clk.Advance(50 * time.Millisecond)
+	select {
+	case <-tm.C():
+		t.Fatal("timer fired before its duration elapsed")
+	default:
+	}
+
+	clk.Advance(50 * time.Millisecond)
+	select {
+	case <-tm.C():
+	default:
+		t.Fatal("timer did not fire after its duration elapsed")
+	}
+
+	clk.Advance(time.Second)
+	select {
+	case <-tm.C():
+		t.Fatal("timer fired twice")
+	default:
+	}
+
+	stopped := clk.NewTimer(100 * time.Millisecond)
+	stopped.Stop()
+	clk.Advance(time.Second)
+	select {
+	case <-stopped.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
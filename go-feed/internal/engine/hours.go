@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// MarketHours is a daily trading session in UTC. The zero value is always
+// open. A session whose close is before its open wraps past midnight.
+type MarketHours struct {
+	open, close time.Duration // offsets from UTC midnight
+	set         bool
+}
+
+// ParseMarketHours parses "HH:MM-HH:MM" (UTC), e.g. "13:30-20:00". An empty
+// string means always open.
+func ParseMarketHours(s string) (MarketHours, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return MarketHours{}, nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return MarketHours{}, fmt.Errorf("market hours %q: want HH:MM-HH:MM", s)
+	}
+	open, err := parseClock(from)
+	if err != nil {
+		return MarketHours{}, fmt.Errorf("market hours %q: %w", s, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return MarketHours{}, fmt.Errorf("market hours %q: %w", s, err)
+	}
+	if open == end {
+		return MarketHours{}, fmt.Errorf("market hours %q: open and close are equal", s)
+	}
+	return MarketHours{open: open, close: end, set: true}, nil
+}
+
+// parseClock parses "HH:MM" as an offset from midnight.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Open reports whether t falls inside the session.
+func (h MarketHours) Open(t time.Time) bool {
+	if !h.set {
+		return true
+	}
+	u := t.UTC()
+	tod := time.Duration(u.Hour())*time.Hour + time.Duration(u.Minute())*time.Minute +
+		time.Duration(u.Second())*time.Second + time.Duration(u.Nanosecond())
+	if h.open < h.close {
+		return tod >= h.open && tod < h.close
+	}
+	return tod >= h.open || tod < h.close
+}
+
+// SessionStart returns when the session t belongs to began: the latest open
+// at or before t, or with no hours set, UTC midnight of t's day. Between a
+// close and the next open that is the session just closed.
+func (h MarketHours) SessionStart(t time.Time) time.Time {
+	midnight := t.UTC().Truncate(24 * time.Hour)
+	if !h.set {
+		return midnight
+	}
+	start := midnight.Add(h.open)
+	if start.After(t) {
+		start = start.Add(-24 * time.Hour)
+	}
+	return start
+}
+
+// PreOpen reports whether t falls within window before the next open. With
+// no hours set there is never a pre-open.
+func (h MarketHours) PreOpen(t time.Time, window time.Duration) bool {
+	if !h.set || window <= 0 || h.Open(t) {
+		return false
+	}
+	next := h.SessionStart(t).Add(24 * time.Hour)
+	return next.Sub(t) <= window
+}
+
+// noiiInterval is how often a gate's pre-open calls for an imbalance update.
+const noiiInterval = time.Second
+
+// MarketGate tracks one runner's view of the session so it can announce the
+// open and close once each.
+type MarketGate struct {
+	mu      sync.Mutex
+	hours   MarketHours
+	checked bool
+	open    bool
+
+	preOpen  time.Duration // opening auction window (0 = none)
+	lastNOII time.Time
+}
+
+// NewMarketGate returns a gate for hours.
+func NewMarketGate(hours MarketHours) *MarketGate {
+	return &MarketGate{hours: hours}
+}
+
+// SetPreOpen enables an opening auction window before each open. 0 disables
+// it.
+func (g *MarketGate) SetPreOpen(window time.Duration) {
+	g.mu.Lock()
+	g.preOpen = window
+	g.mu.Unlock()
+}
+
+// PreOpen reports whether now is in the gate's opening auction window and,
+// at most once a second while it is, that an imbalance update is due.
+func (g *MarketGate) PreOpen(now time.Time) (preOpen, noii bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.hours.PreOpen(now, g.preOpen) {
+		return false, false
+	}
+	if now.Sub(g.lastNOII) >= noiiInterval {
+		g.lastNOII = now
+		noii = true
+	}
+	return true, noii
+}
+
+// Check reports whether the market is open at now and, when that changed
+// since the previous call, the system event announcing it:
+// itch.EventStartOfMarket on open, itch.EventEndOfMarket on close, else 0.
+// The first call only records the state.
+func (g *MarketGate) Check(now time.Time) (open bool, event byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	open = g.hours.Open(now)
+	if g.checked && open != g.open {
+		event = itch.EventEndOfMarket
+		if open {
+			event = itch.EventStartOfMarket
+		}
+	}
+	g.checked, g.open = true, open
+	return open, event
+}
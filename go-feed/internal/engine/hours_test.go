@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// TestMarketGateFollowsSession walks a fake clock across a 13:30-20:00 UTC
+// session and checks the gate is shut before and after it, open inside, and
+// announces each transition once.
+func TestMarketGateFollowsSession(t *testing.T) {
+	hours, err := ParseMarketHours("13:30-20:00")
+	if err != nil {
+		t.Fatalf("ParseMarketHours: %v", err)
+	}
+	clock := NewFakeClock(time.Date(2026, 1, 2, 13, 0, 0, 0, time.UTC))
+	gate := NewMarketGate(hours)
+
+	for _, step := range []struct {
+		advance time.Duration
+		open    bool
+		event   byte
+	}{
+		{0, false, 0},                                // 13:00, first check only records
+		{29 * time.Minute, false, 0},                 // 13:29
+		{time.Minute, true, itch.EventStartOfMarket}, // 13:30, open is inclusive
+		{3 * time.Hour, true, 0},                     // 16:30
+		{3*time.Hour + 29*time.Minute, true, 0},      // 19:59
+		{time.Minute, false, itch.EventEndOfMarket},  // 20:00, close is exclusive
+		{4 * time.Hour, false, 0},                    // 00:00 next day
+	} {
+		clock.Advance(step.advance)
+		open, event := gate.Check(clock.Now())
+		if open != step.open || event != step.event {
+			t.Errorf("%s: Check = %v, %q; want %v, %q", clock.Now().Format("15:04"), open, event, step.open, step.event)
+		}
+	}
+}
+
+func TestMarketGatePreOpen(t *testing.T) {
+	hours, err := ParseMarketHours("13:30-20:00")
+	if err != nil {
+		t.Fatalf("ParseMarketHours: %v", err)
+	}
+	clock := NewFakeClock(time.Date(2026, 1, 2, 13, 0, 0, 0, time.UTC))
+	gate := NewMarketGate(hours)
+	if pre, _ := gate.PreOpen(clock.Now()); pre {
+		t.Fatal("pre-open with no window set")
+	}
+	gate.SetPreOpen(10 * time.Minute)
+
+	for _, step := range []struct {
+		advance   time.Duration
+		pre, noii bool
+	}{
+		{19 * time.Minute, false, false},               // 13:19
+		{time.Minute, true, true},                      // 13:20, window start is inclusive
+		{500 * time.Millisecond, true, false},          // NOII at most once a second
+		{500 * time.Millisecond, true, true},           // 13:20:01
+		{9*time.Minute + 59*time.Second, false, false}, // 13:30, open
+		{6*time.Hour + 30*time.Minute, false, false},   // 20:00, closed
+	} {
+		clock.Advance(step.advance)
+		pre, noii := gate.PreOpen(clock.Now())
+		if pre != step.pre || noii != step.noii {
+			t.Errorf("%s: PreOpen = %v, %v; want %v, %v", clock.Now().Format("15:04:05.000"), pre, noii, step.pre, step.noii)
+		}
+	}
+}
+
+func TestMarketHoursWrapsMidnight(t *testing.T) {
+	hours, err := ParseMarketHours("22:00-02:00")
+	if err != nil {
+		t.Fatalf("ParseMarketHours: %v", err)
+	}
+	for hour, want := range map[int]bool{21: false, 22: true, 23: true, 1: true, 2: false, 12: false} {
+		if got := hours.Open(time.Date(2026, 1, 2, hour, 0, 0, 0, time.UTC)); got != want {
+			t.Errorf("Open(%02d:00) = %v, want %v", hour, got, want)
+		}
+	}
+}
+
+func TestParseMarketHours(t *testing.T) {
+	var always MarketHours
+	if h, err := ParseMarketHours(""); err != nil || h != always {
+		t.Errorf("empty spec = %+v, %v; want always open", h, err)
+	}
+	if !always.Open(time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)) {
+		t.Error("zero MarketHours is closed, want always open")
+	}
+	for _, s := range []string{"09:30", "9:30-16", "25:00-26:00", "10:00-10:00"} {
+		if _, err := ParseMarketHours(s); err == nil {
+			t.Errorf("ParseMarketHours(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestSessionStart(t *testing.T) {
+	hours, _ := ParseMarketHours("13:30-20:00")
+	night, _ := ParseMarketHours("22:00-06:00")
+	day := func(d, h, m int) time.Time { return time.Date(2026, 1, d, h, m, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		hours MarketHours
+		at    time.Time
+		want  time.Time
+	}{
+		{MarketHours{}, day(2, 15, 4), day(2, 0, 0)},
+		{hours, day(2, 13, 30), day(2, 13, 30)},
+		{hours, day(2, 21, 0), day(2, 13, 30)}, // after the close: the session just ended
+		{hours, day(2, 9, 0), day(1, 13, 30)},  // before the open: yesterday's
+		{night, day(3, 2, 0), day(2, 22, 0)},
+	} {
+		if got := tc.hours.SessionStart(tc.at); !got.Equal(tc.want) {
+			t.Errorf("SessionStart(%s) = %s, want %s", tc.at.Format(time.DateTime), got.Format(time.DateTime), tc.want.Format(time.DateTime))
+		}
+	}
+}
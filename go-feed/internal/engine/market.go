@@ -1,8 +1,12 @@
 package engine
 
 import (
+	"fmt"
 	"math"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
 )
@@ -14,41 +18,275 @@ const (
 	ticksPerDay     = 86400 // approximate, for vol scaling
 )
 
+// PriceModelGBM names the engine's price process: geometric Brownian motion
+// with sector- and market-correlated shocks.
+const PriceModelGBM = "gbm"
+
 // MarketEngine drives GBM price movement with sector-correlated returns.
 type MarketEngine struct {
 	mu     sync.RWMutex
 	rng    *RNG
-	prices map[uint16]float64   // locate -> current price
+	prices map[uint16]float64   // locate -> current price, snapped to the tick
+	raw    map[uint16]float64   // locate -> unsnapped GBM price the next tick steps from
+	refs   map[uint16]float64   // locate -> reference price for Change
+	opens  map[uint16]float64   // locate -> price when the current session began
 	syms   []symbol.Symbol
 	byLoc  map[uint16]*symbol.Symbol
 
-	// sector shocks generated once per tick cycle
+	// sector shocks generated once per tick cycle, one per entry of sectors
 	sectorShocks map[symbol.Sector]float64
+	sectors      []symbol.Sector
+
+	// market-wide (index) shock, generated alongside the sector shocks and
+	// blended into every symbol's return with weight marketWeight
+	marketShock  float64
+	marketWeight float64
+
+	// price-banded tick sizes; empty means each symbol's fixed TickSize
+	ticks symbol.TickSchedule
+	// how prices snap to the tick
+	rounding symbol.Rounding
+
+	// GARCH(1,1) volatility clustering: each symbol's conditional variance,
+	// as a multiple of its constant per-tick variance, follows
+	// h' = (1-alpha-beta) + (alpha*z^2 + beta)*h. alpha 0 disables it.
+	garchAlpha float64
+	garchBeta  float64
+	variance   map[uint16]float64
+
+	// locate -> runtime volatility scale on top of the symbol's
+	// VolatilityMultiplier; absent means 1
+	volScale map[uint16]float64
+
+	// locate -> chance a tick that rounds back to the same price moves one
+	// tick instead; absent means never
+	jitter map[uint16]float64
+
+	// start of the session opens belongs to (zero before the first)
+	sessionStart time.Time
 }
 
 // NewMarketEngine creates a price engine for all symbols.
 func NewMarketEngine(rng *RNG, syms []symbol.Symbol) *MarketEngine {
 	prices := make(map[uint16]float64, len(syms))
+	raw := make(map[uint16]float64, len(syms))
+	refs := make(map[uint16]float64, len(syms))
 	byLoc := make(map[uint16]*symbol.Symbol, len(syms))
 	for i := range syms {
 		prices[syms[i].LocateCode] = syms[i].BasePrice
+		raw[syms[i].LocateCode] = syms[i].BasePrice
+		refs[syms[i].LocateCode] = syms[i].BasePrice
 		byLoc[syms[i].LocateCode] = &syms[i]
 	}
 	return &MarketEngine{
 		rng:          rng,
 		prices:       prices,
+		raw:          raw,
+		refs:         refs,
+		opens:        make(map[uint16]float64, len(syms)),
 		syms:         syms,
 		byLoc:        byLoc,
 		sectorShocks: make(map[symbol.Sector]float64),
+		sectors:      sectorsOf(syms),
+		jitter:       make(map[uint16]float64),
+		volScale:     make(map[uint16]float64),
+	}
+}
+
+// sectorsOf returns the sectors to draw shocks for: the static sectors in
+// symbol.Sectors order, then any other sector among syms in first-seen
+// order. Keeping the static sectors first, even ones with no symbols here,
+// leaves the random draws (and so a seeded run) of the built-in universe
+// unchanged.
+func sectorsOf(syms []symbol.Symbol) []symbol.Sector {
+	sectors := symbol.Sectors()
+	seen := make(map[symbol.Sector]bool, len(sectors))
+	for _, sec := range sectors {
+		seen[sec] = true
+	}
+	for _, s := range syms {
+		if !seen[s.Sector] {
+			seen[s.Sector] = true
+			sectors = append(sectors, s.Sector)
+		}
+	}
+	return sectors
+}
+
+// SetMarketWeight sets how much of each symbol's shock comes from the
+// market-wide factor, clamped to [0, 1]. 0 (the default) disables it; higher
+// values make all symbols co-move.
+func (m *MarketEngine) SetMarketWeight(w float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.marketWeight = math.Max(0, math.Min(1, w))
+}
+
+// Model returns the name of the price process driving the engine.
+func (m *MarketEngine) Model() string {
+	return PriceModelGBM
+}
+
+// SetTickSchedule sets the price-band tick schedule applied to every symbol.
+// An empty schedule (the default) keeps each symbol's fixed TickSize.
+func (m *MarketEngine) SetTickSchedule(ts symbol.TickSchedule) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ticks = ts
+}
+
+// SetRounding sets how ticked prices snap to the tick. The default,
+// RoundNearest, rounds to the nearest tick.
+func (m *MarketEngine) SetRounding(r symbol.Rounding) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rounding = r
+}
+
+// SetGARCH enables GARCH(1,1)-style volatility clustering: a large shock
+// raises the symbol's volatility for the following ticks, decaying back to
+// its constant level at rate alpha+beta. alpha = 0 disables it (the default).
+// Requires alpha, beta >= 0 and alpha+beta < 1, so the long-run volatility
+// stays the constant model's.
+func (m *MarketEngine) SetGARCH(alpha, beta float64) error {
+	if alpha < 0 || beta < 0 || alpha+beta >= 1 {
+		return fmt.Errorf("GARCH alpha %v, beta %v: want both >= 0 with alpha+beta < 1", alpha, beta)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.garchAlpha, m.garchBeta = alpha, beta
+	m.variance = make(map[uint16]float64, len(m.syms))
+	for _, s := range m.syms {
+		m.variance[s.LocateCode] = 1
+	}
+	return nil
+}
+
+// MaxVolatilityScale caps a runtime volatility scale.
+const MaxVolatilityScale = 100
+
+// SetVolatilityScale scales a symbol's volatility by scale, on top of its
+// VolatilityMultiplier, until changed again: 2 doubles its per-tick moves,
+// 1 restores them. scale must be in (0, MaxVolatilityScale].
+func (m *MarketEngine) SetVolatilityScale(locateCode uint16, scale float64) error {
+	if !(scale > 0 && scale <= MaxVolatilityScale) {
+		return fmt.Errorf("volatility multiplier %v: want a number in (0, %d]", scale, MaxVolatilityScale)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.setVolatilityScale(locateCode, scale)
+	return nil
+}
+
+// SetSectorVolatilityScale applies SetVolatilityScale to every symbol in
+// sector and returns their locate codes, in symbol order; none when the
+// sector has no symbols.
+func (m *MarketEngine) SetSectorVolatilityScale(sector symbol.Sector, scale float64) ([]uint16, error) {
+	if !(scale > 0 && scale <= MaxVolatilityScale) {
+		return nil, fmt.Errorf("volatility multiplier %v: want a number in (0, %d]", scale, MaxVolatilityScale)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var locates []uint16
+	for _, s := range m.syms {
+		if s.Sector == sector {
+			m.setVolatilityScale(s.LocateCode, scale)
+			locates = append(locates, s.LocateCode)
+		}
+	}
+	return locates, nil
+}
+
+// setVolatilityScale records locate's scale. The caller holds m.mu.
+func (m *MarketEngine) setVolatilityScale(locateCode uint16, scale float64) {
+	if scale == 1 {
+		delete(m.volScale, locateCode)
+	} else {
+		m.volScale[locateCode] = scale
+	}
+}
+
+// VolatilityScale returns a symbol's runtime volatility scale (1 = none).
+func (m *MarketEngine) VolatilityScale(locateCode uint16) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if scale, ok := m.volScale[locateCode]; ok {
+		return scale
+	}
+	return 1
+}
+
+// SetTickJitter gives a symbol probability p, in [0, 1], that a tick whose
+// GBM step rounds back to the unchanged price moves one tick up or down
+// instead. It keeps the tape of a cheap or calm symbol, whose per-tick moves
+// are smaller than its tick, from printing the same price over and over.
+// 0 disables it (the default).
+func (m *MarketEngine) SetTickJitter(locateCode uint16, p float64) error {
+	if !(p >= 0 && p <= 1) {
+		return fmt.Errorf("tick jitter %v: want a probability in [0, 1]", p)
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if p == 0 {
+		delete(m.jitter, locateCode)
+	} else {
+		m.jitter[locateCode] = p
+	}
+	return nil
+}
+
+// ParseTickJitter parses a comma-separated list of TICKER=PROB pairs giving
+// each symbol's tick jitter probability, e.g. "NEXO=0.3,ACME=1". An empty
+// spec yields an empty map (no jitter).
+func ParseTickJitter(spec string) (map[string]float64, error) {
+	out := make(map[string]float64)
+	if strings.TrimSpace(spec) == "" {
+		return out, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		ticker, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || ticker == "" {
+			return nil, fmt.Errorf("tick jitter %q: want TICKER=PROB", part)
+		}
+		p, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(p >= 0 && p <= 1) {
+			return nil, fmt.Errorf("tick jitter %q: probability must be in [0, 1]", part)
+		}
+		if _, dup := out[ticker]; dup {
+			return nil, fmt.Errorf("tick jitter: %s listed more than once", ticker)
+		}
+		out[ticker] = p
+	}
+	return out, nil
 }
 
-// GenerateSectorShocks produces one gaussian shock per sector.
-// Call this once per tick cycle before ticking individual symbols.
+// updateVariance folds the shock z into locate's conditional variance. The
+// caller holds m.mu.
+func (m *MarketEngine) updateVariance(locateCode uint16, z float64) {
+	a, b := m.garchAlpha, m.garchBeta
+	m.variance[locateCode] = (1 - a - b) + (a*z*z+b)*m.variance[locateCode]
+}
+
+// TickAt returns the tick size for a symbol trading at price.
+func (m *MarketEngine) TickAt(locateCode uint16, price float64) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	sym := m.byLoc[locateCode]
+	if sym == nil {
+		return 0
+	}
+	return m.ticks.TickAt(price, sym.TickSize)
+}
+
+// GenerateSectorShocks produces one gaussian shock per sector of the loaded
+// symbols, including sectors outside symbol.Sectors, plus a single
+// market-wide shock. Call this once per tick cycle before ticking individual
+// symbols.
 func (m *MarketEngine) GenerateSectorShocks() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	for _, sec := range symbol.Sectors() {
+	m.marketShock = m.rng.Gaussian()
+	for _, sec := range m.sectors {
 		m.sectorShocks[sec] = m.rng.Gaussian()
 	}
 }
@@ -64,27 +302,55 @@ func (m *MarketEngine) Tick(locateCode uint16) float64 {
 		return 0
 	}
 
-	price := m.prices[locateCode]
+	// Step from the unsnapped price: stepping from the snapped one would
+	// compound floor or ceil rounding into a drift, one tick per tick.
+	prev := m.prices[locateCode]
+	raw := m.raw[locateCode]
 
 	// Per-tick volatility: daily vol / sqrt(ticks_per_day) * symbol multiplier
 	tickVol := baseDailyVol / math.Sqrt(ticksPerDay) * sym.VolatilityMultiplier
+	if scale, ok := m.volScale[locateCode]; ok {
+		tickVol *= scale
+	}
 
 	// Blended shock: sector + idiosyncratic
 	sectorZ := m.sectorShocks[sym.Sector]
 	idioZ := m.rng.Gaussian()
 	z := sectorBlend*sectorZ + (1-sectorBlend)*idioZ
 
+	// Market factor on top: weight w of the shock is shared by every symbol
+	z = m.marketWeight*m.marketShock + (1-m.marketWeight)*z
+
+	// Volatility clustering: scale by this tick's conditional volatility,
+	// then let the shock drive the next one
+	if m.garchAlpha > 0 {
+		tickVol *= math.Sqrt(m.variance[locateCode])
+		m.updateVariance(locateCode, z)
+	}
+
 	// GBM step
 	logReturn := driftPerTick + tickVol*z
-	price *= math.Exp(logReturn)
+	raw *= math.Exp(logReturn)
 
-	// Snap to tick size, floor at 1 tick
-	price = math.Round(price/sym.TickSize) * sym.TickSize
-	if price < sym.TickSize {
-		price = sym.TickSize
+	// Snap to the tick for this price band, floor at 1 tick
+	tick := m.ticks.TickAt(raw, sym.TickSize)
+	raw = math.Max(raw, tick)
+	price := math.Max(m.rounding.Snap(raw, tick), tick)
+
+	// Tick jitter: nudge an unchanged price one tick, upward at the floor.
+	// The draws happen only for jittered symbols, so seeded runs without
+	// jitter are unchanged.
+	if p := m.jitter[locateCode]; p > 0 && price == prev && m.rng.Float64() < p {
+		if m.rng.Float64() < 0.5 || price-tick < tick {
+			price = symbol.RoundNearest.Snap(price+tick, tick)
+		} else {
+			price = symbol.RoundNearest.Snap(price-tick, tick)
+		}
+		raw += price - prev // keep the nudge rather than undo it next tick
 	}
 
 	m.prices[locateCode] = price
+	m.raw[locateCode] = raw
 	return price
 }
 
@@ -100,6 +366,7 @@ func (m *MarketEngine) SetPrice(locateCode uint16, price float64) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.prices[locateCode] = price
+	m.raw[locateCode] = price
 }
 
 // AllPrices returns a snapshot of all current prices.
@@ -112,3 +379,81 @@ func (m *MarketEngine) AllPrices() map[uint16]float64 {
 	}
 	return out
 }
+
+// MarkReference snapshots every current price as the reference Change
+// measures against. Call it each lookback period for a rolling change;
+// RollSession marks the reference at session open.
+func (m *MarketEngine) MarkReference() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for k, v := range m.prices {
+		m.refs[k] = v
+	}
+}
+
+// RollSession begins the session that started at start, if it is later than
+// the current one: every current price is recorded as its symbol's session
+// open and becomes the reference Change measures against. It reports whether
+// a new session began.
+func (m *MarketEngine) RollSession(start time.Time) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !start.After(m.sessionStart) {
+		return false
+	}
+	m.sessionStart = start
+	for k, v := range m.prices {
+		m.opens[k] = v
+		m.refs[k] = v
+	}
+	return true
+}
+
+// SessionOpens returns when the current session began and a copy of each
+// symbol's open, for persistence. start is zero before the first session.
+func (m *MarketEngine) SessionOpens() (start time.Time, opens map[uint16]float64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	opens = make(map[uint16]float64, len(m.opens))
+	for k, v := range m.opens {
+		opens[k] = v
+	}
+	return m.sessionStart, opens
+}
+
+// RestoreSessionOpens reinstates a saved session's opens, which also become
+// the Change reference, so a restart within a session keeps measuring from
+// its real open. Locates the engine does not price are ignored.
+func (m *MarketEngine) RestoreSessionOpens(start time.Time, opens map[uint16]float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessionStart = start
+	for k, v := range opens {
+		if _, ok := m.prices[k]; ok {
+			m.opens[k] = v
+			m.refs[k] = v
+		}
+	}
+}
+
+// Open returns a symbol's price at the start of the current session, or 0
+// before the first session.
+func (m *MarketEngine) Open(locateCode uint16) float64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.opens[locateCode]
+}
+
+// Change returns a symbol's price move since the reference: the session
+// open, or the last MarkReference after it (its base price, before either),
+// absolute and in percent.
+func (m *MarketEngine) Change(locateCode uint16) (change, pct float64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ref := m.refs[locateCode]
+	if ref == 0 {
+		return 0, 0
+	}
+	change = m.prices[locateCode] - ref
+	return change, change / ref * 100
+}
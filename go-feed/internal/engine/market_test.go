@@ -3,6 +3,7 @@ package engine
 import (
 	"math"
 	"testing"
+	"time"
 
 	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
 )
@@ -54,6 +55,51 @@ func TestTickSizeSnapping(t *testing.T) {
 	}
 }
 
+// onTick reports whether p is a whole number of ticks (within float noise).
+func onTick(p, tick float64) bool {
+	n := p / tick
+	return math.Abs(n-math.Round(n)) < 1e-6
+}
+
+// TestTickScheduleBands checks that a sub-$1 symbol snaps to the schedule's
+// finer tick and a high-priced one to its coarser tick.
+func TestTickScheduleBands(t *testing.T) {
+	syms := []symbol.Symbol{
+		{LocateCode: 1, Ticker: "PENY", Sector: symbol.SectorTech, BasePrice: 0.5, TickSize: 0.01, VolatilityMultiplier: 5},
+		{LocateCode: 2, Ticker: "BIGG", Sector: symbol.SectorTech, BasePrice: 2500, TickSize: 0.01, VolatilityMultiplier: 5},
+	}
+	ts, err := symbol.ParseTickSchedule("0:0.0001,1:0.01,1000:0.05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := NewMarketEngine(NewRNG(7), syms)
+	m.SetTickSchedule(ts)
+
+	fine := false
+	for i := 0; i < 2000; i++ {
+		m.GenerateSectorShocks()
+		low, high := m.Tick(1), m.Tick(2)
+		if !onTick(low, 0.0001) {
+			t.Fatalf("sub-$1 price %v not on the 0.0001 tick", low)
+		}
+		if !onTick(low, 0.01) {
+			fine = true
+		}
+		if !onTick(high, 0.05) {
+			t.Fatalf("high price %v not on the 0.05 tick", high)
+		}
+	}
+	if !fine {
+		t.Error("sub-$1 prices never used sub-cent increments")
+	}
+	if got := m.TickAt(1, 0.5); got != 0.0001 {
+		t.Errorf("TickAt(0.5) = %v, want 0.0001", got)
+	}
+	if got := m.TickAt(2, 150); got != 0.01 {
+		t.Errorf("TickAt(150) = %v, want 0.01", got)
+	}
+}
+
 func TestSameSectorCorrelation(t *testing.T) {
 	// Run many ticks and measure correlation between same-sector vs cross-sector
 	rng := NewRNG(42)
@@ -106,6 +152,114 @@ func TestSameSectorCorrelation(t *testing.T) {
 	}
 }
 
+// TestCustomSectorGetsShock checks that symbols in a sector outside
+// symbol.Sectors share a sector shock, so they co-move like built-in sectors
+// do, and still tick.
+func TestCustomSectorGetsShock(t *testing.T) {
+	const crypto symbol.Sector = "Crypto"
+	syms := []symbol.Symbol{
+		{LocateCode: 1, Ticker: "COIN", Sector: crypto, BasePrice: 50, TickSize: 0.01, VolatilityMultiplier: 1},
+		{LocateCode: 2, Ticker: "HASH", Sector: crypto, BasePrice: 80, TickSize: 0.01, VolatilityMultiplier: 1},
+		{LocateCode: 3, Ticker: "NEXO", Sector: symbol.SectorTech, BasePrice: 185, TickSize: 0.01, VolatilityMultiplier: 1},
+	}
+	m := NewMarketEngine(NewRNG(42), syms)
+
+	m.GenerateSectorShocks()
+	if _, ok := m.sectorShocks[crypto]; !ok {
+		t.Fatal("no shock generated for the Crypto sector")
+	}
+
+	prev := [3]float64{50, 80, 185}
+	var same, cross float64
+	moved := false
+	for range 10000 {
+		m.GenerateSectorShocks()
+		var r [3]float64
+		for i := range syms {
+			p := m.Tick(syms[i].LocateCode)
+			moved = moved || p != prev[i]
+			r[i] = (p - prev[i]) / prev[i]
+			prev[i] = p
+		}
+		same += r[0] * r[1]
+		cross += r[0] * r[2]
+	}
+	if !moved {
+		t.Fatal("custom-sector symbols never ticked")
+	}
+	if same <= cross {
+		t.Errorf("same custom-sector corr (%e) should exceed cross-sector corr (%e)", same, cross)
+	}
+}
+
+// TestMarketFactorCorrelatesAllSymbols checks that a heavy market-factor
+// weight makes returns positively correlated across sectors, where without it
+// cross-sector pairs are roughly uncorrelated.
+func TestMarketFactorCorrelatesAllSymbols(t *testing.T) {
+	syms := symbol.AllSymbols()
+
+	avgCorr := func(weight float64) float64 {
+		m := NewMarketEngine(NewRNG(7), syms)
+		m.SetMarketWeight(weight)
+
+		n := 5000
+		prev := m.AllPrices()
+		returns := make([][]float64, len(syms))
+		for i := 0; i < n; i++ {
+			m.GenerateSectorShocks()
+			for j, s := range syms {
+				p := m.Tick(s.LocateCode)
+				returns[j] = append(returns[j], math.Log(p/prev[s.LocateCode]))
+				prev[s.LocateCode] = p
+			}
+		}
+
+		sum, pairs := 0.0, 0
+		for a := range syms {
+			for b := a + 1; b < len(syms); b++ {
+				if syms[a].Sector == syms[b].Sector {
+					continue // isolate the market factor from sector co-movement
+				}
+				sum += pearson(returns[a], returns[b])
+				pairs++
+			}
+		}
+		return sum / float64(pairs)
+	}
+
+	base := avgCorr(0)
+	heavy := avgCorr(0.9)
+	if heavy < 0.3 {
+		t.Errorf("average cross-sector correlation with weight 0.9 = %.3f, want >= 0.3", heavy)
+	}
+	if heavy <= base {
+		t.Errorf("market factor should raise correlation: weight 0.9 = %.3f, weight 0 = %.3f", heavy, base)
+	}
+}
+
+// pearson returns the correlation coefficient of two equal-length series,
+// or 0 when either is constant.
+func pearson(x, y []float64) float64 {
+	n := float64(len(x))
+	var sx, sy float64
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+	}
+	mx, my := sx/n, sy/n
+	var cov, vx, vy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		cov += dx * dy
+		vx += dx * dx
+		vy += dy * dy
+	}
+	if vx == 0 || vy == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(vx*vy)
+}
+
 func TestSetPrice(t *testing.T) {
 	m, _ := newTestMarket()
 	m.SetPrice(1, 999.99)
@@ -155,3 +309,159 @@ func TestTickReturnsSameAsPrice(t *testing.T) {
 		t.Fatalf("Tick returned %f but Price returned %f", tickResult, priceResult)
 	}
 }
+
+// TestGARCHClustersAfterShock feeds a GARCH engine a large shock and checks
+// the following ticks move more than the same seeded path under constant
+// volatility.
+// TestSessionOpenDrivesChange checks that rolling into a session records the
+// price at its start as the open, that Change measures from it, and that
+// restoring the opens (a restart within the session) keeps that reference.
+func TestSessionOpenDrivesChange(t *testing.T) {
+	m, _ := newTestMarket()
+	start := time.Date(2026, 1, 2, 13, 30, 0, 0, time.UTC)
+	m.SetPrice(1, 200.00)
+	if !m.RollSession(start) {
+		t.Fatal("first RollSession did not start a session")
+	}
+	if m.RollSession(start) {
+		t.Error("rolling to the same start began another session")
+	}
+	if got := m.Open(1); got != 200.00 {
+		t.Fatalf("open = %v, want the 200.00 price at session start", got)
+	}
+
+	m.SetPrice(1, 210.00)
+	if change, pct := m.Change(1); change != 10.00 || math.Abs(pct-5.0) > 1e-9 {
+		t.Errorf("Change = %v, %v%%; want 10.00, 5%%", change, pct)
+	}
+
+	savedStart, opens := m.SessionOpens()
+	restarted, _ := newTestMarket()
+	restarted.SetPrice(1, 210.00)
+	restarted.RestoreSessionOpens(savedStart, opens)
+	if restarted.RollSession(start) {
+		t.Error("restored session rolled again at the same start")
+	}
+	if _, pct := restarted.Change(1); math.Abs(pct-5.0) > 1e-9 {
+		t.Errorf("changePct after restore = %v, want 5", pct)
+	}
+
+	if !restarted.RollSession(start.Add(24 * time.Hour)) {
+		t.Fatal("next day's session did not roll")
+	}
+	if got := restarted.Open(1); got != 210.00 {
+		t.Errorf("next session open = %v, want 210.00", got)
+	}
+}
+
+func TestGARCHClustersAfterShock(t *testing.T) {
+	constant, _ := newTestMarket()
+	garch, _ := newTestMarket()
+	if err := garch.SetGARCH(0.10, 0.85); err != nil {
+		t.Fatalf("SetGARCH: %v", err)
+	}
+	const locate = 3 // FLUX: high price, so tick rounding barely mutes returns
+	garch.mu.Lock()
+	garch.updateVariance(locate, 8) // an 8-sigma move on the previous tick
+	garch.mu.Unlock()
+
+	realized := func(m *MarketEngine) float64 {
+		var sum float64
+		prev := m.Price(locate)
+		for range 20 {
+			m.GenerateSectorShocks()
+			p := m.Tick(locate)
+			r := math.Log(p / prev)
+			sum += r * r
+			prev = p
+		}
+		return math.Sqrt(sum / 20)
+	}
+	c, g := realized(constant), realized(garch)
+	if g < 1.3*c {
+		t.Errorf("realized vol after shock = %.3g, constant model %.3g; want clearly elevated", g, c)
+	}
+}
+
+func TestSetGARCHRejectsNonStationary(t *testing.T) {
+	m, _ := newTestMarket()
+	for _, p := range [][2]float64{{-0.1, 0.5}, {0.1, -0.5}, {0.2, 0.8}, {0.5, 0.6}} {
+		if err := m.SetGARCH(p[0], p[1]); err == nil {
+			t.Errorf("SetGARCH(%v, %v) succeeded, want error", p[0], p[1])
+		}
+	}
+}
+
+// TestTickJitterBreaksFlatTape ticks a cheap, calm symbol whose GBM steps are
+// far below its tick, so without jitter the price barely moves.
+func TestTickJitterBreaksFlatTape(t *testing.T) {
+	calm := []symbol.Symbol{{LocateCode: 1, Ticker: "CALM", Sector: symbol.SectorTech, BasePrice: 5, TickSize: 0.01, VolatilityMultiplier: 0.5}}
+	distinct := func(jitter float64) int {
+		m := NewMarketEngine(NewRNG(42), calm)
+		if err := m.SetTickJitter(1, jitter); err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[float64]bool)
+		for range 2000 {
+			m.GenerateSectorShocks()
+			p := m.Tick(1)
+			if p < 0.01 {
+				t.Fatalf("jitter %v: price %v below one tick", jitter, p)
+			}
+			seen[p] = true
+		}
+		return len(seen)
+	}
+
+	if n := distinct(0); n > 3 {
+		t.Fatalf("without jitter: %d distinct prices, want a near-flat tape", n)
+	}
+	if n := distinct(0.5); n < 10 {
+		t.Errorf("with jitter 0.5: %d distinct prices, want at least 10", n)
+	}
+}
+
+// TestRoundingDoesNotDrift ticks a calm symbol whose GBM steps are far below
+// its tick: floor or ceil applied to the previous snapped price would walk it
+// down or up a tick per tick, so each mode must track the nearest-rounded run.
+func TestRoundingDoesNotDrift(t *testing.T) {
+	calm := []symbol.Symbol{{LocateCode: 1, Ticker: "CALM", Sector: symbol.SectorTech, BasePrice: 5, TickSize: 0.01, VolatilityMultiplier: 0.5}}
+	run := func(r symbol.Rounding) []float64 {
+		m := NewMarketEngine(NewRNG(42), calm)
+		m.SetRounding(r)
+		prices := make([]float64, 20000)
+		for i := range prices {
+			m.GenerateSectorShocks()
+			prices[i] = m.Tick(1)
+		}
+		return prices
+	}
+
+	nearest := run(symbol.RoundNearest)
+	for _, r := range []symbol.Rounding{symbol.RoundFloor, symbol.RoundCeil} {
+		for i, p := range run(r) {
+			if math.Abs(p-nearest[i]) > 0.01+1e-9 {
+				t.Fatalf("rounding %v: tick %d price %v, more than a tick from nearest %v", r, i, p, nearest[i])
+			}
+		}
+	}
+}
+
+func TestParseTickJitter(t *testing.T) {
+	got, err := ParseTickJitter("NEXO=0.3, ACME=1")
+	if err != nil {
+		t.Fatalf("ParseTickJitter: %v", err)
+	}
+	if len(got) != 2 || got["NEXO"] != 0.3 || got["ACME"] != 1 {
+		t.Errorf("got %v", got)
+	}
+	for _, spec := range []string{"NEXO", "NEXO=1.5", "NEXO=-0.1", "NEXO=x", "=0.5", "NEXO=0.1,NEXO=0.2"} {
+		if _, err := ParseTickJitter(spec); err == nil {
+			t.Errorf("ParseTickJitter(%q) succeeded, want error", spec)
+		}
+	}
+	m, _ := newTestMarket()
+	if m.SetTickJitter(1, 2) == nil || m.SetTickJitter(1, math.NaN()) == nil {
+		t.Error("SetTickJitter accepted a probability outside [0, 1]")
+	}
+}
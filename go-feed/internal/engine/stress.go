@@ -2,6 +2,7 @@ package engine
 
 import (
 	"math"
+	"sync"
 	"time"
 )
 
@@ -54,6 +55,7 @@ func DefaultStressConfig() StressConfig {
 type StressController struct {
 	rng    *RNG
 	config StressConfig
+	clock  Clock
 
 	// Internal state
 	phase        StressPhase
@@ -65,15 +67,35 @@ type StressController struct {
 	t          float64 // time parameter for sine wave
 	tStep      float64 // increment per call
 	randomWalk float64 // additive random component
+
+	// Published after every Tick for readers on other goroutines.
+	mu   sync.Mutex
+	last StressState
+}
+
+// StressState is a snapshot of a controller as of its most recent Tick.
+type StressState struct {
+	Phase     StressPhase
+	Intensity float64
+	Interval  time.Duration
+	Actions   int
 }
 
-// NewStressController creates a new stress controller.
+// NewStressController creates a new stress controller on the real clock.
 func NewStressController(rng *RNG, cfg StressConfig) *StressController {
+	return NewStressControllerWithClock(rng, cfg, RealClock{})
+}
+
+// NewStressControllerWithClock creates a stress controller whose phase timing
+// is measured against clock, so a FakeClock can drive phase transitions
+// deterministically.
+func NewStressControllerWithClock(rng *RNG, cfg StressConfig, clock Clock) *StressController {
 	sc := &StressController{
 		rng:        rng,
 		config:     cfg,
+		clock:      clock,
 		phase:      PhaseCalm,
-		phaseStart: time.Now(),
+		phaseStart: clock.Now(),
 		tStep:      0.01,
 	}
 	sc.phaseDuration = sc.randomDuration(30, 120) // calm lasts 30-120s
@@ -105,7 +127,7 @@ func (sc *StressController) Tick() (interval time.Duration, numActions int) {
 	}
 
 	// Determine phase from intensity
-	now := time.Now()
+	now := sc.clock.Now()
 	elapsed := now.Sub(sc.phaseStart)
 
 	if elapsed >= sc.phaseDuration {
@@ -142,6 +164,10 @@ func (sc *StressController) Tick() (interval time.Duration, numActions int) {
 		interval = time.Millisecond
 	}
 
+	sc.mu.Lock()
+	sc.last = StressState{Phase: sc.phase, Intensity: sc.intensity, Interval: interval, Actions: numActions}
+	sc.mu.Unlock()
+
 	return interval, numActions
 }
 
@@ -155,6 +181,15 @@ func (sc *StressController) Intensity() float64 {
 	return sc.intensity
 }
 
+// State returns the phase, intensity, interval and action count published by
+// the most recent Tick (zero values before the first). Unlike Phase and
+// Intensity it is safe to call while another goroutine is ticking.
+func (sc *StressController) State() StressState {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.last
+}
+
 func (sc *StressController) updatePhase() {
 	if sc.intensity < 0.3 {
 		sc.phase = PhaseCalm
@@ -85,12 +85,13 @@ func TestActionCountsByPhase(t *testing.T) {
 func TestPhaseTransitions(t *testing.T) {
 	rng := NewRNG(42)
 	cfg := DefaultStressConfig()
-	sc := NewStressController(rng, cfg)
-	// Override phase duration to force transitions
-	sc.phaseDuration = time.Nanosecond
+	clk := NewFakeClock(time.Unix(0, 0))
+	sc := NewStressControllerWithClock(rng, cfg, clk)
 
 	seen := make(map[StressPhase]bool)
 	for i := 0; i < 100000; i++ {
+		// Step past the longest phase duration so every tick re-evaluates the phase.
+		clk.Advance(2 * time.Minute)
 		sc.Tick()
 		seen[sc.Phase()] = true
 		if len(seen) == 3 {
@@ -107,3 +108,41 @@ func TestNewControllerStartsCalm(t *testing.T) {
 		t.Fatalf("initial phase = %s, want calm", sc.Phase())
 	}
 }
+
+// TestFakeClockDeterministicPhases drives two identically-seeded controllers
+// with fake clocks and asserts they walk the same phase sequence, without any
+// real sleeping.
+func TestFakeClockDeterministicPhases(t *testing.T) {
+	run := func() []StressPhase {
+		clk := NewFakeClock(time.Unix(0, 0))
+		sc := NewStressControllerWithClock(NewRNG(7), DefaultStressConfig(), clk)
+		var phases []StressPhase
+		for i := 0; i < 2000; i++ {
+			interval, _ := sc.Tick()
+			clk.Sleep(interval)
+			if i%50 == 0 {
+				clk.Advance(time.Minute)
+			}
+			phases = append(phases, sc.Phase())
+		}
+		return phases
+	}
+
+	start := time.Now()
+	a, b := run(), run()
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("fake-clock run took %v; expected no real sleeping", time.Since(start))
+	}
+	transitions := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("phase diverged at tick %d: %s vs %s", i, a[i], b[i])
+		}
+		if i > 0 && a[i] != a[i-1] {
+			transitions++
+		}
+	}
+	if transitions == 0 {
+		t.Fatal("expected at least one phase transition under the fake clock")
+	}
+}
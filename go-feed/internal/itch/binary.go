@@ -10,41 +10,51 @@ import (
 // EncodeBinary encodes a Message into ITCH 5.0 binary format.
 // Returns the encoded bytes including the 2-byte length prefix.
 func EncodeBinary(m *Message) []byte {
-	var body []byte
+	body := encodeBody(m)
+	if body == nil {
+		return nil
+	}
+
+	// 2-byte length prefix + body
+	frame := make([]byte, 2+len(body))
+	binary.BigEndian.PutUint16(frame[0:2], uint16(len(body)))
+	copy(frame[2:], body)
+	return frame
+}
 
+// encodeBody encodes m's ITCH message body (type byte first), or returns nil
+// for an unsupported type.
+func encodeBody(m *Message) []byte {
 	switch m.Type {
 	case MsgSystemEvent:
-		body = encodeSystemEvent(m)
+		return encodeSystemEvent(m)
 	case MsgStockDirectory:
-		body = encodeStockDirectory(m)
+		return encodeStockDirectory(m)
 	case MsgStockTradingAction:
-		body = encodeStockTradingAction(m)
+		return encodeStockTradingAction(m)
 	case MsgAddOrder:
-		body = encodeAddOrder(m)
+		return encodeAddOrder(m)
 	case MsgAddOrderMPID:
-		body = encodeAddOrderMPID(m)
+		return encodeAddOrderMPID(m)
 	case MsgOrderExecuted:
-		body = encodeOrderExecuted(m)
+		return encodeOrderExecuted(m)
 	case MsgOrderCancel:
-		body = encodeOrderCancel(m)
+		return encodeOrderCancel(m)
 	case MsgOrderDelete:
-		body = encodeOrderDelete(m)
+		return encodeOrderDelete(m)
 	case MsgOrderReplace:
-		body = encodeOrderReplace(m)
+		return encodeOrderReplace(m)
 	case MsgTrade:
-		body = encodeTrade(m)
+		return encodeTrade(m)
+	case MsgCrossTrade:
+		return encodeCrossTrade(m)
+	case MsgNOII:
+		return encodeNOII(m)
 	default:
 		return nil
 	}
-
-	// 2-byte length prefix + body
-	frame := make([]byte, 2+len(body))
-	binary.BigEndian.PutUint16(frame[0:2], uint16(len(body)))
-	copy(frame[2:], body)
-	return frame
 }
 
-// putTimestamp writes a 6-byte nanosecond timestamp.
 func putTimestamp(buf []byte, nanos int64) {
 	// 6 bytes big-endian
 	buf[0] = byte(nanos >> 40)
@@ -227,3 +237,45 @@ func encodeTrade(m *Message) []byte {
 	binary.BigEndian.PutUint64(buf[36:44], m.MatchNumber)
 	return buf
 }
+
+// Cross Trade (40 bytes)
+// Type(1) + StockLocate(2) + TrackingNum(2) + Timestamp(6) + Shares(8) +
+// Stock(8) + CrossPrice(4) + MatchNumber(8) + CrossType(1)
+func encodeCrossTrade(m *Message) []byte {
+	buf := make([]byte, 40)
+	buf[0] = byte(m.Type)
+	binary.BigEndian.PutUint16(buf[1:3], m.StockLocate)
+	binary.BigEndian.PutUint16(buf[3:5], m.TrackingNum)
+	putTimestamp(buf[5:11], m.Timestamp)
+	binary.BigEndian.PutUint64(buf[11:19], uint64(m.Shares))
+	stock := PadStock(m.Stock)
+	copy(buf[19:27], stock[:])
+	binary.BigEndian.PutUint32(buf[27:31], Price4(m.Price))
+	binary.BigEndian.PutUint64(buf[31:39], m.MatchNumber)
+	buf[39] = m.CrossType
+	return buf
+}
+
+// Net Order Imbalance Indicator (50 bytes)
+// Type(1) + StockLocate(2) + TrackingNum(2) + Timestamp(6) + PairedShares(8) +
+// ImbalanceShares(8) + ImbalanceDirection(1) + Stock(8) + FarPrice(4) +
+// NearPrice(4) + CurrentReferencePrice(4) + CrossType(1) +
+// PriceVariationIndicator(1)
+func encodeNOII(m *Message) []byte {
+	buf := make([]byte, 50)
+	buf[0] = byte(m.Type)
+	binary.BigEndian.PutUint16(buf[1:3], m.StockLocate)
+	binary.BigEndian.PutUint16(buf[3:5], m.TrackingNum)
+	putTimestamp(buf[5:11], m.Timestamp)
+	binary.BigEndian.PutUint64(buf[11:19], m.PairedShares)
+	binary.BigEndian.PutUint64(buf[19:27], m.ImbalanceShares)
+	buf[27] = m.ImbalanceDirection
+	stock := PadStock(m.Stock)
+	copy(buf[28:36], stock[:])
+	binary.BigEndian.PutUint32(buf[36:40], Price4(m.FarPrice))
+	binary.BigEndian.PutUint32(buf[40:44], Price4(m.NearPrice))
+	binary.BigEndian.PutUint32(buf[44:48], Price4(m.Price))
+	buf[48] = m.CrossType
+	buf[49] = m.PriceVariation
+	return buf
+}
@@ -151,6 +151,24 @@ func TestEncodeBinaryTrade(t *testing.T) {
 	}
 }
 
+func TestEncodeBinaryCrossTradeAndNOII(t *testing.T) {
+	for _, c := range []struct {
+		m    *Message
+		want uint16
+	}{
+		{&Message{Type: MsgCrossTrade, StockLocate: 1, Shares: 5000, Stock: "NEXO", Price: 185.1, MatchNumber: 9, CrossType: CrossOpening}, 40},
+		{&Message{Type: MsgNOII, StockLocate: 1, Stock: "NEXO", ImbalanceDirection: ImbalanceNone, CrossType: CrossOpening}, 50},
+	} {
+		data := EncodeBinary(c.m)
+		if data == nil {
+			t.Fatalf("EncodeBinary returned nil for %c", c.m.Type)
+		}
+		if bodyLen := binary.BigEndian.Uint16(data[0:2]); bodyLen != c.want {
+			t.Errorf("%c body length = %d, want %d", c.m.Type, bodyLen, c.want)
+		}
+	}
+}
+
 func TestEncodeBinaryUnknownType(t *testing.T) {
 	m := &Message{Type: MsgType('Z')}
 	data := EncodeBinary(m)
@@ -0,0 +1,150 @@
+package itch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// Binary ITCH 5.0 decoder — the inverse of EncodeBinary.
+
+// bodySize is the encoded body size of each supported message type.
+var bodySize = map[MsgType]int{
+	MsgSystemEvent:        12,
+	MsgStockDirectory:     39,
+	MsgStockTradingAction: 25,
+	MsgAddOrder:           36,
+	MsgAddOrderMPID:       40,
+	MsgOrderExecuted:      31,
+	MsgOrderCancel:        23,
+	MsgOrderDelete:        19,
+	MsgOrderReplace:       35,
+	MsgTrade:              44,
+	MsgCrossTrade:         40,
+	MsgNOII:               50,
+}
+
+// DecodeBinary decodes one frame produced by EncodeBinary: a 2-byte length
+// prefix followed by exactly that many body bytes. Stock and MPID come back
+// with their space padding trimmed; fields a message type does not carry on
+// the wire are left zero.
+func DecodeBinary(frame []byte) (Message, error) {
+	if len(frame) < 3 {
+		return Message{}, fmt.Errorf("itch: frame too short (%d bytes)", len(frame))
+	}
+	n := int(binary.BigEndian.Uint16(frame[0:2]))
+	if n != len(frame)-2 {
+		return Message{}, fmt.Errorf("itch: length prefix %d does not match %d-byte body", n, len(frame)-2)
+	}
+	return decodeBody(frame[2:])
+}
+
+func decodeBody(b []byte) (Message, error) {
+	t := MsgType(b[0])
+	want, ok := bodySize[t]
+	if !ok {
+		return Message{}, fmt.Errorf("itch: unsupported message type: %c", b[0])
+	}
+	if len(b) != want {
+		return Message{}, fmt.Errorf("itch: %c message is %d bytes, want %d", b[0], len(b), want)
+	}
+
+	m := Message{
+		Type:        t,
+		StockLocate: binary.BigEndian.Uint16(b[1:3]),
+		TrackingNum: binary.BigEndian.Uint16(b[3:5]),
+		Timestamp:   readTimestamp(b[5:11]),
+	}
+
+	switch t {
+	case MsgSystemEvent:
+		m.EventCode = b[11]
+
+	case MsgStockDirectory:
+		m.Stock = readPadded(b[11:19])
+		m.MarketCategory = b[19]
+		m.FinancialStatus = b[20]
+		m.RoundLotSize = int32(binary.BigEndian.Uint32(b[21:25]))
+		m.RoundLotsOnly = b[25]
+		m.IssueClassification = b[26]
+		copy(m.IssueSubType[:], b[27:29])
+		m.Authenticity = b[29]
+		m.ShortSaleThreshold = b[30]
+		m.IPOFlag = b[31]
+		m.LULDRefPriceTier = b[32]
+		m.ETPFlag = b[33]
+		m.ETPLeverageFactor = int32(binary.BigEndian.Uint32(b[34:38]))
+		m.InverseIndicator = b[38]
+
+	case MsgStockTradingAction:
+		m.Stock = readPadded(b[11:19])
+		m.TradingState = b[19]
+		m.Reserved = b[20]
+
+	case MsgAddOrder, MsgAddOrderMPID:
+		m.OrderRef = binary.BigEndian.Uint64(b[11:19])
+		m.Side = b[19]
+		m.Shares = int32(binary.BigEndian.Uint32(b[20:24]))
+		m.Stock = readPadded(b[24:32])
+		m.Price = Price4ToFloat(binary.BigEndian.Uint32(b[32:36]))
+		if t == MsgAddOrderMPID {
+			m.MPID = readPadded(b[36:40])
+		}
+
+	case MsgOrderExecuted:
+		m.OrderRef = binary.BigEndian.Uint64(b[11:19])
+		m.Shares = int32(binary.BigEndian.Uint32(b[19:23]))
+		m.MatchNumber = binary.BigEndian.Uint64(b[23:31])
+
+	case MsgOrderCancel:
+		m.OrderRef = binary.BigEndian.Uint64(b[11:19])
+		m.Shares = int32(binary.BigEndian.Uint32(b[19:23]))
+
+	case MsgOrderDelete:
+		m.OrderRef = binary.BigEndian.Uint64(b[11:19])
+
+	case MsgOrderReplace:
+		m.OrigOrderRef = binary.BigEndian.Uint64(b[11:19])
+		m.OrderRef = binary.BigEndian.Uint64(b[19:27])
+		m.Shares = int32(binary.BigEndian.Uint32(b[27:31]))
+		m.Price = Price4ToFloat(binary.BigEndian.Uint32(b[31:35]))
+
+	case MsgTrade:
+		m.OrderRef = binary.BigEndian.Uint64(b[11:19])
+		m.Side = b[19]
+		m.Shares = int32(binary.BigEndian.Uint32(b[20:24]))
+		m.Stock = readPadded(b[24:32])
+		m.Price = Price4ToFloat(binary.BigEndian.Uint32(b[32:36]))
+		m.MatchNumber = binary.BigEndian.Uint64(b[36:44])
+
+	case MsgCrossTrade:
+		m.Shares = int32(binary.BigEndian.Uint64(b[11:19]))
+		m.Stock = readPadded(b[19:27])
+		m.Price = Price4ToFloat(binary.BigEndian.Uint32(b[27:31]))
+		m.MatchNumber = binary.BigEndian.Uint64(b[31:39])
+		m.CrossType = b[39]
+
+	case MsgNOII:
+		m.PairedShares = binary.BigEndian.Uint64(b[11:19])
+		m.ImbalanceShares = binary.BigEndian.Uint64(b[19:27])
+		m.ImbalanceDirection = b[27]
+		m.Stock = readPadded(b[28:36])
+		m.FarPrice = Price4ToFloat(binary.BigEndian.Uint32(b[36:40]))
+		m.NearPrice = Price4ToFloat(binary.BigEndian.Uint32(b[40:44]))
+		m.Price = Price4ToFloat(binary.BigEndian.Uint32(b[44:48]))
+		m.CrossType = b[48]
+		m.PriceVariation = b[49]
+	}
+	return m, nil
+}
+
+// readTimestamp reads a 6-byte big-endian nanosecond timestamp.
+func readTimestamp(buf []byte) int64 {
+	return int64(buf[0])<<40 | int64(buf[1])<<32 | int64(buf[2])<<24 |
+		int64(buf[3])<<16 | int64(buf[4])<<8 | int64(buf[5])
+}
+
+// readPadded trims the right space padding from a fixed-width alpha field.
+func readPadded(buf []byte) string {
+	return strings.TrimRight(string(buf), " ")
+}
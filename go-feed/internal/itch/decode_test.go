@@ -0,0 +1,60 @@
+package itch
+
+import (
+	"strings"
+	"testing"
+)
+
+// sampleMessages returns one fully-populated message of every supported type,
+// with only the fields each type carries on the wire.
+func sampleMessages() []Message {
+	return []Message{
+		{Type: MsgSystemEvent, Timestamp: 1, EventCode: EventStartOfMarket},
+		{Type: MsgStockDirectory, StockLocate: 1, Timestamp: 2, Stock: "NEXO", MarketCategory: 'Q', FinancialStatus: 'N',
+			RoundLotSize: 100, RoundLotsOnly: 'N', IssueClassification: 'C', IssueSubType: [2]byte{'Z', ' '}, Authenticity: 'P',
+			ShortSaleThreshold: 'N', IPOFlag: 'N', LULDRefPriceTier: '1', ETPFlag: 'N', ETPLeverageFactor: 1, InverseIndicator: 'N'},
+		{Type: MsgStockTradingAction, StockLocate: 1, Timestamp: 3, Stock: "NEXO", TradingState: TradingHalted},
+		{Type: MsgAddOrder, StockLocate: 1, Timestamp: 4, OrderRef: 10, Side: 'B', Shares: 300, Stock: "NEXO", Price: 185.25},
+		{Type: MsgAddOrderMPID, StockLocate: 1, Timestamp: 5, OrderRef: 11, Side: 'S', Shares: 100, Stock: "NEXO", Price: 185.26, MPID: "GSCO"},
+		{Type: MsgOrderExecuted, StockLocate: 1, Timestamp: 6, OrderRef: 10, Shares: 100, MatchNumber: 7},
+		{Type: MsgOrderCancel, StockLocate: 1, Timestamp: 7, OrderRef: 10, Shares: 100},
+		{Type: MsgOrderDelete, StockLocate: 1, Timestamp: 8, OrderRef: 10},
+		{Type: MsgOrderReplace, StockLocate: 1, Timestamp: 9, OrigOrderRef: 11, OrderRef: 12, Shares: 200, Price: 185.3},
+		{Type: MsgTrade, StockLocate: 1, Timestamp: 86399999999999, OrderRef: 12, Side: 'B', Shares: 200, Stock: "NEXO", Price: 185.3, MatchNumber: 8},
+		{Type: MsgCrossTrade, StockLocate: 1, Timestamp: 10, Shares: 5000, Stock: "NEXO", Price: 185.1, MatchNumber: 9, CrossType: CrossOpening},
+		{Type: MsgNOII, StockLocate: 1, Timestamp: 11, Stock: "NEXO", PairedShares: 4000, ImbalanceShares: 600, ImbalanceDirection: ImbalanceBuy,
+			FarPrice: 185.2, NearPrice: 185.1, Price: 185, CrossType: CrossOpening, PriceVariation: 'L'},
+	}
+}
+
+func TestDecodeBinaryRoundTrip(t *testing.T) {
+	for _, want := range sampleMessages() {
+		got, err := DecodeBinary(EncodeBinary(&want))
+		if err != nil {
+			t.Fatalf("%c: %v", want.Type, err)
+		}
+		if got != want {
+			t.Errorf("%c round trip:\n got  %+v\n want %+v", want.Type, got, want)
+		}
+	}
+}
+
+func TestDecodeBinaryRejectsBadFrames(t *testing.T) {
+	add := EncodeBinary(&Message{Type: MsgAddOrder, OrderRef: 1, Side: 'B', Shares: 100, Stock: "NEXO", Price: 1})
+
+	unknown := append([]byte(nil), add...)
+	unknown[2] = 'Z'
+
+	short := append([]byte{0, 35}, add[2:37]...) // consistent prefix, truncated add order
+
+	for name, frame := range map[string][]byte{
+		"empty":          nil,
+		"length too big": add[:len(add)-1],
+		"unknown type":   unknown,
+		"truncated body": short,
+	} {
+		if _, err := DecodeBinary(frame); err == nil || !strings.HasPrefix(err.Error(), "itch: ") {
+			t.Errorf("%s: err = %v, want itch error", name, err)
+		}
+	}
+}
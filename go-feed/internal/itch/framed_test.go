@@ -0,0 +1,108 @@
+package itch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func TestEncodeFramedHeader(t *testing.T) {
+	for _, want := range sampleMessages() {
+		frame := EncodeFramed(&want)
+		if len(frame) < FramedHeaderSize {
+			t.Fatalf("%c: frame of %d bytes", want.Type, len(frame))
+		}
+		if frame[0] != 'F' || frame[1] != 'S' || frame[2] != FramedVersion {
+			t.Errorf("%c: header % x, want magic FS version %d", want.Type, frame[:3], FramedVersion)
+		}
+		if MsgType(frame[3]) != want.Type {
+			t.Errorf("%c: header type %q", want.Type, frame[3])
+		}
+		if n := int(binary.BigEndian.Uint16(frame[4:6])); n != bodySize[want.Type] || n != len(frame)-FramedHeaderSize {
+			t.Errorf("%c: header length %d, want %d", want.Type, n, bodySize[want.Type])
+		}
+		// The body is byte-for-byte the ITCH body EncodeBinary carries.
+		if plain := EncodeBinary(&want); !bytes.Equal(frame[FramedHeaderSize:], plain[2:]) {
+			t.Errorf("%c: framed body differs from the ITCH body", want.Type)
+		}
+
+		got, err := DecodeFramed(frame)
+		if err != nil {
+			t.Fatalf("%c: %v", want.Type, err)
+		}
+		if got != want {
+			t.Errorf("%c round trip:\n got  %+v\n want %+v", want.Type, got, want)
+		}
+	}
+}
+
+func TestDecodeFramedRejectsBadHeaders(t *testing.T) {
+	add := EncodeFramed(&Message{Type: MsgAddOrder, OrderRef: 1, Side: 'B', Shares: 100, Stock: "NEXO", Price: 1})
+	corrupt := func(i int, b byte) []byte {
+		f := append([]byte(nil), add...)
+		f[i] = b
+		return f
+	}
+
+	for name, frame := range map[string][]byte{
+		"empty":         nil,
+		"bad magic":     corrupt(0, 'X'),
+		"bad version":   corrupt(2, 9),
+		"type mismatch": corrupt(3, byte(MsgTrade)),
+		"length":        add[:len(add)-1],
+	} {
+		if _, err := DecodeFramed(frame); err == nil || !strings.HasPrefix(err.Error(), "itch: ") {
+			t.Errorf("%s: err = %v, want itch error", name, err)
+		}
+	}
+}
+
+// TestFramedVersion2CarriesLiquidity checks that version 2 frames carry a
+// trade's liquidity flag in the header and decode it back, while the bare
+// ITCH encoding leaves the tracking number alone and drops the flag.
+func TestFramedVersion2CarriesLiquidity(t *testing.T) {
+	msgs := append(sampleMessages(),
+		Message{Type: MsgTrade, StockLocate: 1, TrackingNum: 82, Timestamp: 12, OrderRef: 13, Side: 'S', Shares: 100, Stock: "NEXO",
+			Price: 185.2, MatchNumber: 10, LiquidityFlag: LiquidityRemoved},
+		Message{Type: MsgCrossTrade, StockLocate: 1, Timestamp: 13, Shares: 5000, Stock: "NEXO", Price: 185.1, MatchNumber: 11,
+			CrossType: CrossOpening, LiquidityFlag: LiquidityAuction},
+	)
+	for _, want := range msgs {
+		frame := EncodeFramedVersion(&want, FramedVersion2)
+		if len(frame) < FramedHeaderSize2 || frame[2] != FramedVersion2 || frame[6] != want.LiquidityFlag {
+			t.Fatalf("%c: header % x, want version 2 with liquidity %q", want.Type, frame[:min(len(frame), FramedHeaderSize2)], want.LiquidityFlag)
+		}
+		plain := EncodeBinary(&want)
+		if !bytes.Equal(frame[FramedHeaderSize2:], plain[2:]) {
+			t.Errorf("%c: framed body differs from the ITCH body", want.Type)
+		}
+		got, err := DecodeFramed(frame)
+		if err != nil {
+			t.Fatalf("%c: %v", want.Type, err)
+		}
+		if got != want {
+			t.Errorf("%c round trip:\n got  %+v\n want %+v", want.Type, got, want)
+		}
+
+		if want.LiquidityFlag == 0 {
+			continue
+		}
+		bare, err := DecodeBinary(plain)
+		if err != nil {
+			t.Fatalf("%c: %v", want.Type, err)
+		}
+		if bare.TrackingNum != want.TrackingNum || bare.LiquidityFlag != 0 {
+			t.Errorf("%c bare ITCH: tracking %d, liquidity %q; want tracking %d, unflagged", want.Type, bare.TrackingNum, bare.LiquidityFlag, want.TrackingNum)
+		}
+	}
+
+	add := EncodeFramedVersion(&Message{Type: MsgAddOrder, OrderRef: 1, Side: 'B', Shares: 100, Stock: "NEXO", Price: 1}, FramedVersion2)
+	add[6] = LiquidityRemoved
+	if _, err := DecodeFramed(add); err == nil {
+		t.Error("liquidity flag on an add order decoded without error")
+	}
+	if frame := EncodeFramedVersion(&msgs[0], 9); frame != nil {
+		t.Errorf("framing version 9 encoded % x, want nil", frame)
+	}
+}
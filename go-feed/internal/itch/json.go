@@ -3,22 +3,132 @@ package itch
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
 )
 
 // JSON encoder — human-readable mirror of ITCH binary messages.
-// Prices are formatted as 4-decimal strings, timestamps as int64 nanos.
+// Prices are formatted as 4-decimal strings (or JSON numbers with
+// JSONOptions.NumericPrices), timestamps as int64 nanos (plus a readable
+// "time" with JSONOptions.Timestamps).
 
-// EncodeJSON encodes a Message into JSON bytes.
+// JSON protocol versions. Each version only adds fields to the previous one,
+// so a client that negotiated an older version never sees fields it does not
+// know about.
+const (
+	// JSONVersion1 is the original field set.
+	JSONVersion1 = 1
+	// JSONVersion2 adds "stock" to order-level messages (executed, cancel,
+	// delete, replace) and the execution "price" to order_executed.
+	JSONVersion2 = 2
+	// JSONVersion3 adds "seq", the feed sequence number used to resume a
+	// session, to every sequenced message.
+	JSONVersion3 = 3
+	// JSONVersion4 adds "tickDirection" ("up", "down" or "zero" against the
+	// previous trade) to classified trades.
+	JSONVersion4 = 4
+	// JSONVersion5 adds "block": true to trades at or above the block trade
+	// size.
+	JSONVersion5 = 5
+	// JSONVersion6 adds "liquidity" ("added", "removed" or "auction") to
+	// flagged trades and cross trades.
+	JSONVersion6 = 6
+
+	// LatestJSONVersion is the newest version the encoder supports.
+	LatestJSONVersion = JSONVersion6
+)
+
+// EncodeJSON encodes a Message into JSON bytes using JSONVersion1.
 func EncodeJSON(m *Message) ([]byte, error) {
-	obj := msgToMap(m)
+	return EncodeJSONVersion(m, JSONVersion1)
+}
+
+// EncodeJSONVersion encodes a Message into JSON bytes with the field set of
+// the given protocol version. Versions above LatestJSONVersion encode as the
+// latest.
+func EncodeJSONVersion(m *Message, version int) ([]byte, error) {
+	return EncodeJSONWith(m, JSONOptions{Version: version})
+}
+
+// JSONOptions selects how EncodeJSONWith renders a message. It is comparable,
+// so encoders can cache one encoding per distinct set of options.
+type JSONOptions struct {
+	Version       int             // protocol version (JSONVersion1 if zero)
+	NumericPrices bool            // prices as JSON numbers instead of 4-decimal strings
+	Timestamps    TimestampFormat // readable "time" added beside "timestamp"
+}
+
+// EncodeJSONWith encodes a Message into JSON bytes as opts selects.
+func EncodeJSONWith(m *Message, opts JSONOptions) ([]byte, error) {
+	obj := msgToMap(m, opts.NumericPrices)
 	if obj == nil {
 		return nil, fmt.Errorf("unsupported message type: %c", m.Type)
 	}
+	if opts.Version >= JSONVersion2 {
+		addV2Fields(obj, m, opts.NumericPrices)
+	}
+	if opts.Version >= JSONVersion3 && m.Seq != 0 {
+		obj["seq"] = m.Seq
+	}
+	if opts.Version >= JSONVersion4 && m.Type == MsgTrade {
+		if dir := tickDirectionName(m.TickDirection); dir != "" {
+			obj["tickDirection"] = dir
+		}
+	}
+	if opts.Version >= JSONVersion5 && m.Type == MsgTrade && m.Block {
+		obj["block"] = true
+	}
+	if opts.Version >= JSONVersion6 && (m.Type == MsgTrade || m.Type == MsgCrossTrade) {
+		if flag := liquidityName(m.LiquidityFlag); flag != "" {
+			obj["liquidity"] = flag
+		}
+	}
+	if ts := formatTime(m.Timestamp, opts.Timestamps); ts != "" {
+		obj["time"] = ts
+	}
 	return json.Marshal(obj)
 }
 
-func msgToMap(m *Message) map[string]any {
+// tickDirectionName is the JSON name of a TickUp/TickDown/TickZero code, or
+// "" for an unclassified trade.
+func tickDirectionName(dir byte) string {
+	switch dir {
+	case TickUp:
+		return "up"
+	case TickDown:
+		return "down"
+	case TickZero:
+		return "zero"
+	}
+	return ""
+}
+
+// liquidityName is the JSON name of a LiquidityAdded/Removed/Auction flag,
+// or "" for an unflagged trade.
+func liquidityName(flag byte) string {
+	switch flag {
+	case LiquidityAdded:
+		return "added"
+	case LiquidityRemoved:
+		return "removed"
+	case LiquidityAuction:
+		return "auction"
+	}
+	return ""
+}
+
+// addV2Fields extends a v1 object with the fields introduced in JSONVersion2.
+func addV2Fields(obj map[string]any, m *Message, numeric bool) {
+	switch m.Type {
+	case MsgOrderExecuted:
+		obj["stock"] = strings.TrimSpace(m.Stock)
+		obj["price"] = PriceValue(m.Price, numeric)
+	case MsgOrderCancel, MsgOrderDelete, MsgOrderReplace:
+		obj["stock"] = strings.TrimSpace(m.Stock)
+	}
+}
+
+func msgToMap(m *Message, numeric bool) map[string]any {
 	switch m.Type {
 	case MsgSystemEvent:
 		return map[string]any{
@@ -58,7 +168,7 @@ func msgToMap(m *Message) map[string]any {
 			"orderRef":    m.OrderRef,
 			"side":        string([]byte{m.Side}),
 			"shares":      m.Shares,
-			"price":       formatPrice(m.Price),
+			"price":       PriceValue(m.Price, numeric),
 		}
 
 	case MsgAddOrderMPID:
@@ -70,7 +180,7 @@ func msgToMap(m *Message) map[string]any {
 			"orderRef":    m.OrderRef,
 			"side":        string([]byte{m.Side}),
 			"shares":      m.Shares,
-			"price":       formatPrice(m.Price),
+			"price":       PriceValue(m.Price, numeric),
 			"mpid":        strings.TrimSpace(m.MPID),
 		}
 
@@ -109,7 +219,7 @@ func msgToMap(m *Message) map[string]any {
 			"origOrderRef": m.OrigOrderRef,
 			"orderRef":     m.OrderRef,
 			"shares":       m.Shares,
-			"price":        formatPrice(m.Price),
+			"price":        PriceValue(m.Price, numeric),
 		}
 
 	case MsgTrade:
@@ -121,9 +231,37 @@ func msgToMap(m *Message) map[string]any {
 			"side":        string([]byte{m.Side}),
 			"shares":      m.Shares,
 			"stock":       strings.TrimSpace(m.Stock),
-			"price":       formatPrice(m.Price),
+			"price":       PriceValue(m.Price, numeric),
 			"matchNumber": m.MatchNumber,
 		}
+
+	case MsgCrossTrade:
+		return map[string]any{
+			"type":        "cross_trade",
+			"timestamp":   m.Timestamp,
+			"stockLocate": m.StockLocate,
+			"stock":       strings.TrimSpace(m.Stock),
+			"shares":      m.Shares,
+			"price":       PriceValue(m.Price, numeric),
+			"matchNumber": m.MatchNumber,
+			"crossType":   string([]byte{m.CrossType}),
+		}
+
+	case MsgNOII:
+		return map[string]any{
+			"type":               "noii",
+			"timestamp":          m.Timestamp,
+			"stockLocate":        m.StockLocate,
+			"stock":              strings.TrimSpace(m.Stock),
+			"pairedShares":       m.PairedShares,
+			"imbalanceShares":    m.ImbalanceShares,
+			"imbalanceDirection": string([]byte{m.ImbalanceDirection}),
+			"farPrice":           PriceValue(m.FarPrice, numeric),
+			"nearPrice":          PriceValue(m.NearPrice, numeric),
+			"referencePrice":     PriceValue(m.Price, numeric),
+			"crossType":          string([]byte{m.CrossType}),
+			"priceVariation":     string([]byte{m.PriceVariation}),
+		}
 	}
 	return nil
 }
@@ -131,3 +269,13 @@ func msgToMap(m *Message) map[string]any {
 func formatPrice(price float64) string {
 	return fmt.Sprintf("%.4f", price)
 }
+
+// PriceValue renders price as the 4-decimal string, or with numeric as a
+// number rounded to the same 4 decimals, so float noise (184.91999...) never
+// reaches the wire.
+func PriceValue(price float64, numeric bool) any {
+	if numeric {
+		return math.Round(price*10000) / 10000
+	}
+	return formatPrice(price)
+}
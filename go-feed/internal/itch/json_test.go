@@ -8,9 +8,14 @@ import (
 
 func decodeJSON(t *testing.T, m *Message) map[string]any {
 	t.Helper()
-	data, err := EncodeJSON(m)
+	return decodeJSONVersion(t, m, JSONVersion1)
+}
+
+func decodeJSONVersion(t *testing.T, m *Message, version int) map[string]any {
+	t.Helper()
+	data, err := EncodeJSONVersion(m, version)
 	if err != nil {
-		t.Fatalf("EncodeJSON error: %v", err)
+		t.Fatalf("EncodeJSONVersion(%d) error: %v", version, err)
 	}
 	var obj map[string]any
 	if err := json.Unmarshal(data, &obj); err != nil {
@@ -118,6 +123,19 @@ func TestEncodeJSONTrade(t *testing.T) {
 	}
 }
 
+func TestEncodeJSONCrossTradeAndNOII(t *testing.T) {
+	obj := decodeJSON(t, &Message{Type: MsgCrossTrade, StockLocate: 1, Shares: 5000, Stock: "NEXO", Price: 185.1, MatchNumber: 9, CrossType: CrossOpening})
+	if obj["type"] != "cross_trade" || obj["crossType"] != "O" || obj["price"] != "185.1000" {
+		t.Errorf("cross trade = %v", obj)
+	}
+	obj = decodeJSON(t, &Message{Type: MsgNOII, StockLocate: 1, Stock: "NEXO", PairedShares: 4000, ImbalanceShares: 600,
+		ImbalanceDirection: ImbalanceSell, NearPrice: 185.1, Price: 185, CrossType: CrossOpening, PriceVariation: 'L'})
+	if obj["type"] != "noii" || obj["imbalanceDirection"] != "S" || obj["nearPrice"] != "185.1000" ||
+		obj["referencePrice"] != "185.0000" || obj["pairedShares"] != float64(4000) {
+		t.Errorf("noii = %v", obj)
+	}
+}
+
 func TestEncodeJSONUnsupportedType(t *testing.T) {
 	_, err := EncodeJSON(&Message{Type: MsgType('Z')})
 	if err == nil {
@@ -136,3 +154,94 @@ func TestEncodeJSONPriceFormat(t *testing.T) {
 		t.Fatalf("price = %s, want 1.0000", price)
 	}
 }
+
+func TestEncodeJSONSeqFromVersion3(t *testing.T) {
+	m := &Message{Type: MsgOrderDelete, StockLocate: 1, Stock: "NEXO", OrderRef: 7, Seq: 42}
+	for v, want := range map[int]bool{JSONVersion2: false, JSONVersion3: true} {
+		data, err := EncodeJSONVersion(m, v)
+		if err != nil {
+			t.Fatalf("v%d: %v", v, err)
+		}
+		if got := strings.Contains(string(data), `"seq":42`); got != want {
+			t.Errorf("v%d: seq present = %v, want %v (%s)", v, got, want, data)
+		}
+	}
+}
+
+func TestEncodeJSONTickDirectionFromVersion4(t *testing.T) {
+	m := &Message{Type: MsgTrade, StockLocate: 1, Stock: "NEXO", Side: 'B', Shares: 100, Price: 185, MatchNumber: 9, TickDirection: TickDown}
+	for v, want := range map[int]bool{JSONVersion3: false, JSONVersion4: true} {
+		obj := decodeJSONVersion(t, m, v)
+		if got, ok := obj["tickDirection"]; ok != want || want && got != "down" {
+			t.Errorf("v%d: tickDirection = %v (present %v), want present %v as \"down\"", v, got, ok, want)
+		}
+	}
+
+	m.TickDirection = 0
+	if obj := decodeJSONVersion(t, m, JSONVersion4); obj["tickDirection"] != nil {
+		t.Errorf("unclassified trade: tickDirection = %v, want absent", obj["tickDirection"])
+	}
+}
+
+func TestEncodeJSONBlockFromVersion5(t *testing.T) {
+	m := &Message{Type: MsgTrade, StockLocate: 1, Stock: "NEXO", Side: 'B', Shares: 10000, Price: 185, MatchNumber: 9, Block: true}
+	for v, want := range map[int]bool{JSONVersion4: false, JSONVersion5: true} {
+		if got, ok := decodeJSONVersion(t, m, v)["block"]; ok != want || want && got != true {
+			t.Errorf("v%d: block = %v (present %v), want present %v", v, got, ok, want)
+		}
+	}
+
+	m.Block = false
+	if obj := decodeJSONVersion(t, m, JSONVersion5); obj["block"] != nil {
+		t.Errorf("ordinary trade: block = %v, want absent", obj["block"])
+	}
+}
+
+func TestEncodeJSONLiquidityFromVersion6(t *testing.T) {
+	trade := &Message{Type: MsgTrade, StockLocate: 1, Stock: "NEXO", Side: 'B', Shares: 100, Price: 185, MatchNumber: 9, LiquidityFlag: LiquidityRemoved}
+	cross := &Message{Type: MsgCrossTrade, StockLocate: 1, Stock: "NEXO", Shares: 5000, Price: 185, MatchNumber: 10, CrossType: CrossOpening, LiquidityFlag: LiquidityAuction}
+	for _, tc := range []struct {
+		m    *Message
+		want string
+	}{{trade, "removed"}, {cross, "auction"}} {
+		if obj := decodeJSONVersion(t, tc.m, JSONVersion5); obj["liquidity"] != nil {
+			t.Errorf("%c v5: liquidity = %v, want absent", tc.m.Type, obj["liquidity"])
+		}
+		if got := decodeJSONVersion(t, tc.m, JSONVersion6)["liquidity"]; got != tc.want {
+			t.Errorf("%c v6: liquidity = %v, want %q", tc.m.Type, got, tc.want)
+		}
+	}
+
+	trade.LiquidityFlag = 0
+	if obj := decodeJSONVersion(t, trade, JSONVersion6); obj["liquidity"] != nil {
+		t.Errorf("unflagged trade: liquidity = %v, want absent", obj["liquidity"])
+	}
+}
+
+func TestEncodeJSONNumericPrices(t *testing.T) {
+	m := &Message{Type: MsgTrade, StockLocate: 1, Stock: "NEXO", Side: 'B', Shares: 100, Price: 184.92 - 1e-12, MatchNumber: 9}
+	for _, tc := range []struct {
+		numeric bool
+		want    any
+	}{
+		{false, "184.9200"},
+		{true, 184.92},
+	} {
+		data, err := EncodeJSONWith(m, JSONOptions{Version: JSONVersion1, NumericPrices: tc.numeric})
+		if err != nil {
+			t.Fatalf("EncodeJSONWith: %v", err)
+		}
+		var obj map[string]any
+		if err := json.Unmarshal(data, &obj); err != nil {
+			t.Fatalf("json.Unmarshal: %v", err)
+		}
+		if obj["price"] != tc.want {
+			t.Errorf("numeric=%v: price = %#v, want %#v", tc.numeric, obj["price"], tc.want)
+		}
+	}
+
+	data, _ := EncodeJSONWith(&Message{Type: MsgOrderExecuted, Price: 10.5}, JSONOptions{Version: JSONVersion2, NumericPrices: true})
+	if !strings.Contains(string(data), `"price":10.5`) {
+		t.Errorf("v2 order_executed = %s, want numeric price 10.5", data)
+	}
+}
@@ -1,6 +1,9 @@
 package itch
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
 // Message type codes matching ITCH 5.0.
 type MsgType byte
@@ -16,6 +19,23 @@ const (
 	MsgOrderDelete      MsgType = 'D'
 	MsgOrderReplace     MsgType = 'U'
 	MsgTrade            MsgType = 'P'
+	MsgCrossTrade       MsgType = 'Q'
+	MsgNOII             MsgType = 'I' // Net Order Imbalance Indicator
+)
+
+// Cross types, for cross trades and NOII.
+const (
+	CrossOpening byte = 'O'
+	CrossClosing byte = 'C'
+	CrossHalted  byte = 'H' // IPO or halt resumption
+)
+
+// Imbalance directions, for NOII.
+const (
+	ImbalanceBuy          byte = 'B'
+	ImbalanceSell         byte = 'S'
+	ImbalanceNone         byte = 'N'
+	ImbalanceInsufficient byte = 'O' // too few orders to calculate
 )
 
 // System event codes.
@@ -35,6 +55,22 @@ const (
 	TradingResumed  byte = 'T' // trading/quoting
 )
 
+// Tick directions of a trade print against the symbol's previous trade (the
+// tick rule). Zero means the trade is unclassified: the first since start.
+const (
+	TickUp   byte = 'U' // above the previous trade price
+	TickDown byte = 'D' // below it
+	TickZero byte = 'Z' // at the same price
+)
+
+// Liquidity flags of a trade print: how the executed interest traded. Zero
+// means the trade is unflagged.
+const (
+	LiquidityAdded   byte = 'A' // reported for the resting order whose liquidity was taken
+	LiquidityRemoved byte = 'R' // an incoming order took resting liquidity
+	LiquidityAuction byte = 'C' // matched in a cross
+)
+
 // Message is the universal message struct used throughout the simulator.
 // Not all fields are used for every message type.
 type Message struct {
@@ -53,6 +89,20 @@ type Message struct {
 	EventCode    byte    // for system events
 	TradingState byte    // for trading action
 	Reserved     byte
+	Seq          uint64  // feed sequence number (0 = unsequenced); JSON v3 only
+	TickDirection byte   // trade tick rule, TickUp/TickDown/TickZero (0 = unclassified); JSON v4 only
+	Block        bool    // trade at or above the block trade size; JSON v5 only
+	Aggressor    byte    // trade's aggressor side when Side carries the resting side (0 = Side); not encoded
+	LiquidityFlag byte   // trade's LiquidityAdded/Removed/Auction (0 = unflagged); JSON v6, framed v2 header
+	CrossType    byte    // for cross trades and NOII
+
+	// NOII fields (Price is the current reference price)
+	PairedShares       uint64
+	ImbalanceShares    uint64
+	ImbalanceDirection byte
+	FarPrice           float64
+	NearPrice          float64
+	PriceVariation     byte
 
 	// Stock Directory fields
 	MarketCategory      byte
@@ -70,17 +120,33 @@ type Message struct {
 	InverseIndicator    byte
 }
 
+// AggressorSide returns the side that took liquidity in a trade: Aggressor
+// when set, otherwise Side.
+func (m *Message) AggressorSide() byte {
+	if m.Aggressor != 0 {
+		return m.Aggressor
+	}
+	return m.Side
+}
+
 // NanosFromMidnight returns the current nanoseconds since midnight UTC.
 func NanosFromMidnight() int64 {
-	now := time.Now().UTC()
+	return NanosFromMidnightAt(time.Now())
+}
+
+// NanosFromMidnightAt returns t as nanoseconds since its UTC midnight.
+func NanosFromMidnightAt(t time.Time) int64 {
+	now := t.UTC()
 	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 	return now.Sub(midnight).Nanoseconds()
 }
 
-// Price4 converts a float64 price to ITCH 4-decimal fixed-point (uint32).
+// Price4 converts a float64 price to ITCH 4-decimal fixed-point (uint32),
+// rounding to the nearest ten-thousandth so float noise (184.92 is stored as
+// 184.91999...) cannot shift the wire price down a unit.
 // e.g., 125.50 -> 1255000
 func Price4(price float64) uint32 {
-	return uint32(price * 10000)
+	return uint32(math.Round(price * 10000))
 }
 
 // Price4ToFloat converts ITCH fixed-point back to float64.
@@ -88,6 +154,29 @@ func Price4ToFloat(p uint32) float64 {
 	return float64(p) / 10000
 }
 
+// PriceVariation returns the NOII price variation indicator for how far near
+// deviates from ref: 'L' under 1%, '1' to '9' for each whole percent up to
+// 10%, 'A' for 10-19.99%, 'B' for 20-29.99%, 'C' for 30% or more, and ' '
+// when either price is missing.
+func PriceVariation(ref, near float64) byte {
+	if ref <= 0 || near <= 0 {
+		return ' '
+	}
+	pct := math.Abs(near-ref) / ref * 100
+	switch {
+	case pct < 1:
+		return 'L'
+	case pct < 10:
+		return '0' + byte(pct)
+	case pct < 20:
+		return 'A'
+	case pct < 30:
+		return 'B'
+	default:
+		return 'C'
+	}
+}
+
 // PadStock right-pads a ticker to 8 bytes with spaces.
 func PadStock(ticker string) [8]byte {
 	var b [8]byte
@@ -21,6 +21,7 @@ func TestPrice4KnownValues(t *testing.T) {
 		{125.50, 1255000},
 		{0.01, 100},
 		{1.0, 10000},
+		{184.92, 1849200}, // 184.92 * 10000 is 1849199.99... in float64
 	}
 	for _, c := range cases {
 		got := Price4(c.price)
@@ -64,6 +65,8 @@ func TestMsgTypeConstants(t *testing.T) {
 		{"OrderDelete", MsgOrderDelete, 'D'},
 		{"OrderReplace", MsgOrderReplace, 'U'},
 		{"Trade", MsgTrade, 'P'},
+		{"CrossTrade", MsgCrossTrade, 'Q'},
+		{"NOII", MsgNOII, 'I'},
 	}
 	for _, c := range cases {
 		if byte(c.got) != c.want {
@@ -97,3 +100,22 @@ func TestNanosFromMidnightRange(t *testing.T) {
 		t.Errorf("NanosFromMidnight() = %d, out of range [0, %d)", ns, maxNanos)
 	}
 }
+
+func TestPriceVariation(t *testing.T) {
+	cases := []struct {
+		ref, near float64
+		want      byte
+	}{
+		{100, 100.5, 'L'},
+		{100, 97, '3'},
+		{100, 115, 'A'},
+		{100, 75, 'B'},
+		{100, 140, 'C'},
+		{0, 100, ' '},
+	}
+	for _, c := range cases {
+		if got := PriceVariation(c.ref, c.near); got != c.want {
+			t.Errorf("PriceVariation(%v, %v) = %q, want %q", c.ref, c.near, got, c.want)
+		}
+	}
+}
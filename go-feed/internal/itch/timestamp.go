@@ -0,0 +1,81 @@
+package itch
+
+import (
+	"fmt"
+	"time"
+)
+
+// TimestampFormat selects the readable timestamp EncodeJSONWith adds to each
+// message as "time", alongside the raw "timestamp" nanos.
+type TimestampFormat uint8
+
+const (
+	// TimestampNanos adds nothing: "timestamp" alone, as nanoseconds since
+	// midnight UTC.
+	TimestampNanos TimestampFormat = iota
+	// TimestampClock adds the time of day as HH:MM:SS.ffffff (UTC).
+	TimestampClock
+	// TimestampRFC3339 adds the full UTC time as RFC 3339 with nanoseconds.
+	TimestampRFC3339
+)
+
+// ParseTimestampFormat maps a format name ("nanos", "clock" or "rfc3339")
+// to its TimestampFormat.
+func ParseTimestampFormat(name string) (TimestampFormat, bool) {
+	switch name {
+	case "nanos":
+		return TimestampNanos, true
+	case "clock":
+		return TimestampClock, true
+	case "rfc3339":
+		return TimestampRFC3339, true
+	default:
+		return 0, false
+	}
+}
+
+// String returns the format's name as ParseTimestampFormat accepts it.
+func (f TimestampFormat) String() string {
+	switch f {
+	case TimestampClock:
+		return "clock"
+	case TimestampRFC3339:
+		return "rfc3339"
+	default:
+		return "nanos"
+	}
+}
+
+// FormatTimestamp renders nanoseconds since midnight as HH:MM:SS.ffffff.
+func FormatTimestamp(nanos int64) string {
+	d := time.Duration(nanos) * time.Nanosecond
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	s := int(d.Seconds()) % 60
+	us := (nanos / 1000) % 1000000
+	return fmt.Sprintf("%02d:%02d:%02d.%06d", h, m, s, us)
+}
+
+// TimestampTime returns the UTC time nanos since midnight stands for, taking
+// the day from now: the latest such time not after now, so a timestamp
+// stamped just before midnight and encoded just after keeps its own day.
+func TimestampTime(nanos int64, now time.Time) time.Time {
+	now = now.UTC()
+	t := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(time.Duration(nanos))
+	if t.After(now) {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// formatTime renders nanos as f selects, or "" for TimestampNanos.
+func formatTime(nanos int64, f TimestampFormat) string {
+	switch f {
+	case TimestampClock:
+		return FormatTimestamp(nanos)
+	case TimestampRFC3339:
+		return TimestampTime(nanos, time.Now()).Format(time.RFC3339Nano)
+	default:
+		return ""
+	}
+}
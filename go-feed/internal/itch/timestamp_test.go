@@ -0,0 +1,67 @@
+package itch
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFormatTimestampMatchesNanos(t *testing.T) {
+	at := time.Date(2026, 1, 2, 14, 30, 5, 123456789, time.UTC)
+	nanos := NanosFromMidnightAt(at)
+	if got := FormatTimestamp(nanos); got != "14:30:05.123456" {
+		t.Errorf("FormatTimestamp(%d) = %q, want 14:30:05.123456", nanos, got)
+	}
+	if got := TimestampTime(nanos, at.Add(time.Hour)); !got.Equal(at) {
+		t.Errorf("TimestampTime = %v, want %v", got, at)
+	}
+	// Stamped before midnight, read after: still the previous day.
+	late := time.Date(2026, 1, 2, 23, 59, 59, 0, time.UTC)
+	if got := TimestampTime(NanosFromMidnightAt(late), late.Add(2*time.Second)); !got.Equal(late) {
+		t.Errorf("TimestampTime across midnight = %v, want %v", got, late)
+	}
+}
+
+func TestEncodeJSONTime(t *testing.T) {
+	at := time.Date(2026, 1, 2, 9, 15, 0, 500000000, time.UTC)
+	m := &Message{Type: MsgOrderDelete, StockLocate: 1, Timestamp: NanosFromMidnightAt(at), OrderRef: 7}
+	for _, tt := range []struct {
+		f    TimestampFormat
+		want string
+	}{
+		{TimestampNanos, ""},
+		{TimestampClock, "09:15:00.500000"},
+	} {
+		data, err := EncodeJSONWith(m, JSONOptions{Timestamps: tt.f})
+		if err != nil {
+			t.Fatalf("EncodeJSONWith(%v): %v", tt.f, err)
+		}
+		var obj map[string]any
+		json.Unmarshal(data, &obj)
+		if got, _ := obj["time"].(string); got != tt.want {
+			t.Errorf("%v: time = %q, want %q", tt.f, got, tt.want)
+		}
+		if obj["timestamp"] != float64(m.Timestamp) {
+			t.Errorf("%v: timestamp = %v, want the raw nanos %d", tt.f, obj["timestamp"], m.Timestamp)
+		}
+	}
+
+	data, _ := EncodeJSONWith(m, JSONOptions{Timestamps: TimestampRFC3339})
+	var obj map[string]any
+	json.Unmarshal(data, &obj)
+	got, err := time.Parse(time.RFC3339Nano, obj["time"].(string))
+	if err != nil || NanosFromMidnightAt(got) != m.Timestamp {
+		t.Errorf("rfc3339 time = %v (err=%v), want a time of day of %d nanos", obj["time"], err, m.Timestamp)
+	}
+}
+
+func TestParseTimestampFormat(t *testing.T) {
+	for _, f := range []TimestampFormat{TimestampNanos, TimestampClock, TimestampRFC3339} {
+		if got, ok := ParseTimestampFormat(f.String()); !ok || got != f {
+			t.Errorf("ParseTimestampFormat(%q) = %v, %v", f.String(), got, ok)
+		}
+	}
+	if _, ok := ParseTimestampFormat("iso"); ok {
+		t.Error("unknown format accepted")
+	}
+}
@@ -0,0 +1,140 @@
+package itch
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxTimestamp is one past the largest nanos-since-midnight value (24h).
+const maxTimestamp = int64(24 * 60 * 60 * 1e9)
+
+// maxPrice is the largest price representable in Price4 fixed-point.
+const maxPrice = float64(math.MaxUint32) / 10000
+
+// ValidationError reports a single invalid field of a message.
+type ValidationError struct {
+	Type   MsgType
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("itch: invalid %c message: %s %s", e.Type, e.Field, e.Reason)
+}
+
+// Validate checks m against the field requirements of its message type and
+// returns a *ValidationError for the first invalid field, or nil. Fields a
+// type does not encode are ignored. The encoders do not call it; callers that
+// want malformed messages rejected instead of encoded into garbage frames
+// validate first (see session.Manager.SetValidate).
+func Validate(m *Message) error {
+	invalid := func(field, format string, args ...any) error {
+		return &ValidationError{Type: m.Type, Field: field, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	if m.Timestamp < 0 || m.Timestamp >= maxTimestamp {
+		return invalid("timestamp", "%d is outside [0, 24h) nanoseconds", m.Timestamp)
+	}
+
+	var needStock, needSide, needShares, needPrice bool
+	switch m.Type {
+	case MsgSystemEvent:
+		switch m.EventCode {
+		case EventStartOfMessages, EventStartOfSystem, EventStartOfMarket,
+			EventEndOfMarket, EventEndOfSystem, EventEndOfMessages:
+		default:
+			return invalid("eventCode", "%q is not a known event code", m.EventCode)
+		}
+	case MsgStockDirectory:
+		needStock = true
+		if m.RoundLotSize < 0 {
+			return invalid("roundLotSize", "%d is negative", m.RoundLotSize)
+		}
+	case MsgStockTradingAction:
+		needStock = true
+		switch m.TradingState {
+		case TradingHalted, TradingPaused, TradingResumed:
+		default:
+			return invalid("tradingState", "%q is not a known trading state", m.TradingState)
+		}
+	case MsgAddOrder:
+		needStock, needSide, needShares, needPrice = true, true, true, true
+	case MsgAddOrderMPID:
+		needStock, needSide, needShares, needPrice = true, true, true, true
+		if m.MPID == "" || len(m.MPID) > 4 {
+			return invalid("mpid", "%q must be 1-4 characters", m.MPID)
+		}
+	case MsgOrderExecuted, MsgOrderCancel:
+		needShares = true
+	case MsgOrderDelete:
+	case MsgOrderReplace:
+		needShares, needPrice = true, true
+		if m.OrderRef == m.OrigOrderRef {
+			return invalid("orderRef", "%d must differ from origOrderRef", m.OrderRef)
+		}
+	case MsgTrade:
+		needStock, needSide, needShares, needPrice = true, true, true, true
+		if err := validLiquidity(m, invalid); err != nil {
+			return err
+		}
+	case MsgCrossTrade:
+		needStock, needShares, needPrice = true, true, true
+		if err := validCrossType(m, invalid); err != nil {
+			return err
+		}
+		if err := validLiquidity(m, invalid); err != nil {
+			return err
+		}
+	case MsgNOII:
+		needStock = true
+		if err := validCrossType(m, invalid); err != nil {
+			return err
+		}
+		switch m.ImbalanceDirection {
+		case ImbalanceBuy, ImbalanceSell, ImbalanceNone, ImbalanceInsufficient:
+		default:
+			return invalid("imbalanceDirection", "%q is not a known imbalance direction", m.ImbalanceDirection)
+		}
+		for _, p := range []struct {
+			field string
+			price float64
+		}{{"farPrice", m.FarPrice}, {"nearPrice", m.NearPrice}, {"price", m.Price}} {
+			if !(p.price >= 0) || p.price > maxPrice {
+				return invalid(p.field, "%v must be in [0, %.4f]", p.price, maxPrice)
+			}
+		}
+	default:
+		return invalid("type", "is not a supported message type")
+	}
+
+	if needStock && (m.Stock == "" || len(m.Stock) > 8) {
+		return invalid("stock", "%q must be 1-8 characters", m.Stock)
+	}
+	if needSide && m.Side != 'B' && m.Side != 'S' {
+		return invalid("side", "%q must be 'B' or 'S'", m.Side)
+	}
+	if needShares && m.Shares <= 0 {
+		return invalid("shares", "%d must be positive", m.Shares)
+	}
+	if needPrice && (!(m.Price > 0) || m.Price > maxPrice) {
+		return invalid("price", "%v must be in (0, %.4f]", m.Price, maxPrice)
+	}
+	return nil
+}
+
+// validCrossType checks a cross trade's or NOII's cross type.
+func validCrossType(m *Message, invalid func(field, format string, args ...any) error) error {
+	switch m.CrossType {
+	case CrossOpening, CrossClosing, CrossHalted:
+		return nil
+	}
+	return invalid("crossType", "%q is not a known cross type", m.CrossType)
+}
+
+// validLiquidity checks a trade's or cross trade's liquidity flag.
+func validLiquidity(m *Message, invalid func(field, format string, args ...any) error) error {
+	if m.LiquidityFlag == 0 || liquidityName(m.LiquidityFlag) != "" {
+		return nil
+	}
+	return invalid("liquidityFlag", "%q is not a known liquidity flag", m.LiquidityFlag)
+}
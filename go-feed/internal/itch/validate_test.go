@@ -0,0 +1,84 @@
+package itch
+
+import (
+	"errors"
+	"testing"
+)
+
+func validAdd() Message {
+	return Message{Type: MsgAddOrder, Stock: "NEXO", OrderRef: 1, Side: 'B', Shares: 100, Price: 185.5}
+}
+
+func TestValidateAcceptsWellFormed(t *testing.T) {
+	msgs := []Message{
+		{Type: MsgSystemEvent, EventCode: EventStartOfMarket},
+		{Type: MsgStockDirectory, Stock: "NEXO", RoundLotSize: 100},
+		{Type: MsgStockTradingAction, Stock: "NEXO", TradingState: TradingResumed},
+		validAdd(),
+		{Type: MsgAddOrderMPID, Stock: "NEXO", OrderRef: 1, Side: 'S', Shares: 100, Price: 1, MPID: "GSCO"},
+		{Type: MsgOrderExecuted, OrderRef: 1, Shares: 100, MatchNumber: 1},
+		{Type: MsgOrderCancel, OrderRef: 1, Shares: 50},
+		{Type: MsgOrderDelete, OrderRef: 1},
+		{Type: MsgOrderReplace, OrigOrderRef: 1, OrderRef: 2, Shares: 100, Price: 10},
+		{Type: MsgTrade, Stock: "NEXO", Side: 'B', Shares: 100, Price: 10, MatchNumber: 1},
+		{Type: MsgCrossTrade, Stock: "NEXO", Shares: 100, Price: 10, MatchNumber: 1, CrossType: CrossOpening, LiquidityFlag: LiquidityAuction},
+		{Type: MsgNOII, Stock: "NEXO", ImbalanceDirection: ImbalanceInsufficient, CrossType: CrossOpening},
+	}
+	for _, m := range msgs {
+		if err := Validate(&m); err != nil {
+			t.Errorf("%c: unexpected error: %v", m.Type, err)
+		}
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		field string
+		mut   func(m *Message)
+	}{
+		{"side X", "side", func(m *Message) { m.Side = 'X' }},
+		{"negative shares", "shares", func(m *Message) { m.Shares = -100 }},
+		{"zero shares", "shares", func(m *Message) { m.Shares = 0 }},
+		{"empty stock", "stock", func(m *Message) { m.Stock = "" }},
+		{"long stock", "stock", func(m *Message) { m.Stock = "TOOLONGTK" }},
+		{"zero price", "price", func(m *Message) { m.Price = 0 }},
+		{"price overflow", "price", func(m *Message) { m.Price = 500000 }},
+		{"negative timestamp", "timestamp", func(m *Message) { m.Timestamp = -1 }},
+		{"timestamp past midnight", "timestamp", func(m *Message) { m.Timestamp = maxTimestamp }},
+		{"unknown type", "type", func(m *Message) { m.Type = 'Z' }},
+		{"missing mpid", "mpid", func(m *Message) { m.Type = MsgAddOrderMPID }},
+		{"long mpid", "mpid", func(m *Message) { m.Type = MsgAddOrderMPID; m.MPID = "GSCOX" }},
+		{"bad event code", "eventCode", func(m *Message) { m.Type = MsgSystemEvent; m.EventCode = 'Z' }},
+		{"bad trading state", "tradingState", func(m *Message) { m.Type = MsgStockTradingAction; m.TradingState = 'Z' }},
+		{"negative lot size", "roundLotSize", func(m *Message) { m.Type = MsgStockDirectory; m.RoundLotSize = -1 }},
+		{"replace same ref", "orderRef", func(m *Message) { m.Type = MsgOrderReplace; m.OrigOrderRef = m.OrderRef }},
+		{"cancel zero shares", "shares", func(m *Message) { m.Type = MsgOrderCancel; m.Shares = 0 }},
+		{"bad cross type", "crossType", func(m *Message) { m.Type = MsgCrossTrade; m.CrossType = 'Z' }},
+		{"bad liquidity flag", "liquidityFlag", func(m *Message) { m.Type = MsgTrade; m.LiquidityFlag = 'Z' }},
+		{"bad imbalance direction", "imbalanceDirection", func(m *Message) {
+			m.Type, m.CrossType, m.ImbalanceDirection = MsgNOII, CrossOpening, 'Z'
+		}},
+	}
+	for _, tt := range tests {
+		m := validAdd()
+		tt.mut(&m)
+		err := Validate(&m)
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			t.Errorf("%s: got %v, want *ValidationError", tt.name, err)
+			continue
+		}
+		if ve.Field != tt.field {
+			t.Errorf("%s: field = %q, want %q (%v)", tt.name, ve.Field, tt.field, err)
+		}
+	}
+}
+
+func TestValidateIgnoresUnusedFields(t *testing.T) {
+	// A delete carries no side, shares or stock; garbage there is not an error.
+	m := Message{Type: MsgOrderDelete, OrderRef: 1, Side: 'X', Shares: -5}
+	if err := Validate(&m); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
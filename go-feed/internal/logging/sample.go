@@ -0,0 +1,82 @@
+// Package logging provides rate-limited logging for hot paths, where a line
+// per event would flood the log during high-rate phases such as BLITZ bursts.
+package logging
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+)
+
+// DefaultInterval is the sampling interval used until SetInterval is called.
+const DefaultInterval = 5 * time.Second
+
+// Sampler logs at most once per interval for each key. Keys name call sites,
+// so one noisy site cannot suppress another.
+type Sampler struct {
+	mu     sync.Mutex
+	clock  engine.Clock
+	logger *log.Logger
+	last   map[string]time.Time
+}
+
+// NewSampler creates a sampler that writes to logger (nil = the standard
+// logger) and measures intervals on clock.
+func NewSampler(logger *log.Logger, clock engine.Clock) *Sampler {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Sampler{clock: clock, logger: logger, last: make(map[string]time.Time)}
+}
+
+// Log writes msg unless key already logged within the last interval, and
+// reports whether it did. Suppressed calls do not extend the interval.
+func (s *Sampler) Log(key string, interval time.Duration, msg string) bool {
+	now := s.clock.Now()
+	s.mu.Lock()
+	if last, ok := s.last[key]; ok && now.Sub(last) < interval {
+		s.mu.Unlock()
+		return false
+	}
+	s.last[key] = now
+	s.mu.Unlock()
+
+	s.logger.Print(msg)
+	return true
+}
+
+var (
+	std      = NewSampler(nil, engine.RealClock{})
+	mu       sync.RWMutex
+	interval = DefaultInterval
+)
+
+// SetInterval sets the interval SampledLog applies when called with a zero
+// interval. Call once at startup; d <= 0 restores DefaultInterval.
+func SetInterval(d time.Duration) {
+	if d <= 0 {
+		d = DefaultInterval
+	}
+	mu.Lock()
+	interval = d
+	mu.Unlock()
+}
+
+// Interval returns the configured sampling interval.
+func Interval() time.Duration {
+	mu.RLock()
+	defer mu.RUnlock()
+	return interval
+}
+
+// SampledLog writes msg to the standard logger at most once per interval for
+// key and reports whether it logged. A zero interval uses the configured one
+// (see SetInterval).
+func SampledLog(key string, every time.Duration, msg string) bool {
+	if every == 0 {
+		every = Interval()
+	}
+	return std.Log(key, every, msg)
+}
@@ -0,0 +1,36 @@
+package logging
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+)
+
+func TestSamplerLogsFirstCallPerInterval(t *testing.T) {
+	var buf bytes.Buffer
+	clock := engine.NewFakeClock(time.Date(2026, 6, 18, 14, 30, 0, 0, time.UTC))
+	s := NewSampler(log.New(&buf, "", 0), clock)
+
+	if !s.Log("phase", time.Second, "first") {
+		t.Fatal("first call was suppressed")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if s.Log("phase", time.Second, "second") {
+		t.Fatal("call within the interval logged")
+	}
+	if !s.Log("drop", time.Second, "other site") {
+		t.Fatal("a different key was suppressed")
+	}
+	clock.Advance(500 * time.Millisecond)
+	if !s.Log("phase", time.Second, "third") {
+		t.Fatal("call after the interval was suppressed")
+	}
+
+	if got, want := strings.Fields(buf.String()), []string{"first", "other", "site", "third"}; strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Fatalf("logged %q, want first, other site, third", buf.String())
+	}
+}
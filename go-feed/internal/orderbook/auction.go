@@ -0,0 +1,213 @@
+package orderbook
+
+import (
+	"sort"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// Auction collects orders for an opening cross and finds the single price
+// that matches the most volume. Orders are kept as aggregate shares per
+// price; they never rest in a Book and never trade until Clear.
+type Auction struct {
+	ref  float64          // reference price, for breaking ties
+	bids map[uint32]int64 // Price4 -> shares
+	asks map[uint32]int64
+}
+
+// AuctionResult is an auction's indicative or final outcome.
+type AuctionResult struct {
+	Price     float64 // clearing price
+	Matched   int64   // shares paired at Price
+	Imbalance int64   // unpaired shares at Price
+	Side      Side    // side of the imbalance (0 = none)
+}
+
+// NewAuction returns an empty auction around refPrice.
+func NewAuction(refPrice float64) *Auction {
+	return &Auction{
+		ref:  refPrice,
+		bids: make(map[uint32]int64),
+		asks: make(map[uint32]int64),
+	}
+}
+
+// RefPrice returns the auction's reference price.
+func (a *Auction) RefPrice() float64 {
+	return a.ref
+}
+
+// AddOrder enters a limit order into the auction. Non-positive shares or
+// prices are ignored.
+func (a *Auction) AddOrder(side Side, price float64, shares int32) {
+	if shares <= 0 || price <= 0 {
+		return
+	}
+	if side == SideBuy {
+		a.bids[itch.Price4(price)] += int64(shares)
+	} else {
+		a.asks[itch.Price4(price)] += int64(shares)
+	}
+}
+
+// Clear computes the clearing price: of the prices orders were entered at,
+// the one maximizing matched volume, then minimizing the imbalance, then
+// nearest the reference price, then the lowest. Buys at or above the price
+// pair with sells at or below it. ok is false when nothing would match.
+func (a *Auction) Clear() (r AuctionResult, ok bool) {
+	prices := make([]uint32, 0, len(a.bids)+len(a.asks))
+	for p := range a.bids {
+		prices = append(prices, p)
+	}
+	for p := range a.asks {
+		if _, dup := a.bids[p]; !dup {
+			prices = append(prices, p)
+		}
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+
+	ref := itch.Price4(a.ref)
+	var best uint32
+	var bestMatched, bestImb int64
+	for _, p := range prices {
+		var demand, supply int64
+		for bp, s := range a.bids {
+			if bp >= p {
+				demand += s
+			}
+		}
+		for ap, s := range a.asks {
+			if ap <= p {
+				supply += s
+			}
+		}
+		matched, imb := min(demand, supply), demand-supply
+		if matched == 0 {
+			continue
+		}
+		// Prices ascend, so strict comparisons keep the lower price on a
+		// full tie.
+		if ok && (matched < bestMatched ||
+			matched == bestMatched && abs64(imb) > abs64(bestImb) ||
+			matched == bestMatched && abs64(imb) == abs64(bestImb) && dist(p, ref) >= dist(best, ref)) {
+			continue
+		}
+		best, bestMatched, bestImb, ok = p, matched, imb, true
+	}
+	if !ok {
+		return AuctionResult{}, false
+	}
+
+	r = AuctionResult{Price: itch.Price4ToFloat(best), Matched: bestMatched, Imbalance: abs64(bestImb)}
+	switch {
+	case bestImb > 0:
+		r.Side = SideBuy
+	case bestImb < 0:
+		r.Side = SideSell
+	}
+	return r, true
+}
+
+// Reset drops every order, keeping the reference price.
+func (a *Auction) Reset() {
+	clear(a.bids)
+	clear(a.asks)
+}
+
+// CollectAuction enters n random pre-open orders around refPrice into the
+// symbol's opening auction, starting one if none is collecting. Orders land
+// up to five ticks either side of refPrice, so buyers and sellers overlap.
+func (s *Simulator) CollectAuction(refPrice float64, n int) {
+	if s.auction == nil {
+		s.auction = NewAuction(refPrice)
+	}
+	tick := s.tickAt(refPrice)
+	for range n {
+		side := SideBuy
+		if s.rng.Float64() >= s.buyProbability() {
+			side = SideSell
+		}
+		price := s.snap(refPrice + float64(s.rng.IntRange(-5, 5))*tick)
+		if t := s.tickAt(price); price < t {
+			price = t
+		}
+		s.auction.AddOrder(side, price, int32(s.rng.IntRange(1, 10))*100)
+	}
+}
+
+// AuctionNOII returns a Net Order Imbalance Indicator for the collecting
+// opening auction: the shares paired and left over at the indicative
+// clearing price. With no continuous book to cross against, the near and
+// far prices are both that clearing price. ok is false with no auction.
+func (s *Simulator) AuctionNOII() (m itch.Message, ok bool) {
+	if s.auction == nil {
+		return itch.Message{}, false
+	}
+	r, matched := s.auction.Clear()
+	m = itch.Message{
+		Type:               itch.MsgNOII,
+		StockLocate:        s.locateCode,
+		PairedShares:       uint64(r.Matched),
+		ImbalanceShares:    uint64(r.Imbalance),
+		ImbalanceDirection: imbalanceDirection(r, matched),
+		FarPrice:           r.Price,
+		NearPrice:          r.Price,
+		Price:              s.auction.RefPrice(),
+		CrossType:          itch.CrossOpening,
+		PriceVariation:     itch.PriceVariation(s.auction.RefPrice(), r.Price),
+	}
+	return m, true
+}
+
+// CrossAuction runs the opening cross: it clears the collecting auction and
+// returns the Cross Trade for the matched volume, or nil when nothing
+// matched. Either way the auction is over and its unmatched interest is
+// dropped; the continuous book is untouched.
+func (s *Simulator) CrossAuction() []itch.Message {
+	a := s.auction
+	s.auction = nil
+	if a == nil {
+		return nil
+	}
+	r, ok := a.Clear()
+	if !ok {
+		return nil
+	}
+	s.lastTrade = r.Price
+	return []itch.Message{{
+		Type:          itch.MsgCrossTrade,
+		StockLocate:   s.locateCode,
+		Shares:        int32(r.Matched),
+		Price:         r.Price,
+		MatchNumber:   NextMatchNumber(),
+		CrossType:     itch.CrossOpening,
+		LiquidityFlag: s.liquidityFlag(itch.LiquidityAuction),
+	}}
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func dist(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// imbalanceDirection maps an auction result to its NOII imbalance direction.
+func imbalanceDirection(r AuctionResult, ok bool) byte {
+	switch {
+	case !ok:
+		return itch.ImbalanceInsufficient
+	case r.Side == SideBuy:
+		return itch.ImbalanceBuy
+	case r.Side == SideSell:
+		return itch.ImbalanceSell
+	}
+	return itch.ImbalanceNone
+}
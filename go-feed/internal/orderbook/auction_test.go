@@ -0,0 +1,91 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// knownAuction enters a fixed order set whose matched volume by price is
+// 150, 150, 300, 300, 100, 100 at 10.00 through 10.05: 10.02 and 10.03 tie
+// on volume and on the 50-share sell imbalance.
+func knownAuction(ref float64) *Auction {
+	a := NewAuction(ref)
+	a.AddOrder(SideBuy, 10.05, 100)
+	a.AddOrder(SideBuy, 10.03, 200)
+	a.AddOrder(SideBuy, 10.01, 300)
+	a.AddOrder(SideSell, 10.00, 150)
+	a.AddOrder(SideSell, 10.02, 200)
+	a.AddOrder(SideSell, 10.04, 250)
+	return a
+}
+
+func TestAuctionClearMaximizesVolume(t *testing.T) {
+	for _, tt := range []struct {
+		ref  float64
+		want float64
+	}{
+		{10.00, 10.02}, // tie broken toward the reference price
+		{10.10, 10.03},
+	} {
+		r, ok := knownAuction(tt.ref).Clear()
+		if !ok {
+			t.Fatalf("ref %v: Clear() not ok", tt.ref)
+		}
+		if r.Price != tt.want || r.Matched != 300 || r.Imbalance != 50 || r.Side != SideSell {
+			t.Errorf("ref %v: Clear() = %+v, want 300 matched @ %v with 50 to sell", tt.ref, r, tt.want)
+		}
+	}
+}
+
+func TestAuctionClearPrefersSmallerImbalance(t *testing.T) {
+	// A sell at 10.03 leaves both prices matching 300 but doubles 10.03's
+	// imbalance, which outweighs the reference price's pull toward it.
+	a := knownAuction(10.10)
+	a.AddOrder(SideSell, 10.03, 50)
+	r, ok := a.Clear()
+	if !ok || r.Price != 10.02 || r.Matched != 300 || r.Imbalance != 50 {
+		t.Errorf("Clear() = %+v, %v, want 300 matched @ 10.02 with 50 to sell", r, ok)
+	}
+}
+
+func TestAuctionClearNoMatch(t *testing.T) {
+	a := NewAuction(10)
+	a.AddOrder(SideBuy, 9.99, 100)
+	a.AddOrder(SideSell, 10.01, 100)
+	if r, ok := a.Clear(); ok {
+		t.Errorf("Clear() = %+v, want no match for uncrossed orders", r)
+	}
+	a.Reset()
+	if _, ok := a.Clear(); ok {
+		t.Error("Clear() after Reset should not match")
+	}
+}
+
+func TestSimulatorOpeningCross(t *testing.T) {
+	sim := newTestSimulator()
+	if sim.CrossAuction() != nil {
+		t.Fatal("CrossAuction with no auction should emit nothing")
+	}
+	sim.CollectAuction(100, 200)
+
+	noii, ok := sim.AuctionNOII()
+	if !ok || noii.Type != itch.MsgNOII || noii.CrossType != itch.CrossOpening || noii.PairedShares == 0 {
+		t.Fatalf("AuctionNOII() = %+v, %v, want paired opening NOII", noii, ok)
+	}
+	if err := itch.Validate(&itch.Message{Type: noii.Type, Stock: "NEXO", ImbalanceDirection: noii.ImbalanceDirection,
+		CrossType: noii.CrossType, NearPrice: noii.NearPrice, FarPrice: noii.FarPrice, Price: noii.Price}); err != nil {
+		t.Errorf("NOII invalid: %v", err)
+	}
+
+	msgs := sim.CrossAuction()
+	if len(msgs) != 1 || msgs[0].Type != itch.MsgCrossTrade {
+		t.Fatalf("CrossAuction() = %+v, want one cross trade", msgs)
+	}
+	if m := msgs[0]; uint64(m.Shares) != noii.PairedShares || m.Price != noii.NearPrice || m.MatchNumber == 0 {
+		t.Errorf("cross = %+v, want the NOII's %d paired @ %v", m, noii.PairedShares, noii.NearPrice)
+	}
+	if _, ok := sim.AuctionNOII(); ok {
+		t.Error("auction still collecting after the cross")
+	}
+}
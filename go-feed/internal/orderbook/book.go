@@ -1,6 +1,8 @@
 package orderbook
 
 import (
+	"fmt"
+	"math"
 	"sort"
 	"sync"
 )
@@ -24,6 +26,7 @@ type Book struct {
 	Bids     []PriceLevel // sorted descending by price
 	Asks     []PriceLevel // sorted ascending by price
 	orderMap map[uint64]*Order // quick lookup by order ID
+	maxPerLevel int            // per-level order cap (0 = unlimited)
 }
 
 // NewBook creates an empty order book for a symbol.
@@ -35,6 +38,14 @@ func NewBook(locate uint16, tickSize float64) *Book {
 	}
 }
 
+// SetMaxOrdersPerLevel caps how many orders may rest at a single price;
+// AddOrder and ReplaceOrder evict the oldest to make room. n <= 0 means unlimited.
+func (b *Book) SetMaxOrdersPerLevel(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.maxPerLevel = n
+}
+
 // MidPrice returns the midpoint between best bid and best ask.
 // Returns 0 if either side is empty.
 func (b *Book) MidPrice() float64 {
@@ -70,22 +81,56 @@ func (b *Book) BestAsk() float64 {
 	return b.Asks[0].Price
 }
 
-// AddOrder inserts an order into the book at the appropriate price level.
+// BestBidSize returns the total shares resting at the best bid, or 0 if empty.
+func (b *Book) BestBidSize() int32 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.Bids) == 0 {
+		return 0
+	}
+	return levelShares(&b.Bids[0])
+}
+
+// BestAskSize returns the total shares resting at the best ask, or 0 if empty.
+func (b *Book) BestAskSize() int32 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.Asks) == 0 {
+		return 0
+	}
+	return levelShares(&b.Asks[0])
+}
+
+// levelShares sums the shares of every order resting at lvl.
+func levelShares(lvl *PriceLevel) int32 {
+	var total int32
+	for _, o := range lvl.Orders {
+		total += o.Shares
+	}
+	return total
+}
+
+// AddOrder inserts an order into the book at the appropriate price level,
+// stamping it with the next arrival priority so it queues behind every order
+// already resting there.
 // If inserting o pushes a price level past MaxLevels, the orders on the trimmed
 // level are removed from the book and returned so the caller can publish the
 // matching OrderDelete messages. The returned slice may include o itself if o's
-// own level was the one trimmed.
+// own level was the one trimmed. Likewise, when o's level is already at the
+// per-level cap (see SetMaxOrdersPerLevel), its oldest order is evicted to
+// make room.
 func (b *Book) AddOrder(o *Order) []*Order {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	o.Priority = NextPriority()
 	b.orderMap[o.ID] = o
 
 	var evicted []*Order
 	if o.Side == SideBuy {
-		b.Bids, evicted = addToSide(b.Bids, o, true)
+		b.Bids, evicted = addToSide(b.Bids, o, true, b.maxPerLevel)
 	} else {
-		b.Asks, evicted = addToSide(b.Asks, o, false)
+		b.Asks, evicted = addToSide(b.Asks, o, false, b.maxPerLevel)
 	}
 	for _, e := range evicted {
 		delete(b.orderMap, e.ID)
@@ -141,14 +186,30 @@ func (b *Book) ReduceOrder(orderID uint64, reduceBy int32) int32 {
 	return o.Shares
 }
 
-// ReplaceOrder replaces an order with a new price/size. Returns the new order.
-func (b *Book) ReplaceOrder(oldID uint64, newPrice float64, newShares int32) *Order {
+// ReplaceOrder replaces an order with a new price/size. Returns the resulting order.
+//
+// A replace that keeps the price and only reduces (or keeps) the size is
+// applied in place: the order keeps its ID and its position in the level's
+// queue, matching ITCH where a size reduction is an Order Cancel against the
+// original reference. Any other replace (price change or size increase) loses
+// time priority: the order is re-issued under a fresh ID at the back of the
+// queue at its new price. Callers distinguish the two by comparing the
+// returned order's ID with oldID. Like AddOrder, a re-issued order can evict
+// others (level trim or per-level cap); those are returned for the caller to
+// publish as deletes.
+func (b *Book) ReplaceOrder(oldID uint64, newPrice float64, newShares int32) (*Order, []*Order) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	old, ok := b.orderMap[oldID]
 	if !ok {
-		return nil
+		return nil, nil
+	}
+
+	// Size-down at the same price keeps priority.
+	if newPrice == old.Price && newShares <= old.Shares && newShares > 0 {
+		old.Shares = newShares
+		return old, nil
 	}
 
 	// Remove old
@@ -159,28 +220,29 @@ func (b *Book) ReplaceOrder(oldID uint64, newPrice float64, newShares int32) *Or
 		b.Asks = removeFromSide(b.Asks, oldID)
 	}
 
-	// Create replacement
+	// Create replacement at the back of its new level's queue
 	newOrder := &Order{
-		ID:     NextOrderID(),
+		ID:     NextOrderIDFor(old.Locate),
 		Locate: old.Locate,
 		Side:   old.Side,
 		Price:  newPrice,
 		Shares: newShares,
-		MPID:   old.MPID,
+		MPID:     old.MPID,
+		Priority: NextPriority(),
 	}
 	b.orderMap[newOrder.ID] = newOrder
 
 	var evicted []*Order
 	if newOrder.Side == SideBuy {
-		b.Bids, evicted = addToSide(b.Bids, newOrder, true)
+		b.Bids, evicted = addToSide(b.Bids, newOrder, true, b.maxPerLevel)
 	} else {
-		b.Asks, evicted = addToSide(b.Asks, newOrder, false)
+		b.Asks, evicted = addToSide(b.Asks, newOrder, false, b.maxPerLevel)
 	}
 	for _, e := range evicted {
 		delete(b.orderMap, e.ID)
 	}
 
-	return newOrder
+	return newOrder, evicted
 }
 
 // AllOrders returns all orders in the book (for persistence).
@@ -194,6 +256,40 @@ func (b *Book) AllOrders() []*Order {
 	return orders
 }
 
+// RestingOrders returns copies of every resting order in price-time
+// priority: bids best price first, then asks best price first, each level in
+// queue order. Replaying them as adds rebuilds the book exactly.
+func (b *Book) RestingOrders() []Order {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	orders := make([]Order, 0, len(b.orderMap))
+	for _, side := range [][]PriceLevel{b.Bids, b.Asks} {
+		for _, lvl := range side {
+			for _, o := range lvl.Orders {
+				orders = append(orders, *o)
+			}
+		}
+	}
+	return orders
+}
+
+// DepthLimit returns the least aggressive price displayed on side when that
+// side already holds MaxLevels levels, i.e. the worst price a new order can
+// rest at without being trimmed. ok is false when the side has room for
+// another level.
+func (b *Book) DepthLimit(side Side) (price float64, ok bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	levels := b.Asks
+	if side == SideBuy {
+		levels = b.Bids
+	}
+	if len(levels) < MaxLevels {
+		return 0, false
+	}
+	return levels[len(levels)-1].Price, true
+}
+
 // OrderCount returns the total number of orders in the book.
 func (b *Book) OrderCount() int {
 	b.mu.RLock()
@@ -270,22 +366,103 @@ func (b *Book) TotalAskOrders() int {
 }
 
 // RestoreOrder adds an order to the book during state restoration.
-// Same as AddOrder but without generating a new ID.
+// Same as AddOrder but keeping the order's saved ID and priority, and without
+// applying the per-level cap, so the saved book comes back exactly as it was
+// whatever order the orders are restored in.
 func (b *Book) RestoreOrder(o *Order) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 	b.orderMap[o.ID] = o
 	var evicted []*Order
 	if o.Side == SideBuy {
-		b.Bids, evicted = addToSide(b.Bids, o, true)
+		b.Bids, evicted = addToSide(b.Bids, o, true, 0)
 	} else {
-		b.Asks, evicted = addToSide(b.Asks, o, false)
+		b.Asks, evicted = addToSide(b.Asks, o, false, 0)
 	}
 	for _, e := range evicted {
 		delete(b.orderMap, e.ID)
 	}
 }
 
+// Snapshot returns the book's resting orders by value, in the price-time
+// priority of RestingOrders, for persistence and tests. Restore rebuilds the
+// book from it.
+func (b *Book) Snapshot() []Order {
+	return b.RestingOrders()
+}
+
+// Restore replaces the book's contents with copies of orders, keeping their
+// saved IDs and priorities, as RestoreOrder does for each. The slice may be
+// in any order.
+func (b *Book) Restore(orders []Order) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.Bids, b.Asks = nil, nil
+	b.orderMap = make(map[uint64]*Order, len(orders))
+	for i := range orders {
+		o := orders[i]
+		b.orderMap[o.ID] = &o
+		var evicted []*Order
+		if o.Side == SideBuy {
+			b.Bids, evicted = addToSide(b.Bids, &o, true, 0)
+		} else {
+			b.Asks, evicted = addToSide(b.Asks, &o, false, 0)
+		}
+		for _, e := range evicted {
+			delete(b.orderMap, e.ID)
+		}
+	}
+}
+
+// Verify checks the book's internal consistency: every order in the ID index
+// rests on exactly one level and every resting order is indexed, each level
+// is non-empty and holds only orders on its side at its price, and each side
+// is strictly sorted best price first. It returns the first inconsistency
+// found, or nil.
+func (b *Book) Verify() error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	seen := make(map[uint64]bool, len(b.orderMap))
+	for _, s := range []struct {
+		side   Side
+		levels []PriceLevel
+	}{{SideBuy, b.Bids}, {SideSell, b.Asks}} {
+		for i, lvl := range s.levels {
+			if len(lvl.Orders) == 0 {
+				return fmt.Errorf("%c level %.4f is empty", s.side, lvl.Price)
+			}
+			if i > 0 {
+				prev := s.levels[i-1].Price
+				if s.side == SideBuy && lvl.Price >= prev || s.side == SideSell && lvl.Price <= prev {
+					return fmt.Errorf("%c level %.4f is out of order after %.4f", s.side, lvl.Price, prev)
+				}
+			}
+			for _, o := range lvl.Orders {
+				switch {
+				case seen[o.ID]:
+					return fmt.Errorf("order %d rests on more than one level", o.ID)
+				case b.orderMap[o.ID] != o:
+					return fmt.Errorf("order %d on %c level %.4f is not in the order index", o.ID, s.side, lvl.Price)
+				case o.Side != s.side:
+					return fmt.Errorf("order %d (side %c) rests on the %c side", o.ID, o.Side, s.side)
+				case o.Price != lvl.Price:
+					return fmt.Errorf("order %d (price %.4f) rests on level %.4f", o.ID, o.Price, lvl.Price)
+				}
+				seen[o.ID] = true
+			}
+		}
+	}
+	if len(seen) != len(b.orderMap) {
+		for id := range b.orderMap {
+			if !seen[id] {
+				return fmt.Errorf("indexed order %d rests on no level", id)
+			}
+		}
+	}
+	return nil
+}
+
 // DepthLevel represents aggregated data at a single price level.
 type DepthLevel struct {
 	Price       float64
@@ -308,30 +485,9 @@ func (b *Book) Depth() DepthSnapshot {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	snap := DepthSnapshot{}
-
-	for _, lvl := range b.Bids {
-		var total int32
-		for _, o := range lvl.Orders {
-			total += o.Shares
-		}
-		snap.Bids = append(snap.Bids, DepthLevel{
-			Price:       lvl.Price,
-			Orders:      len(lvl.Orders),
-			TotalShares: total,
-		})
-	}
-
-	for _, lvl := range b.Asks {
-		var total int32
-		for _, o := range lvl.Orders {
-			total += o.Shares
-		}
-		snap.Asks = append(snap.Asks, DepthLevel{
-			Price:       lvl.Price,
-			Orders:      len(lvl.Orders),
-			TotalShares: total,
-		})
+	snap := DepthSnapshot{
+		Bids: depthLevels(b.Bids, len(b.Bids)),
+		Asks: depthLevels(b.Asks, len(b.Asks)),
 	}
 
 	if len(b.Bids) > 0 {
@@ -348,19 +504,121 @@ func (b *Book) Depth() DepthSnapshot {
 	return snap
 }
 
+// TopN returns the best n levels per side, aggregated as in Depth, without
+// building the rest of the snapshot. A side with fewer than n levels returns
+// all of them; n <= 0 returns none.
+func (b *Book) TopN(n int) (bids, asks []DepthLevel) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return depthLevels(b.Bids, n), depthLevels(b.Asks, n)
+}
+
+// SharesUpTo returns how many shares an order on side could take from the
+// opposite side at limitPrice or better: a buy sums asks priced at or below
+// the limit, a sell sums bids at or above it.
+func (b *Book) SharesUpTo(side Side, limitPrice float64) int32 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	const eps = 1e-9 // tick-snapped prices carry float noise
+	levels, reachable := b.Asks, func(p float64) bool { return p <= limitPrice+eps }
+	if side == SideSell {
+		levels, reachable = b.Bids, func(p float64) bool { return p >= limitPrice-eps }
+	}
+	var total int32
+	for _, lvl := range levels {
+		if !reachable(lvl.Price) {
+			break
+		}
+		for _, o := range lvl.Orders {
+			total += o.Shares
+		}
+	}
+	return total
+}
+
+// depthLevels aggregates the first n of levels (already in priority order).
+func depthLevels(levels []PriceLevel, n int) []DepthLevel {
+	n = min(n, len(levels))
+	if n <= 0 {
+		return nil
+	}
+	out := make([]DepthLevel, n)
+	for i := range levels[:n] {
+		lvl := &levels[i]
+		out[i] = DepthLevel{Price: lvl.Price, Orders: len(lvl.Orders), TotalShares: levelShares(lvl)}
+	}
+	return out
+}
+
+// DepthAt returns a depth snapshot with levels aggregated into price buckets of
+// width granularity, summing orders and shares per bucket. Bids bucket down
+// and asks bucket up, so a bucket never advertises a better price than any
+// order in it. Best bid/ask, mid and spread stay at raw tick precision. A
+// granularity <= 0 returns the raw per-tick Depth.
+func (b *Book) DepthAt(granularity float64) DepthSnapshot {
+	snap := b.Depth()
+	if granularity <= 0 {
+		return snap
+	}
+	snap.Bids = bucketLevels(snap.Bids, granularity, true)
+	snap.Asks = bucketLevels(snap.Asks, granularity, false)
+	return snap
+}
+
+// bucketLevels merges price-ordered levels into buckets of width g, rounding
+// each price down (bids) or up (asks) to its bucket. Input order is preserved,
+// so adjacent levels that share a bucket are merged in place.
+func bucketLevels(levels []DepthLevel, g float64, down bool) []DepthLevel {
+	// A small epsilon keeps exact multiples of g (e.g. 100.05 / 0.05) from
+	// landing in the neighbouring bucket due to float error.
+	const eps = 1e-9
+	var out []DepthLevel
+	for _, lvl := range levels {
+		var bucket float64
+		if down {
+			bucket = math.Floor(lvl.Price/g+eps) * g
+		} else {
+			bucket = math.Ceil(lvl.Price/g-eps) * g
+		}
+		bucket = roundPrice4(bucket)
+		if n := len(out); n > 0 && out[n-1].Price == bucket {
+			out[n-1].Orders += lvl.Orders
+			out[n-1].TotalShares += lvl.TotalShares
+			continue
+		}
+		out = append(out, DepthLevel{Price: bucket, Orders: lvl.Orders, TotalShares: lvl.TotalShares})
+	}
+	return out
+}
+
+// roundPrice4 rounds to ITCH's 4-decimal price precision.
+func roundPrice4(p float64) float64 {
+	return math.Round(p*10000) / 10000
+}
+
 // --- helpers ---
 
 // addToSide inserts o into the price-ordered levels and trims the side to
-// MaxLevels. It returns the updated levels plus any orders that were dropped by
-// the trim, so the caller can evict them from the book's orderMap. Failing to
+// MaxLevels. When maxPerLevel > 0 and o's level is full, the oldest orders on
+// that level are dropped first. It returns the updated levels plus any orders that were dropped by
+// the trim or the cap, so the caller can evict them from the book's orderMap. Failing to
 // evict trimmed orders orphans them in orderMap (unreachable via the levels but
 // never freed), which leaks memory without bound.
-func addToSide(levels []PriceLevel, o *Order, descending bool) ([]PriceLevel, []*Order) {
+func addToSide(levels []PriceLevel, o *Order, descending bool, maxPerLevel int) ([]PriceLevel, []*Order) {
 	// Find existing level
 	for i := range levels {
 		if levels[i].Price == o.Price {
-			levels[i].Orders = append(levels[i].Orders, o)
-			return levels, nil
+			// At the cap, the oldest order (front of the queue) makes room.
+			var evicted []*Order
+			if maxPerLevel > 0 {
+				for len(levels[i].Orders) >= maxPerLevel {
+					evicted = append(evicted, levels[i].Orders[0])
+					levels[i].Orders = levels[i].Orders[1:]
+				}
+			}
+			levels[i].Orders = insertByPriority(levels[i].Orders, o)
+			return levels, evicted
 		}
 	}
 
@@ -388,6 +646,20 @@ func addToSide(levels []PriceLevel, o *Order, descending bool) ([]PriceLevel, []
 	return levels, nil
 }
 
+// insertByPriority inserts o into a level's queue, keeping it sorted oldest
+// first by (Priority, ID). New orders always carry the highest priority and
+// land at the back; restored orders may arrive in any order.
+func insertByPriority(orders []*Order, o *Order) []*Order {
+	i := sort.Search(len(orders), func(i int) bool {
+		q := orders[i]
+		return q.Priority > o.Priority || q.Priority == o.Priority && q.ID > o.ID
+	})
+	orders = append(orders, nil)
+	copy(orders[i+1:], orders[i:])
+	orders[i] = o
+	return orders
+}
+
 func removeFromSide(levels []PriceLevel, orderID uint64) []PriceLevel {
 	for i := range levels {
 		for j := range levels[i].Orders {
@@ -1,6 +1,7 @@
 package orderbook
 
 import (
+	"reflect"
 	"testing"
 )
 
@@ -15,6 +16,9 @@ func TestEmptyBook(t *testing.T) {
 	if b.BestAsk() != 0 {
 		t.Fatal("empty book BestAsk should be 0")
 	}
+	if b.BestBidSize() != 0 || b.BestAskSize() != 0 {
+		t.Fatalf("empty book best sizes = %d/%d, want 0/0", b.BestBidSize(), b.BestAskSize())
+	}
 	if b.OrderCount() != 0 {
 		t.Fatal("empty book OrderCount should be 0")
 	}
@@ -87,6 +91,32 @@ func TestAddSameLevel(t *testing.T) {
 	}
 }
 
+func TestBestSizeSumsBestLevel(t *testing.T) {
+	b := NewBook(1, 0.01)
+	b.AddOrder(&Order{ID: 1, Side: SideBuy, Price: 100.00, Shares: 100})
+	b.AddOrder(&Order{ID: 2, Side: SideBuy, Price: 100.00, Shares: 250})
+	b.AddOrder(&Order{ID: 3, Side: SideBuy, Price: 99.99, Shares: 900})
+	b.AddOrder(&Order{ID: 4, Side: SideSell, Price: 100.02, Shares: 300})
+	b.AddOrder(&Order{ID: 5, Side: SideSell, Price: 100.01, Shares: 40})
+	b.AddOrder(&Order{ID: 6, Side: SideSell, Price: 100.01, Shares: 60})
+	b.AddOrder(&Order{ID: 7, Side: SideSell, Price: 100.01, Shares: 5})
+	if got := b.BestBidSize(); got != 350 {
+		t.Fatalf("BestBidSize = %d, want 350", got)
+	}
+	if got := b.BestAskSize(); got != 105 {
+		t.Fatalf("BestAskSize = %d, want 105", got)
+	}
+
+	b.RemoveOrder(2)
+	if got := b.BestBidSize(); got != 100 {
+		t.Fatalf("BestBidSize after remove = %d, want 100", got)
+	}
+	b.RemoveOrder(1)
+	if got := b.BestBidSize(); got != 900 {
+		t.Fatalf("BestBidSize after level cleared = %d, want 900", got)
+	}
+}
+
 func TestMaxLevelsTrimming(t *testing.T) {
 	b := NewBook(1, 0.01)
 	// Add more than MaxLevels bid levels
@@ -98,6 +128,31 @@ func TestMaxLevelsTrimming(t *testing.T) {
 	}
 }
 
+func TestMaxOrdersPerLevelCap(t *testing.T) {
+	b := NewBook(1, 0.01)
+	b.SetMaxOrdersPerLevel(5)
+
+	var evictedIDs []uint64
+	for i := 1; i <= 50; i++ {
+		for _, e := range b.AddOrder(&Order{ID: uint64(i), Side: SideBuy, Price: 100.00, Shares: 100}) {
+			evictedIDs = append(evictedIDs, e.ID)
+		}
+		if n := b.Depth().Bids[0].Orders; n > 5 {
+			t.Fatalf("after add %d: level holds %d orders, cap is 5", i, n)
+		}
+	}
+	if b.OrderCount() != 5 {
+		t.Fatalf("OrderCount = %d, want 5 (evicted orders must leave orderMap)", b.OrderCount())
+	}
+	// Oldest first: orders 1..45 were evicted in order, 46..50 remain.
+	if len(evictedIDs) != 45 || evictedIDs[0] != 1 || evictedIDs[44] != 45 {
+		t.Fatalf("evicted %v, want 1..45 in order", evictedIDs)
+	}
+	if b.GetOrder(46) == nil || b.GetOrder(45) != nil {
+		t.Error("expected the newest five orders to remain")
+	}
+}
+
 // TestMaxLevelsNoOrderMapLeak reproduces the unbounded orderMap growth: when a
 // new price level pushes the book past MaxLevels, the trimmed level's orders must
 // be removed from orderMap, not orphaned. Adding ascending prices makes every new
@@ -224,7 +279,7 @@ func TestReplaceOrder(t *testing.T) {
 	SetOrderIDCounter(100)
 	b := NewBook(1, 0.01)
 	b.AddOrder(&Order{ID: 50, Side: SideBuy, Price: 100.00, Shares: 500})
-	newOrder := b.ReplaceOrder(50, 101.00, 300)
+	newOrder, _ := b.ReplaceOrder(50, 101.00, 300)
 	if newOrder == nil {
 		t.Fatal("ReplaceOrder returned nil")
 	}
@@ -242,9 +297,79 @@ func TestReplaceOrder(t *testing.T) {
 	}
 }
 
+// TestReplaceSizeDownKeepsPriority checks that a same-price size reduction is
+// applied in place: the order keeps its ID and stays at the front of the queue.
+func TestReplaceSizeDownKeepsPriority(t *testing.T) {
+	b := NewBook(1, 0.01)
+	first := &Order{ID: 1, Side: SideBuy, Price: 100.00, Shares: 500}
+	b.AddOrder(first)
+	b.AddOrder(&Order{ID: 2, Side: SideBuy, Price: 100.00, Shares: 500})
+	priority := first.Priority
+
+	got, _ := b.ReplaceOrder(1, 100.00, 200)
+	if got == nil {
+		t.Fatal("ReplaceOrder returned nil")
+	}
+	if got.ID != 1 {
+		t.Fatalf("size-down replace ID = %d, want 1 (kept)", got.ID)
+	}
+	if got.Shares != 200 || got.Priority != priority {
+		t.Fatalf("size-down replace: shares=%d priority=%d, want 200/%d", got.Shares, got.Priority, priority)
+	}
+	if first := b.RandomBidOrder(0); first == nil || first.ID != 1 {
+		t.Fatalf("RandomBidOrder(0) = %v, want order 1 (priority kept)", first)
+	}
+	if b.OrderCount() != 2 {
+		t.Fatalf("OrderCount = %d, want 2", b.OrderCount())
+	}
+}
+
+// TestReplacePriceChangeLosesPriority checks that moving an order onto an
+// occupied level re-issues it under a fresh ID behind the resting orders.
+func TestReplacePriceChangeLosesPriority(t *testing.T) {
+	SetOrderIDCounter(100)
+	b := NewBook(1, 0.01)
+	b.AddOrder(&Order{ID: 1, Side: SideBuy, Price: 99.00, Shares: 500})
+	resting := &Order{ID: 2, Side: SideBuy, Price: 100.00, Shares: 500}
+	b.AddOrder(resting)
+
+	got, _ := b.ReplaceOrder(1, 100.00, 500)
+	if got == nil {
+		t.Fatal("ReplaceOrder returned nil")
+	}
+	if got.ID == 1 {
+		t.Fatal("price-change replace kept the original ID")
+	}
+	if got.Priority <= resting.Priority {
+		t.Fatalf("price-change replace priority = %d, want above %d (back of queue)", got.Priority, resting.Priority)
+	}
+	if first := b.RandomBidOrder(0); first == nil || first.ID != 2 {
+		t.Fatalf("RandomBidOrder(0) = %v, want order 2 (resting order first)", first)
+	}
+	if second := b.RandomBidOrder(1); second == nil || second.ID != got.ID {
+		t.Fatalf("RandomBidOrder(1) = %v, want replaced order %d", second, got.ID)
+	}
+}
+
+// TestReplaceSizeUpLosesPriority checks that increasing size at the same price
+// is treated like a price change: fresh ID, back of the queue.
+func TestReplaceSizeUpLosesPriority(t *testing.T) {
+	b := NewBook(1, 0.01)
+	b.AddOrder(&Order{ID: 1, Side: SideBuy, Price: 100.00, Shares: 100})
+	b.AddOrder(&Order{ID: 2, Side: SideBuy, Price: 100.00, Shares: 100})
+
+	got, _ := b.ReplaceOrder(1, 100.00, 300)
+	if got == nil || got.ID == 1 {
+		t.Fatalf("size-up replace = %v, want a fresh order", got)
+	}
+	if first := b.RandomBidOrder(0); first == nil || first.ID != 2 {
+		t.Fatalf("RandomBidOrder(0) = %v, want order 2", first)
+	}
+}
+
 func TestReplaceOrderMissing(t *testing.T) {
 	b := NewBook(1, 0.01)
-	result := b.ReplaceOrder(999, 100.00, 100)
+	result, _ := b.ReplaceOrder(999, 100.00, 100)
 	if result != nil {
 		t.Fatal("ReplaceOrder should return nil for missing order")
 	}
@@ -335,3 +460,234 @@ func TestRandomAskOrder(t *testing.T) {
 		t.Fatal("RandomAskOrder(999) should return nil")
 	}
 }
+
+func TestTopNMatchesDepthTop(t *testing.T) {
+	b := NewBook(1, 0.01)
+	id := uint64(0)
+	for i := range 5 {
+		for range 2 {
+			id++
+			b.AddOrder(&Order{ID: id, Side: SideBuy, Price: 100.00 - float64(i)*0.01, Shares: int32(100 * (i + 1))})
+			id++
+			b.AddOrder(&Order{ID: id, Side: SideSell, Price: 100.05 + float64(i)*0.01, Shares: int32(200 * (i + 1))})
+		}
+	}
+
+	bids, asks := b.TopN(1)
+	if len(bids) != 1 || len(asks) != 1 {
+		t.Fatalf("TopN(1) = %d bids, %d asks, want 1 each", len(bids), len(asks))
+	}
+	snap := b.Depth()
+	if bids[0] != snap.Bids[0] || asks[0] != snap.Asks[0] {
+		t.Errorf("TopN(1) = %+v / %+v, want Depth top %+v / %+v", bids[0], asks[0], snap.Bids[0], snap.Asks[0])
+	}
+
+	bids, asks = b.TopN(50)
+	if len(bids) != len(snap.Bids) || len(asks) != len(snap.Asks) {
+		t.Errorf("TopN(50) = %d/%d levels, want all %d/%d", len(bids), len(asks), len(snap.Bids), len(snap.Asks))
+	}
+	if bids, asks := b.TopN(0); bids != nil || asks != nil {
+		t.Errorf("TopN(0) = %v / %v, want none", bids, asks)
+	}
+}
+
+func TestSharesUpTo(t *testing.T) {
+	b := NewBook(1, 0.01)
+	b.AddOrder(&Order{ID: 1, Side: SideSell, Price: 100.01, Shares: 100})
+	b.AddOrder(&Order{ID: 2, Side: SideSell, Price: 100.01, Shares: 50})
+	b.AddOrder(&Order{ID: 3, Side: SideSell, Price: 100.02, Shares: 200})
+	b.AddOrder(&Order{ID: 4, Side: SideSell, Price: 100.03, Shares: 400})
+	b.AddOrder(&Order{ID: 5, Side: SideBuy, Price: 100.00, Shares: 300})
+	b.AddOrder(&Order{ID: 6, Side: SideBuy, Price: 99.99, Shares: 700})
+
+	for _, tc := range []struct {
+		side  Side
+		limit float64
+		want  int32
+	}{
+		{SideBuy, 100.02, 350}, // two ask levels
+		{SideBuy, 100.00, 0},   // below the best ask
+		{SideBuy, 101.00, 750}, // the whole ask side
+		{SideSell, 100.00, 300},
+		{SideSell, 99.99, 1000},
+		{SideSell, 100.01, 0},
+	} {
+		if got := b.SharesUpTo(tc.side, tc.limit); got != tc.want {
+			t.Errorf("SharesUpTo(%c, %.2f) = %d, want %d", tc.side, tc.limit, got, tc.want)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	newBook := func() *Book {
+		b := NewBook(1, 0.01)
+		b.AddOrder(&Order{ID: 1, Side: SideBuy, Price: 100.00, Shares: 100})
+		b.AddOrder(&Order{ID: 2, Side: SideBuy, Price: 100.00, Shares: 100})
+		b.AddOrder(&Order{ID: 3, Side: SideBuy, Price: 99.99, Shares: 100})
+		b.AddOrder(&Order{ID: 4, Side: SideSell, Price: 100.01, Shares: 100})
+		b.AddOrder(&Order{ID: 5, Side: SideSell, Price: 100.02, Shares: 100})
+		return b
+	}
+	if err := newBook().Verify(); err != nil {
+		t.Fatalf("Verify() on a consistent book = %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		corrupt func(b *Book)
+	}{
+		{"order missing from index", func(b *Book) { delete(b.orderMap, 2) }},
+		{"indexed order on no level", func(b *Book) {
+			b.orderMap[9] = &Order{ID: 9, Side: SideBuy, Price: 99.98, Shares: 100}
+		}},
+		{"order on two levels", func(b *Book) {
+			b.Asks[1].Orders = append(b.Asks[1].Orders, b.Asks[0].Orders[0])
+		}},
+		{"bids out of order", func(b *Book) { b.Bids[0], b.Bids[1] = b.Bids[1], b.Bids[0] }},
+		{"asks out of order", func(b *Book) { b.Asks[0], b.Asks[1] = b.Asks[1], b.Asks[0] }},
+		{"empty level", func(b *Book) {
+			delete(b.orderMap, 3)
+			b.Bids[1].Orders = nil
+		}},
+		{"order on the wrong side", func(b *Book) { b.orderMap[4].Side = SideBuy }},
+		{"order at the wrong price", func(b *Book) { b.orderMap[5].Price = 100.03 }},
+	} {
+		b := newBook()
+		tc.corrupt(b)
+		if err := b.Verify(); err == nil {
+			t.Errorf("%s: Verify() = nil, want an error", tc.name)
+		}
+	}
+}
+
+func TestDepthAtCollapsesLevels(t *testing.T) {
+	b := NewBook(1, 0.01)
+	for i := 0; i < 5; i++ {
+		b.AddOrder(&Order{ID: uint64(i + 1), Side: SideBuy, Price: 100.00 + float64(i)*0.01, Shares: 100})
+	}
+	b.AddOrder(&Order{ID: 10, Side: SideSell, Price: 100.06, Shares: 100})
+	b.AddOrder(&Order{ID: 11, Side: SideSell, Price: 100.10, Shares: 200})
+
+	snap := b.DepthAt(0.05)
+	if len(snap.Bids) != 1 {
+		t.Fatalf("bid buckets = %d, want 1", len(snap.Bids))
+	}
+	if snap.Bids[0].Price != 100.00 || snap.Bids[0].TotalShares != 500 || snap.Bids[0].Orders != 5 {
+		t.Fatalf("bid bucket = %+v, want 100.00 with 5 orders / 500 shares", snap.Bids[0])
+	}
+	// Asks round up: 100.06 and 100.10 share the 100.10 bucket.
+	if len(snap.Asks) != 1 || snap.Asks[0].Price != 100.10 || snap.Asks[0].TotalShares != 300 {
+		t.Fatalf("ask buckets = %+v, want one 100.10 bucket with 300 shares", snap.Asks)
+	}
+	if snap.BestBid != 100.04 {
+		t.Fatalf("BestBid = %f, want raw 100.04", snap.BestBid)
+	}
+}
+
+func TestDepthAtZeroIsRaw(t *testing.T) {
+	b := NewBook(1, 0.01)
+	b.AddOrder(&Order{ID: 1, Side: SideBuy, Price: 100.00, Shares: 100})
+	b.AddOrder(&Order{ID: 2, Side: SideBuy, Price: 100.01, Shares: 100})
+	if got := len(b.DepthAt(0).Bids); got != 2 {
+		t.Fatalf("DepthAt(0) bid levels = %d, want 2 (raw)", got)
+	}
+}
+
+// TestLevelQueueIsArrivalOrdered checks that orders at one price fill oldest
+// first, and that restoring them in any order rebuilds the same queue from
+// their saved priorities.
+func TestLevelQueueIsArrivalOrdered(t *testing.T) {
+	b := NewBook(1, 0.01)
+	for id := uint64(1); id <= 4; id++ {
+		b.AddOrder(&Order{ID: id, Side: SideSell, Price: 100.00, Shares: 100})
+	}
+	// Re-issuing order 2 sends it to the back.
+	b.ReplaceOrder(2, 100.00, 200)
+
+	queue := func(b *Book) []uint64 {
+		var ids []uint64
+		for i := 0; i < b.TotalAskOrders(); i++ {
+			ids = append(ids, b.RandomAskOrder(i).ID)
+		}
+		return ids
+	}
+	want := queue(b)
+	if want[0] != 1 || want[1] != 3 || want[2] != 4 {
+		t.Fatalf("queue = %v, want 1, 3, 4, then the replaced order", want)
+	}
+
+	// Save/load round trip: restore the saved orders newest first.
+	saved := b.RestingOrders()
+	restored := NewBook(1, 0.01)
+	for i := len(saved) - 1; i >= 0; i-- {
+		o := saved[i]
+		restored.RestoreOrder(&o)
+	}
+	got := queue(restored)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("restored queue = %v, want %v", got, want)
+		}
+	}
+
+	// A fill takes the front of the queue.
+	if n := restored.ReduceOrder(want[0], 100); n != 0 {
+		t.Fatalf("ReduceOrder left %d shares", n)
+	}
+	if front := restored.RandomAskOrder(0); front.ID != want[1] {
+		t.Fatalf("front after fill = %d, want %d", front.ID, want[1])
+	}
+	// New orders queue behind the restored ones.
+	restored.AddOrder(&Order{ID: 99, Side: SideSell, Price: 100.00, Shares: 100})
+	if back := restored.RandomAskOrder(restored.TotalAskOrders() - 1); back.ID != 99 {
+		t.Fatalf("back of queue = %d, want new order 99", back.ID)
+	}
+}
+
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	b := NewBook(1, 0.01)
+	b.AddOrder(&Order{ID: 1, Locate: 1, Side: SideBuy, Price: 99.99, Shares: 100})
+	b.AddOrder(&Order{ID: 2, Locate: 1, Side: SideBuy, Price: 100.00, Shares: 200, MPID: "GSCO"})
+	b.AddOrder(&Order{ID: 3, Locate: 1, Side: SideBuy, Price: 100.00, Shares: 300})
+	b.AddOrder(&Order{ID: 4, Locate: 1, Side: SideSell, Price: 100.01, Shares: 400})
+	b.AddOrder(&Order{ID: 5, Locate: 1, Side: SideSell, Price: 100.03, Shares: 500})
+	b.ReplaceOrder(2, 100.00, 250) // size up: back of the queue as a new order
+
+	snap := b.Snapshot()
+	restored := NewBook(1, 0.01)
+	restored.AddOrder(&Order{ID: 99, Locate: 1, Side: SideSell, Price: 120.00, Shares: 1}) // replaced by Restore
+	restored.Restore(snap)
+
+	levels := func(levels []PriceLevel) [][]Order {
+		var out [][]Order
+		for _, lvl := range levels {
+			var q []Order
+			for _, o := range lvl.Orders {
+				q = append(q, *o)
+			}
+			out = append(out, q)
+		}
+		return out
+	}
+	if !reflect.DeepEqual(levels(restored.Bids), levels(b.Bids)) {
+		t.Errorf("restored bids = %v, want %v", levels(restored.Bids), levels(b.Bids))
+	}
+	if !reflect.DeepEqual(levels(restored.Asks), levels(b.Asks)) {
+		t.Errorf("restored asks = %v, want %v", levels(restored.Asks), levels(b.Asks))
+	}
+	if restored.OrderCount() != b.OrderCount() {
+		t.Fatalf("restored %d orders, want %d", restored.OrderCount(), b.OrderCount())
+	}
+	for _, o := range snap {
+		got := restored.GetOrder(o.ID)
+		if got == nil || *got != o {
+			t.Errorf("order map[%d] = %+v, want %+v", o.ID, got, o)
+		}
+	}
+
+	// Value semantics: the restored book doesn't share orders with the source.
+	restored.ReduceOrder(snap[0].ID, 10)
+	if got := b.GetOrder(snap[0].ID).Shares; got != snap[0].Shares {
+		t.Errorf("reducing the restored book changed the source to %d shares", got)
+	}
+}
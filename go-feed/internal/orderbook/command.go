@@ -0,0 +1,52 @@
+package orderbook
+
+import (
+	"context"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// Command is a change to a Simulator requested from another goroutine, such
+// as an admin endpoint. The Simulator is not safe for concurrent use, so the
+// symbol's runner receives commands from Commands and applies them between
+// ticks.
+type Command struct {
+	fn   func(*Simulator) []itch.Message
+	done chan []itch.Message
+}
+
+// Run applies the command to s on the runner's goroutine, hands the
+// messages it produced to publish (to broadcast them), and then returns them
+// to the goroutine waiting in Do.
+func (c Command) Run(s *Simulator, publish func([]itch.Message)) {
+	msgs := c.fn(s)
+	if len(msgs) > 0 {
+		publish(msgs)
+	}
+	c.done <- msgs
+}
+
+// Commands returns the channel the symbol's runner receives Commands on.
+func (s *Simulator) Commands() <-chan Command {
+	return s.cmds
+}
+
+// Do passes fn to the symbol's runner, which applies it between ticks and
+// publishes the messages it returns, and waits for those messages. It fails
+// with ctx's error when ctx ends first, for example when no runner is
+// serving the symbol. If the runner already accepted the command, it is
+// still applied.
+func (s *Simulator) Do(ctx context.Context, fn func(*Simulator) []itch.Message) ([]itch.Message, error) {
+	c := Command{fn: fn, done: make(chan []itch.Message, 1)}
+	select {
+	case s.cmds <- c:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case msgs := <-c.done:
+		return msgs, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,188 @@
+package orderbook
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// makerRequoteProb is the per-step chance each market maker moves its quotes
+// toward the current price.
+const makerRequoteProb = 0.2
+
+// MarketMaker is a participant that keeps exactly one attributed bid and one
+// attributed ask resting on a symbol's book. Its quotes are refreshed by
+// replacing them, and reposted when a trade, cancel or eviction takes them
+// out, so its MPID shows a consistent two-sided presence instead of scattered
+// one-off orders.
+type MarketMaker struct {
+	MPID string
+
+	halfSpread int    // distance of each quote from the price, in ticks
+	bid, ask   uint64 // resting quote order IDs (0 = none)
+}
+
+// SetAttribution replaces the participant IDs orders are attributed to and,
+// when rate >= 0, the probability that a simulated order carries one. A
+// negative rate keeps the per-source defaults (0.3 for seeded orders, 0.2
+// for adds, 0.25 for replenishment). Call before SetMarketMakers, which
+// draws its makers from the same list.
+func (s *Simulator) SetAttribution(ids []string, rate float64) error {
+	if len(ids) == 0 {
+		return fmt.Errorf("attribution: MPID list is empty")
+	}
+	for _, id := range ids {
+		if id == "" || len(id) > 4 {
+			return fmt.Errorf("attribution: MPID %q must be 1-4 characters", id)
+		}
+	}
+	if rate > 1 || math.IsNaN(rate) {
+		return fmt.Errorf("attribution: rate %v must be at most 1", rate)
+	}
+	s.mpids = append([]string(nil), ids...)
+	s.attrRate = rate
+	return nil
+}
+
+// SetMarketMakers enables persistent quoting for the first n market-maker
+// MPIDs (at most the number configured). While enabled, MPID attribution is reserved for
+// maker quotes: other simulated orders are anonymous. n <= 0 disables it.
+// Call before Initialize.
+func (s *Simulator) SetMarketMakers(n int) error {
+	if n > len(s.mpids) {
+		return fmt.Errorf("market makers: %d requested, only %d MPIDs available", n, len(s.mpids))
+	}
+	s.makers = nil
+	for i := 0; i < n; i++ {
+		s.makers = append(s.makers, &MarketMaker{MPID: s.mpids[i], halfSpread: 1 + i%3})
+	}
+	return nil
+}
+
+// MarketMakers returns the MPIDs quoting persistently on this book.
+func (s *Simulator) MarketMakers() []string {
+	out := make([]string, len(s.makers))
+	for i, mm := range s.makers {
+		out[i] = mm.MPID
+	}
+	return out
+}
+
+// attribute tags o with a random MPID with probability p, or the configured
+// attribution rate when one is set. With persistent market makers enabled,
+// the MPIDs belong to their quotes, so other orders stay anonymous.
+func (s *Simulator) attribute(o *Order, p float64) {
+	if s.attrRate >= 0 {
+		p = s.attrRate
+	}
+	if s.rng.Float64() < p && len(s.makers) == 0 {
+		o.MPID = s.mpids[s.rng.Intn(len(s.mpids))]
+	}
+}
+
+// quoteMakers keeps every maker two-sided around price: quotes that left the
+// book are reposted, and with probability makerRequoteProb (or always, when
+// requote is forced) resting quotes are replaced at the current level.
+func (s *Simulator) quoteMakers(price float64, force bool) []itch.Message {
+	var msgs []itch.Message
+	for _, mm := range s.makers {
+		requote := force || s.rng.Float64() < makerRequoteProb
+		msgs = append(msgs, s.quoteMaker(mm, price, requote)...)
+	}
+	// A quote that opened a new level can trim the worst one, taking another
+	// maker's quote with it. Repost until everyone is two-sided again; the
+	// reposts clamp to the displayed depth, so this settles quickly.
+	for pass := 0; pass < len(s.makers); pass++ {
+		var reposts []itch.Message
+		for _, mm := range s.makers {
+			reposts = append(reposts, s.quoteMaker(mm, price, false)...)
+		}
+		if len(reposts) == 0 {
+			break
+		}
+		msgs = append(msgs, reposts...)
+	}
+	return msgs
+}
+
+// quoteMaker applies makerQuote to both sides of mm's quote.
+func (s *Simulator) quoteMaker(mm *MarketMaker, price float64, requote bool) []itch.Message {
+	offset := float64(mm.halfSpread) * s.tickAt(price)
+	msgs := s.makerQuote(mm, SideBuy, s.snap(price-offset), requote)
+	return append(msgs, s.makerQuote(mm, SideSell, s.snap(price+offset), requote)...)
+}
+
+// makerQuote places or moves one side of a maker's quote to target.
+func (s *Simulator) makerQuote(mm *MarketMaker, side Side, target float64, requote bool) []itch.Message {
+	id := &mm.bid
+	if side == SideSell {
+		id = &mm.ask
+	}
+	if t := s.tickAt(target); target < t {
+		target = t
+	}
+	// Never quote past the displayed depth, where the book would trim the
+	// quote as soon as it landed.
+	if limit, ok := s.book.DepthLimit(side); ok {
+		if side == SideBuy && target < limit || side == SideSell && target > limit {
+			target = limit
+		}
+	}
+
+	o := s.book.GetOrder(*id)
+	if o == nil {
+		o = s.adoptQuote(mm.MPID, side)
+	}
+	if o == nil {
+		o = &Order{
+			ID:     NextOrderIDFor(s.locateCode),
+			Locate: s.locateCode,
+			Side:   side,
+			Price:  target,
+			Shares: int32(s.rng.IntRange(1, 5)) * 100,
+			MPID:   mm.MPID,
+		}
+		*id = o.ID
+		return s.addMsgs(o, s.book.AddOrder(o))
+	}
+	*id = o.ID
+
+	if !requote || o.Price == target {
+		return nil
+	}
+	shares := o.Shares
+	newOrder, evicted := s.book.ReplaceOrder(o.ID, target, shares)
+	if newOrder == nil {
+		return nil
+	}
+	*id = newOrder.ID
+	return s.replaceMsgs(o.ID, newOrder, evicted)
+}
+
+// adoptQuote finds a resting order already carrying mpid on side (e.g. one
+// restored from a snapshot) so a maker picks it up instead of posting a
+// second quote. The oldest matching order wins, keeping the choice
+// deterministic.
+func (s *Simulator) adoptQuote(mpid string, side Side) *Order {
+	var found *Order
+	for _, o := range s.book.AllOrders() {
+		if o.MPID == mpid && o.Side == side && (found == nil || o.ID < found.ID) {
+			found = o
+		}
+	}
+	return found
+}
+
+// rebindMaker points a maker quote at newID after its order oldID was
+// re-issued by a replace outside quoteMakers.
+func (s *Simulator) rebindMaker(oldID, newID uint64) {
+	for _, mm := range s.makers {
+		switch oldID {
+		case mm.bid:
+			mm.bid = newID
+		case mm.ask:
+			mm.ask = newID
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package orderbook
 
 import (
+	"maps"
+	"sync"
 	"sync/atomic"
 )
 
@@ -19,7 +21,7 @@ type Order struct {
 	Side     Side
 	Price    float64
 	Shares   int32
-	Priority int32 // time priority within a price level
+	Priority uint64 // arrival sequence; lower is older and fills first at a price
 	MPID     string // market participant ID, empty for anonymous
 }
 
@@ -41,6 +43,81 @@ func GetOrderIDCounter() uint64 {
 	return atomic.LoadUint64(&orderIDCounter)
 }
 
+// OrderIDSpan is the block of order IDs each symbol draws from when order IDs
+// are namespaced: locate L numbers its orders from L*OrderIDSpan+1 up, so an
+// ID reads as the locate followed by a 12-digit sequence, and stays below
+// 2^53 for JSON consumers.
+const OrderIDSpan = 1_000_000_000_000
+
+// per-symbol order ID counters, used instead of orderIDCounter when
+// namespaced
+var (
+	namespacedIDs atomic.Bool
+	symbolIDMu    sync.Mutex
+	symbolIDs     = make(map[uint16]uint64)
+)
+
+// SetOrderIDNamespaces switches NextOrderIDFor between the global counter
+// and per-symbol counters, each in its own OrderIDSpan block.
+func SetOrderIDNamespaces(on bool) {
+	namespacedIDs.Store(on)
+}
+
+// OrderIDNamespaces reports whether order IDs are namespaced by symbol.
+func OrderIDNamespaces() bool {
+	return namespacedIDs.Load()
+}
+
+// NextOrderIDFor returns a globally unique order reference number for a new
+// order on locate's book: the next in locate's block when order IDs are
+// namespaced, else NextOrderID.
+func NextOrderIDFor(locate uint16) uint64 {
+	if !namespacedIDs.Load() {
+		return NextOrderID()
+	}
+	symbolIDMu.Lock()
+	defer symbolIDMu.Unlock()
+	symbolIDs[locate]++
+	return uint64(locate)*OrderIDSpan + symbolIDs[locate]
+}
+
+// SetSymbolOrderIDCounters replaces the per-symbol order ID counters (for
+// restoring from persistence). Each counter is the last sequence handed out
+// within its symbol's block.
+func SetSymbolOrderIDCounters(counters map[uint16]uint64) {
+	symbolIDMu.Lock()
+	defer symbolIDMu.Unlock()
+	symbolIDs = make(map[uint16]uint64, len(counters))
+	maps.Copy(symbolIDs, counters)
+}
+
+// GetSymbolOrderIDCounters returns a copy of the per-symbol order ID counters
+// for persistence.
+func GetSymbolOrderIDCounters() map[uint16]uint64 {
+	symbolIDMu.Lock()
+	defer symbolIDMu.Unlock()
+	return maps.Clone(symbolIDs)
+}
+
+// global arrival sequence, stamped on every order the book accepts
+var priorityCounter uint64
+
+// NextPriority returns the next arrival sequence number. Priorities only grow,
+// so within a price level the order with the lowest one arrived first.
+func NextPriority() uint64 {
+	return atomic.AddUint64(&priorityCounter, 1)
+}
+
+// SetPriorityCounter sets the counter (for restoring from persistence).
+func SetPriorityCounter(val uint64) {
+	atomic.StoreUint64(&priorityCounter, val)
+}
+
+// GetPriorityCounter returns the current priority counter for persistence.
+func GetPriorityCounter() uint64 {
+	return atomic.LoadUint64(&priorityCounter)
+}
+
 // global match number counter for trades
 var matchCounter uint64
 
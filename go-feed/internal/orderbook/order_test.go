@@ -60,6 +60,46 @@ func TestSetGetMatchCounter(t *testing.T) {
 	atomic.StoreUint64(&matchCounter, 0)
 }
 
+func TestNamespacedOrderIDs(t *testing.T) {
+	SetOrderIDNamespaces(true)
+	SetSymbolOrderIDCounters(map[uint16]uint64{2: 99})
+	t.Cleanup(func() {
+		SetOrderIDNamespaces(false)
+		SetSymbolOrderIDCounters(nil)
+	})
+
+	seen := make(map[uint64]bool)
+	last := make(map[uint16]uint64)
+	for i := 0; i < 1000; i++ {
+		loc := uint16(1 + i%2)
+		id := NextOrderIDFor(loc)
+		if seen[id] {
+			t.Fatalf("order ID %d issued twice", id)
+		}
+		seen[id] = true
+		if id <= last[loc] {
+			t.Fatalf("locate %d: ID %d after %d, want increasing", loc, id, last[loc])
+		}
+		if id/OrderIDSpan != uint64(loc) {
+			t.Fatalf("locate %d: ID %d outside its block", loc, id)
+		}
+		last[loc] = id
+	}
+	if last[1] != OrderIDSpan+500 || last[2] != 2*OrderIDSpan+599 {
+		t.Errorf("last IDs = %d, %d; want locate 1 at 500 and locate 2 at 599 (restored from 99)", last[1], last[2])
+	}
+	if c := GetSymbolOrderIDCounters(); c[1] != 500 || c[2] != 599 {
+		t.Errorf("counters = %v, want 1:500 2:599", c)
+	}
+
+	SetOrderIDNamespaces(false)
+	SetOrderIDCounter(7)
+	if id := NextOrderIDFor(1); id != 8 {
+		t.Errorf("un-namespaced NextOrderIDFor = %d, want the global counter's 8", id)
+	}
+	SetOrderIDCounter(0)
+}
+
 func TestOrderStruct(t *testing.T) {
 	o := Order{
 		ID:     1,
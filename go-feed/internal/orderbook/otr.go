@@ -0,0 +1,89 @@
+package orderbook
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+const (
+	// otrGain is how strongly one step's ratio error moves the trade
+	// weight, on a log scale: small enough that the ratio's step-to-step
+	// noise averages out.
+	otrGain = 0.02
+
+	// otrMaxBoost bounds the trade weight's scaling either way.
+	otrMaxBoost = 50
+)
+
+// otrController steers a symbol's order-to-trade ratio, the orders entered
+// per trade printed, toward a target by scaling the Trade action's weight.
+// Orders and trades are counted from each step's messages, decayed so the
+// ratio covers roughly the last window steps.
+type otrController struct {
+	target float64
+	decay  float64 // per-step weight kept by the running counts
+	orders float64
+	trades float64
+	boost  float64 // current Trade weight multiplier
+}
+
+// SetOTRTarget steers the symbol's order-to-trade ratio, new orders entered
+// per trade, toward target over a rolling window of about window steps: the
+// Trade action becomes more likely while orders outrun the target and less
+// likely while trades do. Market maker quotes count as orders, and a book
+// too thin to trade can keep the ratio above target. target 0 disables it
+// (the default).
+func (s *Simulator) SetOTRTarget(target float64, window int) error {
+	if target == 0 {
+		s.otr = nil
+		return nil
+	}
+	if !(target > 0) || math.IsInf(target, 0) {
+		return fmt.Errorf("order-to-trade target %v must be positive", target)
+	}
+	if window < 1 {
+		return fmt.Errorf("order-to-trade window %d must be at least 1 step", window)
+	}
+	s.otr = &otrController{target: target, decay: 1 - 1/float64(window), boost: 1}
+	return nil
+}
+
+// OTR returns the symbol's order-to-trade ratio over the controller's
+// window, or 0 when no target is set or nothing has traded yet.
+func (s *Simulator) OTR() float64 {
+	if s.otr == nil || s.otr.trades == 0 {
+		return 0
+	}
+	return s.otr.orders / s.otr.trades
+}
+
+// weights returns base with the Trade weight scaled by the current boost.
+func (c *otrController) weights(base []float64) []float64 {
+	w := append([]float64(nil), base...)
+	w[actionTrade] *= c.boost
+	return w
+}
+
+// observe folds one step's messages into the running counts and moves the
+// boost by the log of the ratio's error.
+func (c *otrController) observe(msgs []itch.Message) {
+	c.orders *= c.decay
+	c.trades *= c.decay
+	for i := range msgs {
+		switch msgs[i].Type {
+		case itch.MsgAddOrder, itch.MsgAddOrderMPID:
+			c.orders++
+		case itch.MsgTrade:
+			c.trades++
+		}
+	}
+	if c.trades == 0 {
+		c.boost = math.Min(c.boost*(1+otrGain), otrMaxBoost)
+		return
+	}
+	ratio := math.Max(c.orders, 1) / c.trades
+	c.boost *= math.Exp(otrGain * math.Log(ratio/c.target))
+	c.boost = math.Max(1/otrMaxBoost, math.Min(c.boost, otrMaxBoost))
+}
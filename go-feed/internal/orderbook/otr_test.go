@@ -0,0 +1,58 @@
+package orderbook
+
+import (
+	"math"
+	"testing"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// TestOTRTargetConverges steers the ratio below and above the book's natural
+// one (about 3.3 orders per trade) and checks the ratio observed over the
+// second half of the run lands near each target.
+func TestOTRTargetConverges(t *testing.T) {
+	for _, target := range []float64{2, 5} {
+		sim := newTestSimulator()
+		sim.Initialize(100)
+		if err := sim.SetOTRTarget(target, 500); err != nil {
+			t.Fatal(err)
+		}
+		var orders, trades int
+		for i := range 10000 {
+			msgs := sim.Step(100, 2)
+			if i < 5000 {
+				continue
+			}
+			for _, m := range msgs {
+				switch m.Type {
+				case itch.MsgAddOrder, itch.MsgAddOrderMPID:
+					orders++
+				case itch.MsgTrade:
+					trades++
+				}
+			}
+		}
+		got := float64(orders) / float64(trades)
+		if math.Abs(got-target)/target > 0.1 {
+			t.Errorf("target %v: observed ratio %.2f (%d orders, %d trades), want within 10%%", target, got, orders, trades)
+		}
+		if r := sim.OTR(); math.Abs(r-target)/target > 0.2 {
+			t.Errorf("target %v: OTR() = %.2f", target, r)
+		}
+	}
+}
+
+func TestSetOTRTargetValidates(t *testing.T) {
+	sim := newTestSimulator()
+	for _, tt := range []struct {
+		target float64
+		window int
+	}{{-1, 100}, {math.NaN(), 100}, {math.Inf(1), 100}, {3, 0}} {
+		if sim.SetOTRTarget(tt.target, tt.window) == nil {
+			t.Errorf("SetOTRTarget(%v, %d) accepted", tt.target, tt.window)
+		}
+	}
+	if err := sim.SetOTRTarget(0, 0); err != nil || sim.OTR() != 0 {
+		t.Errorf("SetOTRTarget(0, 0) = %v, OTR %v; want disabled", err, sim.OTR())
+	}
+}
@@ -1,19 +1,37 @@
 package orderbook
 
 import (
+	"fmt"
 	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
 )
 
 // Action weights for order book simulation.
 var actionWeights = []float64{
 	0.30, // Add
 	0.20, // Cancel/Delete
-	0.15, // Update/Replace
+	0.10, // Update/Replace
 	0.15, // Trade/Execute
 	0.20, // Replenish
+	0.05, // Amend (size down)
+}
+
+// drainWeights is actionWeights with Add and Replenish disabled, so a
+// draining book only loses liquidity to cancels and trades.
+var drainWeights = []float64{
+	0,    // Add
+	0.20, // Cancel/Delete
+	0.10, // Update/Replace
+	0.15, // Trade/Execute
+	0,    // Replenish
+	0.05, // Amend (size down)
 }
 
 const (
@@ -22,17 +40,143 @@ const (
 	actionReplace   = 2
 	actionTrade     = 3
 	actionReplenish = 4
+	actionAmend     = 5
 )
 
 // Market maker MPIDs for attributed orders.
 var mpids = []string{"GSCO", "MSCO", "JPMS", "CITI", "BARK", "SUSQ", "VIRT", "CITD"}
 
+// DefaultMPIDs returns the built-in market participant IDs orders are
+// attributed to.
+func DefaultMPIDs() []string {
+	return append([]string(nil), mpids...)
+}
+
+// TradePriceMode selects the price printed on the trade tape.
+type TradePriceMode int
+
+const (
+	// TradePriceResting prints trades at the resting order's price, so the
+	// tape always equals a book level (default).
+	TradePriceResting TradePriceMode = iota
+	// TradePriceEngine prints trades at the market engine's current price,
+	// snapped to the tick and clamped into [best bid, best ask], so the tape
+	// tracks the modeled price path.
+	TradePriceEngine
+)
+
+// ParseTradePriceMode maps "resting" or "engine" to its mode.
+func ParseTradePriceMode(s string) (TradePriceMode, error) {
+	switch s {
+	case "resting", "":
+		return TradePriceResting, nil
+	case "engine":
+		return TradePriceEngine, nil
+	default:
+		return 0, fmt.Errorf("unknown trade price mode %q (want \"resting\" or \"engine\")", s)
+	}
+}
+
+// TradeSide selects what a Trade message's Side reports.
+type TradeSide int
+
+const (
+	// TradeSideAggressor reports the side that took liquidity: 'B' for a
+	// buy hitting the ask (default).
+	TradeSideAggressor TradeSide = iota
+	// TradeSideResting reports the side of the resting order that was
+	// matched, as the ITCH spec defines it: 'S' for a buy hitting the ask.
+	TradeSideResting
+)
+
+// ParseTradeSide maps "aggressor" or "resting" to its TradeSide.
+func ParseTradeSide(s string) (TradeSide, error) {
+	switch s {
+	case "aggressor", "":
+		return TradeSideAggressor, nil
+	case "resting":
+		return TradeSideResting, nil
+	default:
+		return 0, fmt.Errorf("unknown trade side %q (want \"aggressor\" or \"resting\")", s)
+	}
+}
+
+// AggressorBias skews which side trades take liquidity from.
+type AggressorBias struct {
+	// Buy is the probability that a trade's aggressor is a buyer, in [0, 1].
+	// 0.5 is balanced order flow.
+	Buy float64
+	// Momentum shifts Buy toward the direction of the last price move: up by
+	// Momentum after an uptick, down after a downtick. The result is clamped
+	// to [0, 1].
+	Momentum float64
+}
+
+// BalancedFlow is the default AggressorBias: a fair coin with no momentum.
+var BalancedFlow = AggressorBias{Buy: 0.5}
+
+// Validate checks that Buy and Momentum are within [0, 1].
+func (b AggressorBias) Validate() error {
+	if !(b.Buy >= 0 && b.Buy <= 1) {
+		return fmt.Errorf("aggressor buy probability %v must be in [0, 1]", b.Buy)
+	}
+	if !(b.Momentum >= 0 && b.Momentum <= 1) {
+		return fmt.Errorf("aggressor momentum %v must be in [0, 1]", b.Momentum)
+	}
+	return nil
+}
+
+// ParseSeedImbalance parses a comma-separated list of TICKER=RATIO pairs
+// giving each symbol's bid:ask seed size ratio, e.g. "NEXO=3,ACME=0.5". An
+// empty spec yields an empty map (every book seeds symmetrically).
+func ParseSeedImbalance(spec string) (map[string]float64, error) {
+	out := make(map[string]float64)
+	if strings.TrimSpace(spec) == "" {
+		return out, nil
+	}
+	for _, part := range strings.Split(spec, ",") {
+		ticker, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || ticker == "" {
+			return nil, fmt.Errorf("seed imbalance %q: want TICKER=RATIO", part)
+		}
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil || !(ratio > 0) || math.IsInf(ratio, 0) {
+			return nil, fmt.Errorf("seed imbalance %q: ratio must be a positive number", part)
+		}
+		if _, dup := out[ticker]; dup {
+			return nil, fmt.Errorf("seed imbalance: %s listed more than once", ticker)
+		}
+		out[ticker] = ratio
+	}
+	return out, nil
+}
+
 // Simulator drives simulated order book activity for a single symbol.
 type Simulator struct {
 	rng        *engine.RNG
 	book       *Book
 	locateCode uint16
 	tickSize   float64
+	ticks      symbol.TickSchedule // price-banded ticks (empty = tickSize)
+	rounding   symbol.Rounding     // how prices snap to the tick
+	priceMode  TradePriceMode
+	tradeSide  TradeSide
+	draining   atomic.Bool
+	bias       atomic.Pointer[AggressorBias]
+
+	makers    []*MarketMaker // persistent quoters (nil = random attribution)
+	mpids     []string       // participant IDs for attribution and makers
+	attrRate  float64        // attribution probability (< 0 = per-source defaults)
+	seedRatio float64        // bid:ask seed size ratio (0 = symmetric)
+	lastPrice float64        // engine price seen by the previous Step
+	priceDir  int            // sign of the latest engine price move
+	lastTrade float64        // price of the previous trade print (0 = none yet)
+	auction   *Auction       // pre-open orders awaiting the opening cross (nil = none)
+	blockSize int32          // shares at which a trade is a block (0 = none are)
+	liquidity bool           // flag trades with how they took liquidity
+	maxTrade  int32          // largest single trade print, in shares (0 = uncapped)
+	otr       *otrController // order-to-trade ratio steering (nil = off)
+	cmds      chan Command   // changes applied by the symbol's runner (see Do)
 }
 
 // NewSimulator creates a new order book simulator.
@@ -42,7 +186,131 @@ func NewSimulator(rng *engine.RNG, book *Book, locateCode uint16, tickSize float
 		book:       book,
 		locateCode: locateCode,
 		tickSize:   tickSize,
+		mpids:      mpids,
+		attrRate:   -1,
+		cmds:       make(chan Command),
+	}
+}
+
+// SetTradePriceMode sets where trade prints are priced.
+func (s *Simulator) SetTradePriceMode(m TradePriceMode) {
+	s.priceMode = m
+}
+
+// SetTradeSide sets what trade messages' Side reports. Either way the
+// message's Aggressor keeps the aggressor side.
+func (s *Simulator) SetTradeSide(m TradeSide) {
+	s.tradeSide = m
+}
+
+// SetSeedImbalance skews the liquidity Initialize seeds: bid order sizes
+// are scaled by ratio when it is above 1 and ask sizes by 1/ratio when it
+// is below, so total bid shares come out about ratio times total ask shares.
+// 1 restores symmetric seeding.
+func (s *Simulator) SetSeedImbalance(ratio float64) error {
+	if !(ratio > 0) || math.IsInf(ratio, 0) {
+		return fmt.Errorf("seed imbalance ratio %v must be positive", ratio)
+	}
+	s.seedRatio = ratio
+	return nil
+}
+
+// seedShares scales a seed order's size for the configured imbalance,
+// keeping round lots of at least 100.
+func (s *Simulator) seedShares(shares int32, side Side) int32 {
+	scale := 1.0
+	switch {
+	case s.seedRatio > 1 && side == SideBuy:
+		scale = s.seedRatio
+	case s.seedRatio > 0 && s.seedRatio < 1 && side == SideSell:
+		scale = 1 / s.seedRatio
+	}
+	if scale == 1 {
+		return shares
+	}
+	lots := math.Round(float64(shares) * scale / 100)
+	return int32(max(lots, 1)) * 100
+}
+
+// SetTickSchedule sets the price-band tick schedule used to place and snap
+// order prices. An empty schedule (the default) uses the fixed tick size.
+func (s *Simulator) SetTickSchedule(ts symbol.TickSchedule) {
+	s.ticks = ts
+}
+
+// tickAt returns the tick size for price.
+func (s *Simulator) tickAt(price float64) float64 {
+	return s.ticks.TickAt(price, s.tickSize)
+}
+
+// SetBlockSize marks trades of at least shares as block trades. 0 disables
+// it.
+func (s *Simulator) SetBlockSize(shares int32) {
+	s.blockSize = max(shares, 0)
+}
+
+// SetMaxTradeShares caps a single trade print at shares: a larger fill is
+// printed as several trades of at most shares each. 0 removes the cap.
+func (s *Simulator) SetMaxTradeShares(shares int32) {
+	s.maxTrade = max(shares, 0)
+}
+
+// SetLiquidityFlags sets whether trades carry a liquidity flag: continuous
+// trades are flagged as removing liquidity, opening cross trades as auction.
+func (s *Simulator) SetLiquidityFlags(on bool) {
+	s.liquidity = on
+}
+
+// liquidityFlag returns flag when liquidity flags are on, else 0.
+func (s *Simulator) liquidityFlag(flag byte) byte {
+	if !s.liquidity {
+		return 0
+	}
+	return flag
+}
+
+// SetRounding sets how order and trade prices snap to the tick. The
+// default, RoundNearest, rounds to the nearest tick.
+func (s *Simulator) SetRounding(r symbol.Rounding) {
+	s.rounding = r
+}
+
+// snap rounds price to the tick of its price band.
+func (s *Simulator) snap(price float64) float64 {
+	return s.rounding.Snap(price, s.tickAt(price))
+}
+
+// SetDrain turns thin-market mode on or off. While draining, Step never adds
+// or replenishes orders, so the book thins out as cancels and trades remove
+// liquidity. It is safe to call while another goroutine is stepping.
+func (s *Simulator) SetDrain(on bool) {
+	s.draining.Store(on)
+}
+
+// Draining reports whether thin-market mode is on.
+func (s *Simulator) Draining() bool {
+	return s.draining.Load()
+}
+
+// SetAggressorBias sets the order-flow imbalance used to pick each trade's
+// aggressor side. It is safe to call while another goroutine is stepping.
+func (s *Simulator) SetAggressorBias(b AggressorBias) {
+	s.bias.Store(&b)
+}
+
+// AggressorBias returns the current order-flow imbalance.
+func (s *Simulator) AggressorBias() AggressorBias {
+	if b := s.bias.Load(); b != nil {
+		return *b
 	}
+	return BalancedFlow
+}
+
+// buyProbability is the chance the next trade's aggressor is a buyer.
+func (s *Simulator) buyProbability() float64 {
+	b := s.AggressorBias()
+	p := b.Buy + b.Momentum*float64(s.priceDir)
+	return math.Max(0, math.Min(1, p))
 }
 
 // Book returns the underlying order book.
@@ -56,28 +324,25 @@ func (s *Simulator) Initialize(refPrice float64) []itch.Message {
 	var msgs []itch.Message
 
 	for level := 0; level < MaxLevels; level++ {
-		offset := float64(level+1) * s.tickSize
+		offset := float64(level+1) * s.tickAt(refPrice)
 
-		bidPrice := snapPrice(refPrice-offset, s.tickSize)
-		askPrice := snapPrice(refPrice+offset, s.tickSize)
+		bidPrice := s.snap(refPrice - offset)
+		askPrice := s.snap(refPrice + offset)
 
-		for j := 0; j < OrdersPerLevel; j++ {
+		for range OrdersPerLevel {
 			shares := int32(s.rng.IntRange(100, 1000))
 			shares = (shares / 100) * 100 // round to lots of 100
 
 			// Bid order
 			bidOrder := &Order{
-				ID:       NextOrderID(),
-				Locate:   s.locateCode,
-				Side:     SideBuy,
-				Price:    bidPrice,
-				Shares:   shares,
-				Priority: int32(j),
+				ID:     NextOrderIDFor(s.locateCode),
+				Locate: s.locateCode,
+				Side:   SideBuy,
+				Price:  bidPrice,
+				Shares: s.seedShares(shares, SideBuy),
 			}
 			// Randomly attribute some orders to market makers
-			if s.rng.Float64() < 0.3 {
-				bidOrder.MPID = mpids[s.rng.Intn(len(mpids))]
-			}
+			s.attribute(bidOrder, 0.3)
 			s.book.AddOrder(bidOrder)
 			msgs = append(msgs, s.makeAddOrderMsg(bidOrder))
 
@@ -85,21 +350,83 @@ func (s *Simulator) Initialize(refPrice float64) []itch.Message {
 			askShares := int32(s.rng.IntRange(100, 1000))
 			askShares = (askShares / 100) * 100
 			askOrder := &Order{
-				ID:       NextOrderID(),
-				Locate:   s.locateCode,
-				Side:     SideSell,
-				Price:    askPrice,
-				Shares:   askShares,
-				Priority: int32(j),
-			}
-			if s.rng.Float64() < 0.3 {
-				askOrder.MPID = mpids[s.rng.Intn(len(mpids))]
+				ID:     NextOrderIDFor(s.locateCode),
+				Locate: s.locateCode,
+				Side:   SideSell,
+				Price:  askPrice,
+				Shares: s.seedShares(askShares, SideSell),
 			}
+			s.attribute(askOrder, 0.3)
 			s.book.AddOrder(askOrder)
 			msgs = append(msgs, s.makeAddOrderMsg(askOrder))
 		}
 	}
 
+	return append(msgs, s.quoteMakers(refPrice, true)...)
+}
+
+// WarmUp runs steps silent Step cycles around refPrice, discarding the
+// generated messages, so a freshly initialized book drifts from its uniform
+// seed layout toward a realistic steady state before clients connect.
+func (s *Simulator) WarmUp(refPrice float64, steps int) {
+	for i := 0; i < steps; i++ {
+		s.Step(refPrice, 1+s.rng.Intn(3))
+	}
+}
+
+// Recenter clears the book and reseeds it around refPrice, returning delete
+// messages for every resting order followed by the new adds, so subscribers
+// can apply the reset incrementally.
+func (s *Simulator) Recenter(refPrice float64) []itch.Message {
+	orders := s.book.AllOrders()
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+
+	var msgs []itch.Message
+	for _, o := range orders {
+		if s.book.RemoveOrder(o.ID) == nil {
+			continue
+		}
+		msgs = append(msgs, itch.Message{
+			Type:        itch.MsgOrderDelete,
+			StockLocate: s.locateCode,
+			OrderRef:    o.ID,
+		})
+	}
+	return append(msgs, s.Initialize(refPrice)...)
+}
+
+// Widen blows out the spread: it deletes every order resting within ticks
+// ticks of the mid (or, with a side empty, of the last engine price), so
+// the touch on each side is at least ticks away and the spread at least
+// twice that. It returns the deletes for broadcast. Nothing stops the book
+// refilling: adds, replenishment and market makers narrow it again as the
+// simulation runs.
+func (s *Simulator) Widen(ticks int) []itch.Message {
+	mid := s.book.MidPrice()
+	if mid == 0 {
+		mid = s.lastPrice
+	}
+	if mid == 0 || ticks <= 0 {
+		return nil
+	}
+	band := float64(ticks) * s.tickAt(mid)
+
+	orders := s.book.AllOrders()
+	sort.Slice(orders, func(i, j int) bool { return orders[i].ID < orders[j].ID })
+	var msgs []itch.Message
+	for _, o := range orders {
+		if math.Abs(o.Price-mid) >= band-1e-9 {
+			continue
+		}
+		if s.book.RemoveOrder(o.ID) == nil {
+			continue
+		}
+		msgs = append(msgs, itch.Message{
+			Type:        itch.MsgOrderDelete,
+			StockLocate: s.locateCode,
+			OrderRef:    o.ID,
+		})
+	}
 	return msgs
 }
 
@@ -108,8 +435,25 @@ func (s *Simulator) Initialize(refPrice float64) []itch.Message {
 func (s *Simulator) Step(currentPrice float64, numActions int) []itch.Message {
 	var msgs []itch.Message
 
+	switch {
+	case s.lastPrice == 0 || currentPrice == s.lastPrice:
+		s.priceDir = 0
+	case currentPrice > s.lastPrice:
+		s.priceDir = 1
+	default:
+		s.priceDir = -1
+	}
+	s.lastPrice = currentPrice
+
+	weights := actionWeights
+	if s.draining.Load() {
+		weights = drainWeights
+	} else if s.otr != nil {
+		weights = s.otr.weights(weights)
+	}
+
 	for i := 0; i < numActions; i++ {
-		action := s.rng.WeightedPick(actionWeights)
+		action := s.rng.WeightedPick(weights)
 		var actionMsgs []itch.Message
 
 		switch action {
@@ -120,14 +464,22 @@ func (s *Simulator) Step(currentPrice float64, numActions int) []itch.Message {
 		case actionReplace:
 			actionMsgs = s.doReplace(currentPrice)
 		case actionTrade:
-			actionMsgs = s.doTrade()
+			actionMsgs = s.doTrade(currentPrice)
 		case actionReplenish:
 			actionMsgs = s.doReplenish(currentPrice)
+		case actionAmend:
+			actionMsgs = s.doAmend()
 		}
 
 		msgs = append(msgs, actionMsgs...)
 	}
 
+	if !s.draining.Load() {
+		msgs = append(msgs, s.quoteMakers(currentPrice, false)...)
+	}
+	if s.otr != nil {
+		s.otr.observe(msgs)
+	}
 	return msgs
 }
 
@@ -138,29 +490,27 @@ func (s *Simulator) doAdd(currentPrice float64) []itch.Message {
 		side = SideSell
 	}
 
-	offset := float64(s.rng.IntRange(1, 10)) * s.tickSize
+	offset := float64(s.rng.IntRange(1, 10)) * s.tickAt(currentPrice)
 	var price float64
 	if side == SideBuy {
-		price = snapPrice(currentPrice-offset, s.tickSize)
+		price = s.snap(currentPrice - offset)
 	} else {
-		price = snapPrice(currentPrice+offset, s.tickSize)
+		price = s.snap(currentPrice + offset)
 	}
-	if price < s.tickSize {
-		price = s.tickSize
+	if t := s.tickAt(price); price < t {
+		price = t
 	}
 
 	shares := int32(s.rng.IntRange(1, 10)) * 100
 
 	o := &Order{
-		ID:     NextOrderID(),
+		ID:     NextOrderIDFor(s.locateCode),
 		Locate: s.locateCode,
 		Side:   side,
 		Price:  price,
 		Shares: shares,
 	}
-	if s.rng.Float64() < 0.2 {
-		o.MPID = mpids[s.rng.Intn(len(mpids))]
-	}
+	s.attribute(o, 0.2)
 
 	evicted := s.book.AddOrder(o)
 	return s.addMsgs(o, evicted)
@@ -249,52 +599,147 @@ func (s *Simulator) doReplace(currentPrice float64) []itch.Message {
 	}
 
 	oldID := o.ID
+	oldShares := o.Shares
 	// New price: shift by -2 to +2 ticks
-	shift := float64(s.rng.IntRange(-2, 2)) * s.tickSize
-	newPrice := snapPrice(o.Price+shift, s.tickSize)
-	if newPrice < s.tickSize {
-		newPrice = s.tickSize
+	shift := float64(s.rng.IntRange(-2, 2)) * s.tickAt(o.Price)
+	newPrice := s.snap(o.Price + shift)
+	if t := s.tickAt(newPrice); newPrice < t {
+		newPrice = t
 	}
 	newShares := int32(s.rng.IntRange(1, 10)) * 100
 
-	newOrder := s.book.ReplaceOrder(oldID, newPrice, newShares)
+	newOrder, evicted := s.book.ReplaceOrder(oldID, newPrice, newShares)
 	if newOrder == nil {
 		return nil
 	}
 
+	// Same price, smaller size: the book kept the order (and its priority), so
+	// publish the reduction as an Order Cancel against the original reference.
+	if newOrder.ID == oldID {
+		cancelled := oldShares - newShares
+		if cancelled <= 0 {
+			return nil
+		}
+		return []itch.Message{
+			{
+				Type:        itch.MsgOrderCancel,
+				StockLocate: s.locateCode,
+				OrderRef:    oldID,
+				Shares:      cancelled,
+			},
+		}
+	}
+
+	s.rebindMaker(oldID, newOrder.ID)
+	return s.replaceMsgs(oldID, newOrder, evicted)
+}
+
+// replaceMsgs builds the wire messages for replacing oldID with newOrder: the
+// OrderReplace, then an OrderDelete for every order the replacement evicted.
+func (s *Simulator) replaceMsgs(oldID uint64, newOrder *Order, evicted []*Order) []itch.Message {
+	msgs := []itch.Message{
+		{
+			Type:         itch.MsgOrderReplace,
+			StockLocate:  s.locateCode,
+			OrderRef:     newOrder.ID,
+			OrigOrderRef: oldID,
+			Shares:       newOrder.Shares,
+			Price:        newOrder.Price,
+		},
+	}
+	// Evictions follow the replace that caused them; if the replacement itself
+	// was trimmed, its delete comes after the replace introduced it.
+	for _, e := range evicted {
+		msgs = append(msgs, itch.Message{
+			Type:        itch.MsgOrderDelete,
+			StockLocate: s.locateCode,
+			OrderRef:    e.ID,
+		})
+	}
+	return msgs
+}
+
+// doAmend reduces a random order larger than one round lot by whole lots,
+// never to zero. The order keeps its reference and queue position, and
+// the reduction is published as a partial Order Cancel, unlike doReplace which
+// may re-issue the order under a new reference.
+func (s *Simulator) doAmend() []itch.Message {
+	totalBid := s.book.TotalBidOrders()
+	totalAsk := s.book.TotalAskOrders()
+	total := totalBid + totalAsk
+	if total == 0 {
+		return nil
+	}
+
+	idx := s.rng.Intn(total)
+	var o *Order
+	if idx < totalBid {
+		o = s.book.RandomBidOrder(idx)
+	} else {
+		o = s.book.RandomAskOrder(idx - totalBid)
+	}
+	if o == nil || o.Shares <= 100 {
+		return nil
+	}
+
+	cancelled := int32(s.rng.IntRange(1, int(o.Shares/100)-1)) * 100
+	if cancelled >= o.Shares {
+		return nil
+	}
+	s.book.ReduceOrder(o.ID, cancelled)
 	return []itch.Message{
 		{
-			Type:           itch.MsgOrderReplace,
-			StockLocate:    s.locateCode,
-			OrderRef:       newOrder.ID,
-			OrigOrderRef:   oldID,
-			Shares:         newShares,
-			Price:          newPrice,
+			Type:        itch.MsgOrderCancel,
+			StockLocate: s.locateCode,
+			OrderRef:    o.ID,
+			Shares:      cancelled,
 		},
 	}
 }
 
-// doTrade executes an aggressive order that crosses the spread.
-func (s *Simulator) doTrade() []itch.Message {
+// doTrade executes an aggressive order that crosses the spread. The executed
+// message always carries the resting order's price; the trade print's price
+// depends on the simulator's TradePriceMode.
+func (s *Simulator) doTrade(currentPrice float64) []itch.Message {
 	bestBid := s.book.BestBid()
 	bestAsk := s.book.BestAsk()
 	if bestBid == 0 || bestAsk == 0 {
 		return nil
 	}
 
-	var msgs []itch.Message
-
-	// Randomly pick aggressor side
-	if s.rng.Float64() < 0.5 {
+	// Pick aggressor side, skewed by the configured order-flow bias
+	aggressor := SideSell
+	var o *Order
+	if s.rng.Float64() < s.buyProbability() {
 		// Buy aggressor hits the ask
-		o := s.book.RandomAskOrder(0) // best ask, first order
-		if o == nil {
-			return nil
-		}
-		tradeShares := int32(s.rng.IntRange(1, int(o.Shares/100))) * 100
-		if tradeShares <= 0 {
-			tradeShares = o.Shares
+		aggressor = SideBuy
+		o = s.book.RandomAskOrder(0) // best ask, first order
+	} else {
+		// Sell aggressor hits the bid
+		o = s.book.RandomBidOrder(0) // best bid, first order
+	}
+	if o == nil {
+		return nil
+	}
+	tradeShares := int32(s.rng.IntRange(1, int(o.Shares/100))) * 100
+	if tradeShares <= 0 {
+		tradeShares = o.Shares
+	}
+	return s.execute(o, aggressor, tradeShares, currentPrice, bestBid, bestAsk)
+}
+
+// execute fills shares of resting order o for an aggressor, returning an
+// Order Executed and Trade pair for each print. A fill larger than the
+// maximum trade size is split into several prints of at most that size,
+// each with its own match number.
+func (s *Simulator) execute(o *Order, aggressor Side, shares int32, currentPrice, bestBid, bestAsk float64) []itch.Message {
+	var msgs []itch.Message
+	for shares > 0 {
+		printShares := shares
+		if s.maxTrade > 0 {
+			printShares = min(shares, s.maxTrade)
 		}
+		shares -= printShares
 
 		matchNum := NextMatchNumber()
 
@@ -303,59 +748,70 @@ func (s *Simulator) doTrade() []itch.Message {
 			Type:        itch.MsgOrderExecuted,
 			StockLocate: s.locateCode,
 			OrderRef:    o.ID,
-			Shares:      tradeShares,
+			Shares:      printShares,
 			MatchNumber: matchNum,
 			Price:       o.Price,
 		})
 
 		// Trade message
+		price := s.printPrice(o.Price, currentPrice, bestBid, bestAsk)
 		msgs = append(msgs, itch.Message{
-			Type:        itch.MsgTrade,
-			StockLocate: s.locateCode,
-			OrderRef:    o.ID,
-			Shares:      tradeShares,
-			Price:       o.Price,
-			MatchNumber: matchNum,
-			Side:        byte(SideBuy),
+			Type:          itch.MsgTrade,
+			StockLocate:   s.locateCode,
+			OrderRef:      o.ID,
+			Shares:        printShares,
+			Price:         price,
+			MatchNumber:   matchNum,
+			Side:          s.printSide(aggressor),
+			Aggressor:     byte(aggressor),
+			TickDirection: s.tickDirection(price),
+			Block:         s.blockSize > 0 && printShares >= s.blockSize,
+			LiquidityFlag: s.liquidityFlag(itch.LiquidityRemoved),
 		})
 
-		s.book.ReduceOrder(o.ID, tradeShares)
-	} else {
-		// Sell aggressor hits the bid
-		o := s.book.RandomBidOrder(0) // best bid, first order
-		if o == nil {
-			return nil
-		}
-		tradeShares := int32(s.rng.IntRange(1, int(o.Shares/100))) * 100
-		if tradeShares <= 0 {
-			tradeShares = o.Shares
-		}
-
-		matchNum := NextMatchNumber()
-
-		msgs = append(msgs, itch.Message{
-			Type:        itch.MsgOrderExecuted,
-			StockLocate: s.locateCode,
-			OrderRef:    o.ID,
-			Shares:      tradeShares,
-			MatchNumber: matchNum,
-			Price:       o.Price,
-		})
+		s.book.ReduceOrder(o.ID, printShares)
+	}
+	return msgs
+}
 
-		msgs = append(msgs, itch.Message{
-			Type:        itch.MsgTrade,
-			StockLocate: s.locateCode,
-			OrderRef:    o.ID,
-			Shares:      tradeShares,
-			Price:       o.Price,
-			MatchNumber: matchNum,
-			Side:        byte(SideSell),
-		})
+// printSide returns the Side a trade taken by aggressor reports under the
+// simulator's TradeSide.
+func (s *Simulator) printSide(aggressor Side) byte {
+	if s.tradeSide == TradeSideResting {
+		if aggressor == SideBuy {
+			return byte(SideSell)
+		}
+		return byte(SideBuy)
+	}
+	return byte(aggressor)
+}
 
-		s.book.ReduceOrder(o.ID, tradeShares)
+// tickDirection classifies a trade print at price by the tick rule against
+// the previous print, then records price as the previous print. The first
+// trade is unclassified (0).
+func (s *Simulator) tickDirection(price float64) byte {
+	prev := s.lastTrade
+	s.lastTrade = price
+	switch {
+	case prev == 0:
+		return 0
+	case itch.Price4(price) > itch.Price4(prev):
+		return itch.TickUp
+	case itch.Price4(price) < itch.Price4(prev):
+		return itch.TickDown
+	default:
+		return itch.TickZero
 	}
+}
 
-	return msgs
+// printPrice returns the trade-print price for an execution against a
+// resting order at resting, given the engine price and the touch.
+func (s *Simulator) printPrice(resting, currentPrice, bestBid, bestAsk float64) float64 {
+	if s.priceMode != TradePriceEngine || currentPrice <= 0 {
+		return resting
+	}
+	p := s.snap(currentPrice)
+	return math.Max(bestBid, math.Min(bestAsk, p))
 }
 
 // doReplenish adds liquidity at 1-5 ticks from mid.
@@ -365,29 +821,27 @@ func (s *Simulator) doReplenish(currentPrice float64) []itch.Message {
 		side = SideSell
 	}
 
-	offset := float64(s.rng.IntRange(1, 5)) * s.tickSize
+	offset := float64(s.rng.IntRange(1, 5)) * s.tickAt(currentPrice)
 	var price float64
 	if side == SideBuy {
-		price = snapPrice(currentPrice-offset, s.tickSize)
+		price = s.snap(currentPrice - offset)
 	} else {
-		price = snapPrice(currentPrice+offset, s.tickSize)
+		price = s.snap(currentPrice + offset)
 	}
-	if price < s.tickSize {
-		price = s.tickSize
+	if t := s.tickAt(price); price < t {
+		price = t
 	}
 
 	shares := int32(s.rng.IntRange(2, 10)) * 100
 
 	o := &Order{
-		ID:     NextOrderID(),
+		ID:     NextOrderIDFor(s.locateCode),
 		Locate: s.locateCode,
 		Side:   side,
 		Price:  price,
 		Shares: shares,
 	}
-	if s.rng.Float64() < 0.25 {
-		o.MPID = mpids[s.rng.Intn(len(mpids))]
-	}
+	s.attribute(o, 0.25)
 
 	evicted := s.book.AddOrder(o)
 	return s.addMsgs(o, evicted)
@@ -408,7 +862,3 @@ func (s *Simulator) makeAddOrderMsg(o *Order) itch.Message {
 		MPID:        o.MPID,
 	}
 }
-
-func snapPrice(price, tickSize float64) float64 {
-	return math.Round(price/tickSize) * tickSize
-}
@@ -1,10 +1,12 @@
 package orderbook
 
 import (
+	"math"
 	"testing"
 
 	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
 )
 
 func newTestSimulator() *Simulator {
@@ -243,3 +245,723 @@ func TestAddMsgsEvictionMessages(t *testing.T) {
 		t.Fatalf("self-eviction produced %d msgs, want 0", len(msgs))
 	}
 }
+
+func TestRecenterReseedsAroundNewPrice(t *testing.T) {
+	sim := newTestSimulator()
+	book := sim.Book()
+	sim.Initialize(100.00)
+	before := book.OrderCount()
+
+	msgs := sim.Recenter(150.00)
+
+	deletes, adds := 0, 0
+	for _, m := range msgs {
+		switch m.Type {
+		case itch.MsgOrderDelete:
+			if adds > 0 {
+				t.Fatal("delete emitted after adds; deletes must come first")
+			}
+			deletes++
+		case itch.MsgAddOrder, itch.MsgAddOrderMPID:
+			adds++
+		default:
+			t.Fatalf("unexpected message type %c", m.Type)
+		}
+	}
+	if deletes != before {
+		t.Errorf("deletes = %d, want %d", deletes, before)
+	}
+	if adds != book.OrderCount() {
+		t.Errorf("adds = %d, book has %d orders", adds, book.OrderCount())
+	}
+	if mid := book.MidPrice(); math.Abs(mid-150.00) > 0.001 {
+		t.Errorf("mid after recenter = %.4f, want 150.00", mid)
+	}
+}
+
+func TestStepMessagesPassValidation(t *testing.T) {
+	sim := newTestSimulator()
+	msgs := sim.Initialize(100.00)
+	for i := 0; i < 2000; i++ {
+		msgs = append(msgs, sim.Step(100.00, 3)...)
+	}
+	for i := range msgs {
+		msgs[i].Stock = "TEST" // stamped by the session layer before encoding
+		if err := itch.Validate(&msgs[i]); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+	}
+}
+
+// levelShape returns the number of distinct orders-per-level counts and
+// distinct level share totals across both sides of the book.
+func levelShape(b *Book) (orderCounts, shareTotals int) {
+	counts := map[int]bool{}
+	totals := map[int32]bool{}
+	snap := b.Depth()
+	for _, lv := range append(snap.Bids, snap.Asks...) {
+		counts[lv.Orders] = true
+		totals[lv.TotalShares] = true
+	}
+	return len(counts), len(totals)
+}
+
+func TestWarmUpVariesBook(t *testing.T) {
+	sim := newTestSimulator()
+	sim.Initialize(100.00)
+	initCounts, initTotals := levelShape(sim.Book())
+	if initCounts != 1 {
+		t.Fatalf("Initialize should give every level the same order count, got %d distinct", initCounts)
+	}
+
+	sim.WarmUp(100.00, 500)
+	warmCounts, warmTotals := levelShape(sim.Book())
+	if warmCounts <= initCounts {
+		t.Errorf("distinct orders-per-level after warm-up = %d, want > %d", warmCounts, initCounts)
+	}
+	if warmTotals <= initTotals {
+		t.Errorf("distinct level sizes after warm-up = %d, want > %d", warmTotals, initTotals)
+	}
+}
+
+// tradePrints runs doTrade on fresh books seeded around 100.00 (touch
+// 99.99 x 100.01) at the given engine price and returns the trade prints.
+func tradePrints(mode TradePriceMode, enginePrice float64, n int) []float64 {
+	var prints []float64
+	for i := 0; i < n; i++ {
+		sim := newTestSimulator()
+		sim.rng = engine.NewRNG(int64(i + 1))
+		sim.SetTradePriceMode(mode)
+		sim.Initialize(100.00)
+		for _, m := range sim.doTrade(enginePrice) {
+			if m.Type == itch.MsgTrade {
+				prints = append(prints, m.Price)
+			}
+		}
+	}
+	return prints
+}
+
+func TestAmendKeepsOrderID(t *testing.T) {
+	sim := newTestSimulator()
+	sim.Initialize(100.00)
+	before := make(map[uint64]Order)
+	for _, o := range sim.Book().Snapshot() {
+		before[o.ID] = o
+	}
+
+	amended := 0
+	for range 50 {
+		for _, m := range sim.doAmend() {
+			if m.Type != itch.MsgOrderCancel {
+				t.Fatalf("amend emitted %c, want only Order Cancel", m.Type)
+			}
+			prev, ok := before[m.OrderRef]
+			if !ok {
+				t.Fatalf("amend cancelled unknown order %d", m.OrderRef)
+			}
+			o := sim.Book().GetOrder(m.OrderRef)
+			if o == nil {
+				t.Fatalf("amended order %d left the book", m.OrderRef)
+			}
+			if o.Shares != prev.Shares-m.Shares || o.Shares <= 0 || m.Shares%100 != 0 {
+				t.Fatalf("order %d: %d shares less %d cancelled left %d", m.OrderRef, prev.Shares, m.Shares, o.Shares)
+			}
+			if o.Priority != prev.Priority {
+				t.Errorf("order %d lost its queue priority", m.OrderRef)
+			}
+			before[m.OrderRef] = *o
+			amended++
+		}
+	}
+	if amended == 0 {
+		t.Fatal("no order was amended")
+	}
+	if sim.Book().OrderCount() != len(before) {
+		t.Errorf("book holds %d orders after amends, want %d", sim.Book().OrderCount(), len(before))
+	}
+}
+
+func TestTradePriceRestingMode(t *testing.T) {
+	for _, p := range tradePrints(TradePriceResting, 100.004, 20) {
+		if math.Abs(p-99.99) > 1e-9 && math.Abs(p-100.01) > 1e-9 {
+			t.Errorf("resting mode printed %.4f, want a resting level (99.99 or 100.01)", p)
+		}
+	}
+}
+
+func TestTradePriceEngineMode(t *testing.T) {
+	// Inside the spread: prints track the engine price (snapped to the tick),
+	// not a resting level.
+	prints := tradePrints(TradePriceEngine, 100.004, 20)
+	if len(prints) == 0 {
+		t.Fatal("no trades printed")
+	}
+	for _, p := range prints {
+		if math.Abs(p-100.00) > 1e-9 {
+			t.Errorf("engine mode printed %.4f, want 100.00", p)
+		}
+	}
+
+	// Outside the spread: clamped to the touch so the print stays marketable.
+	for _, p := range tradePrints(TradePriceEngine, 105.00, 10) {
+		if math.Abs(p-100.01) > 1e-9 {
+			t.Errorf("engine price above ask printed %.4f, want clamp to 100.01", p)
+		}
+	}
+	for _, p := range tradePrints(TradePriceEngine, 95.00, 10) {
+		if math.Abs(p-99.99) > 1e-9 {
+			t.Errorf("engine price below bid printed %.4f, want clamp to 99.99", p)
+		}
+	}
+}
+
+// TestTradeSideSemantics runs a buy-aggressor trade under each TradeSide and
+// checks the print's Side follows the mode while Aggressor stays the buyer.
+func TestTradeSideSemantics(t *testing.T) {
+	for _, tt := range []struct {
+		mode TradeSide
+		want byte
+	}{
+		{TradeSideAggressor, 'B'},
+		{TradeSideResting, 'S'},
+	} {
+		sim := newTestSimulator()
+		sim.SetAggressorBias(AggressorBias{Buy: 1}) // always buy
+		sim.SetTradeSide(tt.mode)
+		sim.Initialize(100.00)
+		var trade *itch.Message
+		msgs := sim.doTrade(100.00)
+		for i := range msgs {
+			if msgs[i].Type == itch.MsgTrade {
+				trade = &msgs[i]
+			}
+		}
+		if trade == nil {
+			t.Fatalf("mode %d: no trade printed", tt.mode)
+		}
+		if trade.Side != tt.want {
+			t.Errorf("mode %d: Side = %q, want %q", tt.mode, trade.Side, tt.want)
+		}
+		if trade.Aggressor != 'B' || trade.AggressorSide() != 'B' {
+			t.Errorf("mode %d: aggressor = %q, want the buyer", tt.mode, trade.AggressorSide())
+		}
+	}
+
+	if _, err := ParseTradeSide("passive"); err == nil {
+		t.Error("unknown trade side accepted")
+	}
+}
+
+func TestLiquidityFlags(t *testing.T) {
+	for _, on := range []bool{false, true} {
+		sim := newTestSimulator()
+		sim.SetLiquidityFlags(on)
+		sim.Initialize(100.00)
+		var trades int
+		for _, m := range sim.doTrade(100.00) {
+			if m.Type != itch.MsgTrade {
+				continue
+			}
+			trades++
+			if want := map[bool]byte{true: itch.LiquidityRemoved}[on]; m.LiquidityFlag != want {
+				t.Errorf("flags %v: trade LiquidityFlag = %q, want %q", on, m.LiquidityFlag, want)
+			}
+		}
+		if trades == 0 {
+			t.Fatalf("flags %v: no trade printed", on)
+		}
+
+		sim.CollectAuction(100, 200)
+		cross := sim.CrossAuction()
+		if len(cross) != 1 {
+			t.Fatalf("flags %v: CrossAuction() = %+v, want one cross trade", on, cross)
+		}
+		if want := map[bool]byte{true: itch.LiquidityAuction}[on]; cross[0].LiquidityFlag != want {
+			t.Errorf("flags %v: cross LiquidityFlag = %q, want %q", on, cross[0].LiquidityFlag, want)
+		}
+	}
+}
+
+func TestMaxTradeSharesSplitsPrints(t *testing.T) {
+	for _, tt := range []struct {
+		limit  int32
+		prints int
+	}{{0, 1}, {1000, 5}, {1500, 4}} {
+		sim := newTestSimulator()
+		sim.SetMaxTradeShares(tt.limit)
+		sim.book.AddOrder(&Order{ID: 1, Side: SideBuy, Price: 99.99, Shares: 500})
+		sim.book.AddOrder(&Order{ID: 2, Side: SideSell, Price: 100.01, Shares: 6000})
+
+		msgs := sim.execute(sim.book.GetOrder(2), SideBuy, 5000, 100.00, 99.99, 100.01)
+		if len(msgs) != 2*tt.prints {
+			t.Fatalf("limit %d: %d messages, want %d executed/trade pairs", tt.limit, len(msgs), tt.prints)
+		}
+		var total int32
+		matches := make(map[uint64]bool)
+		for i := 0; i < len(msgs); i += 2 {
+			exec, trade := msgs[i], msgs[i+1]
+			if exec.Type != itch.MsgOrderExecuted || trade.Type != itch.MsgTrade {
+				t.Fatalf("limit %d: pair %d is %c/%c, want an execution then its trade", tt.limit, i/2, exec.Type, trade.Type)
+			}
+			if exec.MatchNumber != trade.MatchNumber || exec.Shares != trade.Shares || matches[trade.MatchNumber] {
+				t.Errorf("limit %d: pair %d = %+v / %+v, want one new match number and size", tt.limit, i/2, exec, trade)
+			}
+			matches[trade.MatchNumber] = true
+			if tt.limit > 0 && trade.Shares > tt.limit {
+				t.Errorf("limit %d: print of %d shares", tt.limit, trade.Shares)
+			}
+			total += trade.Shares
+		}
+		if total != 5000 {
+			t.Errorf("limit %d: prints sum to %d shares, want 5000", tt.limit, total)
+		}
+		if o := sim.book.GetOrder(2); o == nil || o.Shares != 1000 {
+			t.Errorf("limit %d: resting order left = %+v, want 1000 shares", tt.limit, o)
+		}
+	}
+}
+
+func TestTickDirectionClassifiesPrints(t *testing.T) {
+	sim := newTestSimulator()
+	prices := []float64{100.00, 100.01, 100.03, 100.02, 100.02, 99.98, 100.00}
+	want := []byte{0, itch.TickUp, itch.TickUp, itch.TickDown, itch.TickZero, itch.TickDown, itch.TickUp}
+	for i, p := range prices {
+		if got := sim.tickDirection(p); got != want[i] {
+			t.Errorf("print %d at %.2f: direction %q, want %q", i, p, got, want[i])
+		}
+	}
+}
+
+func TestTradesCarryTickDirection(t *testing.T) {
+	sim := newTestSimulator()
+	sim.Initialize(100.00)
+	var prev float64
+	trades := 0
+	for i := 0; i < 2000; i++ {
+		for _, m := range sim.Step(100.00, 1) {
+			if m.Type != itch.MsgTrade {
+				continue
+			}
+			var want byte
+			switch {
+			case prev == 0:
+			case m.Price > prev+1e-9:
+				want = itch.TickUp
+			case m.Price < prev-1e-9:
+				want = itch.TickDown
+			default:
+				want = itch.TickZero
+			}
+			if m.TickDirection != want {
+				t.Fatalf("trade at %.4f after %.4f: direction %q, want %q", m.Price, prev, m.TickDirection, want)
+			}
+			prev = m.Price
+			trades++
+		}
+	}
+	if trades < 2 {
+		t.Fatalf("only %d trades printed", trades)
+	}
+}
+
+func TestTradesFlagBlocks(t *testing.T) {
+	sim := newTestSimulator()
+	sim.SetBlockSize(300)
+	sim.Initialize(100.00)
+	var blocks, others int
+	for i := 0; i < 2000; i++ {
+		for _, m := range sim.Step(100.00, 1) {
+			if m.Type != itch.MsgTrade {
+				continue
+			}
+			if m.Block != (m.Shares >= 300) {
+				t.Fatalf("trade of %d shares: block = %v, want %v", m.Shares, m.Block, !m.Block)
+			}
+			if m.Block {
+				blocks++
+			} else {
+				others++
+			}
+		}
+	}
+	if blocks == 0 || others == 0 {
+		t.Fatalf("%d block and %d other trades, want both", blocks, others)
+	}
+
+	sim.SetBlockSize(0)
+	for i := 0; i < 500; i++ {
+		for _, m := range sim.Step(100.00, 1) {
+			if m.Block {
+				t.Fatalf("trade of %d shares flagged with blocks off", m.Shares)
+			}
+		}
+	}
+}
+
+func TestWidenOpensSpreadThenRefills(t *testing.T) {
+	sim := newTestSimulator()
+	sim.Initialize(100.00)
+	book := sim.Book()
+	before := book.BestAsk() - book.BestBid()
+
+	msgs := sim.Widen(5)
+	if len(msgs) == 0 {
+		t.Fatal("Widen(5) deleted nothing")
+	}
+	for _, m := range msgs {
+		if m.Type != itch.MsgOrderDelete || book.GetOrder(m.OrderRef) != nil {
+			t.Fatalf("message %+v: want a delete of a removed order", m)
+		}
+	}
+	spread := book.BestAsk() - book.BestBid()
+	if book.BestBid() == 0 || book.BestAsk() == 0 || spread < 5*0.01-1e-9 {
+		t.Fatalf("spread after Widen(5) = %.4f (bid %.2f, ask %.2f), want at least 5 ticks",
+			spread, book.BestBid(), book.BestAsk())
+	}
+	if err := book.Verify(); err != nil {
+		t.Fatalf("book inconsistent after widening: %v", err)
+	}
+
+	for i := 0; i < 200; i++ {
+		sim.Step(100.00, 2)
+	}
+	if narrowed := book.BestAsk() - book.BestBid(); narrowed >= spread || narrowed > before+5*0.01 {
+		t.Errorf("spread %.4f after replenishment, want narrower than the widened %.4f", narrowed, spread)
+	}
+}
+
+func TestParseTradePriceMode(t *testing.T) {
+	for in, want := range map[string]TradePriceMode{"": TradePriceResting, "resting": TradePriceResting, "engine": TradePriceEngine} {
+		if got, err := ParseTradePriceMode(in); err != nil || got != want {
+			t.Errorf("ParseTradePriceMode(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseTradePriceMode("mid"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+func TestDrainModeEmptiesBook(t *testing.T) {
+	sim := newTestSimulator()
+	book := sim.Book()
+	sim.Initialize(100.00)
+	sim.SetDrain(true)
+
+	prev := book.OrderCount()
+	start := prev
+	for i := 0; i < 5000 && prev > 0; i++ {
+		for _, m := range sim.Step(100.00, 3) {
+			if m.Type == itch.MsgAddOrder || m.Type == itch.MsgAddOrderMPID {
+				t.Fatalf("step %d: drain mode emitted an add order", i)
+			}
+		}
+		n := book.OrderCount()
+		if n > prev {
+			t.Fatalf("step %d: order count rose from %d to %d while draining", i, prev, n)
+		}
+		prev = n
+	}
+	if prev != 0 {
+		t.Fatalf("order count fell from %d to %d, want 0", start, prev)
+	}
+
+	sim.SetDrain(false)
+	for i := 0; i < 200; i++ {
+		sim.Step(100.00, 3)
+	}
+	if book.OrderCount() <= prev {
+		t.Errorf("order count %d did not recover after leaving drain mode", book.OrderCount())
+	}
+}
+
+// buyAggressorShare steps sim around the given price path and returns the
+// fraction of trades whose aggressor was a buyer.
+func buyAggressorShare(t *testing.T, sim *Simulator, prices func(i int) float64, steps int) float64 {
+	t.Helper()
+	buys, trades := 0, 0
+	for i := 0; i < steps; i++ {
+		for _, m := range sim.Step(prices(i), 3) {
+			if m.Type != itch.MsgTrade {
+				continue
+			}
+			trades++
+			if m.Side == byte(SideBuy) {
+				buys++
+			}
+		}
+	}
+	if trades < 500 {
+		t.Fatalf("only %d trades printed", trades)
+	}
+	return float64(buys) / float64(trades)
+}
+
+func TestAggressorBiasSkewsFlow(t *testing.T) {
+	flat := func(int) float64 { return 100.00 }
+
+	sim := newTestSimulator()
+	sim.Initialize(100.00)
+	if got := buyAggressorShare(t, sim, flat, 5000); math.Abs(got-0.5) > 0.05 {
+		t.Errorf("balanced flow: buy share %.3f, want ~0.5", got)
+	}
+
+	sim = newTestSimulator()
+	sim.Initialize(100.00)
+	sim.SetAggressorBias(AggressorBias{Buy: 0.85})
+	if got := buyAggressorShare(t, sim, flat, 5000); got < 0.8 {
+		t.Errorf("buy bias 0.85: buy share %.3f, want > 0.8", got)
+	}
+}
+
+func TestAggressorMomentumFollowsPrice(t *testing.T) {
+	sim := newTestSimulator()
+	sim.Initialize(100.00)
+	sim.SetAggressorBias(AggressorBias{Buy: 0.5, Momentum: 0.4})
+	falling := func(i int) float64 { return 100.00 - float64(i)*0.0001 }
+	if got := buyAggressorShare(t, sim, falling, 5000); got > 0.2 {
+		t.Errorf("falling price with momentum: buy share %.3f, want < 0.2", got)
+	}
+}
+
+func TestSeedImbalanceSkewsInitialLiquidity(t *testing.T) {
+	sideShares := func(sim *Simulator) (bid, ask int64) {
+		for _, o := range sim.Book().RestingOrders() {
+			if o.Side == SideBuy {
+				bid += int64(o.Shares)
+			} else {
+				ask += int64(o.Shares)
+			}
+		}
+		return bid, ask
+	}
+
+	sim := newTestSimulator()
+	if err := sim.SetSeedImbalance(3); err != nil {
+		t.Fatalf("SetSeedImbalance: %v", err)
+	}
+	sim.Initialize(100.00)
+	bid, ask := sideShares(sim)
+	if r := float64(bid) / float64(ask); r < 2.5 || r > 3.5 {
+		t.Errorf("3:1 seed: bid/ask shares = %d/%d (%.2f), want ~3", bid, ask, r)
+	}
+
+	sim = newTestSimulator()
+	if err := sim.SetSeedImbalance(1.0 / 3); err != nil {
+		t.Fatalf("SetSeedImbalance: %v", err)
+	}
+	sim.Initialize(100.00)
+	bid, ask = sideShares(sim)
+	if r := float64(ask) / float64(bid); r < 2.5 || r > 3.5 {
+		t.Errorf("1:3 seed: ask/bid shares = %d/%d (%.2f), want ~3", ask, bid, r)
+	}
+
+	if sim.SetSeedImbalance(0) == nil || sim.SetSeedImbalance(-2) == nil {
+		t.Error("non-positive ratio accepted, want error")
+	}
+}
+
+func TestParseSeedImbalance(t *testing.T) {
+	got, err := ParseSeedImbalance("NEXO=3, ACME=0.5")
+	if err != nil {
+		t.Fatalf("ParseSeedImbalance: %v", err)
+	}
+	if len(got) != 2 || got["NEXO"] != 3 || got["ACME"] != 0.5 {
+		t.Errorf("got %v", got)
+	}
+	for _, spec := range []string{"NEXO", "NEXO=0", "NEXO=x", "=2", "NEXO=2,NEXO=3"} {
+		if _, err := ParseSeedImbalance(spec); err == nil {
+			t.Errorf("ParseSeedImbalance(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestAggressorBiasValidate(t *testing.T) {
+	for _, b := range []AggressorBias{{Buy: -0.1}, {Buy: 1.1}, {Buy: 0.5, Momentum: -1}, {Buy: math.NaN()}} {
+		if b.Validate() == nil {
+			t.Errorf("%+v accepted, want error", b)
+		}
+	}
+	if err := BalancedFlow.Validate(); err != nil {
+		t.Errorf("BalancedFlow: %v", err)
+	}
+}
+
+func TestMaxOrdersPerLevelPublishesEvictions(t *testing.T) {
+	sim := newTestSimulator()
+	book := sim.Book()
+	sim.Initialize(100.00) // OrdersPerLevel orders at every level
+	book.SetMaxOrdersPerLevel(OrdersPerLevel)
+
+	live := make(map[uint64]bool)
+	for _, o := range book.AllOrders() {
+		live[o.ID] = true
+	}
+	for i := 0; i < 2000; i++ {
+		// Replaying the messages must track the book exactly, so every
+		// eviction the cap causes is published.
+		for _, m := range sim.Step(100.00, 3) {
+			switch m.Type {
+			case itch.MsgAddOrder, itch.MsgAddOrderMPID:
+				live[m.OrderRef] = true
+			case itch.MsgOrderDelete:
+				delete(live, m.OrderRef)
+			case itch.MsgOrderReplace:
+				delete(live, m.OrigOrderRef)
+				live[m.OrderRef] = true
+			case itch.MsgOrderExecuted:
+				if book.GetOrder(m.OrderRef) == nil {
+					delete(live, m.OrderRef)
+				}
+			}
+		}
+		if len(live) != book.OrderCount() {
+			t.Fatalf("step %d: messages imply %d live orders, book has %d", i, len(live), book.OrderCount())
+		}
+		for _, lvl := range append(book.Depth().Bids, book.Depth().Asks...) {
+			if lvl.Orders > OrdersPerLevel {
+				t.Fatalf("step %d: level %.2f holds %d orders, cap is %d", i, lvl.Price, lvl.Orders, OrdersPerLevel)
+			}
+		}
+	}
+}
+
+func TestInitializeUsesTickSchedule(t *testing.T) {
+	ts, err := symbol.ParseTickSchedule("0:0.0001,1:0.01,1000:0.05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		ref, tick float64
+	}{{0.50, 0.0001}, {2000.00, 0.05}} {
+		sim := newTestSimulator()
+		sim.SetTickSchedule(ts)
+		sim.Initialize(c.ref)
+		if got := sim.Book().BestAsk() - sim.Book().BestBid(); math.Abs(got-2*c.tick) > 1e-9 {
+			t.Errorf("ref %v: spread %v, want two %v ticks", c.ref, got, c.tick)
+		}
+		for i := 0; i < 500; i++ {
+			for _, m := range sim.Step(c.ref, 3) {
+				if m.Type != itch.MsgAddOrder && m.Type != itch.MsgAddOrderMPID && m.Type != itch.MsgOrderReplace {
+					continue
+				}
+				if n := m.Price / c.tick; math.Abs(n-math.Round(n)) > 1e-6 {
+					t.Fatalf("ref %v: order price %v not on the %v tick", c.ref, m.Price, c.tick)
+				}
+			}
+		}
+	}
+}
+
+func TestFloorRoundingNeverRoundsUp(t *testing.T) {
+	sim := newTestSimulator()
+	sim.SetRounding(symbol.RoundFloor)
+	rng := engine.NewRNG(7)
+	for range 10000 {
+		p := 1 + rng.Float64()*500
+		got := sim.snap(p)
+		if got > p+1e-9 || p-got >= 0.01-1e-9 {
+			t.Fatalf("floor snap(%v) = %v, want the tick at or below", p, got)
+		}
+	}
+	if got := sim.snap(185.01); math.Abs(got-185.01) > 1e-9 {
+		t.Errorf("floor snap(185.01) = %v, want a price already on the tick kept", got)
+	}
+}
+
+func TestSetAttributionCustomMPIDsAndRate(t *testing.T) {
+	custom := map[string]bool{"AAAA": true, "BBBB": true, "CCC": true}
+	sim := newTestSimulator()
+	if err := sim.SetAttribution([]string{"AAAA", "BBBB", "CCC"}, 0.1); err != nil {
+		t.Fatalf("SetAttribution: %v", err)
+	}
+
+	msgs := sim.Initialize(100.00)
+	for i := range 2000 {
+		msgs = append(msgs, sim.Step(100.00+float64(i%7)*0.01, 3)...)
+	}
+	var adds, attributed int
+	for _, m := range msgs {
+		switch m.Type {
+		case itch.MsgAddOrder:
+			adds++
+		case itch.MsgAddOrderMPID:
+			adds++
+			attributed++
+			if !custom[m.MPID] {
+				t.Fatalf("MPID %q is not from the configured list", m.MPID)
+			}
+		}
+	}
+	if rate := float64(attributed) / float64(adds); math.Abs(rate-0.1) > 0.03 {
+		t.Errorf("attributed %d of %d adds (%.3f), want ~0.1", attributed, adds, rate)
+	}
+
+	for _, bad := range [][]string{nil, {""}, {"TOOLONG"}} {
+		if sim.SetAttribution(bad, 0.1) == nil {
+			t.Errorf("SetAttribution(%q) accepted", bad)
+		}
+	}
+	if sim.SetAttribution([]string{"AAAA"}, 1.5) == nil {
+		t.Error("rate 1.5 accepted")
+	}
+}
+
+func TestMarketMakersHoldTwoSidedQuotes(t *testing.T) {
+	sim := newTestSimulator()
+	if err := sim.SetMarketMakers(len(mpids) + 1); err == nil {
+		t.Fatal("SetMarketMakers accepted more makers than MPIDs")
+	}
+	if err := sim.SetMarketMakers(3); err != nil {
+		t.Fatal(err)
+	}
+	makers := map[string]bool{}
+	for _, mpid := range sim.MarketMakers() {
+		makers[mpid] = true
+	}
+	book := sim.Book()
+
+	quotes := func(step int) map[uint64]bool {
+		ids := map[uint64]bool{}
+		count := map[string][2]int{}
+		for _, o := range book.AllOrders() {
+			if o.MPID == "" {
+				continue
+			}
+			if !makers[o.MPID] {
+				t.Fatalf("step %d: order %d attributed to non-maker %q", step, o.ID, o.MPID)
+			}
+			c := count[o.MPID]
+			if o.Side == SideBuy {
+				c[0]++
+			} else {
+				c[1]++
+			}
+			count[o.MPID] = c
+			ids[o.ID] = true
+		}
+		for mpid := range makers {
+			if c := count[mpid]; c != [2]int{1, 1} {
+				t.Fatalf("step %d: %s has %d bids and %d asks, want one each", step, mpid, c[0], c[1])
+			}
+		}
+		return ids
+	}
+
+	sim.Initialize(100.00)
+	prev := quotes(-1)
+	price := 100.00
+	requotes := 0
+	for i := 0; i < 1000; i++ {
+		price += float64(sim.rng.IntRange(-2, 2)) * 0.01
+		for _, m := range sim.Step(price, 3) {
+			if m.Type == itch.MsgOrderReplace && prev[m.OrigOrderRef] {
+				requotes++
+			}
+		}
+		prev = quotes(i)
+	}
+	if requotes == 0 {
+		t.Fatal("maker quotes never moved via Order Replace")
+	}
+}
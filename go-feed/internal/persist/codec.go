@@ -0,0 +1,283 @@
+package persist
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+)
+
+// StateCodec encodes the state blobs the snapshotter keeps in sim_state.
+type StateCodec interface {
+	Name() string
+	Encode(b []byte) ([]byte, error)
+	Decode(b []byte) ([]byte, error)
+}
+
+// Codec IDs recorded in each blob's header, so a snapshot loads whichever
+// codec the current process is configured with.
+const (
+	codecRaw  byte = 0
+	codecGzip byte = 1
+	codecZstd byte = 2
+)
+
+// A codec-framed blob starts with a header: stateMagic, the header version,
+// the codec ID, then the encoded payload's length as a big-endian uint32.
+var stateMagic = []byte("FSS")
+
+const (
+	stateVersion    byte = 1
+	stateHeaderSize      = 3 + 1 + 1 + 4
+
+	// legacyRNGSize is the length of an rng_state saved before blobs were
+	// framed: the raw PCG state.
+	legacyRNGSize = 16
+)
+
+type rawCodec struct{}
+
+func (rawCodec) Name() string                    { return "raw" }
+func (rawCodec) Encode(b []byte) ([]byte, error) { return b, nil }
+func (rawCodec) Decode(b []byte) ([]byte, error) { return b, nil }
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return "gzip" }
+
+func (gzipCodec) Encode(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCodec) Decode(b []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// zstdEncoder and zstdDecoder are shared: EncodeAll and DecodeAll are safe
+// for concurrent use, and building either allocates sizeable tables.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+)
+
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string { return "zstd" }
+
+func (zstdCodec) Encode(b []byte) ([]byte, error) {
+	return zstdEncoder.EncodeAll(b, nil), nil
+}
+
+func (zstdCodec) Decode(b []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(b, nil)
+}
+
+// ParseStateCodec returns the codec named by name: "raw" (or empty), "gzip"
+// or "zstd".
+func ParseStateCodec(name string) (StateCodec, error) {
+	switch name {
+	case "", "raw":
+		return rawCodec{}, nil
+	case "gzip":
+		return gzipCodec{}, nil
+	case "zstd":
+		return zstdCodec{}, nil
+	}
+	return nil, fmt.Errorf("unknown state codec %q (want raw, gzip or zstd)", name)
+}
+
+func codecID(c StateCodec) (byte, error) {
+	switch c.(type) {
+	case rawCodec:
+		return codecRaw, nil
+	case gzipCodec:
+		return codecGzip, nil
+	case zstdCodec:
+		return codecZstd, nil
+	}
+	return 0, fmt.Errorf("state codec %s has no blob ID", c.Name())
+}
+
+// encodeState frames b, encoded with c, behind the blob header.
+func encodeState(c StateCodec, b []byte) ([]byte, error) {
+	id, err := codecID(c)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := c.Encode(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s encode: %w", c.Name(), err)
+	}
+	if uint64(len(enc)) > math.MaxUint32 {
+		return nil, fmt.Errorf("%s-encoded state is %d bytes, over the blob limit", c.Name(), len(enc))
+	}
+	out := make([]byte, 0, stateHeaderSize+len(enc))
+	out = append(out, stateMagic...)
+	out = append(out, stateVersion, id)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(enc)))
+	return append(out, enc...), nil
+}
+
+// decodeState reverses encodeState with the codec named in the header. The
+// one unframed blob it accepts is a legacyRNGSize rng_state from before
+// codecs, recognised by its length and by lacking a header whose version
+// and payload length match the blob; a random PCG state carries a matching
+// header with negligible odds. Any other blob without a complete header is
+// an error, so a truncated blob is never mistaken for raw state.
+func decodeState(b []byte) ([]byte, error) {
+	framed := len(b) >= stateHeaderSize && bytes.HasPrefix(b, stateMagic) &&
+		b[len(stateMagic)] == stateVersion &&
+		int64(binary.BigEndian.Uint32(b[len(stateMagic)+2:])) == int64(len(b)-stateHeaderSize)
+	if !framed {
+		switch {
+		case len(b) == legacyRNGSize:
+			return b, nil
+		case !bytes.HasPrefix(b, stateMagic):
+			return nil, fmt.Errorf("state blob of %d bytes has no codec header", len(b))
+		case len(b) < stateHeaderSize:
+			return nil, fmt.Errorf("state blob header truncated at %d bytes", len(b))
+		case b[len(stateMagic)] != stateVersion:
+			return nil, fmt.Errorf("unknown state blob version %d", b[len(stateMagic)])
+		default:
+			return nil, fmt.Errorf("state blob truncated: header gives a %d-byte payload, found %d",
+				binary.BigEndian.Uint32(b[len(stateMagic)+2:]), len(b)-stateHeaderSize)
+		}
+	}
+	var c StateCodec
+	switch id := b[len(stateMagic)+1]; id {
+	case codecRaw:
+		c = rawCodec{}
+	case codecGzip:
+		c = gzipCodec{}
+	case codecZstd:
+		c = zstdCodec{}
+	default:
+		return nil, fmt.Errorf("unknown state codec ID %d", id)
+	}
+	out, err := c.Decode(b[stateHeaderSize:])
+	if err != nil {
+		return nil, fmt.Errorf("%s decode: %w", c.Name(), err)
+	}
+	return out, nil
+}
+
+// marshalOrders packs resting orders into the book_state blob: per order the
+// ID, locate, side, price bits, shares, priority, then a length-prefixed MPID.
+func marshalOrders(orders []orderbook.Order) []byte {
+	buf := make([]byte, 0, len(orders)*36)
+	for _, o := range orders {
+		buf = binary.BigEndian.AppendUint64(buf, o.ID)
+		buf = binary.BigEndian.AppendUint16(buf, o.Locate)
+		buf = append(buf, byte(o.Side))
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(o.Price))
+		buf = binary.BigEndian.AppendUint32(buf, uint32(o.Shares))
+		buf = binary.BigEndian.AppendUint64(buf, o.Priority)
+		buf = append(buf, byte(len(o.MPID)))
+		buf = append(buf, o.MPID...)
+	}
+	return buf
+}
+
+// unmarshalOrders reverses marshalOrders.
+func unmarshalOrders(b []byte) ([]orderbook.Order, error) {
+	const fixed = 8 + 2 + 1 + 8 + 4 + 8 + 1
+	var orders []orderbook.Order
+	for len(b) > 0 {
+		if len(b) < fixed {
+			return nil, fmt.Errorf("book state truncated after %d orders", len(orders))
+		}
+		o := orderbook.Order{
+			ID:       binary.BigEndian.Uint64(b[0:]),
+			Locate:   binary.BigEndian.Uint16(b[8:]),
+			Side:     orderbook.Side(b[10]),
+			Price:    math.Float64frombits(binary.BigEndian.Uint64(b[11:])),
+			Shares:   int32(binary.BigEndian.Uint32(b[19:])),
+			Priority: binary.BigEndian.Uint64(b[23:]),
+		}
+		n := int(b[31])
+		b = b[fixed:]
+		if len(b) < n {
+			return nil, fmt.Errorf("book state truncated after %d orders", len(orders))
+		}
+		o.MPID = string(b[:n])
+		b = b[n:]
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+// marshalOpens packs session opens into the session_open blob: per symbol,
+// in locate order, the locate then the price bits.
+func marshalOpens(opens map[uint16]float64) []byte {
+	locates := make([]uint16, 0, len(opens))
+	for loc := range opens {
+		locates = append(locates, loc)
+	}
+	slices.Sort(locates)
+	buf := make([]byte, 0, len(opens)*10)
+	for _, loc := range locates {
+		buf = binary.BigEndian.AppendUint16(buf, loc)
+		buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(opens[loc]))
+	}
+	return buf
+}
+
+// unmarshalOpens reverses marshalOpens.
+func unmarshalOpens(b []byte) (map[uint16]float64, error) {
+	if len(b)%10 != 0 {
+		return nil, fmt.Errorf("session opens: %d bytes is not a whole number of entries", len(b))
+	}
+	opens := make(map[uint16]float64, len(b)/10)
+	for ; len(b) > 0; b = b[10:] {
+		opens[binary.BigEndian.Uint16(b)] = math.Float64frombits(binary.BigEndian.Uint64(b[2:]))
+	}
+	return opens, nil
+}
+
+// marshalCounters packs per-symbol counters into a blob: per symbol, in
+// locate order, the locate then the counter.
+func marshalCounters(counters map[uint16]uint64) []byte {
+	locates := make([]uint16, 0, len(counters))
+	for loc := range counters {
+		locates = append(locates, loc)
+	}
+	slices.Sort(locates)
+	buf := make([]byte, 0, len(counters)*10)
+	for _, loc := range locates {
+		buf = binary.BigEndian.AppendUint16(buf, loc)
+		buf = binary.BigEndian.AppendUint64(buf, counters[loc])
+	}
+	return buf
+}
+
+// unmarshalCounters reverses marshalCounters.
+func unmarshalCounters(b []byte) (map[uint16]uint64, error) {
+	if len(b)%10 != 0 {
+		return nil, fmt.Errorf("order ID counters: %d bytes is not a whole number of entries", len(b))
+	}
+	counters := make(map[uint16]uint64, len(b)/10)
+	for ; len(b) > 0; b = b[10:] {
+		counters[binary.BigEndian.Uint16(b)] = binary.BigEndian.Uint64(b[2:])
+	}
+	return counters, nil
+}
@@ -0,0 +1,123 @@
+package persist
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+)
+
+func TestStateCodecRoundTrip(t *testing.T) {
+	orders := []orderbook.Order{
+		{ID: 7, Locate: 1, Side: orderbook.SideBuy, Price: 184.99, Shares: 300, Priority: 12, MPID: "GSCO"},
+		{ID: 9, Locate: 2, Side: orderbook.SideSell, Price: 42.5, Shares: 100, Priority: 15},
+	}
+	rng := engine.NewRNG(42).StateBytes()
+
+	for _, name := range []string{"raw", "gzip", "zstd"} {
+		t.Run(name, func(t *testing.T) {
+			codec, err := ParseStateCodec(name)
+			if err != nil {
+				t.Fatalf("ParseStateCodec(%q): %v", name, err)
+			}
+
+			blob, err := encodeState(codec, marshalOrders(orders))
+			if err != nil {
+				t.Fatalf("encode book: %v", err)
+			}
+			raw, err := decodeState(blob)
+			if err != nil {
+				t.Fatalf("decode book: %v", err)
+			}
+			got, err := unmarshalOrders(raw)
+			if err != nil {
+				t.Fatalf("unmarshal orders: %v", err)
+			}
+			if !reflect.DeepEqual(got, orders) {
+				t.Errorf("orders = %+v, want %+v", got, orders)
+			}
+
+			blob, err = encodeState(codec, rng)
+			if err != nil {
+				t.Fatalf("encode rng: %v", err)
+			}
+			if raw, err := decodeState(blob); err != nil || !bytes.Equal(raw, rng) {
+				t.Errorf("rng state = %x, %v; want %x", raw, err, rng)
+			}
+		})
+	}
+}
+
+func TestDecodeStateLegacyRNG(t *testing.T) {
+	rng := engine.NewRNG(1).StateBytes()
+	if got, err := decodeState(rng); err != nil || !bytes.Equal(got, rng) {
+		t.Errorf("decodeState(unframed) = %x, %v; want it unchanged", got, err)
+	}
+	// A raw state may begin with the magic, even with a valid codec ID.
+	copy(rng, "FSS\x01\x01")
+	if got, err := decodeState(rng); err != nil || !bytes.Equal(got, rng) {
+		t.Errorf("decodeState(unframed, magic prefix) = %x, %v; want it unchanged", got, err)
+	}
+}
+
+// TestDecodeStateRejectsDamagedBlobs checks that a blob with a cut-off or
+// inconsistent header is an error rather than being loaded as raw state.
+func TestDecodeStateRejectsDamagedBlobs(t *testing.T) {
+	blob, err := encodeState(gzipCodec{}, marshalOrders([]orderbook.Order{{ID: 1, Locate: 1, Side: orderbook.SideBuy, Price: 10, Shares: 100}}))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	for name, b := range map[string][]byte{
+		"truncated header":  blob[:5],
+		"truncated payload": blob[:len(blob)-1],
+		"no header":         blob[stateHeaderSize:],
+		"unknown version":   append([]byte("FSS\x09"), blob[4:]...),
+	} {
+		if got, err := decodeState(b); err == nil {
+			t.Errorf("%s: decoded to %x, want an error", name, got)
+		}
+	}
+}
+
+func TestParseStateCodecRejects(t *testing.T) {
+	for _, name := range []string{"lz4", "GZIP"} {
+		if _, err := ParseStateCodec(name); err == nil {
+			t.Errorf("ParseStateCodec(%q) succeeded, want an error", name)
+		}
+	}
+}
+
+func TestSessionOpensRoundTrip(t *testing.T) {
+	opens := map[uint16]float64{1: 185.25, 7: 78.5, 30: 412.0001}
+	got, err := unmarshalOpens(marshalOpens(opens))
+	if err != nil {
+		t.Fatalf("unmarshalOpens: %v", err)
+	}
+	if len(got) != len(opens) {
+		t.Fatalf("got %d opens, want %d", len(got), len(opens))
+	}
+	for loc, p := range opens {
+		if got[loc] != p {
+			t.Errorf("open[%d] = %v, want %v", loc, got[loc], p)
+		}
+	}
+	if _, err := unmarshalOpens([]byte{0, 1, 2}); err == nil {
+		t.Error("truncated blob decoded without error")
+	}
+}
+
+func TestOrderIDCountersRoundTrip(t *testing.T) {
+	want := map[uint16]uint64{1: 42, 2: 7, 30: 1 << 40}
+	got, err := unmarshalCounters(marshalCounters(want))
+	if err != nil {
+		t.Fatalf("unmarshalCounters: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %v, want %v", got, want)
+	}
+	if _, err := unmarshalCounters(make([]byte, 9)); err == nil {
+		t.Error("unmarshalCounters accepted a partial entry")
+	}
+}
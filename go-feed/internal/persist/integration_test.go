@@ -7,6 +7,10 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
 )
 
 // newTestPool connects to the database named by TEST_DATABASE_URL and skips the
@@ -80,6 +84,46 @@ func TestPgQueryTradesClampAndOrder(t *testing.T) {
 	}
 }
 
+// TestPgQueryTradesSinceMatch checks the incremental contract: only trades
+// after the given match number, in ascending match order, with limit applied
+// from the oldest end.
+func TestPgQueryTradesSinceMatch(t *testing.T) {
+	pool := newTestPool(t)
+	r := NewPgTradeReader(pool)
+	base := time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	var trades []Trade
+	for i := 0; i < 6; i++ {
+		trades = append(trades, Trade{
+			Ticker: "NEXO", Price: 100 + float64(i), Shares: 10,
+			Aggressor: "B", ExecutedAt: base.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	seedTrades(t, pool, trades, 1) // match numbers 1..6
+
+	got, err := r.QueryTrades(context.Background(), TradeFilter{SymbolLocate: 1, SinceMatch: 2, Limit: 3})
+	if err != nil {
+		t.Fatalf("QueryTrades: %v", err)
+	}
+	want := []int64{3, 4, 5}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d trades, got %d", len(want), len(got))
+	}
+	for i, mn := range want {
+		if got[i].MatchNumber != mn {
+			t.Errorf("got[%d].MatchNumber = %d, want %d", i, got[i].MatchNumber, mn)
+		}
+	}
+
+	got, err = r.QueryTrades(context.Background(), TradeFilter{SymbolLocate: 1, SinceMatch: 6})
+	if err != nil {
+		t.Fatalf("QueryTrades: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("expected no trades after the last match, got %d", len(got))
+	}
+}
+
 func TestPgQueryCandlesBeforeAndFill(t *testing.T) {
 	pool := newTestPool(t)
 	r := NewPgTradeReader(pool)
@@ -208,3 +252,131 @@ func TestPgQueryDBSize(t *testing.T) {
 		t.Errorf("expected positive pct, got %v", size.PctOfBudget())
 	}
 }
+
+// TestSaveTradeRunIDIdempotency checks that match numbers are unique per run:
+// a retry within a run is dropped, while a later run reusing the same match
+// number (reset counter) still persists.
+func TestSaveTradeRunIDIdempotency(t *testing.T) {
+	pool := newTestPool(t)
+	ctx := context.Background()
+	if _, err := pool.Exec(ctx, `TRUNCATE trades`); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	snap := NewSnapshotter(&Store{pool: pool}, nil, nil, nil, nil)
+
+	snap.SetRunID("run-a")
+	for i := 0; i < 2; i++ { // second call is a retry of the same trade
+		if err := snap.SaveTrade(ctx, 42, 1, 100, 10, 'B'); err != nil {
+			t.Fatalf("SaveTrade run-a: %v", err)
+		}
+	}
+	snap.SetRunID("run-b")
+	if err := snap.SaveTrade(ctx, 42, 1, 101, 20, 'S'); err != nil {
+		t.Fatalf("SaveTrade run-b: %v", err)
+	}
+
+	got, err := NewPgTradeReader(pool).QueryTrades(ctx, TradeFilter{SymbolLocate: 1})
+	if err != nil {
+		t.Fatalf("QueryTrades: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 trades (one per run), got %d: %+v", len(got), got)
+	}
+	runs := map[string]bool{}
+	for _, tr := range got {
+		if tr.MatchNumber != 42 {
+			t.Errorf("MatchNumber = %d, want 42", tr.MatchNumber)
+		}
+		runs[tr.RunID] = true
+	}
+	if !runs["run-a"] || !runs["run-b"] {
+		t.Errorf("run IDs = %v, want run-a and run-b", runs)
+	}
+}
+
+// TestSnapshotRestoresQueuePriority checks that a saved book comes back with
+// each level's queue in arrival order, and that the priority counter resumes
+// past every restored order.
+func TestSnapshotRestoresQueuePriority(t *testing.T) {
+	pool := newTestPool(t)
+	ctx := context.Background()
+	syms := symbol.AllSymbols()[:1]
+	locate := syms[0].LocateCode
+
+	newSnapshotter := func() (*Snapshotter, *orderbook.Book) {
+		rng := engine.NewRNG(1)
+		book := orderbook.NewBook(locate, syms[0].TickSize)
+		sims := map[uint16]*orderbook.Simulator{locate: orderbook.NewSimulator(rng, book, locate, syms[0].TickSize)}
+		return NewSnapshotter(&Store{pool: pool}, engine.NewMarketEngine(rng, syms), sims, rng, syms), book
+	}
+
+	snap, book := newSnapshotter()
+	for id := uint64(1); id <= 4; id++ {
+		book.AddOrder(&orderbook.Order{ID: id, Locate: locate, Side: orderbook.SideBuy, Price: 50.00, Shares: 100})
+	}
+	book.ReplaceOrder(1, 50.00, 200) // back of the queue
+	want := book.RestingOrders()
+	if err := snap.Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	orderbook.SetPriorityCounter(0)
+	loader, restored := newSnapshotter()
+	if ok, err := loader.Load(ctx); err != nil || !ok {
+		t.Fatalf("Load = %v, %v", ok, err)
+	}
+	got := restored.RestingOrders()
+	if len(got) != len(want) {
+		t.Fatalf("restored %d orders, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Priority != want[i].Priority {
+			t.Fatalf("queue[%d] = order %d priority %d, want order %d priority %d",
+				i, got[i].ID, got[i].Priority, want[i].ID, want[i].Priority)
+		}
+	}
+	if next := orderbook.GetPriorityCounter(); next < want[len(want)-1].Priority {
+		t.Fatalf("priority counter = %d, behind restored priority %d", next, want[len(want)-1].Priority)
+	}
+}
+
+func TestSnapshotPersistsSeed(t *testing.T) {
+	pool := newTestPool(t)
+	ctx := context.Background()
+	syms := symbol.AllSymbols()[:1]
+	if _, err := pool.Exec(ctx, `DELETE FROM sim_state WHERE key = 'rng_seed'`); err != nil {
+		t.Fatalf("clear seed: %v", err)
+	}
+
+	newSnapshotter := func(seed int64) *Snapshotter {
+		rng := engine.NewRNG(seed)
+		snap := NewSnapshotter(&Store{pool: pool}, engine.NewMarketEngine(rng, syms), nil, rng, syms)
+		snap.SetSeed(seed)
+		return snap
+	}
+
+	first := newSnapshotter(42)
+	if err := first.Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	var saved int64
+	if err := pool.QueryRow(ctx, `SELECT value_int FROM sim_state WHERE key = 'rng_seed'`).Scan(&saved); err != nil || saved != 42 {
+		t.Fatalf("saved seed = %d, %v; want 42", saved, err)
+	}
+
+	// A restart with a different seed reports, and keeps saving, the
+	// original one.
+	restarted := newSnapshotter(7)
+	if ok, err := restarted.Load(ctx); err != nil || !ok {
+		t.Fatalf("Load = %v, %v", ok, err)
+	}
+	if seed, ok := restarted.Seed(); !ok || seed != 42 {
+		t.Fatalf("Seed() after Load = %d, %v; want 42", seed, ok)
+	}
+	if err := restarted.Save(ctx); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := pool.QueryRow(ctx, `SELECT value_int FROM sim_state WHERE key = 'rng_seed'`).Scan(&saved); err != nil || saved != 42 {
+		t.Fatalf("seed after second save = %d, %v; want 42", saved, err)
+	}
+}
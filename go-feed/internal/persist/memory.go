@@ -0,0 +1,181 @@
+package persist
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// memoryRetention is how long InMemoryTradeReader keeps minute bars: long
+// enough for one 1d candle.
+const memoryRetention = 24 * time.Hour
+
+// ErrNoDatabase is returned by InMemoryTradeReader for the queries only a
+// trade store can answer.
+var ErrNoDatabase = errors.New("not available without a database")
+
+// InMemoryTradeReader serves candles and trade totals from trades recorded
+// in memory, for running without a database. It keeps one-minute bars per
+// symbol for the last 24 hours and builds the longer intervals from them, so
+// a candle query's From and To bounds match whole minutes. Individual trades
+// are not kept: trade lookups, histograms and database sizes return
+// ErrNoDatabase or zero values. It is safe for concurrent use.
+type InMemoryTradeReader struct {
+	mu     sync.RWMutex
+	series map[uint16]*minuteBars
+	stats  TradeStats
+	rate   *TradeRate
+	pruned int64 // unix minute bars were last pruned at
+}
+
+// minuteBars is one symbol's minute bars.
+type minuteBars struct {
+	bars   map[int64]*Candle // minute start (unix) -> bar
+	newest int64             // newest minute with a trade
+}
+
+// NewInMemoryTradeReader creates an empty in-memory reader.
+func NewInMemoryTradeReader() *InMemoryTradeReader {
+	return &InMemoryTradeReader{series: make(map[uint16]*minuteBars)}
+}
+
+// SetTradeRate counts every trade Record takes on r, as SaveTrade does for
+// persisted trades. nil disables it.
+func (r *InMemoryTradeReader) SetTradeRate(rate *TradeRate) {
+	r.rate = rate
+}
+
+// Record folds one trade for locate, executed at at, into its minute bar.
+// Trades should arrive roughly in time order: a late trade updates its bar's
+// high, low and volume but not its open or close.
+func (r *InMemoryTradeReader) Record(locate uint16, at time.Time, price float64, shares int32) {
+	minute := alignDown(at, 60).Unix()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.series[locate]
+	if !ok {
+		s = &minuteBars{bars: make(map[int64]*Candle)}
+		r.series[locate] = s
+	}
+	b, ok := s.bars[minute]
+	if !ok {
+		b = &Candle{Bucket: time.Unix(minute, 0).UTC(), Open: price, High: price, Low: price, Close: price}
+		s.bars[minute] = b
+	}
+	if minute >= s.newest {
+		s.newest = minute
+		b.Close = price
+	}
+	b.High = max(b.High, price)
+	b.Low = min(b.Low, price)
+	b.Volume += int64(shares)
+	b.Count++
+	r.stats.TotalTrades++
+	r.stats.TotalVolume += int64(shares)
+	r.rate.Add(1)
+
+	if minute > r.pruned {
+		r.pruned = minute
+		cutoff := minute - int64(memoryRetention/time.Second)
+		for _, s := range r.series {
+			for m := range s.bars {
+				if m < cutoff {
+					delete(s.bars, m)
+				}
+			}
+		}
+	}
+}
+
+// QueryCandles returns OHLCV bars for a symbol newest-first, built from the
+// minute bars, with the same interval, limit, bound, cursor and fill
+// handling as PgTradeReader.
+func (r *InMemoryTradeReader) QueryCandles(_ context.Context, f CandleFilter) ([]Candle, error) {
+	secs, ok := intervalSeconds[f.Interval]
+	if !ok {
+		return nil, NewIntervalError(f.Interval)
+	}
+	f.Limit = ClampLimit(f.Limit)
+
+	r.mu.RLock()
+	var minutes []Candle
+	if s, ok := r.series[f.SymbolLocate]; ok {
+		minutes = make([]Candle, 0, len(s.bars))
+		for _, b := range s.bars {
+			if f.From != nil && !b.Bucket.Add(time.Minute).After(*f.From) ||
+				f.To != nil && b.Bucket.After(*f.To) {
+				continue
+			}
+			minutes = append(minutes, *b)
+		}
+	}
+	r.mu.RUnlock()
+	slices.SortFunc(minutes, func(a, b Candle) int { return a.Bucket.Compare(b.Bucket) })
+
+	// Merge the minutes oldest-first into interval buckets.
+	var merged []Candle
+	for _, m := range minutes {
+		bucket := alignDown(m.Bucket, secs)
+		if f.Before != nil && !bucket.Before(*f.Before) {
+			continue
+		}
+		if n := len(merged); n > 0 && merged[n-1].Bucket.Equal(bucket) {
+			c := &merged[n-1]
+			c.High = max(c.High, m.High)
+			c.Low = min(c.Low, m.Low)
+			c.Close = m.Close
+			c.Volume += m.Volume
+			c.Count += m.Count
+			continue
+		}
+		m.Bucket = bucket
+		merged = append(merged, m)
+	}
+	slices.Reverse(merged)
+	candles := merged[:min(len(merged), f.Limit)]
+	if candles == nil {
+		candles = []Candle{}
+	}
+
+	if f.Fill {
+		if hi, lo, ok := f.fillBounds(secs, candles); ok {
+			return zeroFill(candles, hi, lo, secs, f.Limit), nil
+		}
+	}
+	return candles, nil
+}
+
+// QueryTradeStats returns the count and volume of every trade recorded.
+func (r *InMemoryTradeReader) QueryTradeStats(context.Context) (TradeStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.stats, nil
+}
+
+// QueryDBSize reports zero sizes: there is no database.
+func (r *InMemoryTradeReader) QueryDBSize(context.Context) (DBSize, error) {
+	return DBSize{}, nil
+}
+
+// QueryTrades returns ErrNoDatabase; individual trades are not kept.
+func (r *InMemoryTradeReader) QueryTrades(context.Context, TradeFilter) ([]Trade, error) {
+	return nil, ErrNoDatabase
+}
+
+// QueryTradesMulti returns ErrNoDatabase; individual trades are not kept.
+func (r *InMemoryTradeReader) QueryTradesMulti(context.Context, MultiTradeFilter) ([]Trade, error) {
+	return nil, ErrNoDatabase
+}
+
+// QueryTradeByMatch returns ErrNoDatabase; individual trades are not kept.
+func (r *InMemoryTradeReader) QueryTradeByMatch(context.Context, uint64) (Trade, error) {
+	return Trade{}, ErrNoDatabase
+}
+
+// QueryTradeSizeHistogram returns ErrNoDatabase; individual trades are not
+// kept.
+func (r *InMemoryTradeReader) QueryTradeSizeHistogram(context.Context, uint16, []int32) ([]HistogramBucket, error) {
+	return nil, ErrNoDatabase
+}
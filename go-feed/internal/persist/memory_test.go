@@ -0,0 +1,73 @@
+package persist
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInMemoryCandles(t *testing.T) {
+	r := NewInMemoryTradeReader()
+	t0 := time.Date(2026, 1, 2, 14, 30, 0, 0, time.UTC)
+	r.Record(1, t0.Add(5*time.Second), 10.00, 100)
+	r.Record(1, t0.Add(20*time.Second), 10.50, 200)
+	r.Record(1, t0.Add(40*time.Second), 9.80, 50)
+	r.Record(1, t0.Add(59*time.Second), 10.10, 150)
+	r.Record(2, t0.Add(30*time.Second), 99, 1000) // another symbol
+	r.Record(1, t0.Add(70*time.Second), 10.20, 300)
+
+	ctx := context.Background()
+	got, err := r.QueryCandles(ctx, CandleFilter{SymbolLocate: 1, Interval: "1m"})
+	if err != nil {
+		t.Fatalf("QueryCandles: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d candles, want 2: %+v", len(got), got)
+	}
+	want := Candle{Bucket: t0, Open: 10.00, High: 10.50, Low: 9.80, Close: 10.10, Volume: 500, Count: 4}
+	if got[1] != want {
+		t.Errorf("14:30 bar = %+v, want %+v", got[1], want)
+	}
+	if got[0].Bucket != t0.Add(time.Minute) || got[0].Open != 10.20 || got[0].Volume != 300 {
+		t.Errorf("newest bar = %+v, want 14:31 with one 300-share trade at 10.20", got[0])
+	}
+
+	// Longer intervals merge the minutes.
+	got, _ = r.QueryCandles(ctx, CandleFilter{SymbolLocate: 1, Interval: "5m"})
+	want = Candle{Bucket: t0, Open: 10.00, High: 10.50, Low: 9.80, Close: 10.20, Volume: 800, Count: 5}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("5m candles = %+v, want [%+v]", got, want)
+	}
+
+	// The cursor, like the database's, excludes buckets at or after it.
+	before := t0.Add(time.Minute)
+	got, _ = r.QueryCandles(ctx, CandleFilter{SymbolLocate: 1, Interval: "1m", Before: &before})
+	if len(got) != 1 || got[0].Bucket != t0 {
+		t.Errorf("before 14:31: %+v, want only the 14:30 bar", got)
+	}
+
+	if _, err := r.QueryCandles(ctx, CandleFilter{SymbolLocate: 1, Interval: "2m"}); err == nil {
+		t.Error("unsupported interval accepted")
+	}
+	if got, _ := r.QueryCandles(ctx, CandleFilter{SymbolLocate: 3, Interval: "1m"}); got == nil || len(got) != 0 {
+		t.Errorf("symbol with no trades: %v, want an empty slice", got)
+	}
+	if s, _ := r.QueryTradeStats(ctx); s.TotalTrades != 6 || s.TotalVolume != 1800 {
+		t.Errorf("stats = %+v, want 6 trades, 1800 shares", s)
+	}
+	if _, err := r.QueryTrades(ctx, TradeFilter{SymbolLocate: 1}); !errors.Is(err, ErrNoDatabase) {
+		t.Errorf("QueryTrades error = %v, want ErrNoDatabase", err)
+	}
+}
+
+func TestInMemoryCandlesPruneOldBars(t *testing.T) {
+	r := NewInMemoryTradeReader()
+	t0 := time.Date(2026, 1, 2, 14, 30, 0, 0, time.UTC)
+	r.Record(1, t0, 10, 100)
+	r.Record(1, t0.Add(memoryRetention+time.Minute), 11, 100)
+	got, _ := r.QueryCandles(context.Background(), CandleFilter{SymbolLocate: 1, Interval: "1m"})
+	if len(got) != 1 || got[0].Open != 11 {
+		t.Errorf("candles = %+v, want only the bar inside the retention window", got)
+	}
+}
@@ -2,14 +2,21 @@ package persist
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // Trade represents a persisted trade document.
 type Trade struct {
+	RunID       string    `json:"runId,omitempty"`
 	MatchNumber int64     `json:"matchNumber"`
 	Ticker      string    `json:"ticker"`
 	Price       float64   `json:"price"`
@@ -25,6 +32,10 @@ type TradeFilter struct {
 	Offset       int
 	From         *time.Time
 	To           *time.Time
+	// SinceMatch, when non-zero, switches to incremental mode: only trades with
+	// match_number > SinceMatch are returned, oldest-first (ascending match
+	// number) so a poller can feed the last number it saw back in.
+	SinceMatch uint64
 }
 
 // MultiTradeFilter selects trades across one or more symbols. Locates lists the
@@ -47,6 +58,9 @@ type Candle struct {
 	Close  float64   `json:"c"`
 	Volume int64     `json:"v"`
 	Count  int64     `json:"n"`
+	// Partial marks the still-forming bar for the current interval, built
+	// from live trades rather than the database (see the API's ?live=true).
+	Partial bool `json:"partial,omitempty"`
 }
 
 // CandleFilter controls candle query parameters.
@@ -70,11 +84,48 @@ type TradeStats struct {
 	TotalVolume int64 `json:"totalVolume"`
 }
 
+// HistogramBucket counts trades whose size falls in [Min, Max).
+type HistogramBucket struct {
+	Min   int32 `json:"min"`
+	Max   int32 `json:"max,omitempty"` // 0 = unbounded (the last bucket)
+	Count int64 `json:"count"`
+}
+
+// DefaultSizeBuckets are the trade-size histogram's bucket lower bounds when
+// none are requested.
+var DefaultSizeBuckets = []int32{1, 100, 200, 500, 1000, 2000, 5000, 10000}
+
+// MaxHistogramBuckets caps the number of buckets in one histogram query.
+const MaxHistogramBuckets = 50
+
+// ValidateSizeBuckets checks histogram bucket lower bounds: between 1 and
+// MaxHistogramBuckets positive bounds, strictly ascending.
+func ValidateSizeBuckets(buckets []int32) error {
+	if len(buckets) == 0 || len(buckets) > MaxHistogramBuckets {
+		return fmt.Errorf("want 1 to %d buckets, got %d", MaxHistogramBuckets, len(buckets))
+	}
+	for i, b := range buckets {
+		if b <= 0 {
+			return fmt.Errorf("bucket bound %d is not positive", b)
+		}
+		if i > 0 && b <= buckets[i-1] {
+			return fmt.Errorf("bucket bounds must ascend: %d follows %d", b, buckets[i-1])
+		}
+	}
+	return nil
+}
+
+// ErrTradeNotFound is returned by QueryTradeByMatch when no trade has the
+// match number.
+var ErrTradeNotFound = errors.New("trade not found")
+
 // TradeReader abstracts read-only trade/candle/stats queries.
 type TradeReader interface {
 	QueryTrades(ctx context.Context, f TradeFilter) ([]Trade, error)
 	QueryTradesMulti(ctx context.Context, f MultiTradeFilter) ([]Trade, error)
+	QueryTradeByMatch(ctx context.Context, matchNumber uint64) (Trade, error)
 	QueryCandles(ctx context.Context, f CandleFilter) ([]Candle, error)
+	QueryTradeSizeHistogram(ctx context.Context, locate uint16, buckets []int32) ([]HistogramBucket, error)
 	QueryTradeStats(ctx context.Context) (TradeStats, error)
 	QueryDBSize(ctx context.Context) (DBSize, error)
 }
@@ -125,12 +176,83 @@ func ValidInterval(s string) bool {
 	return ok
 }
 
+// SupportedIntervals returns the supported candle intervals, shortest first.
+func SupportedIntervals() []string {
+	out := make([]string, 0, len(intervalSeconds))
+	for iv := range intervalSeconds {
+		out = append(out, iv)
+	}
+	sort.Slice(out, func(i, j int) bool { return intervalSeconds[out[i]] < intervalSeconds[out[j]] })
+	return out
+}
+
+// NearestInterval returns the supported interval closest in length to s,
+// which may be any Go duration or a whole number of days ("2d"). Ties go to
+// the shorter interval. ok is false when s cannot be parsed as a duration.
+func NearestInterval(s string) (string, bool) {
+	var secs float64
+	if days, found := strings.CutSuffix(s, "d"); found {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return "", false
+		}
+		secs = n * 86400
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return "", false
+		}
+		secs = d.Seconds()
+	}
+	if !(secs > 0) {
+		return "", false
+	}
+	best, bestDiff := "", math.Inf(1)
+	for _, iv := range SupportedIntervals() {
+		if diff := math.Abs(float64(intervalSeconds[iv]) - secs); diff < bestDiff {
+			best, bestDiff = iv, diff
+		}
+	}
+	return best, true
+}
+
+// IntervalError reports an unsupported candle interval, with the nearest
+// supported one when the input parsed as a duration.
+type IntervalError struct {
+	Interval string
+	Nearest  string // "" when Interval is not a duration
+}
+
+// NewIntervalError builds the IntervalError for an unsupported interval.
+func NewIntervalError(interval string) *IntervalError {
+	nearest, _ := NearestInterval(interval)
+	return &IntervalError{Interval: interval, Nearest: nearest}
+}
+
+func (e *IntervalError) Error() string {
+	if e.Nearest == "" {
+		return fmt.Sprintf("unsupported interval: %s", e.Interval)
+	}
+	return fmt.Sprintf("unsupported interval: %s (did you mean %s?)", e.Interval, e.Nearest)
+}
+
 // IntervalSeconds returns the duration in seconds for a supported interval.
 func IntervalSeconds(s string) (int, bool) {
 	secs, ok := intervalSeconds[s]
 	return secs, ok
 }
 
+// BucketStart returns the start (UTC) of the interval bucket containing t, on
+// the same grid QueryCandles groups by. ok is false for an unsupported
+// interval.
+func BucketStart(t time.Time, interval string) (time.Time, bool) {
+	secs, ok := intervalSeconds[interval]
+	if !ok {
+		return time.Time{}, false
+	}
+	return alignDown(t, secs), true
+}
+
 // FillCandles zero-fills candles (newest-first) over the range implied by f,
 // inserting zero-volume bars for empty buckets, capped at limit. Exported for
 // the live+archive merge layer, which composes candles from two sources before
@@ -142,19 +264,33 @@ func FillCandles(candles []Candle, f CandleFilter, secs, limit int) []Candle {
 	return candles
 }
 
-// QueryTrades returns trades for a symbol with optional time range and pagination.
+// QueryTrades returns trades for a symbol with optional time range and pagination,
+// newest-first. When f.SinceMatch is set it instead returns trades after that
+// match number in ascending match order.
 func (r *PgTradeReader) QueryTrades(ctx context.Context, f TradeFilter) ([]Trade, error) {
 	f.Limit = ClampLimit(f.Limit)
 
-	rows, err := r.pool.Query(ctx,
-		`SELECT match_number, ticker, price, shares, aggressor, executed_at
+	query := `SELECT run_id, match_number, ticker, price, shares, aggressor, executed_at
 		 FROM trades
 		 WHERE symbol_locate = $1
 		   AND ($2::timestamptz IS NULL OR executed_at >= $2)
 		   AND ($3::timestamptz IS NULL OR executed_at <= $3)
 		 ORDER BY executed_at DESC
-		 LIMIT $4 OFFSET $5`,
-		int16(f.SymbolLocate), f.From, f.To, f.Limit, f.Offset)
+		 LIMIT $4 OFFSET $5`
+	args := []any{int16(f.SymbolLocate), f.From, f.To, f.Limit, f.Offset}
+	if f.SinceMatch > 0 {
+		query = `SELECT run_id, match_number, ticker, price, shares, aggressor, executed_at
+		 FROM trades
+		 WHERE symbol_locate = $1
+		   AND ($2::timestamptz IS NULL OR executed_at >= $2)
+		   AND ($3::timestamptz IS NULL OR executed_at <= $3)
+		   AND match_number > $6
+		 ORDER BY match_number ASC
+		 LIMIT $4 OFFSET $5`
+		args = append(args, int64(f.SinceMatch))
+	}
+
+	rows, err := r.pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("query trades: %w", err)
 	}
@@ -163,7 +299,7 @@ func (r *PgTradeReader) QueryTrades(ctx context.Context, f TradeFilter) ([]Trade
 	trades := []Trade{}
 	for rows.Next() {
 		var t Trade
-		if err := rows.Scan(&t.MatchNumber, &t.Ticker, &t.Price, &t.Shares, &t.Aggressor, &t.ExecutedAt); err != nil {
+		if err := rows.Scan(&t.RunID, &t.MatchNumber, &t.Ticker, &t.Price, &t.Shares, &t.Aggressor, &t.ExecutedAt); err != nil {
 			return nil, fmt.Errorf("scan trade: %w", err)
 		}
 		trades = append(trades, t)
@@ -174,6 +310,27 @@ func (r *PgTradeReader) QueryTrades(ctx context.Context, f TradeFilter) ([]Trade
 	return trades, nil
 }
 
+// QueryTradeByMatch returns the trade with matchNumber, or ErrTradeNotFound.
+// Match numbers carry over restarts with the snapshot, but should a fresh
+// start reuse one, the most recent trade wins.
+func (r *PgTradeReader) QueryTradeByMatch(ctx context.Context, matchNumber uint64) (Trade, error) {
+	var t Trade
+	err := r.pool.QueryRow(ctx,
+		`SELECT run_id, match_number, ticker, price, shares, aggressor, executed_at
+		 FROM trades
+		 WHERE match_number = $1
+		 ORDER BY executed_at DESC
+		 LIMIT 1`, int64(matchNumber)).
+		Scan(&t.RunID, &t.MatchNumber, &t.Ticker, &t.Price, &t.Shares, &t.Aggressor, &t.ExecutedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return Trade{}, ErrTradeNotFound
+	}
+	if err != nil {
+		return Trade{}, fmt.Errorf("query trade by match: %w", err)
+	}
+	return t, nil
+}
+
 // QueryTradesMulti returns trades across multiple symbols, ordered newest-first
 // with ticker as a stable tiebreak. Returns an empty slice if no locates given.
 func (r *PgTradeReader) QueryTradesMulti(ctx context.Context, f MultiTradeFilter) ([]Trade, error) {
@@ -188,7 +345,7 @@ func (r *PgTradeReader) QueryTradesMulti(ctx context.Context, f MultiTradeFilter
 	}
 
 	rows, err := r.pool.Query(ctx,
-		`SELECT match_number, ticker, price, shares, aggressor, executed_at
+		`SELECT run_id, match_number, ticker, price, shares, aggressor, executed_at
 		 FROM trades
 		 WHERE symbol_locate = ANY($1)
 		   AND ($2::timestamptz IS NULL OR executed_at >= $2)
@@ -204,7 +361,7 @@ func (r *PgTradeReader) QueryTradesMulti(ctx context.Context, f MultiTradeFilter
 	trades := []Trade{}
 	for rows.Next() {
 		var t Trade
-		if err := rows.Scan(&t.MatchNumber, &t.Ticker, &t.Price, &t.Shares, &t.Aggressor, &t.ExecutedAt); err != nil {
+		if err := rows.Scan(&t.RunID, &t.MatchNumber, &t.Ticker, &t.Price, &t.Shares, &t.Aggressor, &t.ExecutedAt); err != nil {
 			return nil, fmt.Errorf("scan trade: %w", err)
 		}
 		trades = append(trades, t)
@@ -219,7 +376,7 @@ func (r *PgTradeReader) QueryTradesMulti(ctx context.Context, f MultiTradeFilter
 func (r *PgTradeReader) QueryCandles(ctx context.Context, f CandleFilter) ([]Candle, error) {
 	secs, ok := intervalSeconds[f.Interval]
 	if !ok {
-		return nil, fmt.Errorf("unsupported interval: %s", f.Interval)
+		return nil, NewIntervalError(f.Interval)
 	}
 	f.Limit = ClampLimit(f.Limit)
 
@@ -322,6 +479,55 @@ func zeroFill(dbCandles []Candle, hi, lo time.Time, secs, limit int) []Candle {
 	return out
 }
 
+// QueryTradeSizeHistogram counts a symbol's trades by size. buckets are the
+// ascending lower bounds: bucket i holds sizes from buckets[i] up to, but not
+// including, buckets[i+1], and the last is unbounded. Trades smaller than
+// buckets[0] are not counted. Every bucket is returned, empty ones with a
+// zero count.
+func (r *PgTradeReader) QueryTradeSizeHistogram(ctx context.Context, locate uint16, buckets []int32) ([]HistogramBucket, error) {
+	if err := ValidateSizeBuckets(buckets); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.pool.Query(ctx,
+		`SELECT width_bucket(shares, $2::int[]) AS bucket, count(*)::bigint
+		 FROM trades
+		 WHERE symbol_locate = $1
+		   AND shares >= $3
+		 GROUP BY bucket`,
+		int16(locate), buckets, buckets[0])
+	if err != nil {
+		return nil, fmt.Errorf("query trade size histogram: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int64, len(buckets))
+	for rows.Next() {
+		var b int
+		var n int64
+		if err := rows.Scan(&b, &n); err != nil {
+			return nil, fmt.Errorf("scan histogram bucket: %w", err)
+		}
+		counts[b-1] = n // width_bucket numbers from 1
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate histogram buckets: %w", err)
+	}
+	return sizeHistogram(buckets, counts), nil
+}
+
+// sizeHistogram lays counts, keyed by bucket index, over the buckets' bounds.
+func sizeHistogram(buckets []int32, counts map[int]int64) []HistogramBucket {
+	out := make([]HistogramBucket, len(buckets))
+	for i, lo := range buckets {
+		out[i] = HistogramBucket{Min: lo, Count: counts[i]}
+		if i+1 < len(buckets) {
+			out[i].Max = buckets[i+1]
+		}
+	}
+	return out
+}
+
 // QueryTradeStats returns aggregate trade count and volume.
 func (r *PgTradeReader) QueryTradeStats(ctx context.Context) (TradeStats, error) {
 	var ts TradeStats
@@ -2,6 +2,7 @@ package persist
 
 import (
 	"math"
+	"slices"
 	"testing"
 	"time"
 )
@@ -39,6 +40,24 @@ func TestValidInterval(t *testing.T) {
 	}
 }
 
+func TestNearestInterval(t *testing.T) {
+	for in, want := range map[string]string{
+		"7m": "5m", "2m": "1m", "10m": "5m", "30m": "15m", "2h": "1h", "3h": "4h", "2d": "1d", "90s": "1m",
+	} {
+		if got, ok := NearestInterval(in); !ok || got != want {
+			t.Errorf("NearestInterval(%q) = %q, %v, want %q", in, got, ok, want)
+		}
+	}
+	for _, bad := range []string{"", "99x", "1week", "0m", "-5m"} {
+		if got, ok := NearestInterval(bad); ok {
+			t.Errorf("NearestInterval(%q) = %q, want no match", bad, got)
+		}
+	}
+	if got := SupportedIntervals(); !slices.Equal(got, []string{"1m", "5m", "15m", "1h", "4h", "1d"}) {
+		t.Errorf("SupportedIntervals = %v", got)
+	}
+}
+
 func TestAlignDown(t *testing.T) {
 	// 2025-01-15T10:32:45Z, 1m bucket -> 10:32:00
 	tm := time.Date(2025, 1, 15, 10, 32, 45, 0, time.UTC)
@@ -153,3 +172,22 @@ func TestSafeRetentionDays(t *testing.T) {
 		t.Errorf("zero-rate window = %v, want +Inf", got)
 	}
 }
+
+func TestValidateSizeBuckets(t *testing.T) {
+	if err := ValidateSizeBuckets(DefaultSizeBuckets); err != nil {
+		t.Errorf("DefaultSizeBuckets invalid: %v", err)
+	}
+	for _, bad := range [][]int32{nil, {0, 100}, {100, 100}, {500, 100}, make([]int32, MaxHistogramBuckets+1)} {
+		if ValidateSizeBuckets(bad) == nil {
+			t.Errorf("ValidateSizeBuckets(%v) = nil, want an error", bad)
+		}
+	}
+}
+
+func TestSizeHistogram(t *testing.T) {
+	got := sizeHistogram([]int32{1, 100, 500}, map[int]int64{0: 4, 2: 1})
+	want := []HistogramBucket{{Min: 1, Max: 100, Count: 4}, {Min: 100, Max: 500}, {Min: 500, Count: 1}}
+	if !slices.Equal(got, want) {
+		t.Errorf("sizeHistogram = %+v, want %+v", got, want)
+	}
+}
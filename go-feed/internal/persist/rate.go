@@ -0,0 +1,59 @@
+package persist
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+)
+
+// rateWindow is the longest window TradeRate reports, kept as one bucket per
+// second.
+const rateWindow = 5 * time.Minute
+
+// TradeRate counts persisted trades over rolling windows of up to five
+// minutes, in memory, for /api/stats. It is safe for concurrent use.
+type TradeRate struct {
+	mu      sync.Mutex
+	clock   engine.Clock
+	buckets [rateWindow / time.Second]int64 // trades per second, by unix second mod len
+	seconds [rateWindow / time.Second]int64 // unix second each bucket counts
+}
+
+// NewTradeRate creates an empty trade counter on clock.
+func NewTradeRate(clock engine.Clock) *TradeRate {
+	return &TradeRate{clock: clock}
+}
+
+// Add records n trades now. A nil TradeRate ignores it.
+func (r *TradeRate) Add(n int64) {
+	if r == nil {
+		return
+	}
+	now := r.clock.Now().Unix()
+	i := now % int64(len(r.buckets))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seconds[i] != now {
+		r.seconds[i], r.buckets[i] = now, 0
+	}
+	r.buckets[i] += n
+}
+
+// Count returns how many trades were recorded in the last window, which is
+// capped at five minutes and counted in whole seconds including the current
+// one.
+func (r *TradeRate) Count(window time.Duration) int64 {
+	window = min(window, rateWindow)
+	now := r.clock.Now().Unix()
+	oldest := now - int64(window/time.Second) + 1
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for i, sec := range r.seconds {
+		if sec >= oldest && sec <= now {
+			n += r.buckets[i]
+		}
+	}
+	return n
+}
@@ -0,0 +1,45 @@
+package persist
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+)
+
+func TestTradeRateWindowsDecay(t *testing.T) {
+	clock := engine.NewFakeClock(time.Date(2026, 1, 2, 15, 0, 0, 0, time.UTC))
+	r := NewTradeRate(clock)
+
+	// A burst of 100 trades over ten seconds.
+	for i := 0; i < 10; i++ {
+		r.Add(10)
+		clock.Advance(time.Second)
+	}
+	if got := r.Count(time.Minute); got != 100 {
+		t.Fatalf("after the burst: last minute = %d, want 100", got)
+	}
+	if got := r.Count(5 * time.Minute); got != 100 {
+		t.Fatalf("after the burst: last 5 minutes = %d, want 100", got)
+	}
+
+	clock.Advance(time.Minute)
+	r.Add(1)
+	if got := r.Count(time.Minute); got != 1 {
+		t.Errorf("a minute later: last minute = %d, want 1", got)
+	}
+	if got := r.Count(5 * time.Minute); got != 101 {
+		t.Errorf("a minute later: last 5 minutes = %d, want 101", got)
+	}
+
+	clock.Advance(5 * time.Minute)
+	if got := r.Count(5 * time.Minute); got != 0 {
+		t.Errorf("after 5 quiet minutes: last 5 minutes = %d, want 0", got)
+	}
+
+	// A bucket reused a full window later starts from zero.
+	r.Add(3)
+	if got := r.Count(time.Minute); got != 3 {
+		t.Errorf("reused bucket: last minute = %d, want 3", got)
+	}
+}
@@ -0,0 +1,71 @@
+package persist
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// rebuildTrades is how many of a symbol's most recent trades a rebuild
+// replays.
+const rebuildTrades = 100
+
+// SetRebuildFromTrades enables a warm restart when no order snapshot
+// survives: Load reseeds every book around its symbol's last persisted trade
+// price, read through r, instead of leaving the books empty. nil disables it.
+func (s *Snapshotter) SetRebuildFromTrades(r TradeReader) {
+	s.rebuild = r
+}
+
+// rebuildBooks reconstructs approximate books from the trade log. Each book
+// is seeded around the symbol's last trade price, then stepped through its
+// recent trade prices oldest first, so the resting orders bear the marks of
+// the recent path while staying centred on the last trade. Symbols with no
+// trades are seeded around their current engine price, unless no symbol has
+// trades and seedQuiet is false, in which case the books are left untouched
+// for the caller's fresh start. It returns how many books were rebuilt from
+// trades.
+func (s *Snapshotter) rebuildBooks(ctx context.Context, seedQuiet bool) (int, error) {
+	recent := make(map[uint16][]Trade, len(s.books))
+	for _, sym := range s.syms {
+		if _, ok := s.books[sym.LocateCode]; !ok {
+			continue
+		}
+		trades, err := s.rebuild.QueryTrades(ctx, TradeFilter{SymbolLocate: sym.LocateCode, Limit: rebuildTrades})
+		if err != nil {
+			return 0, fmt.Errorf("rebuild %s: %w", sym.Ticker, err)
+		}
+		if len(trades) > 0 {
+			recent[sym.LocateCode] = trades
+		}
+	}
+	if len(recent) == 0 && !seedQuiet {
+		return 0, nil
+	}
+
+	// Symbol order, not map order, so the simulators' random draws are
+	// reproducible.
+	for _, sym := range s.syms {
+		locate := sym.LocateCode
+		sim, ok := s.books[locate]
+		if !ok {
+			continue
+		}
+		sim.Book().Restore(nil)
+		trades := recent[locate]
+		if len(trades) == 0 {
+			sim.Initialize(s.market.Price(locate))
+			continue
+		}
+
+		// Trades come newest first.
+		last := trades[0].Price
+		s.market.SetPrice(locate, last)
+		sim.Initialize(last)
+		for i := len(trades) - 1; i >= 0; i-- {
+			sim.Step(trades[i].Price, 1)
+		}
+	}
+	log.Printf("rebuilt %d books from the trade log", len(recent))
+	return len(recent), nil
+}
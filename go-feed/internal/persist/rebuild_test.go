@@ -0,0 +1,80 @@
+package persist
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+)
+
+// stubTrades serves canned trades, newest first, per locate. Only
+// QueryTrades is implemented.
+type stubTrades struct {
+	TradeReader
+	byLocate map[uint16][]Trade
+}
+
+func (s *stubTrades) QueryTrades(_ context.Context, f TradeFilter) ([]Trade, error) {
+	return s.byLocate[f.SymbolLocate], nil
+}
+
+// TestRebuildBooksFromTrades rebuilds NEXO's book from a short trade history
+// and checks it is populated on both sides and centred near the last trade,
+// not the base price; QBIT, with no trades, is seeded at its engine price.
+func TestRebuildBooksFromTrades(t *testing.T) {
+	syms := symbol.AllSymbols()[:2] // NEXO, QBIT
+	rng := engine.NewRNG(42)
+	market := engine.NewMarketEngine(rng, syms)
+	books := make(map[uint16]*orderbook.Simulator, len(syms))
+	for _, s := range syms {
+		books[s.LocateCode] = orderbook.NewSimulator(rng, orderbook.NewBook(s.LocateCode, s.TickSize), s.LocateCode, s.TickSize)
+	}
+	snap := NewSnapshotter(nil, market, books, rng, syms)
+	snap.SetRebuildFromTrades(&stubTrades{byLocate: map[uint16][]Trade{
+		1: {{Price: 201.50}, {Price: 201.20}, {Price: 200.90}, {Price: 200.40}},
+	}})
+
+	n, err := snap.rebuildBooks(context.Background(), false)
+	if err != nil {
+		t.Fatalf("rebuildBooks: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("rebuilt %d books from trades, want 1", n)
+	}
+
+	book := books[1].Book()
+	bid, ask := book.BestBid(), book.BestAsk()
+	if bid == 0 || ask == 0 || book.BidLevels() < 5 || book.AskLevels() < 5 {
+		t.Fatalf("NEXO book not populated: bid %v (%d levels), ask %v (%d levels)", bid, book.BidLevels(), ask, book.AskLevels())
+	}
+	if mid := (bid + ask) / 2; mid < 201.00 || mid > 202.00 {
+		t.Errorf("NEXO mid = %.2f, want near the 201.50 last trade", mid)
+	}
+	if got := market.Price(1); got != 201.50 {
+		t.Errorf("NEXO engine price = %v, want the 201.50 last trade", got)
+	}
+
+	qbit := books[2].Book()
+	if mid := qbit.MidPrice(); qbit.OrderCount() == 0 || mid < syms[1].BasePrice-0.5 || mid > syms[1].BasePrice+0.5 {
+		t.Errorf("QBIT mid = %.2f with %d orders, want seeded at its %.2f price", mid, qbit.OrderCount(), syms[1].BasePrice)
+	}
+}
+
+// TestRebuildBooksWithoutTradesLeavesBooks checks a fresh start with an empty
+// trade log leaves the books for the caller to initialize.
+func TestRebuildBooksWithoutTradesLeavesBooks(t *testing.T) {
+	syms := symbol.AllSymbols()[:1]
+	rng := engine.NewRNG(42)
+	books := map[uint16]*orderbook.Simulator{1: orderbook.NewSimulator(rng, orderbook.NewBook(1, 0.01), 1, 0.01)}
+	snap := NewSnapshotter(nil, engine.NewMarketEngine(rng, syms), books, rng, syms)
+	snap.SetRebuildFromTrades(&stubTrades{})
+
+	if n, err := snap.rebuildBooks(context.Background(), false); err != nil || n != 0 {
+		t.Fatalf("rebuildBooks = %d, %v; want 0, nil", n, err)
+	}
+	if got := books[1].Book().OrderCount(); got != 0 {
+		t.Errorf("book has %d orders, want it untouched", got)
+	}
+}
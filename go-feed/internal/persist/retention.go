@@ -4,25 +4,46 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
 	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// RunRetention periodically deletes trades older than the retention period.
-// Blocks until ctx is cancelled. Pass retentionDays <= 0 to disable.
-func RunRetention(ctx context.Context, store *Store, retentionDays int) {
-	if retentionDays <= 0 {
+// RunRetention periodically deletes trades older than the retention period
+// and, when maxPerSymbol > 0, each symbol's oldest trades beyond its most
+// recent maxPerSymbol. Blocks until ctx is cancelled. Pass retentionDays <= 0
+// and maxPerSymbol <= 0 to disable.
+func RunRetention(ctx context.Context, store *Store, retentionDays int, maxPerSymbol int64) {
+	if retentionDays <= 0 && maxPerSymbol <= 0 {
 		log.Println("trade retention disabled (keep forever)")
 		return
 	}
 
 	interval := 1 * time.Hour
-	log.Printf("trade retention: pruning trades older than %d days every %v (storage budget %d GiB)",
-		retentionDays, interval, SizeBudgetBytes>>30)
+	if retentionDays > 0 {
+		log.Printf("trade retention: pruning trades older than %d days every %v (storage budget %d GiB)",
+			retentionDays, interval, SizeBudgetBytes>>30)
+	}
+	if maxPerSymbol > 0 {
+		log.Printf("trade retention: keeping the newest %d trades per symbol", maxPerSymbol)
+	}
 
 	mon := &sizeMonitor{}
+	deleter := &pgTradeDeleter{pool: store.pool}
+	pruneAll := func() {
+		if retentionDays > 0 {
+			prune(ctx, store, retentionDays)
+		}
+		if maxPerSymbol > 0 {
+			if _, err := pruneByCount(ctx, deleter, maxPerSymbol); err != nil {
+				log.Printf("trade retention count prune error: %v", err)
+			}
+		}
+	}
 
 	// Run once immediately on startup, then on the ticker.
-	prune(ctx, store, retentionDays)
+	pruneAll()
 	mon.report(ctx, store)
 
 	ticker := time.NewTicker(interval)
@@ -33,7 +54,7 @@ func RunRetention(ctx context.Context, store *Store, retentionDays int) {
 		case <-ctx.Done():
 			return
 		case now := <-ticker.C:
-			prune(ctx, store, retentionDays)
+			pruneAll()
 			mon.reportAt(ctx, store, now)
 		}
 	}
@@ -120,3 +141,81 @@ func prune(ctx context.Context, store *Store, retentionDays int) {
 		log.Printf("trade retention: pruned %d trades older than %s", result.RowsAffected(), cutoff.Format(time.DateOnly))
 	}
 }
+
+// tradeDeleter is the storage pruneByCount works against.
+type tradeDeleter interface {
+	// TradeCounts returns the number of stored trades per symbol locate.
+	TradeCounts(ctx context.Context) (map[uint16]int64, error)
+	// DeleteOldestTrades deletes locate's n oldest trades and returns how
+	// many were removed.
+	DeleteOldestTrades(ctx context.Context, locate uint16, n int64) (int64, error)
+}
+
+// pruneByCount trims every symbol to its newest keep trades, deleting the
+// oldest beyond the cap, and returns the total deleted.
+func pruneByCount(ctx context.Context, d tradeDeleter, keep int64) (int64, error) {
+	counts, err := d.TradeCounts(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("count trades: %w", err)
+	}
+	locates := make([]uint16, 0, len(counts))
+	for loc := range counts {
+		locates = append(locates, loc)
+	}
+	slices.Sort(locates)
+
+	var total int64
+	for _, loc := range locates {
+		excess := counts[loc] - keep
+		if excess <= 0 {
+			continue
+		}
+		n, err := d.DeleteOldestTrades(ctx, loc, excess)
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("prune locate %d: %w", loc, err)
+		}
+	}
+	if total > 0 {
+		log.Printf("trade retention: pruned %d trades beyond %d per symbol", total, keep)
+	}
+	return total, nil
+}
+
+// pgTradeDeleter implements tradeDeleter on the trades table.
+type pgTradeDeleter struct {
+	pool *pgxpool.Pool
+}
+
+func (d *pgTradeDeleter) TradeCounts(ctx context.Context) (map[uint16]int64, error) {
+	rows, err := d.pool.Query(ctx, `SELECT symbol_locate, count(*) FROM trades GROUP BY symbol_locate`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[uint16]int64)
+	for rows.Next() {
+		var locate int16
+		var n int64
+		if err := rows.Scan(&locate, &n); err != nil {
+			return nil, err
+		}
+		counts[uint16(locate)] = n
+	}
+	return counts, rows.Err()
+}
+
+func (d *pgTradeDeleter) DeleteOldestTrades(ctx context.Context, locate uint16, n int64) (int64, error) {
+	result, err := d.pool.Exec(ctx,
+		`DELETE FROM trades
+		 WHERE (run_id, match_number) IN (
+			SELECT run_id, match_number FROM trades
+			 WHERE symbol_locate = $1
+			 ORDER BY executed_at ASC, match_number ASC
+			 LIMIT $2)`,
+		int16(locate), n)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -1,6 +1,10 @@
 package persist
 
-import "testing"
+import (
+	"context"
+	"slices"
+	"testing"
+)
 
 func TestSizeMonitorHighWater(t *testing.T) {
 	m := &sizeMonitor{}
@@ -27,3 +31,44 @@ func TestSizeMonitorHighWater(t *testing.T) {
 		}
 	}
 }
+
+// fakeDeleter holds per-symbol trade timestamps, oldest first.
+type fakeDeleter struct {
+	trades map[uint16][]int
+}
+
+func (f *fakeDeleter) TradeCounts(ctx context.Context) (map[uint16]int64, error) {
+	counts := make(map[uint16]int64)
+	for loc, ts := range f.trades {
+		counts[loc] = int64(len(ts))
+	}
+	return counts, nil
+}
+
+func (f *fakeDeleter) DeleteOldestTrades(ctx context.Context, locate uint16, n int64) (int64, error) {
+	ts := f.trades[locate]
+	n = min(n, int64(len(ts)))
+	f.trades[locate] = ts[n:]
+	return n, nil
+}
+
+func TestPruneByCountDeletesOldestBeyondCap(t *testing.T) {
+	f := &fakeDeleter{trades: map[uint16][]int{
+		1: {1, 2, 3, 4, 5, 6},
+		2: {10, 11},
+		3: {20, 21, 22},
+	}}
+	n, err := pruneByCount(context.Background(), f, 3)
+	if err != nil {
+		t.Fatalf("pruneByCount: %v", err)
+	}
+	if n != 3 {
+		t.Errorf("deleted %d, want 3", n)
+	}
+	want := map[uint16][]int{1: {4, 5, 6}, 2: {10, 11}, 3: {20, 21, 22}}
+	for loc, ts := range want {
+		if !slices.Equal(f.trades[loc], ts) {
+			t.Errorf("locate %d kept %v, want %v", loc, f.trades[loc], ts)
+		}
+	}
+}
@@ -29,22 +29,41 @@ CREATE TABLE IF NOT EXISTS orders (
 	side           CHAR(1) NOT NULL,
 	price          DOUBLE PRECISION NOT NULL,
 	shares         INTEGER NOT NULL,
-	priority       INTEGER NOT NULL DEFAULT 0,
+	priority       BIGINT NOT NULL DEFAULT 0,
 	mpid           TEXT NOT NULL DEFAULT ''
 );
 CREATE INDEX IF NOT EXISTS idx_orders_locate ON orders(symbol_locate);
 
+-- Priorities became a global arrival sequence; widen tables created with a
+-- per-level INTEGER priority.
+ALTER TABLE orders ALTER COLUMN priority TYPE BIGINT;
+
 CREATE TABLE IF NOT EXISTS trades (
-	match_number   BIGINT PRIMARY KEY,
+	run_id         TEXT NOT NULL DEFAULT '',
+	match_number   BIGINT NOT NULL,
 	symbol_locate  SMALLINT NOT NULL,
 	ticker         TEXT NOT NULL,
 	price          DOUBLE PRECISION NOT NULL,
 	shares         INTEGER NOT NULL,
 	aggressor      CHAR(1) NOT NULL,
-	executed_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+	executed_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (run_id, match_number)
 );
 CREATE INDEX IF NOT EXISTS idx_trades_locate_time ON trades(symbol_locate, executed_at);
 
+-- Upgrade trades tables created before run_id: add the column and widen the
+-- match_number-only primary key to (run_id, match_number).
+ALTER TABLE trades ADD COLUMN IF NOT EXISTS run_id TEXT NOT NULL DEFAULT '';
+DO $$
+BEGIN
+	IF (SELECT array_length(i.indkey::int2[], 1)
+	      FROM pg_index i
+	     WHERE i.indrelid = 'trades'::regclass AND i.indisprimary) = 1 THEN
+		ALTER TABLE trades DROP CONSTRAINT trades_pkey;
+		ALTER TABLE trades ADD PRIMARY KEY (run_id, match_number);
+	END IF;
+END $$;
+
 CREATE TABLE IF NOT EXISTS sim_state (
 	key         TEXT PRIMARY KEY,
 	value_bytes BYTEA,
@@ -2,8 +2,13 @@ package persist
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	mathrand "math/rand/v2"
+	"sync"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -21,8 +26,21 @@ type Snapshotter struct {
 	rng       *engine.RNG
 	syms      []symbol.Symbol
 	tickerMap map[uint16]string // locate -> ticker for trade denormalization
+	runID     string            // scopes match-number idempotency to this run
+	codec     StateCodec        // encodes the rng_state and book_state blobs
+	jitter    time.Duration     // up to this much random delay added to each interval
+	skipBusy  bool              // Save returns ErrSnapshotBusy instead of waiting
+	saveMu    sync.Mutex        // held for the length of a save
+	rebuild   TradeReader       // rebuilds books from trades when no orders were saved (nil = off)
+	rate      *TradeRate        // counts saved trades for /api/stats (nil = off)
+	seed      int64             // PRNG seed the saved state descends from
+	seedKnown bool              // false until SetSeed, or after restoring state saved without one
 }
 
+// ErrSnapshotBusy is returned by Save when a snapshot is already being
+// written and busy snapshots are skipped (see SetSkipBusy).
+var ErrSnapshotBusy = errors.New("snapshot already in progress")
+
 // NewSnapshotter creates a new snapshotter.
 func NewSnapshotter(store *Store, market *engine.MarketEngine, books map[uint16]*orderbook.Simulator, rng *engine.RNG, syms []symbol.Symbol) *Snapshotter {
 	tm := make(map[uint16]string, len(syms))
@@ -36,35 +54,99 @@ func NewSnapshotter(store *Store, market *engine.MarketEngine, books map[uint16]
 		rng:       rng,
 		syms:      syms,
 		tickerMap: tm,
+		codec:     rawCodec{},
+	}
+}
+
+// SetStateCodec sets the codec for the state blobs written by Save. Load
+// reads each blob with the codec recorded in it, so the codec can change
+// between runs.
+func (s *Snapshotter) SetStateCodec(c StateCodec) {
+	s.codec = c
+}
+
+// SetJitter adds a random delay of up to d to every snapshot interval, so
+// snapshots drift apart from other periodic writers instead of colliding
+// with them each time. d <= 0 keeps the interval fixed.
+func (s *Snapshotter) SetJitter(d time.Duration) {
+	s.jitter = max(d, 0)
+}
+
+// SetSeed records the PRNG seed this run started with, saved with every
+// snapshot. Load replaces it with the seed of the state it restores.
+func (s *Snapshotter) SetSeed(seed int64) {
+	s.seed, s.seedKnown = seed, true
+}
+
+// Seed returns the PRNG seed that originally produced the current state:
+// the seed saved with a restored snapshot, else the one passed to SetSeed.
+// ok is false when neither is known, as after restoring a snapshot written
+// before seeds were saved.
+func (s *Snapshotter) Seed() (seed int64, ok bool) {
+	return s.seed, s.seedKnown
+}
+
+// SetSkipBusy makes Save return ErrSnapshotBusy at once while another
+// snapshot is still being written, instead of waiting to write its own.
+func (s *Snapshotter) SetSkipBusy(on bool) {
+	s.skipBusy = on
+}
+
+// nextDelay returns the wait before the next snapshot: interval plus up to
+// the jitter.
+func (s *Snapshotter) nextDelay(interval time.Duration) time.Duration {
+	if s.jitter <= 0 {
+		return interval
 	}
+	return interval + mathrand.N(s.jitter)
 }
 
 // Run starts the periodic snapshot loop. Blocks until ctx is cancelled.
 func (s *Snapshotter) Run(ctx context.Context, interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+	timer := time.NewTimer(s.nextDelay(interval))
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
-			// Final snapshot on shutdown
+			// Final snapshot on shutdown, after any save in flight
 			log.Println("performing final snapshot...")
 			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			if err := s.Save(shutdownCtx); err != nil {
+			s.saveMu.Lock()
+			if err := s.save(shutdownCtx); err != nil {
 				log.Printf("final snapshot error: %v", err)
 			}
+			s.saveMu.Unlock()
 			cancel()
 			return
-		case <-ticker.C:
-			if err := s.Save(ctx); err != nil {
+		case <-timer.C:
+			if err := s.Save(ctx); errors.Is(err, ErrSnapshotBusy) {
+				log.Println("snapshot skipped: previous snapshot still running")
+			} else if err != nil {
 				log.Printf("snapshot error: %v", err)
 			}
+			timer.Reset(s.nextDelay(interval))
 		}
 	}
 }
 
-// Save persists the full simulator state to PostgreSQL in a single transaction.
+// Save persists the full simulator state to PostgreSQL in a single
+// transaction. Saves never overlap: a call made while another is writing
+// waits for it, or with SetSkipBusy returns ErrSnapshotBusy.
 func (s *Snapshotter) Save(ctx context.Context) error {
+	if s.skipBusy {
+		if !s.saveMu.TryLock() {
+			return ErrSnapshotBusy
+		}
+	} else {
+		s.saveMu.Lock()
+	}
+	defer s.saveMu.Unlock()
+	return s.save(ctx)
+}
+
+// save writes one snapshot. The caller holds saveMu.
+func (s *Snapshotter) save(ctx context.Context) error {
 	start := time.Now()
 
 	tx, err := s.store.pool.Begin(ctx)
@@ -90,41 +172,52 @@ func (s *Snapshotter) Save(ctx context.Context) error {
 		}
 	}
 
-	// 2. Replace all orders: delete then bulk copy
+	// 2. Save the book as one codec-encoded blob. The orders table is
+	// cleared so an older build reading it doesn't restore a stale book.
 	if _, err := tx.Exec(ctx, "DELETE FROM orders"); err != nil {
 		return fmt.Errorf("delete orders: %w", err)
 	}
 
-	var allOrders []*orderbook.Order
+	var allOrders []orderbook.Order
 	for _, sim := range s.books {
-		allOrders = append(allOrders, sim.Book().AllOrders()...)
-	}
-	if len(allOrders) > 0 {
-		_, err = tx.CopyFrom(ctx,
-			pgx.Identifier{"orders"},
-			[]string{"id", "symbol_locate", "side", "price", "shares", "priority", "mpid"},
-			pgx.CopyFromSlice(len(allOrders), func(i int) ([]any, error) {
-				o := allOrders[i]
-				return []any{int64(o.ID), int16(o.Locate), string(o.Side), o.Price, o.Shares, o.Priority, o.MPID}, nil
-			}),
-		)
-		if err != nil {
-			return fmt.Errorf("copy orders: %w", err)
-		}
+		allOrders = append(allOrders, sim.Book().Snapshot()...)
+	}
+	bookState, err := encodeState(s.codec, marshalOrders(allOrders))
+	if err != nil {
+		return fmt.Errorf("encode book state: %w", err)
+	}
+	if err := saveBlob(ctx, tx, "book_state", bookState, now); err != nil {
+		return fmt.Errorf("save book state: %w", err)
 	}
 
 	// 3. Upsert PRNG state
-	rngState := s.rng.StateBytes()
-	_, err = tx.Exec(ctx,
-		`INSERT INTO sim_state (key, value_bytes, updated_at)
-		 VALUES ('rng_state', $1, $2)
-		 ON CONFLICT (key) DO UPDATE SET value_bytes = EXCLUDED.value_bytes, updated_at = EXCLUDED.updated_at`,
-		rngState, now)
+	rngState, err := encodeState(s.codec, s.rng.StateBytes())
 	if err != nil {
+		return fmt.Errorf("encode rng state: %w", err)
+	}
+	if err := saveBlob(ctx, tx, "rng_state", rngState, now); err != nil {
 		return fmt.Errorf("save rng state: %w", err)
 	}
 
-	// 4. Upsert order ID counter
+	// 4. Upsert the session opens, stamped with the session start so a
+	// restart in a later session does not reuse them
+	sessionStart, opens := s.market.SessionOpens()
+	if !sessionStart.IsZero() {
+		openState, err := encodeState(s.codec, marshalOpens(opens))
+		if err != nil {
+			return fmt.Errorf("encode session opens: %w", err)
+		}
+		_, err = tx.Exec(ctx,
+			`INSERT INTO sim_state (key, value_bytes, value_time, updated_at)
+			 VALUES ('session_open', $1, $2, $3)
+			 ON CONFLICT (key) DO UPDATE SET value_bytes = EXCLUDED.value_bytes, value_time = EXCLUDED.value_time, updated_at = EXCLUDED.updated_at`,
+			openState, sessionStart, now)
+		if err != nil {
+			return fmt.Errorf("save session opens: %w", err)
+		}
+	}
+
+	// 5. Upsert order ID counter
 	_, err = tx.Exec(ctx,
 		`INSERT INTO sim_state (key, value_int, updated_at)
 		 VALUES ('order_id_counter', $1, $2)
@@ -134,7 +227,7 @@ func (s *Snapshotter) Save(ctx context.Context) error {
 		return fmt.Errorf("save order counter: %w", err)
 	}
 
-	// 5. Upsert match counter
+	// 6. Upsert match counter
 	_, err = tx.Exec(ctx,
 		`INSERT INTO sim_state (key, value_int, updated_at)
 		 VALUES ('match_counter', $1, $2)
@@ -144,6 +237,39 @@ func (s *Snapshotter) Save(ctx context.Context) error {
 		return fmt.Errorf("save match counter: %w", err)
 	}
 
+	// 7. Upsert the per-symbol order ID counters, when IDs are namespaced
+	if counters := orderbook.GetSymbolOrderIDCounters(); len(counters) > 0 {
+		counterState, err := encodeState(s.codec, marshalCounters(counters))
+		if err != nil {
+			return fmt.Errorf("encode order ID counters: %w", err)
+		}
+		if err := saveBlob(ctx, tx, "order_id_counters", counterState, now); err != nil {
+			return fmt.Errorf("save order ID counters: %w", err)
+		}
+	}
+
+	// 8. Upsert the seed the state descends from, when known
+	if s.seedKnown {
+		_, err = tx.Exec(ctx,
+			`INSERT INTO sim_state (key, value_int, updated_at)
+			 VALUES ('rng_seed', $1, $2)
+			 ON CONFLICT (key) DO UPDATE SET value_int = EXCLUDED.value_int, updated_at = EXCLUDED.updated_at`,
+			s.seed, now)
+		if err != nil {
+			return fmt.Errorf("save rng seed: %w", err)
+		}
+	}
+
+	// 9. Upsert priority counter
+	_, err = tx.Exec(ctx,
+		`INSERT INTO sim_state (key, value_int, updated_at)
+		 VALUES ('priority_counter', $1, $2)
+		 ON CONFLICT (key) DO UPDATE SET value_int = EXCLUDED.value_int, updated_at = EXCLUDED.updated_at`,
+		int64(orderbook.GetPriorityCounter()), now)
+	if err != nil {
+		return fmt.Errorf("save priority counter: %w", err)
+	}
+
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("commit snapshot: %w", err)
 	}
@@ -152,6 +278,16 @@ func (s *Snapshotter) Save(ctx context.Context) error {
 	return nil
 }
 
+// saveBlob upserts a value_bytes row of sim_state.
+func saveBlob(ctx context.Context, tx pgx.Tx, key string, b []byte, now time.Time) error {
+	_, err := tx.Exec(ctx,
+		`INSERT INTO sim_state (key, value_bytes, updated_at)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT (key) DO UPDATE SET value_bytes = EXCLUDED.value_bytes, updated_at = EXCLUDED.updated_at`,
+		key, b, now)
+	return err
+}
+
 // Load restores simulator state from PostgreSQL.
 // Returns true if state was found and loaded, false for fresh start.
 func (s *Snapshotter) Load(ctx context.Context) (bool, error) {
@@ -164,6 +300,15 @@ func (s *Snapshotter) Load(ctx context.Context) (bool, error) {
 		return false, fmt.Errorf("check symbols: %w", err)
 	}
 	if count == 0 {
+		if s.rebuild != nil {
+			n, err := s.rebuildBooks(ctx, false)
+			if err != nil {
+				return false, err
+			}
+			if n > 0 {
+				return true, nil
+			}
+		}
 		log.Println("no persisted state found, starting fresh")
 		return false, nil
 	}
@@ -187,51 +332,74 @@ func (s *Snapshotter) Load(ctx context.Context) (bool, error) {
 		return false, fmt.Errorf("iterate symbols: %w", err)
 	}
 
-	// Load orders
-	orderRows, err := pool.Query(ctx, "SELECT id, symbol_locate, side, price, shares, priority, mpid FROM orders")
+	// Load orders: from the book_state blob, or from the orders table for
+	// snapshots written before it existed.
+	orders, err := s.loadOrders(ctx)
 	if err != nil {
-		return false, fmt.Errorf("load orders: %w", err)
+		return false, err
 	}
-	defer orderRows.Close()
-
+	byLocate := make(map[uint16][]orderbook.Order)
 	orderCount := 0
-	for orderRows.Next() {
-		var id int64
-		var locate int16
-		var side string
-		var price float64
-		var shares, priority int32
-		var mpid string
-		if err := orderRows.Scan(&id, &locate, &side, &price, &shares, &priority, &mpid); err != nil {
-			return false, fmt.Errorf("scan order: %w", err)
-		}
-
-		sim, ok := s.books[uint16(locate)]
-		if !ok {
+	var maxPriority uint64
+	for _, o := range orders {
+		if _, ok := s.books[o.Locate]; !ok {
 			continue
 		}
-
-		o := &orderbook.Order{
-			ID:       uint64(id),
-			Locate:   uint16(locate),
-			Side:     orderbook.Side(side[0]),
-			Price:    price,
-			Shares:   shares,
-			Priority: priority,
-			MPID:     mpid,
-		}
-		sim.Book().RestoreOrder(o)
+		byLocate[o.Locate] = append(byLocate[o.Locate], o)
 		orderCount++
+		maxPriority = max(maxPriority, o.Priority)
 	}
-	if err := orderRows.Err(); err != nil {
-		return false, fmt.Errorf("iterate orders: %w", err)
+	for locate, sim := range s.books {
+		sim.Book().Restore(byLocate[locate])
+	}
+	if len(orders) == 0 && s.rebuild != nil {
+		log.Println("no order snapshot found, rebuilding books from the trade log")
+		if _, err := s.rebuildBooks(ctx, true); err != nil {
+			return false, err
+		}
 	}
 
 	// Load PRNG state
 	var rngState []byte
 	err = pool.QueryRow(ctx, "SELECT value_bytes FROM sim_state WHERE key = 'rng_state'").Scan(&rngState)
-	if err == nil && len(rngState) >= 16 {
-		s.rng.RestoreStateBytes(rngState)
+	if err == nil {
+		rngState, err = decodeState(rngState)
+		if err != nil {
+			return false, fmt.Errorf("decode rng state: %w", err)
+		}
+		if len(rngState) >= 16 {
+			s.rng.RestoreStateBytes(rngState)
+		}
+	}
+
+	// Load the seed the restored state descends from. Without one the
+	// original seed is unknown; this run's seed did not produce the state.
+	var seed int64
+	err = pool.QueryRow(ctx, "SELECT value_int FROM sim_state WHERE key = 'rng_seed'").Scan(&seed)
+	switch {
+	case err == nil:
+		s.seed, s.seedKnown = seed, true
+	case errors.Is(err, pgx.ErrNoRows):
+		s.seedKnown = false
+	default:
+		return false, fmt.Errorf("load rng seed: %w", err)
+	}
+
+	// Load session opens; the caller rolls to a new session if they are
+	// from an earlier one
+	var openState []byte
+	var sessionStart time.Time
+	err = pool.QueryRow(ctx, "SELECT value_bytes, value_time FROM sim_state WHERE key = 'session_open'").Scan(&openState, &sessionStart)
+	if err == nil {
+		raw, err := decodeState(openState)
+		if err != nil {
+			return false, fmt.Errorf("decode session opens: %w", err)
+		}
+		opens, err := unmarshalOpens(raw)
+		if err != nil {
+			return false, err
+		}
+		s.market.RestoreSessionOpens(sessionStart, opens)
 	}
 
 	// Load counters
@@ -246,17 +414,133 @@ func (s *Snapshotter) Load(ctx context.Context) (bool, error) {
 		orderbook.SetMatchCounter(uint64(intVal))
 	}
 
+	if err := s.loadSymbolOrderIDs(ctx, orders); err != nil {
+		return false, err
+	}
+
+	// Snapshots from before the priority counter existed only carry per-level
+	// priorities; start past the largest restored one either way so new
+	// orders queue behind everything restored.
+	priorityCounter := maxPriority
+	err = pool.QueryRow(ctx, "SELECT value_int FROM sim_state WHERE key = 'priority_counter'").Scan(&intVal)
+	if err == nil {
+		priorityCounter = max(priorityCounter, uint64(intVal))
+	}
+	orderbook.SetPriorityCounter(priorityCounter)
+
 	log.Printf("restored state: %d symbols, %d orders", count, orderCount)
 	return true, nil
 }
 
+// loadSymbolOrderIDs restores the per-symbol order ID counters, then moves
+// each past the largest restored order ID in its symbol's block, so a
+// snapshot saved before namespacing was switched on (or one missing the
+// counters) cannot lead to a reused ID.
+func (s *Snapshotter) loadSymbolOrderIDs(ctx context.Context, orders []orderbook.Order) error {
+	counters := make(map[uint16]uint64)
+	var blob []byte
+	err := s.store.pool.QueryRow(ctx, "SELECT value_bytes FROM sim_state WHERE key = 'order_id_counters'").Scan(&blob)
+	if err == nil {
+		raw, err := decodeState(blob)
+		if err != nil {
+			return fmt.Errorf("decode order ID counters: %w", err)
+		}
+		if counters, err = unmarshalCounters(raw); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("load order ID counters: %w", err)
+	}
+	for _, o := range orders {
+		if loc := o.ID / orderbook.OrderIDSpan; loc == uint64(o.Locate) {
+			counters[o.Locate] = max(counters[o.Locate], o.ID%orderbook.OrderIDSpan)
+		}
+	}
+	orderbook.SetSymbolOrderIDCounters(counters)
+	return nil
+}
+
+// loadOrders reads the saved resting orders.
+func (s *Snapshotter) loadOrders(ctx context.Context) ([]orderbook.Order, error) {
+	pool := s.store.pool
+
+	var blob []byte
+	err := pool.QueryRow(ctx, "SELECT value_bytes FROM sim_state WHERE key = 'book_state'").Scan(&blob)
+	if err == nil {
+		raw, err := decodeState(blob)
+		if err != nil {
+			return nil, fmt.Errorf("decode book state: %w", err)
+		}
+		return unmarshalOrders(raw)
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("load book state: %w", err)
+	}
+
+	rows, err := pool.Query(ctx, "SELECT id, symbol_locate, side, price, shares, priority, mpid FROM orders")
+	if err != nil {
+		return nil, fmt.Errorf("load orders: %w", err)
+	}
+	defer rows.Close()
+
+	var orders []orderbook.Order
+	for rows.Next() {
+		var id, priority int64
+		var locate int16
+		var side string
+		var price float64
+		var shares int32
+		var mpid string
+		if err := rows.Scan(&id, &locate, &side, &price, &shares, &priority, &mpid); err != nil {
+			return nil, fmt.Errorf("scan order: %w", err)
+		}
+		orders = append(orders, orderbook.Order{
+			ID:       uint64(id),
+			Locate:   uint16(locate),
+			Side:     orderbook.Side(side[0]),
+			Price:    price,
+			Shares:   shares,
+			Priority: uint64(priority),
+			MPID:     mpid,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate orders: %w", err)
+	}
+	return orders, nil
+}
+
+// SetRunID sets the run identifier stamped on saved trades. Trades are unique
+// per (run ID, match number), so a retried write within a run is dropped but a
+// later run whose match counter was reset (e.g. restored from a stale
+// snapshot) still persists its trades.
+func (s *Snapshotter) SetRunID(id string) {
+	s.runID = id
+}
+
+// NewRunID returns a fresh run identifier: the UTC start time plus a random
+// suffix, so IDs sort by start and don't collide across restarts.
+func NewRunID() string {
+	var b [4]byte
+	rand.Read(b[:])
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b[:])
+}
+
 // SaveTrade persists a single trade to the trades log.
 func (s *Snapshotter) SaveTrade(ctx context.Context, matchNumber uint64, locate uint16, price float64, shares int32, aggressor byte) error {
 	ticker := s.tickerMap[locate]
 	_, err := s.store.pool.Exec(ctx,
-		`INSERT INTO trades (match_number, symbol_locate, ticker, price, shares, aggressor, executed_at)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7)
-		 ON CONFLICT (match_number) DO NOTHING`,
-		int64(matchNumber), int16(locate), ticker, price, shares, string(aggressor), time.Now())
+		`INSERT INTO trades (run_id, match_number, symbol_locate, ticker, price, shares, aggressor, executed_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT (run_id, match_number) DO NOTHING`,
+		s.runID, int64(matchNumber), int16(locate), ticker, price, shares, string(aggressor), time.Now())
+	if err == nil {
+		s.rate.Add(1)
+	}
 	return err
 }
+
+// SetTradeRate counts every trade SaveTrade writes on r. nil disables it.
+func (s *Snapshotter) SetTradeRate(r *TradeRate) {
+	s.rate = r
+}
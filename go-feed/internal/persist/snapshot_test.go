@@ -0,0 +1,54 @@
+package persist
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestSaveSkipsWhileBusy holds the save lock as an in-flight snapshot would
+// and checks a concurrent Save returns ErrSnapshotBusy at once, without
+// touching the store.
+func TestSaveSkipsWhileBusy(t *testing.T) {
+	s := NewSnapshotter(nil, nil, nil, nil, nil)
+	s.SetSkipBusy(true)
+
+	s.saveMu.Lock() // a snapshot is being written
+	done := make(chan error, 1)
+	go func() { done <- s.Save(context.Background()) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrSnapshotBusy) {
+			t.Fatalf("Save during another save = %v, want ErrSnapshotBusy", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Save waited for the running snapshot instead of skipping")
+	}
+	s.saveMu.Unlock()
+
+	if !s.saveMu.TryLock() {
+		t.Fatal("skipped Save left the save lock held")
+	}
+	s.saveMu.Unlock()
+}
+
+func TestNextDelayJitter(t *testing.T) {
+	s := NewSnapshotter(nil, nil, nil, nil, nil)
+	if got := s.nextDelay(30 * time.Second); got != 30*time.Second {
+		t.Errorf("nextDelay without jitter = %v, want 30s", got)
+	}
+	s.SetJitter(5 * time.Second)
+	varied := false
+	first := s.nextDelay(30 * time.Second)
+	for range 100 {
+		d := s.nextDelay(30 * time.Second)
+		if d < 30*time.Second || d >= 35*time.Second {
+			t.Fatalf("nextDelay = %v, want within [30s, 35s)", d)
+		}
+		varied = varied || d != first
+	}
+	if !varied {
+		t.Error("jittered delays never varied")
+	}
+}
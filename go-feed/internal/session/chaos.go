@@ -0,0 +1,63 @@
+package session
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// chaos disorders broadcast batches to exercise consumers' handling of
+// out-of-sequence data. Each message is disturbed with probability rate:
+// half the time it swaps places with the message after it, otherwise it is
+// held back and sent at the end of the symbol's next batch, late, with its
+// original timestamp. Messages are only moved, never altered, so fields like
+// an execution's and trade's shared match number stay intact.
+type chaos struct {
+	rate float64
+	rng  *engine.RNG
+
+	mu   sync.Mutex
+	held map[uint16][]itch.Message // locate -> messages delayed to its next batch
+}
+
+// SetChaos enables fault injection: Broadcast reorders or delays a rate
+// fraction of messages (see chaos), drawing from rng so a seed reproduces
+// the disorder. rate must be in [0, 1]; 0 disables it. This is for testing
+// consumers only: it breaks the batch ordering Broadcast otherwise keeps.
+func (m *Manager) SetChaos(rate float64, rng *engine.RNG) error {
+	if !(rate >= 0 && rate <= 1) {
+		return fmt.Errorf("chaos rate %v must be in [0, 1]", rate)
+	}
+	if rate == 0 {
+		m.chaos = nil
+		return nil
+	}
+	m.chaos = &chaos{rate: rate, rng: rng, held: make(map[uint16][]itch.Message)}
+	return nil
+}
+
+// disorder returns msgs, one batch for locate, with messages swapped and
+// delayed, followed by those held back from the symbol's previous batch.
+func (c *chaos) disorder(locate uint16, msgs []itch.Message) []itch.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	late := c.held[locate]
+	delete(c.held, locate)
+
+	out := make([]itch.Message, 0, len(msgs)+len(late))
+	for i := 0; i < len(msgs); i++ {
+		switch {
+		case c.rng.Float64() >= c.rate:
+			out = append(out, msgs[i])
+		case c.rng.Float64() < 0.5 && i+1 < len(msgs):
+			out = append(out, msgs[i+1], msgs[i])
+			i++
+		default:
+			c.held[locate] = append(c.held[locate], msgs[i])
+		}
+	}
+	return append(out, late...)
+}
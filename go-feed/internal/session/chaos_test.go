@@ -0,0 +1,83 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// TestChaosReordersButKeepsPairing broadcasts execution/trade pairs with
+// chaos on and checks some arrive out of order or late, while every message
+// arrives once, unaltered, with its execution and trade still sharing a
+// match number.
+func TestChaosReordersButKeepsPairing(t *testing.T) {
+	m := newTestManager()
+	if err := m.SetChaos(0.1, engine.NewRNG(7)); err != nil {
+		t.Fatal(err)
+	}
+	locs, _ := m.ResolveTickers([]string{"NEXO"})
+	nexo := locs[0]
+	c := newTestClient(1000)
+	c.Subscribe([]uint16{nexo})
+	m.mu.Lock()
+	m.clients[c.ID] = c
+	m.mu.Unlock()
+
+	const pairs = 200
+	for n := uint64(1); n <= pairs; n++ {
+		m.Broadcast(nexo, "NEXO", []itch.Message{
+			{Type: itch.MsgOrderExecuted, StockLocate: nexo, OrderRef: n, Shares: 100, MatchNumber: n},
+			{Type: itch.MsgTrade, StockLocate: nexo, OrderRef: n, Side: 'B', Shares: 100, Price: 10, MatchNumber: n},
+		})
+	}
+
+	type fill struct {
+		Type        string `json:"type"`
+		OrderRef    uint64 `json:"orderRef"`
+		Shares      int32  `json:"shares"`
+		MatchNumber uint64 `json:"matchNumber"`
+	}
+	seen := make(map[uint64]map[string]fill, pairs)
+	var reordered int
+	var prev uint64
+	for _, o := range drain(c) {
+		var f fill
+		if err := json.Unmarshal(o.data, &f); err != nil {
+			t.Fatalf("decode %s: %v", o.data, err)
+		}
+		if f.MatchNumber < prev || f.Type == "trade" && seen[f.MatchNumber]["order_executed"] == (fill{}) {
+			reordered++
+		}
+		prev = f.MatchNumber
+		if seen[f.MatchNumber] == nil {
+			seen[f.MatchNumber] = make(map[string]fill, 2)
+		}
+		if _, dup := seen[f.MatchNumber][f.Type]; dup {
+			t.Errorf("match %d: %s delivered twice", f.MatchNumber, f.Type)
+		}
+		seen[f.MatchNumber][f.Type] = f
+	}
+	if reordered == 0 {
+		t.Error("chaos delivered every message in order")
+	}
+
+	held := len(m.chaos.held[nexo])
+	var delivered int
+	for n, byType := range seen {
+		delivered += len(byType)
+		for typ, f := range byType {
+			if f.OrderRef != n || f.Shares != 100 {
+				t.Errorf("match %d %s = %+v, want order %d for 100 shares", n, typ, f, n)
+			}
+		}
+	}
+	if delivered+held != 2*pairs {
+		t.Errorf("delivered %d + held %d messages, want %d", delivered, held, 2*pairs)
+	}
+
+	if err := m.SetChaos(1.5, engine.NewRNG(7)); err == nil {
+		t.Error("chaos rate 1.5 accepted")
+	}
+}
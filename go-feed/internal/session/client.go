@@ -3,8 +3,12 @@ package session
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
 )
 
 // Format represents the client's preferred encoding format.
@@ -15,6 +19,16 @@ const (
 	FormatBinary Format = 1
 )
 
+// outbound is one queued WebSocket write, tagged with the format it was
+// encoded in so the write pump picks the matching frame type. Control replies
+// are always sent as JSON text frames, regardless of the feed format.
+type outbound struct {
+	data    []byte
+	format  Format
+	control bool
+	queued  time.Time // when it was queued, while a latency is set
+}
+
 // Client represents a connected WebSocket client.
 type Client struct {
 	ID   uint64
@@ -22,13 +36,29 @@ type Client struct {
 
 	mu          sync.RWMutex
 	format      Format
+	symFormat   map[uint16]Format // per-symbol format overrides from subscribe
 	symbols     map[uint16]bool // locate code -> subscribed
 	allSymbols  bool            // subscribed to all symbols
+	maxSubs     int             // max distinct subscriptions (0 = unlimited)
+	version     int             // negotiated JSON protocol version
+	numeric     bool            // JSON prices as numbers, not strings
+	timestamps  itch.TimestampFormat // readable JSON "time" beside the nanos
+	framing     byte            // framed header version of binary messages (0 = bare ITCH)
+	maxFrame    int             // largest frame written, in bytes (0 = unlimited)
+	compressMin int             // smallest payload written compressed, when negotiated
+	token       string          // resume token (empty = resume disabled)
+	throttles   map[uint16]*throttle // per-symbol update rate caps
+	spotlights  map[uint16]bool      // symbols followed by full-depth snapshots
 
-	sendCh      chan []byte
+	sendCh      chan outbound
 	done        chan struct{}
 	closeOnce   sync.Once
 	bufferSize  int
+	sendWait    time.Duration // how long a send may wait on a full buffer (0 = drop at once)
+	congested   atomic.Bool   // a wait timed out; drop without waiting until a send fits
+	dropOldest  atomic.Bool   // a full buffer evicts its oldest message, not the new one
+	latency     atomic.Pointer[latency] // simulated network delay (nil = none)
+	clock       engine.Clock
 
 	// stats
 	Dropped uint64
@@ -42,10 +72,15 @@ func NewClient(conn *websocket.Conn, bufferSize int) *Client {
 		ID:         atomic.AddUint64(&clientIDCounter, 1),
 		Conn:       conn,
 		format:     FormatJSON,
+		version:    itch.JSONVersion1,
+		symFormat:  make(map[uint16]Format),
 		symbols:    make(map[uint16]bool),
-		sendCh:     make(chan []byte, bufferSize),
+		throttles:  make(map[uint16]*throttle),
+		spotlights: make(map[uint16]bool),
+		sendCh:     make(chan outbound, bufferSize),
 		done:       make(chan struct{}),
 		bufferSize: bufferSize,
+		clock:      engine.RealClock{},
 	}
 	return c
 }
@@ -64,20 +99,176 @@ func (c *Client) SetFormat(f Format) {
 	c.format = f
 }
 
-// Subscribe adds symbols to the client's subscription.
-func (c *Client) Subscribe(locates []uint16) {
+// FormatFor returns the encoding for a symbol's feed: the format given when
+// it was subscribed, if any, otherwise the client's current format.
+func (c *Client) FormatFor(locate uint16) Format {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if f, ok := c.symFormat[locate]; ok {
+		return f
+	}
+	return c.format
+}
+
+// SetSymbolFormat pins the encoding for the given symbols, independent of the
+// client's format, so one connection can carry JSON and binary streams.
+func (c *Client) SetSymbolFormat(locates []uint16, f Format) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	for _, loc := range locates {
+		c.symFormat[loc] = f
+	}
+}
+
+// Version returns the client's JSON protocol version.
+func (c *Client) Version() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.version
+}
+
+// SetVersion records the JSON protocol version negotiated by a hello message.
+func (c *Client) SetVersion(v int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version = v
+}
+
+// NumericPrices reports whether JSON messages to the client carry prices as
+// numbers instead of 4-decimal strings.
+func (c *Client) NumericPrices() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.numeric
+}
+
+// SetNumericPrices records the JSON price encoding negotiated by a hello
+// message.
+func (c *Client) SetNumericPrices(on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.numeric = on
+}
+
+// TimestampFormat returns the readable timestamp JSON messages to the client
+// carry beside the raw nanos.
+func (c *Client) TimestampFormat() itch.TimestampFormat {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.timestamps
+}
+
+// SetTimestampFormat sets the readable "time" field added to the client's
+// JSON messages; itch.TimestampNanos omits it.
+func (c *Client) SetTimestampFormat(f itch.TimestampFormat) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timestamps = f
+}
+
+// jsonOptions returns the JSON encoding the client negotiated.
+func (c *Client) jsonOptions() itch.JSONOptions {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return itch.JSONOptions{Version: c.version, NumericPrices: c.numeric, Timestamps: c.timestamps}
+}
+
+// Framing returns the framed header version (itch.FramedVersion or
+// itch.FramedVersion2) binary messages to the client carry, or 0 for the
+// bare 2-byte ITCH length prefix.
+func (c *Client) Framing() byte {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.framing
+}
+
+// SetFraming records the binary framing negotiated by a hello message.
+func (c *Client) SetFraming(version byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.framing = version
+}
+
+// SetMaxSubscriptions caps the number of distinct symbols the client may
+// subscribe to. n <= 0 means unlimited.
+func (c *Client) SetMaxSubscriptions(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxSubs = n
+}
+
+// SetMaxFrameSize caps the size of a single WebSocket frame written to the
+// client. A larger message is sent as a fragmented message (a first frame
+// and continuation frames), which WebSocket clients reassemble, so each
+// message still arrives whole. The connection's write buffer must be no
+// larger than n for the cap to hold (Manager.upgrader sizes it so). n <= 0
+// means unlimited.
+func (c *Client) SetMaxFrameSize(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxFrame = n
+}
+
+// MaxFrameSize returns the frame size cap (0 = unlimited).
+func (c *Client) MaxFrameSize() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.maxFrame
+}
+
+// SetCompressionThreshold sends payloads under n bytes uncompressed when the
+// connection negotiated per-message compression, so tiny frames skip the
+// deflate cost. n <= 0 compresses every message.
+func (c *Client) SetCompressionThreshold(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.compressMin = max(n, 0)
+}
+
+// CompressionThreshold returns the smallest payload sent compressed.
+func (c *Client) CompressionThreshold() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.compressMin
+}
+
+func (c *Client) maxSubscriptions() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.maxSubs
+}
+
+// Subscribe adds symbols to the client's subscription and returns the locates
+// refused because the subscription limit was reached. Already-subscribed
+// locates are accepted without counting again. Once subscribed to all symbols
+// the client is at its limit, so further subscriptions are refused.
+func (c *Client) Subscribe(locates []uint16) (rejected []uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, loc := range locates {
+		if c.symbols[loc] {
+			continue
+		}
+		if c.maxSubs > 0 && (c.allSymbols || len(c.symbols) >= c.maxSubs) {
+			rejected = append(rejected, loc)
+			continue
+		}
 		c.symbols[loc] = true
 	}
+	return rejected
 }
 
-// SubscribeAll subscribes the client to all symbols.
-func (c *Client) SubscribeAll() {
+// SubscribeAll subscribes the client to all symbols, of which there are
+// universe. It counts as the full subscription limit, and is refused (false)
+// when the limit is below universe.
+func (c *Client) SubscribeAll(universe int) bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	if c.maxSubs > 0 && c.maxSubs < universe {
+		return false
+	}
 	c.allSymbols = true
+	return true
 }
 
 // Unsubscribe removes symbols from the client's subscription.
@@ -86,6 +277,7 @@ func (c *Client) Unsubscribe(locates []uint16) {
 	defer c.mu.Unlock()
 	for _, loc := range locates {
 		delete(c.symbols, loc)
+		delete(c.symFormat, loc)
 	}
 }
 
@@ -120,20 +312,94 @@ func (c *Client) IsAllSubscribed() bool {
 	return c.allSymbols
 }
 
-// Send enqueues data to be sent to the client.
-// Returns false if the buffer is full (message dropped).
+// Send enqueues feed data encoded in the client's current format.
+// Returns false if the buffer is full (message dropped). Under SetDropOldest
+// it evicts the oldest queued message instead and returns true.
 func (c *Client) Send(data []byte) bool {
+	return c.SendFormat(data, c.Format())
+}
+
+// SendFormat enqueues feed data encoded in format f.
+// Returns false if the buffer is full (message dropped).
+func (c *Client) SendFormat(data []byte, f Format) bool {
+	return c.enqueue(outbound{data: data, format: f})
+}
+
+// SendControl enqueues a JSON control reply (e.g. a subscription ack).
+// Returns false if the buffer is full (message dropped).
+func (c *Client) SendControl(data []byte) bool {
+	return c.enqueue(outbound{data: data, control: true})
+}
+
+// SetSendWait lets a send wait up to d for room in a full buffer before
+// dropping, so a brief spike the write pump catches up with loses nothing.
+// The wait ends early if the client disconnects. After a wait times out the
+// client counts as congested and later sends drop without waiting until one
+// fits again, so a stuck client stalls the broadcast for at most one wait.
+// d <= 0 drops at once (the default).
+func (c *Client) SetSendWait(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sendWait = max(d, 0)
+}
+
+// SetDropOldest chooses which message a full buffer loses: with on, the
+// oldest queued message is evicted to make room, so the client always gets
+// the latest data; otherwise the new message is dropped (the default).
+// Either way the loss counts in Dropped.
+func (c *Client) SetDropOldest(on bool) {
+	c.dropOldest.Store(on)
+}
+
+// evictAttempts bounds how many queued messages one drop-oldest send evicts
+// while racing other senders for the freed slot.
+const evictAttempts = 3
+
+func (c *Client) enqueue(out outbound) bool {
+	if c.latency.Load() != nil {
+		out.queued = c.clock.Now()
+	}
 	select {
-	case c.sendCh <- data:
+	case c.sendCh <- out:
+		c.congested.Store(false)
 		return true
 	default:
-		atomic.AddUint64(&c.Dropped, 1)
-		return false
 	}
+
+	c.mu.RLock()
+	wait := c.sendWait
+	c.mu.RUnlock()
+	if wait > 0 && !c.congested.Load() {
+		timer := c.clock.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case c.sendCh <- out:
+			return true
+		case <-c.done:
+		case <-timer.C():
+			c.congested.Store(true)
+		}
+	}
+	if c.dropOldest.Load() {
+		for range evictAttempts {
+			select {
+			case <-c.sendCh:
+				atomic.AddUint64(&c.Dropped, 1)
+			default:
+			}
+			select {
+			case c.sendCh <- out:
+				return true
+			default:
+			}
+		}
+	}
+	atomic.AddUint64(&c.Dropped, 1)
+	return false
 }
 
 // SendCh returns the send channel for the write pump.
-func (c *Client) SendCh() <-chan []byte {
+func (c *Client) SendCh() <-chan outbound {
 	return c.sendCh
 }
 
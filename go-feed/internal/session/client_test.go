@@ -1,8 +1,12 @@
 package session
 
 import (
+	"runtime"
 	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
 )
 
 func newTestClient(bufSize int) *Client {
@@ -44,7 +48,7 @@ func TestSubscribe(t *testing.T) {
 
 func TestSubscribeAll(t *testing.T) {
 	c := newTestClient(10)
-	c.SubscribeAll()
+	c.SubscribeAll(10)
 	if !c.IsSubscribed(1) {
 		t.Fatal("should be subscribed to any locate after SubscribeAll")
 	}
@@ -89,7 +93,7 @@ func TestSubscribedLocates(t *testing.T) {
 
 func TestSubscribedLocatesAllNil(t *testing.T) {
 	c := newTestClient(10)
-	c.SubscribeAll()
+	c.SubscribeAll(10)
 	locs := c.SubscribedLocates()
 	if locs != nil {
 		t.Fatalf("SubscribedLocates should return nil for all-subscribed, got %v", locs)
@@ -113,6 +117,104 @@ func TestSendBufferFull(t *testing.T) {
 	}
 }
 
+func TestSendWaitRidesOutFullBuffer(t *testing.T) {
+	c := newTestClient(2)
+	c.SetSendWait(time.Second)
+	c.Send([]byte("msg1"))
+	c.Send([]byte("msg2"))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		<-c.sendCh // the writer catches up
+	}()
+	if !c.Send([]byte("msg3")) {
+		t.Fatal("send should wait for the buffer to drain, not drop")
+	}
+	if dropped := atomic.LoadUint64(&c.Dropped); dropped != 0 {
+		t.Fatalf("Dropped = %d, want 0", dropped)
+	}
+}
+
+// TestSendWaitTimesOut runs the wait on a fake clock: only advancing it past
+// the hour-long wait ends the send.
+func TestSendWaitTimesOut(t *testing.T) {
+	clk := engine.NewFakeClock(time.Unix(0, 0))
+	c := newTestClient(1)
+	c.clock = clk
+	c.SetSendWait(time.Hour)
+	c.Send([]byte("msg1"))
+
+	sent := make(chan bool)
+	go func() { sent <- c.Send([]byte("msg2")) }()
+	for waiting := true; waiting; {
+		select {
+		case ok := <-sent:
+			if ok {
+				t.Fatal("send should drop once the wait times out")
+			}
+			waiting = false
+		default:
+			clk.Advance(time.Hour) // fires the timer once the send has set it
+			runtime.Gosched()
+		}
+	}
+
+	// Congested: the next send drops without waiting, so it returns
+	// without the fake clock moving.
+	if c.Send([]byte("msg3")) {
+		t.Fatal("send to a congested client should drop")
+	}
+	if dropped := atomic.LoadUint64(&c.Dropped); dropped != 2 {
+		t.Fatalf("Dropped = %d, want 2", dropped)
+	}
+
+	// Room again: the client waits once more.
+	<-c.sendCh
+	if !c.Send([]byte("msg4")) {
+		t.Fatal("send should succeed once the buffer has room")
+	}
+}
+
+func TestSendWaitEndsOnClose(t *testing.T) {
+	c := newTestClient(1)
+	c.SetSendWait(time.Minute)
+	c.Send([]byte("msg1"))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(c.done) // as Close does, without a connection to close
+	}()
+	sent := make(chan bool)
+	go func() { sent <- c.Send([]byte("msg2")) }()
+	select {
+	case ok := <-sent:
+		if ok {
+			t.Fatal("send to a closed client should drop")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("send still waiting after the client closed")
+	}
+}
+
+func TestSendDropOldestKeepsNewest(t *testing.T) {
+	c := newTestClient(3)
+	c.SetDropOldest(true)
+	for i := range 10 {
+		if !c.Send([]byte{byte('0' + i)}) {
+			t.Fatalf("send %d dropped, want the oldest evicted instead", i)
+		}
+	}
+
+	// Saturated: the buffer holds the three most recent, newest last.
+	got := drain(c)
+	if len(got) != 3 || string(got[0].data) != "7" || string(got[1].data) != "8" || string(got[2].data) != "9" {
+		t.Errorf("queued %d messages %+v, want 7, 8, 9", len(got), got)
+	}
+	if dropped := atomic.LoadUint64(&c.Dropped); dropped != 7 {
+		t.Fatalf("Dropped = %d, want 7", dropped)
+	}
+}
+
 func TestSendNotFull(t *testing.T) {
 	c := newTestClient(100)
 	ok := c.Send([]byte("hello"))
@@ -142,3 +244,39 @@ func TestIsSubscribedDefault(t *testing.T) {
 		t.Fatal("new client should not be subscribed to any symbol")
 	}
 }
+
+func TestSubscribeLimit(t *testing.T) {
+	c := newTestClient(10)
+	c.SetMaxSubscriptions(2)
+	if rejected := c.Subscribe([]uint16{1, 2}); len(rejected) != 0 {
+		t.Fatalf("first two subscriptions rejected: %v", rejected)
+	}
+	// Re-subscribing to an existing symbol does not count against the limit.
+	if rejected := c.Subscribe([]uint16{1}); len(rejected) != 0 {
+		t.Fatalf("re-subscribe rejected: %v", rejected)
+	}
+	rejected := c.Subscribe([]uint16{3})
+	if len(rejected) != 1 || rejected[0] != 3 {
+		t.Fatalf("third subscription rejected = %v, want [3]", rejected)
+	}
+	if c.IsSubscribed(3) {
+		t.Fatal("rejected locate should not be subscribed")
+	}
+}
+
+func TestSubscribeAllCountsAsLimit(t *testing.T) {
+	c := newTestClient(10)
+	c.SetMaxSubscriptions(5)
+	if !c.SubscribeAll(5) {
+		t.Fatal("SubscribeAll refused with a limit covering every symbol")
+	}
+	if rejected := c.Subscribe([]uint16{1}); len(rejected) != 1 {
+		t.Fatalf("subscribe after SubscribeAll rejected = %v, want [1]", rejected)
+	}
+
+	c = newTestClient(10)
+	c.SetMaxSubscriptions(4)
+	if c.SubscribeAll(5) || c.IsAllSubscribed() {
+		t.Fatal("SubscribeAll accepted with a limit below the symbol count")
+	}
+}
@@ -2,8 +2,10 @@ package session
 
 import (
 	"encoding/json"
+	"io"
 	"log"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,29 +19,78 @@ const (
 	maxMessageSize = 4096
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 4096,
-	CheckOrigin:     func(r *http.Request) bool { return true },
+// Default WebSocket buffer sizes, in bytes (see Manager.SetBufferSizes).
+const (
+	defaultReadBuffer  = 1024
+	defaultWriteBuffer = 4096
+)
+
+// newUpgrader returns an upgrader with the given buffer sizes that accepts
+// any origin.
+func newUpgrader(readSize, writeSize int) *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  readSize,
+		WriteBufferSize: writeSize,
+		CheckOrigin:     func(r *http.Request) bool { return true },
+	}
 }
 
 // controlMessage represents a client → server control message.
 type controlMessage struct {
-	Action  string   `json:"action"`
-	Symbols []string `json:"symbols,omitempty"`
-	Format  string   `json:"format,omitempty"`
+	Action     string   `json:"action"`
+	Symbols    []string `json:"symbols,omitempty"`
+	Format     string   `json:"format,omitempty"`
+	Version    int      `json:"version,omitempty"`
+	Framing    string   `json:"framing,omitempty"`
+	Token      string   `json:"token,omitempty"`
+	SinceSeq   uint64   `json:"sinceSeq,omitempty"`
+	Prices     string   `json:"prices,omitempty"`
+	Timestamps string   `json:"timestamps,omitempty"`
+	Symbol     string   `json:"symbol,omitempty"`
+	MaxPerSec  float64  `json:"maxPerSec,omitempty"`
+	MeanMs     float64  `json:"meanMs,omitempty"`
+	JitterMs   float64  `json:"jitterMs,omitempty"`
+	Count      int      `json:"count,omitempty"`
+	On         *bool    `json:"on,omitempty"`
 }
 
-// Handler creates the HTTP handler for WebSocket upgrades.
+// Handler creates the HTTP handler for WebSocket upgrades. An optional
+// ?format=json|binary query parameter sets the connection's starting format
+// and sizes its write buffer for it; the format can still be changed later.
+// At the client limit (see Manager.SetMaxClients) the upgrade is refused with
+// a 503.
 func Handler(mgr *Manager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		conn, err := upgrader.Upgrade(w, r, nil)
+		format := FormatJSON
+		if name := r.URL.Query().Get("format"); name != "" {
+			f, ok := parseFormat(name)
+			if !ok {
+				http.Error(w, "unknown format: "+name, http.StatusBadRequest)
+				return
+			}
+			format = f
+		}
+
+		if mgr.Full() {
+			http.Error(w, "server at client capacity", http.StatusServiceUnavailable)
+			return
+		}
+
+		conn, err := mgr.upgrader(format).Upgrade(w, r, nil)
 		if err != nil {
 			log.Printf("websocket upgrade error: %v", err)
 			return
 		}
 
-		client := mgr.Register(conn)
+		client, err := mgr.register(conn, format)
+		if err != nil {
+			// Lost a race for the last slot after the capacity check.
+			conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "server at client capacity"),
+				time.Now().Add(writeWait))
+			conn.Close()
+			return
+		}
 
 		// Start read and write pumps
 		go writePump(client)
@@ -81,16 +132,52 @@ func readPump(c *Client, mgr *Manager) {
 func handleControl(c *Client, mgr *Manager, ctrl *controlMessage) {
 	switch ctrl.Action {
 	case "subscribe":
+		// An optional format pins the encoding for just these symbols, so one
+		// connection can carry JSON and binary streams side by side.
+		format, pinned := FormatJSON, false
+		if ctrl.Format != "" {
+			f, ok := parseFormat(ctrl.Format)
+			if !ok {
+				log.Printf("client %d unknown subscribe format: %s", c.ID, ctrl.Format)
+				return
+			}
+			format, pinned = f, true
+		}
+
 		locates, all := mgr.ResolveTickers(ctrl.Symbols)
 		if all {
-			c.SubscribeAll()
+			if !c.SubscribeAll(len(mgr.symbols)) {
+				log.Printf("client %d subscription limit below %d symbols, rejected *", c.ID, len(mgr.symbols))
+				rejectAll(c)
+				return
+			}
+			if pinned {
+				c.SetFormat(format)
+			}
 			log.Printf("client %d subscribed to all symbols", c.ID)
-			// Send stock directory for all symbols
+			// Send stock directory and BBO for all symbols
 			sendStockDirectory(c, mgr, nil, true)
+			sendBBO(c, mgr, nil, true)
 		} else if len(locates) > 0 {
-			c.Subscribe(locates)
-			log.Printf("client %d subscribed to %v", c.ID, ctrl.Symbols)
-			sendStockDirectory(c, mgr, locates, false)
+			rejected := c.Subscribe(locates)
+			accepted := withoutLocates(locates, rejected)
+			if len(accepted) > 0 {
+				if pinned {
+					c.SetSymbolFormat(accepted, format)
+				}
+				log.Printf("client %d subscribed to %v", c.ID, ctrl.Symbols)
+				sendStockDirectory(c, mgr, accepted, false)
+				sendBBO(c, mgr, accepted, false)
+			}
+			if len(rejected) > 0 {
+				log.Printf("client %d subscription limit reached, rejected %d symbols", c.ID, len(rejected))
+				sendReply(c, subscribeRejected{
+					Type:    "subscribe_rejected",
+					Symbols: tickersFor(mgr, rejected),
+					Reason:  "subscription limit reached",
+					Limit:   c.maxSubscriptions(),
+				})
+			}
 		}
 
 	case "unsubscribe":
@@ -101,22 +188,283 @@ func handleControl(c *Client, mgr *Manager, ctrl *controlMessage) {
 		}
 
 	case "format":
-		switch ctrl.Format {
-		case "binary":
-			c.SetFormat(FormatBinary)
-			log.Printf("client %d switched to binary format", c.ID)
-		case "json":
-			c.SetFormat(FormatJSON)
-			log.Printf("client %d switched to json format", c.ID)
-		default:
+		f, ok := parseFormat(ctrl.Format)
+		if !ok {
 			log.Printf("client %d unknown format: %s", c.ID, ctrl.Format)
+			return
+		}
+		c.SetFormat(f)
+		log.Printf("client %d switched to %s format", c.ID, ctrl.Format)
+
+	case "bookSnapshot":
+		// One-shot: the current book goes out as adds without touching the
+		// client's subscriptions, so nothing further follows for these symbols.
+		locates, all := mgr.ResolveTickers(ctrl.Symbols)
+		if all {
+			locates = locates[:0]
+			for _, s := range mgr.Symbols() {
+				locates = append(locates, s.LocateCode)
+			}
+		}
+		tickers := tickersFor(mgr, locates)
+		reply := bookSnapshotReply{Type: "bookSnapshot", Symbols: []string{}}
+		for i, loc := range locates {
+			msgs, ok := mgr.BookMessages(loc)
+			if !ok {
+				continue
+			}
+			for j := range msgs {
+				msgs[j].Stock = tickers[i]
+			}
+			mgr.SendToClient(c, msgs)
+			reply.Symbols = append(reply.Symbols, tickers[i])
+			reply.Orders += len(msgs)
+		}
+		log.Printf("client %d requested book snapshot of %v", c.ID, reply.Symbols)
+		sendReply(c, reply)
+
+	case "resume":
+		// Restores a previous connection's subscriptions and replays what it
+		// missed from the resume ring.
+		reply := mgr.resume(c, ctrl.Token, ctrl.SinceSeq)
+		if reply.OK {
+			log.Printf("client %d resumed %v, replayed %d messages after seq %d", c.ID, reply.Symbols, reply.Replayed, ctrl.SinceSeq)
+		} else {
+			log.Printf("client %d resume refused: %s", c.ID, reply.Reason)
+		}
+		sendReply(c, reply)
+
+	case "history":
+		// One-shot: replays a symbol's last count messages from the resume
+		// ring, without touching the client's subscriptions.
+		reply := mgr.history(c, ctrl.Symbol, ctrl.Count)
+		if reply.OK {
+			log.Printf("client %d requested history of %s, replayed %d messages", c.ID, ctrl.Symbol, reply.Replayed)
+		} else {
+			log.Printf("client %d history refused: %s", c.ID, reply.Reason)
+		}
+		sendReply(c, reply)
+
+	case "throttle":
+		// Caps one symbol's update rate; its messages are conflated into
+		// top-of-book snapshots at most maxPerSec times a second.
+		locates, all := mgr.ResolveTickers([]string{ctrl.Symbol})
+		if all || len(locates) != 1 {
+			log.Printf("client %d throttle unknown symbol: %q", c.ID, ctrl.Symbol)
+			return
 		}
+		rate := max(ctrl.MaxPerSec, 0)
+		c.SetThrottle(locates[0], rate)
+		log.Printf("client %d throttled %s to %g updates/sec", c.ID, ctrl.Symbol, rate)
+		sendReply(c, throttleReply{Type: "throttle", Symbol: ctrl.Symbol, MaxPerSec: rate})
+
+	case "spotlight":
+		// Follows each update to one symbol with a full-depth snapshot of
+		// its book; "on": false clears it.
+		locates, all := mgr.ResolveTickers([]string{ctrl.Symbol})
+		if all || len(locates) != 1 {
+			log.Printf("client %d spotlight unknown symbol: %q", c.ID, ctrl.Symbol)
+			return
+		}
+		on := ctrl.On == nil || *ctrl.On
+		c.SetSpotlight(locates[0], on)
+		log.Printf("client %d spotlight %s: %v", c.ID, ctrl.Symbol, on)
+		sendReply(c, spotlightReply{Type: "spotlight", Symbol: ctrl.Symbol, On: on})
+
+	case "latency":
+		// Simulates network conditions: the client's messages are held
+		// meanMs, give or take up to jitterMs, before being written.
+		mean, jitter := c.SetLatency(
+			time.Duration(ctrl.MeanMs*float64(time.Millisecond)),
+			time.Duration(ctrl.JitterMs*float64(time.Millisecond)))
+		log.Printf("client %d latency set to %v ± %v", c.ID, mean, jitter)
+		sendReply(c, latencyReply{
+			Type:     "latency",
+			MeanMs:   float64(mean) / float64(time.Millisecond),
+			JitterMs: float64(jitter) / float64(time.Millisecond),
+		})
+
+	case "hello":
+		if ctrl.Version < itch.JSONVersion1 {
+			log.Printf("client %d invalid protocol version: %d", c.ID, ctrl.Version)
+			return
+		}
+		framing := c.Framing()
+		if ctrl.Framing != "" {
+			v, ok := parseFraming(ctrl.Framing)
+			if !ok {
+				log.Printf("client %d unknown binary framing: %s", c.ID, ctrl.Framing)
+				return
+			}
+			framing = v
+		}
+		numeric := c.NumericPrices()
+		switch ctrl.Prices {
+		case "":
+		case "string":
+			numeric = false
+		case "number":
+			numeric = true
+		default:
+			log.Printf("client %d unknown price encoding: %s", c.ID, ctrl.Prices)
+			return
+		}
+		timestamps := c.TimestampFormat()
+		if ctrl.Timestamps != "" {
+			f, ok := itch.ParseTimestampFormat(ctrl.Timestamps)
+			if !ok {
+				log.Printf("client %d unknown timestamp format: %s", c.ID, ctrl.Timestamps)
+				return
+			}
+			timestamps = f
+		}
+		v := min(ctrl.Version, itch.LatestJSONVersion)
+		c.SetVersion(v)
+		c.SetFraming(framing)
+		c.SetNumericPrices(numeric)
+		c.SetTimestampFormat(timestamps)
+		log.Printf("client %d negotiated protocol version %d", c.ID, v)
+		reply := helloReply{Type: "hello", Version: v, Framing: framingName(framing), Prices: "string", Timestamps: timestamps.String()}
+		if numeric {
+			reply.Prices = "number"
+		}
+		sendReply(c, reply)
 
 	default:
 		log.Printf("client %d unknown action: %s", c.ID, ctrl.Action)
 	}
 }
 
+// parseFraming maps a hello framing name to its framed header version: 0
+// for the bare ITCH length prefix.
+func parseFraming(name string) (byte, bool) {
+	switch name {
+	case "itch":
+		return 0, true
+	case "framed":
+		return itch.FramedVersion, true
+	case "framed2":
+		return itch.FramedVersion2, true
+	default:
+		return 0, false
+	}
+}
+
+// framingName reverses parseFraming.
+func framingName(version byte) string {
+	switch version {
+	case itch.FramedVersion:
+		return "framed"
+	case itch.FramedVersion2:
+		return "framed2"
+	default:
+		return "itch"
+	}
+}
+
+// parseFormat maps a control-message format name to its Format.
+func parseFormat(name string) (Format, bool) {
+	switch name {
+	case "json":
+		return FormatJSON, true
+	case "binary":
+		return FormatBinary, true
+	default:
+		return 0, false
+	}
+}
+
+// helloReply acks a hello with the protocol version the server will speak:
+// the client's requested version, capped at the newest one supported, and
+// the binary framing, JSON price encoding and timestamp format in effect.
+type helloReply struct {
+	Type       string `json:"type"`
+	Version    int    `json:"version"`
+	Framing    string `json:"framing"`
+	Prices     string `json:"prices"`
+	Timestamps string `json:"timestamps"`
+}
+
+// bookSnapshotReply follows the Add Order messages of a bookSnapshot, marking
+// the snapshot complete and listing the symbols it covered.
+type bookSnapshotReply struct {
+	Type    string   `json:"type"`
+	Symbols []string `json:"symbols"`
+	Orders  int      `json:"orders"`
+}
+
+// bboReply is the one-time top-of-book snapshot sent per symbol after a
+// subscribe's stock directory (type "bbo"), and the body of an idle symbol's
+// heartbeat (type "heartbeat"). An empty side has zero price and size.
+type bboReply struct {
+	Type     string  `json:"type"`
+	Symbol   string  `json:"symbol"`
+	BidPrice float64 `json:"bidPrice"`
+	BidSize  int32   `json:"bidSize"`
+	AskPrice float64 `json:"askPrice"`
+	AskSize  int32   `json:"askSize"`
+}
+
+// subscribeRejected acks symbols refused by the per-client subscription limit.
+type subscribeRejected struct {
+	Type    string   `json:"type"`
+	Symbols []string `json:"symbols"`
+	Reason  string   `json:"reason"`
+	Limit   int      `json:"limit"`
+}
+
+// rejectAll acks a "*" subscription refused because the subscription limit
+// is below the number of symbols.
+func rejectAll(c *Client) {
+	sendReply(c, subscribeRejected{
+		Type:    "subscribe_rejected",
+		Symbols: []string{"*"},
+		Reason:  "subscription limit below symbol count",
+		Limit:   c.maxSubscriptions(),
+	})
+}
+
+// sendReply queues a JSON control reply to the client.
+func sendReply(c *Client, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("client %d encode reply: %v", c.ID, err)
+		return
+	}
+	c.SendControl(data)
+}
+
+// withoutLocates returns locates minus any in drop, preserving order.
+func withoutLocates(locates, drop []uint16) []uint16 {
+	if len(drop) == 0 {
+		return locates
+	}
+	skip := make(map[uint16]bool, len(drop))
+	for _, l := range drop {
+		skip[l] = true
+	}
+	out := make([]uint16, 0, len(locates))
+	for _, l := range locates {
+		if !skip[l] {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// tickersFor maps locate codes back to tickers, preserving order.
+func tickersFor(mgr *Manager, locates []uint16) []string {
+	byLocate := make(map[uint16]string, len(mgr.Symbols()))
+	for _, s := range mgr.Symbols() {
+		byLocate[s.LocateCode] = s.Ticker
+	}
+	out := make([]string, 0, len(locates))
+	for _, l := range locates {
+		out = append(out, byLocate[l])
+	}
+	return out
+}
+
 // sendStockDirectory sends stock directory messages for subscribed symbols.
 func sendStockDirectory(c *Client, mgr *Manager, locates []uint16, all bool) {
 	syms := mgr.Symbols()
@@ -159,6 +507,54 @@ func sendStockDirectory(c *Client, mgr *Manager, locates []uint16, all bool) {
 	mgr.SendToClient(c, msgs)
 }
 
+// sendBBO sends a bboReply for each newly subscribed symbol that has a book,
+// in universe order, so the client has the touch before the next update.
+func sendBBO(c *Client, mgr *Manager, locates []uint16, all bool) {
+	for _, s := range mgr.Symbols() {
+		if !all && !slices.Contains(locates, s.LocateCode) {
+			continue
+		}
+		if r, ok := mgr.quote("bbo", s.LocateCode, s.Ticker); ok {
+			sendReply(c, r)
+		}
+	}
+}
+
+// frameWriter is the part of a WebSocket connection writeOutbound uses.
+type frameWriter interface {
+	NextWriter(messageType int) (io.WriteCloser, error)
+	EnableWriteCompression(enable bool)
+}
+
+// writeOutbound writes out as one WebSocket message, compressed (when the
+// connection negotiated it) only if the payload is at least compressMin
+// bytes. With maxFrame > 0 the payload is written at most maxFrame bytes at
+// a time into a connection whose write buffer is capped at maxFrame (see
+// Manager.upgrader), so a larger message goes out as protocol-level
+// fragments that clients reassemble into the whole message.
+func writeOutbound(w frameWriter, out outbound, maxFrame, compressMin int) error {
+	msgType := websocket.TextMessage
+	if !out.control && out.format == FormatBinary {
+		msgType = websocket.BinaryMessage
+	}
+	w.EnableWriteCompression(len(out.data) >= compressMin)
+	mw, err := w.NextWriter(msgType)
+	if err != nil {
+		return err
+	}
+	for data := out.data; len(data) > 0; {
+		n := len(data)
+		if maxFrame > 0 {
+			n = min(n, maxFrame)
+		}
+		if _, err := mw.Write(data[:n]); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return mw.Close()
+}
+
 // writePump sends messages from the send channel to the WebSocket.
 func writePump(c *Client) {
 	ticker := time.NewTicker(pingPeriod)
@@ -169,18 +565,13 @@ func writePump(c *Client) {
 
 	for {
 		select {
-		case data, ok := <-c.SendCh():
+		case out, ok := <-c.SendCh():
 			if !ok {
 				return
 			}
+			c.awaitLatency(out)
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-
-			msgType := websocket.TextMessage
-			if c.Format() == FormatBinary {
-				msgType = websocket.BinaryMessage
-			}
-
-			if err := c.Conn.WriteMessage(msgType, data); err != nil {
+			if err := writeOutbound(c.Conn, out, c.MaxFrameSize(), c.CompressionThreshold()); err != nil {
 				return
 			}
 
@@ -0,0 +1,751 @@
+package session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+)
+
+// drain returns everything queued on the client's send channel.
+func drain(c *Client) []outbound {
+	var out []outbound
+	for {
+		select {
+		case o := <-c.sendCh:
+			out = append(out, o)
+		default:
+			return out
+		}
+	}
+}
+
+func TestSubscribeOverLimitIsReported(t *testing.T) {
+	mgr := newTestManager()
+	c := newTestClient(100)
+	c.SetMaxSubscriptions(2)
+
+	handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"NEXO", "QBIT"}})
+	for _, o := range drain(c) {
+		if o.control {
+			t.Fatalf("unexpected control reply within limit: %s", o.data)
+		}
+	}
+
+	handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"FLUX"}})
+	if c.IsSubscribed(3) {
+		t.Fatal("FLUX should have been refused")
+	}
+
+	var reply subscribeRejected
+	found := false
+	for _, o := range drain(c) {
+		if !o.control {
+			t.Fatalf("refused subscription still sent feed data: %s", o.data)
+		}
+		if err := json.Unmarshal(o.data, &reply); err != nil {
+			t.Fatalf("decode reply: %v", err)
+		}
+		found = true
+	}
+	if !found {
+		t.Fatal("no rejection reply queued")
+	}
+	if reply.Type != "subscribe_rejected" || len(reply.Symbols) != 1 || reply.Symbols[0] != "FLUX" || reply.Limit != 2 {
+		t.Fatalf("reply = %+v, want subscribe_rejected for [FLUX] limit 2", reply)
+	}
+}
+
+// TestSubscribeAllOverLimitIsRejected checks that "*" is refused with a
+// rejection ack, and no feed data, when the subscription limit is below the
+// number of symbols.
+func TestSubscribeAllOverLimitIsRejected(t *testing.T) {
+	mgr := newTestManager()
+	c := newTestClient(100)
+	c.SetMaxSubscriptions(len(mgr.symbols) - 1)
+
+	handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"*"}})
+	if c.IsAllSubscribed() || c.IsSubscribed(1) {
+		t.Fatal("* should have been refused")
+	}
+	out := drain(c)
+	var reply subscribeRejected
+	if len(out) != 1 || !out[0].control || json.Unmarshal(out[0].data, &reply) != nil {
+		t.Fatalf("got %d frames, want just the rejection ack", len(out))
+	}
+	if reply.Type != "subscribe_rejected" || !slices.Equal(reply.Symbols, []string{"*"}) || reply.Limit != len(mgr.symbols)-1 {
+		t.Fatalf("reply = %+v, want subscribe_rejected for [*] limit %d", reply, len(mgr.symbols)-1)
+	}
+
+	c.SetMaxSubscriptions(len(mgr.symbols))
+	handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"*"}})
+	if !c.IsAllSubscribed() {
+		t.Fatal("* refused with a limit covering every symbol")
+	}
+}
+
+// TestSubscribeFormatPerSymbol checks that a format on a subscribe message
+// applies only to the symbols it names: NEXO arrives binary and QBIT JSON on
+// the same connection.
+func TestSubscribeFormatPerSymbol(t *testing.T) {
+	mgr := newTestManager()
+	c := newTestClient(100)
+	mgr.mu.Lock()
+	mgr.clients[c.ID] = c
+	mgr.mu.Unlock()
+
+	handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"NEXO"}, Format: "binary"})
+	handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"QBIT"}, Format: "json"})
+	drain(c)
+
+	locs, _ := mgr.ResolveTickers([]string{"NEXO", "QBIT"})
+	nexo, qbit := locs[0], locs[1]
+	add := func(ref uint64) []itch.Message {
+		return []itch.Message{{Type: itch.MsgAddOrder, OrderRef: ref, Side: 'B', Shares: 100, Price: 10}}
+	}
+	mgr.Broadcast(nexo, "NEXO", add(1))
+	mgr.Broadcast(qbit, "QBIT", add(2))
+
+	out := drain(c)
+	if len(out) != 2 {
+		t.Fatalf("%d frames queued, want 2", len(out))
+	}
+
+	bin := out[0]
+	if bin.format != FormatBinary {
+		t.Errorf("NEXO frame tagged %v, want binary", bin.format)
+	}
+	if len(bin.data) < 3 || int(binary.BigEndian.Uint16(bin.data)) != len(bin.data)-2 || bin.data[2] != byte(itch.MsgAddOrder) {
+		t.Errorf("NEXO frame is not a length-prefixed binary add order: % x", bin.data)
+	}
+
+	js := out[1]
+	if js.format != FormatJSON {
+		t.Errorf("QBIT frame tagged %v, want json", js.format)
+	}
+	var m struct{ Stock string }
+	if err := json.Unmarshal(js.data, &m); err != nil || m.Stock != "QBIT" {
+		t.Errorf("QBIT frame is not JSON for QBIT (err=%v): %s", err, js.data)
+	}
+}
+
+// TestHelloVersionSelectsJSONFields checks that a client which said hello
+// with version 2 receives the v2 fields, while a default (v1) client on the
+// same broadcast does not.
+func TestHelloVersionSelectsJSONFields(t *testing.T) {
+	mgr := newTestManager()
+	v1, v2 := newTestClient(100), newTestClient(100)
+	mgr.mu.Lock()
+	mgr.clients[v1.ID] = v1
+	mgr.clients[v2.ID] = v2
+	mgr.mu.Unlock()
+
+	handleControl(v2, mgr, &controlMessage{Action: "hello", Version: 2})
+	var reply helloReply
+	out := drain(v2)
+	if len(out) != 1 || !out[0].control {
+		t.Fatalf("hello: got %d frames, want one control reply", len(out))
+	}
+	if err := json.Unmarshal(out[0].data, &reply); err != nil || reply.Version != 2 {
+		t.Fatalf("hello reply = %s (err=%v), want version 2", out[0].data, err)
+	}
+
+	for _, c := range []*Client{v1, v2} {
+		handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"NEXO"}})
+		drain(c)
+	}
+	mgr.Broadcast(1, "NEXO", []itch.Message{
+		{Type: itch.MsgOrderExecuted, OrderRef: 7, Shares: 100, Price: 10, MatchNumber: 1},
+	})
+
+	for _, tc := range []struct {
+		c    *Client
+		want bool
+	}{{v1, false}, {v2, true}} {
+		out := drain(tc.c)
+		if len(out) != 1 {
+			t.Fatalf("version %d: %d frames, want 1", tc.c.Version(), len(out))
+		}
+		var obj map[string]any
+		if err := json.Unmarshal(out[0].data, &obj); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		for _, field := range []string{"stock", "price"} {
+			if _, has := obj[field]; has != tc.want {
+				t.Errorf("version %d: has %q = %v, want %v: %s", tc.c.Version(), field, has, tc.want, out[0].data)
+			}
+		}
+	}
+}
+
+func TestHelloCapsVersion(t *testing.T) {
+	mgr := newTestManager()
+	c := newTestClient(10)
+	handleControl(c, mgr, &controlMessage{Action: "hello", Version: 99})
+	if c.Version() != itch.LatestJSONVersion {
+		t.Errorf("version = %d, want %d", c.Version(), itch.LatestJSONVersion)
+	}
+	handleControl(c, mgr, &controlMessage{Action: "hello", Version: 0})
+	if c.Version() != itch.LatestJSONVersion {
+		t.Errorf("invalid hello changed version to %d", c.Version())
+	}
+}
+
+// TestHelloFramedBinary checks that hello can switch binary output to the
+// framed header encoding, and back to bare ITCH.
+func TestHelloFramedBinary(t *testing.T) {
+	mgr := newTestManager()
+	c := newTestClient(100)
+	mgr.mu.Lock()
+	mgr.clients[c.ID] = c
+	mgr.mu.Unlock()
+
+	handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"NEXO"}, Format: "binary"})
+	handleControl(c, mgr, &controlMessage{Action: "hello", Version: 1, Framing: "framed"})
+	var reply helloReply
+	for _, o := range drain(c) {
+		if o.control {
+			if err := json.Unmarshal(o.data, &reply); err != nil {
+				t.Fatalf("decode reply: %v", err)
+			}
+		}
+	}
+	if reply.Framing != "framed" {
+		t.Fatalf("hello reply = %+v, want framing framed", reply)
+	}
+
+	add := itch.Message{Type: itch.MsgAddOrder, OrderRef: 7, Side: 'B', Shares: 100, Price: 10}
+	locs, _ := mgr.ResolveTickers([]string{"NEXO"})
+	mgr.Broadcast(locs[0], "NEXO", []itch.Message{add})
+	out := drain(c)
+	if len(out) != 1 {
+		t.Fatalf("got %d frames, want 1", len(out))
+	}
+	m, err := itch.DecodeFramed(out[0].data)
+	if err != nil {
+		t.Fatalf("DecodeFramed: %v", err)
+	}
+	if m.Type != itch.MsgAddOrder || m.OrderRef != 7 || m.Stock != "NEXO" {
+		t.Fatalf("decoded %+v, want add order 7 for NEXO", m)
+	}
+
+	handleControl(c, mgr, &controlMessage{Action: "hello", Version: 1, Framing: "itch"})
+	drain(c)
+	mgr.Broadcast(locs[0], "NEXO", []itch.Message{add})
+	if out := drain(c); len(out) != 1 {
+		t.Fatalf("got %d frames, want 1", len(out))
+	} else if _, err := itch.DecodeBinary(out[0].data); err != nil {
+		t.Fatalf("after framing itch: DecodeBinary: %v", err)
+	}
+}
+
+// TestHelloFramed2CarriesLiquidity checks that a client negotiating framing
+// version 2 gets a trade's liquidity flag in the header, while a bare ITCH
+// client gets the trade with its tracking number untouched.
+func TestHelloFramed2CarriesLiquidity(t *testing.T) {
+	mgr := newTestManager()
+	framed := newTestClient(100)
+	bare := newTestClient(100)
+	for _, c := range []*Client{framed, bare} {
+		mgr.mu.Lock()
+		mgr.clients[c.ID] = c
+		mgr.mu.Unlock()
+		handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"NEXO"}, Format: "binary"})
+	}
+	handleControl(framed, mgr, &controlMessage{Action: "hello", Version: 1, Framing: "framed2"})
+	drain(bare)
+	var reply helloReply
+	for _, o := range drain(framed) {
+		if o.control {
+			json.Unmarshal(o.data, &reply)
+		}
+	}
+	if reply.Framing != "framed2" || framed.Framing() != itch.FramedVersion2 {
+		t.Fatalf("hello reply = %+v, want framing framed2", reply)
+	}
+
+	locs, _ := mgr.ResolveTickers([]string{"NEXO"})
+	mgr.Broadcast(locs[0], "NEXO", []itch.Message{{Type: itch.MsgTrade, TrackingNum: 67, OrderRef: 3, Side: 'B', Shares: 100,
+		Price: 10, MatchNumber: 1, LiquidityFlag: itch.LiquidityRemoved}})
+
+	out := drain(framed)
+	if len(out) != 1 {
+		t.Fatalf("framed2: got %d frames, want 1", len(out))
+	}
+	if m, err := itch.DecodeFramed(out[0].data); err != nil || m.LiquidityFlag != itch.LiquidityRemoved || m.TrackingNum != 67 {
+		t.Fatalf("framed2 decoded %+v, %v; want liquidity R, tracking 67", m, err)
+	}
+	out = drain(bare)
+	if len(out) != 1 {
+		t.Fatalf("bare: got %d frames, want 1", len(out))
+	}
+	if m, err := itch.DecodeBinary(out[0].data); err != nil || m.LiquidityFlag != 0 || m.TrackingNum != 67 {
+		t.Fatalf("bare decoded %+v, %v; want tracking 67 and no liquidity", m, err)
+	}
+}
+
+// TestHelloNumericPrices checks that hello can switch a JSON client's prices
+// to numbers and back to 4-decimal strings.
+func TestHelloNumericPrices(t *testing.T) {
+	mgr := newTestManager()
+	c := newTestClient(100)
+	mgr.mu.Lock()
+	mgr.clients[c.ID] = c
+	mgr.mu.Unlock()
+
+	handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"NEXO"}})
+	locs, _ := mgr.ResolveTickers([]string{"NEXO"})
+	add := itch.Message{Type: itch.MsgAddOrder, OrderRef: 7, Side: 'B', Shares: 100, Price: 185.25}
+	for _, tc := range []struct {
+		prices string
+		want   any
+	}{
+		{"number", 185.25},
+		{"string", "185.2500"},
+	} {
+		handleControl(c, mgr, &controlMessage{Action: "hello", Version: 1, Prices: tc.prices})
+		drain(c)
+		mgr.Broadcast(locs[0], "NEXO", []itch.Message{add})
+		out := drain(c)
+		if len(out) != 1 {
+			t.Fatalf("prices %s: got %d frames, want 1", tc.prices, len(out))
+		}
+		var msg map[string]any
+		if err := json.Unmarshal(out[0].data, &msg); err != nil {
+			t.Fatalf("decode %s: %v", out[0].data, err)
+		}
+		if msg["price"] != tc.want {
+			t.Errorf("prices %s: price = %#v, want %#v", tc.prices, msg["price"], tc.want)
+		}
+	}
+}
+
+// TestBookSnapshotDoesNotSubscribe checks that bookSnapshot sends the resting
+// orders once, in priority order, and leaves the client unsubscribed so later
+// broadcasts for the symbol do not reach it.
+func TestBookSnapshotDoesNotSubscribe(t *testing.T) {
+	mgr := newTestManager()
+	c := newTestClient(100)
+	mgr.mu.Lock()
+	mgr.clients[c.ID] = c
+	mgr.mu.Unlock()
+
+	locs, _ := mgr.ResolveTickers([]string{"NEXO"})
+	nexo := locs[0]
+	book := orderbook.NewBook(nexo, 0.01)
+	book.AddOrder(&orderbook.Order{ID: 1, Locate: nexo, Side: orderbook.SideBuy, Price: 9.99, Shares: 100})
+	book.AddOrder(&orderbook.Order{ID: 2, Locate: nexo, Side: orderbook.SideBuy, Price: 10.00, Shares: 200, MPID: "GSCO"})
+	book.AddOrder(&orderbook.Order{ID: 3, Locate: nexo, Side: orderbook.SideSell, Price: 10.01, Shares: 300})
+	mgr.SetBooks(map[uint16]*orderbook.Book{nexo: book})
+
+	handleControl(c, mgr, &controlMessage{Action: "bookSnapshot", Symbols: []string{"NEXO"}})
+
+	var refs []uint64
+	var reply bookSnapshotReply
+	for _, o := range drain(c) {
+		if o.control {
+			if err := json.Unmarshal(o.data, &reply); err != nil {
+				t.Fatalf("decode reply: %v", err)
+			}
+			continue
+		}
+		var m struct {
+			Type     string `json:"type"`
+			Stock    string `json:"stock"`
+			OrderRef uint64 `json:"orderRef"`
+		}
+		if err := json.Unmarshal(o.data, &m); err != nil {
+			t.Fatalf("decode snapshot message: %v", err)
+		}
+		if m.Stock != "NEXO" {
+			t.Errorf("snapshot message %s has stock %q, want NEXO", o.data, m.Stock)
+		}
+		refs = append(refs, m.OrderRef)
+	}
+	if want := []uint64{2, 1, 3}; len(refs) != len(want) || refs[0] != want[0] || refs[1] != want[1] || refs[2] != want[2] {
+		t.Fatalf("snapshot order refs = %v, want %v", refs, want)
+	}
+	if reply.Type != "bookSnapshot" || len(reply.Symbols) != 1 || reply.Symbols[0] != "NEXO" || reply.Orders != 3 {
+		t.Fatalf("reply = %+v, want bookSnapshot of NEXO with 3 orders", reply)
+	}
+
+	if c.IsSubscribed(nexo) {
+		t.Fatal("bookSnapshot subscribed the client")
+	}
+	mgr.Broadcast(nexo, "NEXO", []itch.Message{{Type: itch.MsgAddOrder, OrderRef: 4, Side: 'B', Shares: 100, Price: 10}})
+	if out := drain(c); len(out) != 0 {
+		t.Fatalf("client received %d messages after the snapshot", len(out))
+	}
+}
+
+// recordConn keeps a copy of every byte read from the connection.
+type recordConn struct {
+	net.Conn
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (r *recordConn) Read(p []byte) (int, error) {
+	n, err := r.Conn.Read(p)
+	r.mu.Lock()
+	r.buf = append(r.buf, p[:n]...)
+	r.mu.Unlock()
+	return n, err
+}
+
+// wsFrame is one frame header of a server-to-client WebSocket stream.
+type wsFrame struct {
+	fin    bool
+	opcode byte
+	size   int
+}
+
+// serverFrames parses the (unmasked) frames recorded after the upgrade
+// response.
+func serverFrames(t *testing.T, raw []byte) []wsFrame {
+	t.Helper()
+	end := bytes.Index(raw, []byte("\r\n\r\n"))
+	if end < 0 {
+		t.Fatal("no upgrade response recorded")
+	}
+	raw = raw[end+4:]
+	var frames []wsFrame
+	for len(raw) >= 2 {
+		f := wsFrame{fin: raw[0]&0x80 != 0, opcode: raw[0] & 0x0f, size: int(raw[1] & 0x7f)}
+		off := 2
+		switch f.size {
+		case 126:
+			f.size, off = int(binary.BigEndian.Uint16(raw[2:4])), 4
+		case 127:
+			f.size, off = int(binary.BigEndian.Uint64(raw[2:10])), 10
+		}
+		if len(raw) < off+f.size {
+			t.Fatalf("truncated frame: %+v", f)
+		}
+		frames = append(frames, f)
+		raw = raw[off+f.size:]
+	}
+	return frames
+}
+
+// TestMaxFrameSizeFragmentsMessages checks that a message over the frame cap
+// goes out as a fragmented WebSocket message: capped frames a real client
+// reassembles into the original payload. A JSON reply split mid-rune stays
+// valid JSON and UTF-8, and a binary batch decodes whole.
+func TestMaxFrameSizeFragmentsMessages(t *testing.T) {
+	const maxFrame = 64
+	mgr := newTestManager()
+	mgr.SetMaxFrameSize(maxFrame)
+	srv := httptest.NewServer(Handler(mgr))
+	defer srv.Close()
+	rec := &recordConn{}
+	dialer := websocket.Dialer{NetDial: func(network, addr string) (net.Conn, error) {
+		conn, err := net.Dial(network, addr)
+		rec.Conn = conn
+		return rec, err
+	}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	var c *Client
+	for deadline := time.Now().Add(5 * time.Second); c == nil && time.Now().Before(deadline); {
+		mgr.mu.RLock()
+		for _, cl := range mgr.clients {
+			c = cl
+		}
+		mgr.mu.RUnlock()
+		time.Sleep(time.Millisecond)
+	}
+	if c == nil {
+		t.Fatal("client never registered")
+	}
+
+	// "é" is two bytes, so some 64-byte boundary falls inside one.
+	reply, _ := json.Marshal(map[string]string{"type": "bookSnapshot", "note": strings.Repeat("é", 100)})
+	var batch []byte
+	for ref := uint64(1); ref <= 5; ref++ {
+		batch = append(batch, itch.EncodeBinary(&itch.Message{Type: itch.MsgAddOrder, StockLocate: 1, OrderRef: ref,
+			Side: 'B', Shares: 100, Stock: "NEXO", Price: 10})...)
+	}
+	c.SendControl(reply)
+	c.SendFormat(batch, FormatBinary)
+
+	kind, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read reply: %v", err)
+	}
+	if kind != websocket.TextMessage || !bytes.Equal(data, reply) || !json.Valid(data) {
+		t.Fatalf("reply arrived as type %d, %d bytes, want the whole %d-byte JSON text", kind, len(data), len(reply))
+	}
+	kind, data, err = conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read batch: %v", err)
+	}
+	if kind != websocket.BinaryMessage || !bytes.Equal(data, batch) {
+		t.Fatalf("batch arrived as type %d, %d bytes, want the whole %d-byte binary batch", kind, len(data), len(batch))
+	}
+	for off := 0; off < len(data); {
+		n := 2 + int(binary.BigEndian.Uint16(data[off:]))
+		if _, err := itch.DecodeBinary(data[off : off+n]); err != nil {
+			t.Fatalf("batch message at %d: %v", off, err)
+		}
+		off += n
+	}
+
+	rec.mu.Lock()
+	frames := serverFrames(t, rec.buf)
+	rec.mu.Unlock()
+	var messages, fragments int
+	for i, f := range frames {
+		if f.size > maxFrame {
+			t.Errorf("frame %d carries %d bytes, over the %d cap", i, f.size, maxFrame)
+		}
+		if f.opcode != 0 {
+			messages++
+		} else {
+			fragments++
+		}
+	}
+	if messages != 2 || fragments < 2 || !frames[len(frames)-1].fin {
+		t.Errorf("frames = %+v, want 2 fragmented messages", frames)
+	}
+}
+
+// fakeFrameConn records whether compression was enabled for each message
+// written.
+type fakeFrameConn struct {
+	compress   bool
+	compressed []bool
+}
+
+func (f *fakeFrameConn) NextWriter(int) (io.WriteCloser, error) {
+	f.compressed = append(f.compressed, f.compress)
+	return nopWriteCloser{io.Discard}, nil
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func (f *fakeFrameConn) EnableWriteCompression(on bool) { f.compress = on }
+
+// TestCompressionThreshold checks that a message under the threshold is
+// written uncompressed and one at or above it compressed.
+func TestCompressionThreshold(t *testing.T) {
+	mgr := newTestManager()
+	mgr.SetCompression(true, 256)
+	if !mgr.upgrader(FormatJSON).EnableCompression {
+		t.Fatal("upgrader does not offer compression")
+	}
+
+	conn := &fakeFrameConn{compress: true}
+	small := outbound{data: bytes.Repeat([]byte("x"), 255)}
+	large := outbound{data: bytes.Repeat([]byte("x"), 256), format: FormatBinary}
+	for _, out := range []outbound{small, large, small} {
+		if err := writeOutbound(conn, out, 0, 256); err != nil {
+			t.Fatalf("writeOutbound: %v", err)
+		}
+	}
+	if want := []bool{false, true, false}; !slices.Equal(conn.compressed, want) {
+		t.Errorf("frames compressed = %v, want %v", conn.compressed, want)
+	}
+
+	// A zero threshold compresses everything, even fragmented messages.
+	conn = &fakeFrameConn{}
+	writeOutbound(conn, small, 100, 0)
+	if want := []bool{true}; !slices.Equal(conn.compressed, want) {
+		t.Errorf("no threshold: frames compressed = %v, want %v", conn.compressed, want)
+	}
+}
+
+// TestSubscribeSendsBBO checks that a subscribe is followed by one BBO reply
+// per subscribed symbol with a book, carrying the book's current touch.
+func TestSubscribeSendsBBO(t *testing.T) {
+	mgr := newTestManager()
+	c := newTestClient(100)
+
+	locs, _ := mgr.ResolveTickers([]string{"NEXO", "QBIT"})
+	nexo, qbit := locs[0], locs[1]
+	book := orderbook.NewBook(nexo, 0.01)
+	book.AddOrder(&orderbook.Order{ID: 1, Locate: nexo, Side: orderbook.SideBuy, Price: 9.99, Shares: 100})
+	book.AddOrder(&orderbook.Order{ID: 2, Locate: nexo, Side: orderbook.SideBuy, Price: 10.00, Shares: 200})
+	book.AddOrder(&orderbook.Order{ID: 3, Locate: nexo, Side: orderbook.SideBuy, Price: 10.00, Shares: 50})
+	book.AddOrder(&orderbook.Order{ID: 4, Locate: nexo, Side: orderbook.SideSell, Price: 10.02, Shares: 300})
+	empty := orderbook.NewBook(qbit, 0.01)
+	mgr.SetBooks(map[uint16]*orderbook.Book{nexo: book, qbit: empty})
+
+	handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"NEXO", "QBIT"}})
+
+	var got []bboReply
+	for _, o := range drain(c) {
+		if !o.control {
+			continue // stock directory
+		}
+		var r bboReply
+		if err := json.Unmarshal(o.data, &r); err != nil {
+			t.Fatalf("decode reply: %v", err)
+		}
+		got = append(got, r)
+	}
+	want := []bboReply{
+		{Type: "bbo", Symbol: "NEXO", BidPrice: book.BestBid(), BidSize: 250, AskPrice: book.BestAsk(), AskSize: 300},
+		{Type: "bbo", Symbol: "QBIT"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d BBO replies %+v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("BBO %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestUpgraderBufferSizes(t *testing.T) {
+	mgr := newTestManager()
+	if u := mgr.upgrader(FormatJSON); u.ReadBufferSize != defaultReadBuffer || u.WriteBufferSize != defaultWriteBuffer {
+		t.Fatalf("default upgrader buffers = %d/%d, want %d/%d",
+			u.ReadBufferSize, u.WriteBufferSize, defaultReadBuffer, defaultWriteBuffer)
+	}
+
+	mgr.SetBufferSizes(2048, 8192, 65536)
+	for _, tc := range []struct {
+		format Format
+		write  int
+	}{{FormatJSON, 8192}, {FormatBinary, 65536}} {
+		u := mgr.upgrader(tc.format)
+		if u.ReadBufferSize != 2048 || u.WriteBufferSize != tc.write {
+			t.Errorf("format %d: upgrader buffers = %d/%d, want 2048/%d",
+				tc.format, u.ReadBufferSize, u.WriteBufferSize, tc.write)
+		}
+	}
+
+	mgr.SetBufferSizes(0, -1, 0)
+	if u := mgr.upgrader(FormatBinary); u.ReadBufferSize != defaultReadBuffer || u.WriteBufferSize != defaultWriteBuffer {
+		t.Errorf("non-positive sizes: upgrader buffers = %d/%d, want the defaults", u.ReadBufferSize, u.WriteBufferSize)
+	}
+}
+
+// TestConnectFormatQuery checks that ?format= sets the connection's starting
+// format and that an unknown format is refused before the upgrade.
+func TestConnectFormatQuery(t *testing.T) {
+	mgr := newTestManager()
+	srv := httptest.NewServer(Handler(mgr))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"?format=xml", nil); err == nil || resp == nil || resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("?format=xml: err %v, want a 400 refusal", err)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?format=binary", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	var c *Client
+	for deadline := time.Now().Add(5 * time.Second); c == nil && time.Now().Before(deadline); {
+		mgr.mu.RLock()
+		for _, cl := range mgr.clients {
+			c = cl
+		}
+		mgr.mu.RUnlock()
+		time.Sleep(time.Millisecond)
+	}
+	if c == nil {
+		t.Fatal("client never registered")
+	}
+	if c.Format() != FormatBinary {
+		t.Fatalf("format = %d, want FormatBinary", c.Format())
+	}
+}
+
+func TestMaxClientsRefusesBeyondCap(t *testing.T) {
+	const maxClients = 2
+	mgr := newTestManager()
+	mgr.SetMaxClients(maxClients)
+	srv := httptest.NewServer(Handler(mgr))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	for i := 0; i < maxClients; i++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("dial %d: %v", i, err)
+		}
+		defer conn.Close()
+	}
+	for deadline := time.Now().Add(5 * time.Second); mgr.ClientCount() < maxClients && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("dial beyond the cap: err %v, want a 503 refusal", err)
+	}
+	if n := mgr.ClientCount(); n != maxClients {
+		t.Fatalf("ClientCount = %d, want %d", n, maxClients)
+	}
+
+	// A direct registration past the check is refused too.
+	if _, err := mgr.Register(nil); err != ErrTooManyClients {
+		t.Fatalf("Register at the cap: err %v, want ErrTooManyClients", err)
+	}
+}
+
+// TestHelloTimestamps checks that hello can add a readable "time" to the
+// client's JSON messages beside the raw nanos.
+func TestHelloTimestamps(t *testing.T) {
+	mgr := newTestManager()
+	c := newTestClient(100)
+	mgr.mu.Lock()
+	mgr.clients[c.ID] = c
+	mgr.mu.Unlock()
+
+	handleControl(c, mgr, &controlMessage{Action: "hello", Version: 1, Timestamps: "clock"})
+	var reply helloReply
+	if out := drain(c); len(out) != 1 || json.Unmarshal(out[0].data, &reply) != nil || reply.Timestamps != "clock" {
+		t.Fatalf("hello reply = %+v, want clock timestamps", reply)
+	}
+	handleControl(c, mgr, &controlMessage{Action: "subscribe", Symbols: []string{"NEXO"}})
+	drain(c)
+	mgr.Broadcast(1, "NEXO", []itch.Message{{Type: itch.MsgOrderDelete, OrderRef: 7}})
+
+	out := drain(c)
+	if len(out) != 1 {
+		t.Fatalf("%d frames, want 1", len(out))
+	}
+	var obj struct {
+		Timestamp int64  `json:"timestamp"`
+		Time      string `json:"time"`
+	}
+	if err := json.Unmarshal(out[0].data, &obj); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if want := itch.FormatTimestamp(obj.Timestamp); obj.Time != want {
+		t.Errorf("time = %q, want %q for timestamp %d", obj.Time, want, obj.Timestamp)
+	}
+
+	handleControl(c, mgr, &controlMessage{Action: "hello", Version: 1, Timestamps: "unix"})
+	if out := drain(c); len(out) != 0 || c.TimestampFormat() != itch.TimestampClock {
+		t.Errorf("unknown timestamp format changed the client to %v", c.TimestampFormat())
+	}
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// SetHeartbeat enables heartbeats: a symbol that has broadcast nothing for
+// idle gets a heartbeat carrying its current BBO, sent to its subscribers and
+// repeated every idle while it stays quiet. idle <= 0 disables them. Call it
+// before RunHeartbeats.
+func (m *Manager) SetHeartbeat(idle time.Duration) {
+	m.idle = idle
+	if idle <= 0 {
+		m.lastBeat = nil
+		return
+	}
+	now := m.clock.Now().UnixNano()
+	m.lastBeat = make(map[uint16]*atomic.Int64, len(m.symbols))
+	for _, s := range m.symbols {
+		m.lastBeat[s.LocateCode] = new(atomic.Int64)
+		m.lastBeat[s.LocateCode].Store(now)
+	}
+}
+
+// RunHeartbeats checks for idle symbols at a quarter of the idle period until
+// ctx is cancelled. It returns at once when heartbeats are disabled.
+func (m *Manager) RunHeartbeats(ctx context.Context) {
+	if m.idle <= 0 {
+		return
+	}
+	ticker := m.clock.NewTicker(m.idle / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.heartbeat()
+		}
+	}
+}
+
+// heartbeat sends a heartbeat for every symbol whose last broadcast and last
+// heartbeat are both at least idle ago.
+func (m *Manager) heartbeat() {
+	now := m.clock.Now().UnixNano()
+	for _, s := range m.symbols {
+		beat := m.lastBeat[s.LocateCode]
+		if now-max(beat.Load(), m.lastSent[s.LocateCode].Load()) < int64(m.idle) {
+			continue
+		}
+		beat.Store(now)
+
+		r, _ := m.quote("heartbeat", s.LocateCode, s.Ticker)
+		data, err := json.Marshal(r)
+		if err != nil {
+			log.Printf("encode heartbeat for %s: %v", s.Ticker, err)
+			continue
+		}
+
+		for _, c := range m.clientList() {
+			if c.IsSubscribed(s.LocateCode) {
+				c.SendControl(data)
+			}
+		}
+	}
+}
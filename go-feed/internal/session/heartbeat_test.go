@@ -0,0 +1,75 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+)
+
+// TestHeartbeatOnlyForIdleSymbols subscribes to a quiet and an active symbol
+// and checks that only the quiet one gets a heartbeat, with its BBO, once
+// the idle period has passed.
+func TestHeartbeatOnlyForIdleSymbols(t *testing.T) {
+	clock := engine.NewFakeClock(time.Date(2026, 1, 2, 14, 30, 0, 0, time.UTC))
+	m := NewManagerWithClock(symbol.AllSymbols(), 100, clock)
+	m.SetHeartbeat(5 * time.Second)
+
+	locs, _ := m.ResolveTickers([]string{"NEXO", "QBIT"})
+	nexo, qbit := locs[0], locs[1]
+	book := orderbook.NewBook(nexo, 0.01)
+	book.AddOrder(&orderbook.Order{ID: 1, Locate: nexo, Side: orderbook.SideBuy, Price: 10.00, Shares: 200})
+	book.AddOrder(&orderbook.Order{ID: 2, Locate: nexo, Side: orderbook.SideSell, Price: 10.01, Shares: 300})
+	m.SetBooks(map[uint16]*orderbook.Book{nexo: book})
+
+	c := newTestClient(100)
+	c.Subscribe([]uint16{nexo, qbit})
+	m.mu.Lock()
+	m.clients[c.ID] = c
+	m.mu.Unlock()
+
+	beats := func() []bboReply {
+		var out []bboReply
+		for _, o := range drain(c) {
+			if !o.control {
+				continue
+			}
+			var r bboReply
+			if err := json.Unmarshal(o.data, &r); err != nil {
+				t.Fatalf("decode heartbeat: %v", err)
+			}
+			out = append(out, r)
+		}
+		return out
+	}
+
+	clock.Advance(4 * time.Second)
+	m.heartbeat()
+	if got := beats(); len(got) != 0 {
+		t.Fatalf("heartbeats before the idle period: %+v", got)
+	}
+
+	// QBIT stays active; NEXO goes quiet past the idle period.
+	for range 3 {
+		clock.Advance(time.Second)
+		m.Broadcast(qbit, "QBIT", []itch.Message{{Type: itch.MsgOrderDelete, OrderRef: 9}})
+		drain(c)
+	}
+	m.heartbeat()
+	got := beats()
+	want := bboReply{Type: "heartbeat", Symbol: "NEXO", BidPrice: 10.00, BidSize: 200, AskPrice: 10.01, AskSize: 300}
+	if len(got) != 1 || got[0] != want {
+		t.Fatalf("heartbeats = %+v, want only %+v", got, want)
+	}
+
+	// The next one waits another full idle period.
+	clock.Advance(4 * time.Second)
+	m.heartbeat()
+	if got := beats(); len(got) != 0 {
+		t.Errorf("heartbeat repeated within the idle period: %+v", got)
+	}
+}
@@ -0,0 +1,55 @@
+package session
+
+import (
+	mathrand "math/rand/v2"
+	"time"
+)
+
+// maxLatency caps a client's simulated mean latency plus jitter, so a write
+// pump held by it still keeps well inside the ping period.
+const maxLatency = 10 * time.Second
+
+// latency is a client's simulated network delay: each message is held until
+// mean, plus or minus up to jitter, has passed since it was queued.
+type latency struct {
+	mean, jitter time.Duration
+}
+
+// latencyReply acks a latency action with the delay applied after clamping.
+// Both zero means the latency was cleared.
+type latencyReply struct {
+	Type     string  `json:"type"`
+	MeanMs   float64 `json:"meanMs"`
+	JitterMs float64 `json:"jitterMs"`
+}
+
+// SetLatency delays the client's messages by mean, varied uniformly by up to
+// jitter either way, before they are written. Jitter is capped at mean, so no
+// message goes early, and mean plus jitter at maxLatency. Zero mean and
+// jitter clear it. It returns the values applied.
+func (c *Client) SetLatency(mean, jitter time.Duration) (time.Duration, time.Duration) {
+	mean = min(max(mean, 0), maxLatency)
+	jitter = min(max(jitter, 0), mean, maxLatency-mean)
+	if mean == 0 {
+		c.latency.Store(nil)
+		return 0, 0
+	}
+	c.latency.Store(&latency{mean: mean, jitter: jitter})
+	return mean, jitter
+}
+
+// awaitLatency holds the write pump until out's simulated latency has passed
+// since it was queued. Messages queued with no latency set go at once.
+func (c *Client) awaitLatency(out outbound) {
+	l := c.latency.Load()
+	if l == nil || out.queued.IsZero() {
+		return
+	}
+	d := l.mean
+	if l.jitter > 0 {
+		d += time.Duration(mathrand.Int64N(int64(2*l.jitter)+1)) - l.jitter
+	}
+	if wait := out.queued.Add(d).Sub(c.clock.Now()); wait > 0 {
+		c.clock.Sleep(wait)
+	}
+}
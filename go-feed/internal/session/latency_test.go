@@ -0,0 +1,75 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+)
+
+// TestLatencyDelaysDelivery sets a 50ms ± 10ms latency on a fake clock and
+// checks each message is held for between 40ms and 60ms after it was queued.
+func TestLatencyDelaysDelivery(t *testing.T) {
+	clock := engine.NewFakeClock(time.Date(2026, 1, 2, 14, 30, 0, 0, time.UTC))
+	c := newTestClient(100)
+	c.clock = clock
+	m := newTestManager()
+
+	handleControl(c, m, &controlMessage{Action: "latency", MeanMs: 50, JitterMs: 10})
+	var ack latencyReply
+	if out := drain(c); len(out) != 1 || json.Unmarshal(out[0].data, &ack) != nil || ack.MeanMs != 50 || ack.JitterMs != 10 {
+		t.Fatalf("latency ack = %+v, want 50ms ± 10ms", ack)
+	}
+
+	var total time.Duration
+	for i := range 20 {
+		c.enqueue(outbound{data: []byte("x")})
+		out := <-c.sendCh
+		start := clock.Now()
+		c.awaitLatency(out)
+		d := clock.Now().Sub(start)
+		if d < 40*time.Millisecond || d > 60*time.Millisecond {
+			t.Errorf("message %d held %v, want 50ms ± 10ms", i, d)
+		}
+		total += d
+	}
+	if avg := total / 20; avg < 45*time.Millisecond || avg > 55*time.Millisecond {
+		t.Errorf("average delay %v, want about 50ms", avg)
+	}
+
+	// Time already spent queued counts toward the delay.
+	c.enqueue(outbound{data: []byte("x")})
+	clock.Advance(time.Second)
+	start := clock.Now()
+	c.awaitLatency(<-c.sendCh)
+	if d := clock.Now().Sub(start); d != 0 {
+		t.Errorf("message queued for 1s held another %v", d)
+	}
+}
+
+func TestSetLatencyClamps(t *testing.T) {
+	c := newTestClient(10)
+	tests := []struct {
+		mean, jitter         time.Duration
+		wantMean, wantJitter time.Duration
+	}{
+		{20 * time.Millisecond, 50 * time.Millisecond, 20 * time.Millisecond, 20 * time.Millisecond},
+		{-time.Second, time.Second, 0, 0},
+		{time.Minute, time.Second, maxLatency, 0},
+		{9 * time.Second, 5 * time.Second, 9 * time.Second, time.Second},
+	}
+	for _, tt := range tests {
+		mean, jitter := c.SetLatency(tt.mean, tt.jitter)
+		if mean != tt.wantMean || jitter != tt.wantJitter {
+			t.Errorf("SetLatency(%v, %v) = %v, %v; want %v, %v", tt.mean, tt.jitter, mean, jitter, tt.wantMean, tt.wantJitter)
+		}
+	}
+
+	// Cleared: messages go at once.
+	c.SetLatency(0, 0)
+	c.enqueue(outbound{data: []byte("x")})
+	if out := <-c.sendCh; !out.queued.IsZero() {
+		t.Errorf("message stamped %v with no latency set", out.queued)
+	}
+}
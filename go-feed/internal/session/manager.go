@@ -1,12 +1,21 @@
 package session
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/logging"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/tape"
 )
 
 // Manager handles client registration, subscriptions, and message fan-out.
@@ -16,37 +25,257 @@ type Manager struct {
 	symbols    []symbol.Symbol
 	byTicker   map[string]uint16 // ticker -> locate code
 	bufferSize int
+	clock      engine.Clock               // stamps outgoing message timestamps
+	maxSubs    int                        // per-client subscription limit (0 = unlimited)
+	maxClients int                        // concurrent client limit (0 = unlimited)
+	maxFrame   int                        // per-client frame size cap (0 = unlimited)
+	compress   bool                       // negotiate per-message compression
+	minDeflate int                        // smallest payload sent compressed
+	sendWait   time.Duration              // per-client wait on a full send buffer (0 = drop at once)
+	dropOldest bool                       // full client buffers evict their oldest message
+	validate   bool                       // drop messages failing itch.Validate before encoding
+	concat     bool                       // send a batch's binary messages as one frame
+	timestamps itch.TimestampFormat       // JSON "time" format for new clients
+	tape       *tape.Tape                 // records broadcast trades (nil = disabled)
+	books      map[uint16]*orderbook.Book // served by bookSnapshot and subscribe BBOs
+	lastSent   map[uint16]*atomic.Int64   // locate -> unix nanos of the last broadcast
+	rates      map[uint16]*msgRate        // locate -> recent broadcast messages a second
+	lastPrice  map[uint16]*atomic.Uint64  // locate -> float64 bits of the last tick price (nil = no price quotes)
+	chaos      *chaos                     // broadcast reordering fault injection (nil = off)
+	ring       *replayRing                // recent messages for resume (nil = disabled)
+	seqMu      sync.Mutex                 // held from seq stamping through fan-out, so clients see seqs in order
+	parked     map[string]parkedSession   // resume token -> disconnected subscriptions
+	idle       time.Duration              // quiet period before a heartbeat (0 = off)
+	lastBeat   map[uint16]*atomic.Int64   // locate -> unix nanos of the last heartbeat
+	readBuf    int                        // WebSocket read buffer, in bytes
+	writeBufs  map[Format]int             // WebSocket write buffer by the format requested at connect
 }
 
-// NewManager creates a session manager.
+// NewManager creates a session manager on the real clock.
 func NewManager(syms []symbol.Symbol, bufferSize int) *Manager {
+	return NewManagerWithClock(syms, bufferSize, engine.RealClock{})
+}
+
+// NewManagerWithClock creates a session manager that stamps message
+// timestamps from clock.
+func NewManagerWithClock(syms []symbol.Symbol, bufferSize int, clock engine.Clock) *Manager {
 	byTicker := make(map[string]uint16, len(syms))
+	lastSent := make(map[uint16]*atomic.Int64, len(syms))
+	rates := make(map[uint16]*msgRate, len(syms))
 	for _, s := range syms {
 		byTicker[s.Ticker] = s.LocateCode
+		lastSent[s.LocateCode] = new(atomic.Int64)
+		rates[s.LocateCode] = new(msgRate)
 	}
 	return &Manager{
 		clients:    make(map[uint64]*Client),
 		symbols:    syms,
 		byTicker:   byTicker,
 		bufferSize: bufferSize,
+		clock:      clock,
+		lastSent:   lastSent,
+		rates:      rates,
+		parked:     make(map[string]parkedSession),
+		readBuf:    defaultReadBuffer,
+		writeBufs:  map[Format]int{FormatJSON: defaultWriteBuffer, FormatBinary: defaultWriteBuffer},
+	}
+}
+
+// SetMaxSubscriptions sets the per-client limit on distinct symbol
+// subscriptions applied to clients registered afterwards. n <= 0 means
+// unlimited.
+func (m *Manager) SetMaxSubscriptions(n int) {
+	m.maxSubs = n
+}
+
+// ErrTooManyClients is returned by Register when the manager already has its
+// maximum number of clients.
+var ErrTooManyClients = errors.New("too many clients")
+
+// SetMaxClients caps how many clients may be connected at once; Register
+// refuses more with ErrTooManyClients. n <= 0 means unlimited.
+func (m *Manager) SetMaxClients(n int) {
+	m.maxClients = n
+}
+
+// Full reports whether the manager is at its client limit.
+func (m *Manager) Full() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.maxClients > 0 && len(m.clients) >= m.maxClients
+}
+
+// SetSendWait sets how long a send to a client registered afterwards may
+// wait for room in its full buffer before dropping (see Client.SetSendWait).
+// d <= 0 drops at once.
+func (m *Manager) SetSendWait(d time.Duration) {
+	m.sendWait = d
+}
+
+// SetDropOldest makes the buffers of clients registered afterwards evict
+// their oldest message when full, rather than drop the new one (see
+// Client.SetDropOldest).
+func (m *Manager) SetDropOldest(on bool) {
+	m.dropOldest = on
+}
+
+// SetTimestampFormat sets the readable JSON timestamp of clients registered
+// afterwards (see Client.SetTimestampFormat); a hello can change it.
+func (m *Manager) SetTimestampFormat(f itch.TimestampFormat) {
+	m.timestamps = f
+}
+
+// SetConcatBinary makes Broadcast send each batch's binary messages to a
+// client as one frame, concatenated in order, instead of one frame per
+// message. Each message keeps its length prefix or framed header, so
+// clients split the frame by reading lengths. JSON is unaffected.
+func (m *Manager) SetConcatBinary(on bool) {
+	m.concat = on
+}
+
+// SetMaxFrameSize caps the WebSocket frame size written to clients connected
+// afterwards; larger messages are fragmented (see Client.SetMaxFrameSize).
+// It also caps their write buffers. n <= 0 means unlimited.
+func (m *Manager) SetMaxFrameSize(n int) {
+	m.maxFrame = n
+}
+
+// SetCompression offers per-message (permessage-deflate) compression to
+// clients connecting afterwards. Payloads under minBytes are still sent
+// uncompressed (see Client.SetCompressionThreshold).
+func (m *Manager) SetCompression(on bool, minBytes int) {
+	m.compress = on
+	m.minDeflate = minBytes
+}
+
+// SetBufferSizes sets the WebSocket read buffer and the per-format write
+// buffers, in bytes, for connections upgraded afterwards. A connection's
+// write buffer follows the format it asks for at connect (see Handler), so
+// high-rate binary clients can get larger writes than JSON ones. A size <= 0
+// keeps that buffer's default.
+func (m *Manager) SetBufferSizes(read, writeJSON, writeBinary int) {
+	if read <= 0 {
+		read = defaultReadBuffer
+	}
+	if writeJSON <= 0 {
+		writeJSON = defaultWriteBuffer
 	}
+	if writeBinary <= 0 {
+		writeBinary = defaultWriteBuffer
+	}
+	m.readBuf = read
+	m.writeBufs = map[Format]int{FormatJSON: writeJSON, FormatBinary: writeBinary}
 }
 
-// Register adds a new client. Returns the client for further use.
-func (m *Manager) Register(conn *websocket.Conn) *Client {
+// upgrader returns an upgrader sized for connections asking for format f.
+// With a frame size cap, the write buffer is at most the cap: the connection
+// flushes a fragment whenever its buffer fills, which is what keeps frames
+// within it.
+func (m *Manager) upgrader(f Format) *websocket.Upgrader {
+	write := m.writeBufs[f]
+	if m.maxFrame > 0 {
+		write = min(write, m.maxFrame)
+	}
+	u := newUpgrader(m.readBuf, write)
+	u.EnableCompression = m.compress
+	return u
+}
+
+// SetValidate enables itch.Validate on every outgoing message; invalid
+// messages are logged and dropped instead of being encoded. Off by default.
+func (m *Manager) SetValidate(on bool) {
+	m.validate = on
+}
+
+// SetTape records every broadcast trade on t. A nil tape disables recording.
+func (m *Manager) SetTape(t *tape.Tape) {
+	m.tape = t
+}
+
+// SetBooks gives the manager read access to the live order books, keyed by
+// locate code, so clients can request one-shot book snapshots.
+func (m *Manager) SetBooks(books map[uint16]*orderbook.Book) {
+	m.books = books
+}
+
+// BookMessages reconstructs the resting orders of locate's book as Add Order
+// messages in price-time priority. ok is false when no book is attached for
+// locate.
+func (m *Manager) BookMessages(locate uint16) (msgs []itch.Message, ok bool) {
+	book, ok := m.books[locate]
+	if !ok {
+		return nil, false
+	}
+	orders := book.RestingOrders()
+	msgs = make([]itch.Message, 0, len(orders))
+	for _, o := range orders {
+		msgType := itch.MsgAddOrder
+		if o.MPID != "" {
+			msgType = itch.MsgAddOrderMPID
+		}
+		msgs = append(msgs, itch.Message{
+			Type:        msgType,
+			StockLocate: locate,
+			OrderRef:    o.ID,
+			Side:        byte(o.Side),
+			Shares:      o.Shares,
+			Price:       o.Price,
+			MPID:        o.MPID,
+		})
+	}
+	return msgs, true
+}
+
+// quote returns locate's top of book as a bboReply of type typ for ticker:
+// the best price and the total shares resting there on each side, an empty
+// side coming back as zeros. ok is false, with both sides zero, when no book
+// is attached for locate.
+func (m *Manager) quote(typ string, locate uint16, ticker string) (r bboReply, ok bool) {
+	r = bboReply{Type: typ, Symbol: ticker}
+	book, ok := m.books[locate]
+	if !ok {
+		return r, false
+	}
+	r.BidPrice, r.BidSize = book.BestBid(), book.BestBidSize()
+	r.AskPrice, r.AskSize = book.BestAsk(), book.BestAskSize()
+	return r, true
+}
+
+// Register adds a new client. Returns the client for further use, or
+// ErrTooManyClients when the client limit is reached.
+func (m *Manager) Register(conn *websocket.Conn) (*Client, error) {
+	return m.register(conn, FormatJSON)
+}
+
+// register adds a new client starting in format f.
+func (m *Manager) register(conn *websocket.Conn, f Format) (*Client, error) {
 	c := NewClient(conn, m.bufferSize)
+	c.clock = m.clock
+	c.SetFormat(f)
+	c.SetMaxSubscriptions(m.maxSubs)
+	c.SetMaxFrameSize(m.maxFrame)
+	c.SetCompressionThreshold(m.minDeflate)
+	c.SetSendWait(m.sendWait)
+	c.SetDropOldest(m.dropOldest)
+	c.SetTimestampFormat(m.timestamps)
 
 	m.mu.Lock()
+	if m.maxClients > 0 && len(m.clients) >= m.maxClients {
+		m.mu.Unlock()
+		return nil, ErrTooManyClients
+	}
 	m.clients[c.ID] = c
 	m.mu.Unlock()
+	m.issueToken(c)
 
 	log.Printf("client %d connected (%s)", c.ID, conn.RemoteAddr())
-	return c
+	return c, nil
 }
 
 // Unregister removes a client.
 func (m *Manager) Unregister(c *Client) {
 	m.mu.Lock()
+	m.park(c)
 	delete(m.clients, c.ID)
 	m.mu.Unlock()
 
@@ -70,74 +299,154 @@ func (m *Manager) ResolveTickers(tickers []string) (locates []uint16, all bool)
 
 // Broadcast sends a batch of ITCH messages to all subscribed clients.
 // Messages are encoded once per format and fanned out.
+//
+// Ordering: every client receives the batch in slice order, whatever its
+// format, so an Order Executed the simulator emitted before its Trade arrives
+// before it. Clients are visited in no particular order, and a message that
+// finds a client's buffer full is dropped without reordering the rest.
+// SetChaos deliberately breaks this, for testing consumers.
 func (m *Manager) Broadcast(locate uint16, stock string, msgs []itch.Message) {
 	if len(msgs) == 0 {
 		return
 	}
 
 	// Stamp all messages with timestamp and stock
-	ts := itch.NanosFromMidnight()
+	now := m.clock.Now()
+	ts := itch.NanosFromMidnightAt(now)
 	for i := range msgs {
 		msgs[i].Timestamp = ts
 		if msgs[i].Stock == "" {
 			msgs[i].Stock = stock
 		}
 	}
+	if m.validate {
+		if msgs = validMessages(msgs); len(msgs) == 0 {
+			return
+		}
+	}
+	if m.chaos != nil {
+		if msgs = m.chaos.disorder(locate, msgs); len(msgs) == 0 {
+			return
+		}
+	}
+	if m.ring != nil {
+		// Stamp and fan out as one step: were concurrent runners to
+		// interleave, a client could get seq 10 before seq 9, and a resume
+		// after seq 10 would skip the batch still in flight.
+		m.seqMu.Lock()
+		defer m.seqMu.Unlock()
+		m.ring.append(locate, msgs)
+	}
+	if m.tape != nil {
+		m.tape.Record(locate, now, msgs)
+	}
+	if last, ok := m.lastSent[locate]; ok {
+		last.Store(now.UnixNano())
+	}
+	if r, ok := m.rates[locate]; ok {
+		r.add(now.Unix(), len(msgs))
+	}
 
-	// Pre-encode for each format (lazy, only if needed)
-	var jsonEncoded [][]byte
-	var binaryEncoded [][]byte
-	var jsonOnce, binaryOnce sync.Once
-
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	// Pre-encode for each format and JSON option set (lazy, only if needed)
+	jsonEncoded := make(map[itch.JSONOptions][][]byte)
+	binaryEncoded := make(map[byte][][]byte) // keyed by framing
+	var depth []byte                         // spotlight snapshot, built once
 
-	for _, c := range m.clients {
+	for _, c := range m.clientList() {
 		if !c.IsSubscribed(locate) {
 			continue
 		}
+		if throttled, due := c.throttled(locate, now); throttled {
+			if due {
+				m.sendThrottled(c, locate, stock)
+			}
+			continue
+		}
 
-		switch c.Format() {
+		switch f := c.FormatFor(locate); f {
 		case FormatJSON:
-			jsonOnce.Do(func() {
-				jsonEncoded = encodeAllJSON(msgs)
-			})
-			for _, data := range jsonEncoded {
-				if !c.Send(data) {
-					// buffer full, message dropped
+			opts := c.jsonOptions()
+			encoded, ok := jsonEncoded[opts]
+			if !ok {
+				encoded = encodeAllJSON(msgs, opts)
+				jsonEncoded[opts] = encoded
+			}
+			for _, data := range encoded {
+				if !c.SendFormat(data, f) {
+					logBufferFull(c)
 				}
 			}
 
 		case FormatBinary:
-			binaryOnce.Do(func() {
-				binaryEncoded = encodeAllBinary(msgs)
-			})
-			for _, data := range binaryEncoded {
-				if !c.Send(data) {
-					// buffer full, message dropped
+			framing := c.Framing()
+			encoded, ok := binaryEncoded[framing]
+			if !ok {
+				encoded = encodeAllBinary(msgs, framing)
+				if m.concat && len(encoded) > 1 {
+					encoded = [][]byte{bytes.Join(encoded, nil)}
+				}
+				binaryEncoded[framing] = encoded
+			}
+			for _, data := range encoded {
+				if !c.SendFormat(data, f) {
+					logBufferFull(c)
 				}
 			}
 		}
+
+		if c.spotlighted(locate) {
+			if depth == nil {
+				depth = m.encodeDepth(locate, stock)
+			}
+			if depth != nil && !c.SendControl(depth) {
+				logBufferFull(c)
+			}
+		}
 	}
 }
 
 // SendToClient sends messages directly to a specific client (e.g., stock directory on connect).
 func (m *Manager) SendToClient(c *Client, msgs []itch.Message) {
-	ts := itch.NanosFromMidnight()
+	ts := itch.NanosFromMidnightAt(m.clock.Now())
 	for i := range msgs {
 		msgs[i].Timestamp = ts
 	}
+	if m.validate {
+		msgs = validMessages(msgs)
+	}
+	m.sendStamped(c, msgs)
+}
 
-	switch c.Format() {
-	case FormatJSON:
-		for _, data := range encodeAllJSON(msgs) {
-			c.Send(data)
+// sendStamped sends already-timestamped messages to c, e.g. a resume replay
+// that must keep its original timestamps and sequence numbers, and returns
+// how many were queued before the send buffer filled.
+func (m *Manager) sendStamped(c *Client, msgs []itch.Message) int {
+	// Encode per message: the batch may span symbols subscribed in different
+	// formats (e.g. a stock directory).
+	for i := range msgs {
+		f := c.FormatFor(msgs[i].StockLocate)
+		var data []byte
+		switch f {
+		case FormatJSON:
+			data, _ = itch.EncodeJSONWith(&msgs[i], c.jsonOptions())
+		case FormatBinary:
+			data = encodeBinary(&msgs[i], c.Framing())
 		}
-	case FormatBinary:
-		for _, data := range encodeAllBinary(msgs) {
-			c.Send(data)
+		if data != nil && !c.SendFormat(data, f) {
+			return i
 		}
 	}
+	return len(msgs)
+}
+
+// LastBroadcast returns when locate last had messages broadcast, or the zero
+// time if it never has (or is unknown).
+func (m *Manager) LastBroadcast(locate uint16) time.Time {
+	last, ok := m.lastSent[locate]
+	if !ok || last.Load() == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last.Load()).UTC()
 }
 
 // ClientCount returns the number of connected clients.
@@ -147,15 +456,59 @@ func (m *Manager) ClientCount() int {
 	return len(m.clients)
 }
 
+// clientList returns the registered clients. Callers send to them after
+// m.mu is released: a send can wait up to sendWait on a full buffer, and
+// holding the lock meanwhile would stall Register and Unregister, and with
+// them (a waiting writer blocks new readers) every other broadcast.
+func (m *Manager) clientList() []*Client {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	clients := make([]*Client, 0, len(m.clients))
+	for _, c := range m.clients {
+		clients = append(clients, c)
+	}
+	return clients
+}
+
 // Symbols returns the symbol list.
 func (m *Manager) Symbols() []symbol.Symbol {
 	return m.symbols
 }
 
-func encodeAllJSON(msgs []itch.Message) [][]byte {
+// logBufferFull reports a message dropped on c's full send buffer, sampled
+// since a slow client drops on every broadcast.
+func logBufferFull(c *Client) {
+	logging.SampledLog("send-buffer-full", 0, fmt.Sprintf("client %d send buffer full, dropping messages", c.ID))
+}
+
+// validMessages returns msgs without the entries that fail itch.Validate,
+// logging (sampled) the drops. The input is returned as-is when all are valid.
+func validMessages(msgs []itch.Message) []itch.Message {
+	var out []itch.Message
+	for i := range msgs {
+		if err := itch.Validate(&msgs[i]); err != nil {
+			logging.SampledLog("validate-drop", 0, fmt.Sprintf("dropping message for locate %d: %v", msgs[i].StockLocate, err))
+			if out == nil {
+				out = append(make([]itch.Message, 0, len(msgs)), msgs[:i]...)
+			}
+			continue
+		}
+		if out != nil {
+			out = append(out, msgs[i])
+		}
+	}
+	if out == nil {
+		return msgs
+	}
+	return out
+}
+
+// encodeAllJSON and encodeAllBinary return one encoding per message in msgs
+// order (skipping any that fail to encode); Broadcast's ordering relies on it.
+func encodeAllJSON(msgs []itch.Message, opts itch.JSONOptions) [][]byte {
 	out := make([][]byte, 0, len(msgs))
 	for i := range msgs {
-		data, err := itch.EncodeJSON(&msgs[i])
+		data, err := itch.EncodeJSONWith(&msgs[i], opts)
 		if err != nil {
 			continue
 		}
@@ -164,13 +517,22 @@ func encodeAllJSON(msgs []itch.Message) [][]byte {
 	return out
 }
 
-func encodeAllBinary(msgs []itch.Message) [][]byte {
+func encodeAllBinary(msgs []itch.Message, framing byte) [][]byte {
 	out := make([][]byte, 0, len(msgs))
 	for i := range msgs {
-		data := itch.EncodeBinary(&msgs[i])
+		data := encodeBinary(&msgs[i], framing)
 		if data != nil {
 			out = append(out, data)
 		}
 	}
 	return out
 }
+
+// encodeBinary encodes m with the framing's header, or the bare ITCH length
+// prefix for framing 0.
+func encodeBinary(m *itch.Message, framing byte) []byte {
+	if framing != 0 {
+		return itch.EncodeFramedVersion(m, framing)
+	}
+	return itch.EncodeBinary(m)
+}
@@ -1,9 +1,16 @@
 package session
 
 import (
+	"encoding/binary"
+	"encoding/json"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
 	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/tape"
 )
 
 func newTestManager() *Manager {
@@ -72,3 +79,216 @@ func TestResolveTickersWildcardShortCircuits(t *testing.T) {
 		t.Fatalf("wildcard should return nil locates, got %v", locs)
 	}
 }
+
+func TestSendToClientStampsFromClock(t *testing.T) {
+	at := time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC)
+	m := NewManagerWithClock(symbol.AllSymbols(), 100, engine.NewFakeClock(at))
+	c := newTestClient(10)
+
+	msgs := []itch.Message{{Type: itch.MsgSystemEvent, EventCode: itch.EventStartOfMarket}}
+	m.SendToClient(c, msgs)
+
+	want := int64(9*time.Hour + 30*time.Minute)
+	if msgs[0].Timestamp != want {
+		t.Fatalf("Timestamp = %d, want %d (from fake clock)", msgs[0].Timestamp, want)
+	}
+}
+
+func TestSetValidateDropsMalformed(t *testing.T) {
+	m := newTestManager()
+	c := newTestClient(10)
+	c.Subscribe([]uint16{1})
+	m.mu.Lock()
+	m.clients[c.ID] = c
+	m.mu.Unlock()
+
+	msgs := []itch.Message{
+		{Type: itch.MsgAddOrder, OrderRef: 1, Side: 'X', Shares: 100, Price: 10},
+		{Type: itch.MsgAddOrder, OrderRef: 2, Side: 'B', Shares: 100, Price: 10},
+	}
+
+	m.Broadcast(1, "NEXO", append([]itch.Message(nil), msgs...))
+	if got := len(c.SendCh()); got != 2 {
+		t.Fatalf("without validation: %d frames queued, want 2", got)
+	}
+	for len(c.SendCh()) > 0 {
+		<-c.SendCh()
+	}
+
+	m.SetValidate(true)
+	m.Broadcast(1, "NEXO", append([]itch.Message(nil), msgs...))
+	if got := len(c.SendCh()); got != 1 {
+		t.Fatalf("with validation: %d frames queued, want 1", got)
+	}
+}
+
+func TestBroadcastRecordsTape(t *testing.T) {
+	m := newTestManager()
+	tp := tape.New(10)
+	m.SetTape(tp)
+
+	for match := uint64(1); match <= 3; match++ {
+		m.Broadcast(1, "NEXO", []itch.Message{
+			{Type: itch.MsgOrderExecuted, OrderRef: 9, Shares: 100, MatchNumber: match},
+			{Type: itch.MsgTrade, OrderRef: 9, Side: 'S', Shares: 100, Price: 10, MatchNumber: match},
+		})
+	}
+
+	got := tp.Recent(1, 0)
+	if len(got) != 3 {
+		t.Fatalf("tape holds %d trades, want 3", len(got))
+	}
+	for i, want := range []int64{3, 2, 1} {
+		if got[i].MatchNumber != want || got[i].Ticker != "NEXO" {
+			t.Errorf("trade %d = %+v, want NEXO match %d", i, got[i], want)
+		}
+	}
+}
+
+// TestBroadcastPreservesOrderPerFormat guards Broadcast's ordering contract:
+// JSON, binary and framed binary clients all receive a batch in the order
+// the simulator produced it.
+func TestBroadcastPreservesOrderPerFormat(t *testing.T) {
+	m := newTestManager()
+	jsonClient := newTestClient(100)
+	binClient := newTestClient(100)
+	binClient.SetFormat(FormatBinary)
+	framedClient := newTestClient(100)
+	framedClient.SetFormat(FormatBinary)
+	framedClient.SetFraming(itch.FramedVersion)
+	for _, c := range []*Client{jsonClient, binClient, framedClient} {
+		c.Subscribe([]uint16{1})
+		m.mu.Lock()
+		m.clients[c.ID] = c
+		m.mu.Unlock()
+	}
+
+	m.Broadcast(1, "NEXO", []itch.Message{
+		{Type: itch.MsgAddOrder, OrderRef: 5, Side: 'S', Shares: 100, Price: 10},
+		{Type: itch.MsgOrderExecuted, OrderRef: 4, Shares: 100, MatchNumber: 1},
+		{Type: itch.MsgTrade, OrderRef: 3, Side: 'B', Shares: 100, Price: 10, MatchNumber: 1},
+		{Type: itch.MsgOrderCancel, OrderRef: 2, Shares: 50},
+		{Type: itch.MsgOrderDelete, OrderRef: 1},
+	})
+	want := []uint64{5, 4, 3, 2, 1}
+
+	decoders := map[string]struct {
+		c      *Client
+		decode func([]byte) (uint64, error)
+	}{
+		"json": {jsonClient, func(b []byte) (uint64, error) {
+			var v struct {
+				OrderRef uint64 `json:"orderRef"`
+			}
+			err := json.Unmarshal(b, &v)
+			return v.OrderRef, err
+		}},
+		"binary": {binClient, func(b []byte) (uint64, error) {
+			msg, err := itch.DecodeBinary(b)
+			return msg.OrderRef, err
+		}},
+		"framed": {framedClient, func(b []byte) (uint64, error) {
+			msg, err := itch.DecodeFramed(b)
+			return msg.OrderRef, err
+		}},
+	}
+	for name, d := range decoders {
+		var got []uint64
+		for _, o := range drain(d.c) {
+			ref, err := d.decode(o.data)
+			if err != nil {
+				t.Fatalf("%s: decode: %v", name, err)
+			}
+			got = append(got, ref)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s client received order refs %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestBroadcastConcatBinary(t *testing.T) {
+	m := newTestManager()
+	m.SetConcatBinary(true)
+	bin := newTestClient(100)
+	bin.SetFormat(FormatBinary)
+	jsonClient := newTestClient(100)
+	for _, c := range []*Client{bin, jsonClient} {
+		c.Subscribe([]uint16{1})
+		m.mu.Lock()
+		m.clients[c.ID] = c
+		m.mu.Unlock()
+	}
+
+	m.Broadcast(1, "NEXO", []itch.Message{
+		{Type: itch.MsgAddOrder, OrderRef: 3, Side: 'B', Shares: 100, Price: 10},
+		{Type: itch.MsgOrderCancel, OrderRef: 2, Shares: 50},
+		{Type: itch.MsgOrderDelete, OrderRef: 1},
+	})
+
+	frames := drain(bin)
+	if len(frames) != 1 {
+		t.Fatalf("binary client got %d frames, want 1", len(frames))
+	}
+	var got []uint64
+	for data := frames[0].data; len(data) > 0; {
+		n := 2 + int(binary.BigEndian.Uint16(data))
+		if n > len(data) {
+			t.Fatalf("length prefix %d overruns the %d bytes left", n-2, len(data))
+		}
+		msg, err := itch.DecodeBinary(data[:n])
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		got = append(got, msg.OrderRef)
+		data = data[n:]
+	}
+	if want := []uint64{3, 2, 1}; !slices.Equal(got, want) {
+		t.Errorf("frame decodes to order refs %v, want %v", got, want)
+	}
+
+	if n := len(drain(jsonClient)); n != 3 {
+		t.Errorf("JSON client got %d frames, want one per message", n)
+	}
+}
+
+// TestBroadcastWaitingOnSlowClientDoesNotHoldLock checks that a broadcast
+// waiting out a full client's send wait leaves the client map unlocked, so
+// registration and other broadcasts carry on.
+func TestBroadcastWaitingOnSlowClientDoesNotHoldLock(t *testing.T) {
+	m := newTestManager()
+	slow := newTestClient(1)
+	slow.SetSendWait(time.Minute)
+	slow.Subscribe([]uint16{1})
+	slow.Send([]byte("fill"))
+	m.mu.Lock()
+	m.clients[slow.ID] = slow
+	m.mu.Unlock()
+
+	msg := []itch.Message{{Type: itch.MsgOrderDelete, StockLocate: 1, OrderRef: 9}}
+	broadcast := make(chan struct{})
+	go func() {
+		m.Broadcast(1, "NEXO", msg)
+		close(broadcast)
+	}()
+	time.Sleep(10 * time.Millisecond) // let the broadcast reach the full buffer
+
+	locked := make(chan struct{})
+	go func() {
+		m.mu.Lock()
+		m.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client map still locked while a broadcast waits on a slow client")
+	}
+
+	close(slow.done) // as Close does, without a connection to close
+	select {
+	case <-broadcast:
+	case <-time.After(5 * time.Second):
+		t.Fatal("broadcast still waiting after the slow client closed")
+	}
+}
@@ -0,0 +1,112 @@
+package session
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+)
+
+// runPipeline drives engine -> simulator -> Broadcast -> WebSocket -> decode
+// for one symbol and returns what the simulator produced (as stamped by
+// Broadcast) alongside what a binary subscriber decoded off the wire.
+func runPipeline(t *testing.T, seed int64, steps int) (sent, received []itch.Message) {
+	t.Helper()
+	orderbook.SetOrderIDCounter(0)
+	orderbook.SetMatchCounter(0)
+
+	syms := symbol.AllSymbols()
+	sym := syms[0]
+	rng := engine.NewRNG(seed)
+	market := engine.NewMarketEngine(rng, syms)
+	sim := orderbook.NewSimulator(rng, orderbook.NewBook(sym.LocateCode, sym.TickSize), sym.LocateCode, sym.TickSize)
+	sim.Initialize(sym.BasePrice)
+
+	clock := engine.NewFakeClock(time.Date(2026, 6, 18, 14, 30, 0, 0, time.UTC))
+	mgr := NewManagerWithClock(syms, 1<<14, clock)
+
+	srv := httptest.NewServer(Handler(mgr))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+
+	read := func() itch.Message {
+		t.Helper()
+		kind, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read after %d messages: %v", len(received), err)
+		}
+		if kind != websocket.BinaryMessage {
+			t.Fatalf("got frame type %d, want binary: %s", kind, data)
+		}
+		m, err := itch.DecodeBinary(data)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return m
+	}
+
+	// The stock directory reply proves the subscription is in place before
+	// anything is broadcast.
+	if err := conn.WriteJSON(controlMessage{Action: "subscribe", Symbols: []string{sym.Ticker}, Format: "binary"}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if dir := read(); dir.Type != itch.MsgStockDirectory || dir.Stock != sym.Ticker {
+		t.Fatalf("first message = %+v, want stock directory for %s", dir, sym.Ticker)
+	}
+
+	for i := 0; i < steps; i++ {
+		clock.Advance(100 * time.Millisecond)
+		market.GenerateSectorShocks()
+		msgs := sim.Step(market.Tick(sym.LocateCode), 3)
+		mgr.Broadcast(sym.LocateCode, sym.Ticker, msgs)
+		sent = append(sent, msgs...)
+	}
+	for len(received) < len(sent) {
+		received = append(received, read())
+	}
+	return sent, received
+}
+
+// TestPipelineEndToEnd checks that a binary subscriber decodes exactly the
+// messages the simulator produced, in order, and that a fixed seed makes the
+// whole run reproducible.
+func TestPipelineEndToEnd(t *testing.T) {
+	const steps = 300
+	sent, received := runPipeline(t, 42, steps)
+	if len(sent) < steps {
+		t.Fatalf("simulator produced only %d messages in %d steps", len(sent), steps)
+	}
+
+	for i := range sent {
+		want, got := sent[i], received[i]
+		if got.Type != want.Type || got.OrderRef != want.OrderRef {
+			t.Fatalf("message %d: got %c ref %d, want %c ref %d", i, got.Type, got.OrderRef, want.Type, want.OrderRef)
+		}
+		// Compare every field the wire carries for this type.
+		if !bytes.Equal(itch.EncodeBinary(&got), itch.EncodeBinary(&want)) {
+			t.Fatalf("message %d differs on the wire:\n got  %+v\n want %+v", i, got, want)
+		}
+	}
+
+	_, again := runPipeline(t, 42, steps)
+	if len(again) != len(received) {
+		t.Fatalf("rerun with same seed produced %d messages, want %d", len(again), len(received))
+	}
+	for i := range again {
+		if again[i] != received[i] {
+			t.Fatalf("rerun diverged at message %d:\n got  %+v\n want %+v", i, again[i], received[i])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package session
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"sync/atomic"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// priceReply is the price-only quote sent for a tick that moved a symbol's
+// price without producing any book messages. Price is a 4-decimal string or
+// a number, as the client's JSON messages carry prices.
+type priceReply struct {
+	Type   string `json:"type"`
+	Symbol string `json:"symbol"`
+	Price  any    `json:"price"`
+}
+
+// SetPriceQuotes enables price quotes: a tick that moves a symbol's price
+// but produces no book messages sends its subscribers a "quote" with the new
+// price, so the move is not lost until the book next changes. Call it before
+// the runners start ticking.
+func (m *Manager) SetPriceQuotes(on bool) {
+	if !on {
+		m.lastPrice = nil
+		return
+	}
+	m.lastPrice = make(map[uint16]*atomic.Uint64, len(m.symbols))
+	for _, s := range m.symbols {
+		m.lastPrice[s.LocateCode] = new(atomic.Uint64)
+	}
+}
+
+// BroadcastTick broadcasts the messages one tick produced for locate at
+// price, as Broadcast does. A tick that produced none sends nothing, unless
+// price quotes are on and price differs from the previous tick's, in which
+// case subscribers get a price quote instead.
+func (m *Manager) BroadcastTick(locate uint16, stock string, price float64, msgs []itch.Message) {
+	var moved bool
+	if last, ok := m.lastPrice[locate]; ok {
+		moved = math.Float64frombits(last.Swap(math.Float64bits(price))) != price
+	}
+	if len(msgs) > 0 {
+		m.Broadcast(locate, stock, msgs)
+		return
+	}
+	if moved {
+		m.broadcastPrice(locate, stock, price)
+	}
+}
+
+// broadcastPrice sends locate's JSON subscribers a price quote, counting as
+// a broadcast for heartbeats. A throttled subscriber gets its conflated
+// top of book when due instead; binary subscribers, whose stream has no
+// quote message, get nothing.
+func (m *Manager) broadcastPrice(locate uint16, stock string, price float64) {
+	now := m.clock.Now()
+	if last, ok := m.lastSent[locate]; ok {
+		last.Store(now.UnixNano())
+	}
+
+	encoded := make(map[bool][]byte) // keyed by numeric prices, built lazily
+	for _, c := range m.clientList() {
+		if !c.IsSubscribed(locate) || c.FormatFor(locate) != FormatJSON {
+			continue
+		}
+		if throttled, due := c.throttled(locate, now); throttled {
+			if due {
+				m.sendThrottled(c, locate, stock)
+			}
+			continue
+		}
+
+		numeric := c.NumericPrices()
+		data, ok := encoded[numeric]
+		if !ok {
+			var err error
+			data, err = json.Marshal(priceReply{Type: "quote", Symbol: stock, Price: itch.PriceValue(price, numeric)})
+			if err != nil {
+				log.Printf("encode %s price quote: %v", stock, err)
+				return
+			}
+			encoded[numeric] = data
+		}
+		if !c.SendControl(data) {
+			logBufferFull(c)
+		}
+	}
+}
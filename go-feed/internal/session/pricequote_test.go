@@ -0,0 +1,108 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// TestBroadcastTickPriceQuotes checks that an empty tick sends nothing when
+// the price held and a price-only quote when it moved, and that a tick with
+// messages broadcasts them without a quote.
+func TestBroadcastTickPriceQuotes(t *testing.T) {
+	m := newTestManager()
+	m.SetPriceQuotes(true)
+	locs, _ := m.ResolveTickers([]string{"NEXO"})
+	nexo := locs[0]
+	c := newTestClient(100)
+	c.Subscribe([]uint16{nexo})
+	m.mu.Lock()
+	m.clients[c.ID] = c
+	m.mu.Unlock()
+
+	m.BroadcastTick(nexo, "NEXO", 10.00, nil)
+	drain(c)
+
+	m.BroadcastTick(nexo, "NEXO", 10.00, nil)
+	if out := drain(c); len(out) != 0 {
+		t.Fatalf("unchanged tick sent %d frames, want none", len(out))
+	}
+
+	m.BroadcastTick(nexo, "NEXO", 10.01, nil)
+	out := drain(c)
+	var q priceReply
+	if len(out) != 1 || !out[0].control || json.Unmarshal(out[0].data, &q) != nil {
+		t.Fatalf("price-change tick sent %d frames, want one quote", len(out))
+	}
+	if q != (priceReply{Type: "quote", Symbol: "NEXO", Price: "10.0100"}) {
+		t.Errorf("quote = %+v, want NEXO at \"10.0100\"", q)
+	}
+
+	del := []itch.Message{{Type: itch.MsgOrderDelete, StockLocate: nexo, OrderRef: 9}}
+	m.BroadcastTick(nexo, "NEXO", 10.02, del)
+	if out := drain(c); len(out) != 1 || out[0].control {
+		t.Errorf("tick with messages sent %d frames, want just the message", len(out))
+	}
+	m.BroadcastTick(nexo, "NEXO", 10.02, nil)
+	if out := drain(c); len(out) != 0 {
+		t.Errorf("tick unchanged since the last message sent %d frames, want none", len(out))
+	}
+
+	m.SetPriceQuotes(false)
+	m.BroadcastTick(nexo, "NEXO", 10.03, nil)
+	if out := drain(c); len(out) != 0 {
+		t.Errorf("price quotes off: empty tick sent %d frames, want none", len(out))
+	}
+}
+
+// TestPriceQuoteFollowsClientSettings checks that a quote carries each
+// client's price encoding, and that a throttled client gets its conflated
+// top of book and a binary client nothing.
+func TestPriceQuoteFollowsClientSettings(t *testing.T) {
+	m := newTestManager()
+	m.SetPriceQuotes(true)
+	locs, _ := m.ResolveTickers([]string{"NEXO"})
+	nexo := locs[0]
+	newClient := func() *Client {
+		c := newTestClient(100)
+		c.Subscribe([]uint16{nexo})
+		m.mu.Lock()
+		m.clients[c.ID] = c
+		m.mu.Unlock()
+		return c
+	}
+	str, num, bin, thr := newClient(), newClient(), newClient(), newClient()
+	handleControl(str, m, &controlMessage{Action: "hello", Version: itch.JSONVersion2, Prices: "string"})
+	handleControl(num, m, &controlMessage{Action: "hello", Version: itch.JSONVersion2, Prices: "number"})
+	bin.SetFormat(FormatBinary)
+	thr.SetThrottle(nexo, 1)
+	for _, c := range []*Client{str, num, bin, thr} {
+		drain(c)
+	}
+
+	m.BroadcastTick(nexo, "NEXO", 184.92, nil)
+	for _, tc := range []struct {
+		c    *Client
+		want string
+	}{
+		{str, `{"type":"quote","symbol":"NEXO","price":"184.9200"}`},
+		{num, `{"type":"quote","symbol":"NEXO","price":184.92}`},
+	} {
+		if out := drain(tc.c); len(out) != 1 || string(out[0].data) != tc.want {
+			t.Errorf("quote frames = %v, want %s", out, tc.want)
+		}
+	}
+	if out := drain(bin); len(out) != 0 {
+		t.Errorf("binary client got %d frames, want none", len(out))
+	}
+	var bbo bboReply
+	if out := drain(thr); len(out) != 1 || json.Unmarshal(out[0].data, &bbo) != nil || bbo.Type != "bbo" {
+		t.Fatalf("throttled client got %v, want one bbo", out)
+	}
+
+	m.BroadcastTick(nexo, "NEXO", 184.93, nil)
+	if out := drain(thr); len(out) != 0 {
+		t.Errorf("throttled client got %d frames within its interval, want none", len(out))
+	}
+}
@@ -0,0 +1,54 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// rateWindow is the rolling window MessageRate averages over, kept as one
+// bucket per second.
+const rateWindow = 10 * time.Second
+
+// msgRate counts one symbol's broadcast messages per second over the last
+// rateWindow. It is safe for concurrent use.
+type msgRate struct {
+	mu      sync.Mutex
+	buckets [rateWindow / time.Second]int64 // messages per second, by unix second mod len
+	seconds [rateWindow / time.Second]int64 // unix second each bucket counts
+}
+
+// add records n messages broadcast at now (unix seconds).
+func (r *msgRate) add(now int64, n int) {
+	i := now % int64(len(r.buckets))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seconds[i] != now {
+		r.seconds[i], r.buckets[i] = now, 0
+	}
+	r.buckets[i] += int64(n)
+}
+
+// perSecond returns the average messages a second over the window ending in
+// the second now, which counts whole.
+func (r *msgRate) perSecond(now int64) float64 {
+	oldest := now - int64(len(r.buckets)) + 1
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int64
+	for i, sec := range r.seconds {
+		if sec >= oldest && sec <= now {
+			n += r.buckets[i]
+		}
+	}
+	return float64(n) / rateWindow.Seconds()
+}
+
+// MessageRate returns locate's broadcast messages a second, averaged over the
+// last ten seconds, or 0 for an unknown symbol.
+func (m *Manager) MessageRate(locate uint16) float64 {
+	r, ok := m.rates[locate]
+	if !ok {
+		return 0
+	}
+	return r.perSecond(m.clock.Now().Unix())
+}
@@ -0,0 +1,44 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+)
+
+func TestMessageRateRollingWindow(t *testing.T) {
+	clock := engine.NewFakeClock(time.Date(2026, 1, 2, 14, 30, 0, 0, time.UTC))
+	m := NewManagerWithClock(symbol.AllSymbols(), 100, clock)
+	del := func() []itch.Message {
+		return []itch.Message{{Type: itch.MsgOrderDelete, StockLocate: 1, OrderRef: 9}}
+	}
+
+	// 5 messages a second for 10 seconds.
+	for i := range 10 {
+		if i > 0 {
+			clock.Advance(time.Second)
+		}
+		for range 5 {
+			m.Broadcast(1, "NEXO", del())
+		}
+	}
+	if got := m.MessageRate(1); got != 5 {
+		t.Errorf("rate = %v, want 5/sec", got)
+	}
+	if got := m.MessageRate(2); got != 0 {
+		t.Errorf("quiet symbol rate = %v, want 0", got)
+	}
+
+	// Quiet seconds roll the burst out of the window.
+	clock.Advance(5 * time.Second)
+	if got := m.MessageRate(1); got != 2.5 {
+		t.Errorf("rate 5s after the burst = %v, want 2.5/sec", got)
+	}
+	clock.Advance(10 * time.Second)
+	if got := m.MessageRate(1); got != 0 {
+		t.Errorf("rate once the burst has left the window = %v, want 0", got)
+	}
+}
@@ -0,0 +1,261 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+const (
+	// DefaultResumeBuffer is the number of recent broadcast messages kept
+	// for replay to resuming clients.
+	DefaultResumeBuffer = 8192
+
+	// maxHistory caps the messages one history action may replay.
+	maxHistory = 1000
+
+	// resumeTTL is how long a disconnected client's token stays resumable.
+	resumeTTL = 5 * time.Minute
+)
+
+// replayRing numbers every broadcast message with a feed-wide sequence and
+// keeps the most recent ones for replay. Sequence numbers start at 1.
+type replayRing struct {
+	mu   sync.Mutex
+	buf  []replayEntry // circular; buf[(seq-1)%len(buf)] holds seq
+	last uint64        // sequence of the newest message (0 = none yet)
+}
+
+// replayEntry is one buffered message and the symbol it was broadcast for.
+type replayEntry struct {
+	locate uint16
+	msg    itch.Message
+}
+
+func newReplayRing(size int) *replayRing {
+	return &replayRing{buf: make([]replayEntry, size)}
+}
+
+// append stamps msgs, broadcast for locate, with consecutive sequence
+// numbers, in place, and keeps a copy of each, overwriting the oldest once
+// the ring is full.
+func (r *replayRing) append(locate uint16, msgs []itch.Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := range msgs {
+		r.last++
+		msgs[i].Seq = r.last
+		r.buf[(r.last-1)%uint64(len(r.buf))] = replayEntry{locate: locate, msg: msgs[i]}
+	}
+}
+
+// lastSeq returns the sequence of the newest message.
+func (r *replayRing) lastSeq() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// since returns the buffered messages with sequence > seq for which keep
+// returns true, oldest first. complete is false when messages after seq have
+// already been overwritten, so the replay has a gap at its start.
+func (r *replayRing) since(seq uint64, keep func(locate uint16) bool) (msgs []itch.Message, complete bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	first := uint64(1)
+	if n := uint64(len(r.buf)); r.last > n {
+		first = r.last - n + 1
+	}
+	complete = seq+1 >= first
+	for s := max(seq+1, first); s <= r.last; s++ {
+		e := r.buf[(s-1)%uint64(len(r.buf))]
+		if keep(e.locate) {
+			msgs = append(msgs, e.msg)
+		}
+	}
+	return msgs, complete
+}
+
+// recent returns up to the n newest buffered messages broadcast for locate,
+// oldest first.
+func (r *replayRing) recent(locate uint16, n int) []itch.Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	first := uint64(1)
+	if size := uint64(len(r.buf)); r.last > size {
+		first = r.last - size + 1
+	}
+	var msgs []itch.Message
+	for s := r.last; s >= first && len(msgs) < n; s-- {
+		if e := r.buf[(s-1)%uint64(len(r.buf))]; e.locate == locate {
+			msgs = append(msgs, e.msg)
+		}
+	}
+	slices.Reverse(msgs)
+	return msgs
+}
+
+// parkedSession is a disconnected client's subscriptions, kept under its
+// resume token until resumeTTL passes.
+type parkedSession struct {
+	all     bool
+	locates []uint16
+	at      time.Time
+}
+
+// newResumeToken returns a random, unguessable resume token.
+func newResumeToken() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// sessionReply is sent on connect when resume is enabled: the token to quote
+// in a later resume, and the sequence of the newest message so far.
+type sessionReply struct {
+	Type  string `json:"type"`
+	Token string `json:"token"`
+	Seq   uint64 `json:"seq"`
+}
+
+// resumeReply follows the replayed messages of a resume. Complete is false
+// when the ring no longer held every message after sinceSeq or the replay
+// did not fit the send buffer.
+type resumeReply struct {
+	Type     string   `json:"type"`
+	OK       bool     `json:"ok"`
+	Reason   string   `json:"reason,omitempty"`
+	Symbols  []string `json:"symbols,omitempty"`
+	Replayed int      `json:"replayed"`
+	Complete bool     `json:"complete"`
+	Seq      uint64   `json:"seq"`
+}
+
+// SetResumeBuffer enables session resume with a ring of the n most recent
+// broadcast messages. Clients registered afterwards are given a resume token.
+// n <= 0 disables resume.
+func (m *Manager) SetResumeBuffer(n int) {
+	if n <= 0 {
+		m.ring = nil
+		return
+	}
+	m.ring = newReplayRing(n)
+}
+
+// issueToken gives c a resume token and tells it the token, when resume is
+// enabled.
+func (m *Manager) issueToken(c *Client) {
+	if m.ring == nil {
+		return
+	}
+	c.token = newResumeToken()
+	sendReply(c, sessionReply{Type: "session", Token: c.token, Seq: m.ring.lastSeq()})
+}
+
+// park records c's subscriptions under its resume token and forgets parked
+// sessions older than resumeTTL. The caller holds m.mu.
+func (m *Manager) park(c *Client) {
+	if c.token == "" {
+		return
+	}
+	now := m.clock.Now()
+	for tok, p := range m.parked {
+		if now.Sub(p.at) > resumeTTL {
+			delete(m.parked, tok)
+		}
+	}
+	m.parked[c.token] = parkedSession{
+		all:     c.IsAllSubscribed(),
+		locates: c.SubscribedLocates(),
+		at:      now,
+	}
+}
+
+// resume restores the subscriptions parked under token onto c and replays
+// the buffered messages for them with sequence > sinceSeq. Subscriptions are
+// restored before the replay is read, so a message broadcast meanwhile can
+// arrive both replayed and live; clients dedupe by seq. A replay larger than
+// the client's free send buffer is cut short and reported incomplete. A token
+// resumes at most once.
+func (m *Manager) resume(c *Client, token string, sinceSeq uint64) resumeReply {
+	reply := resumeReply{Type: "resume"}
+	if m.ring == nil {
+		reply.Reason = "resume disabled"
+		return reply
+	}
+
+	m.mu.Lock()
+	p, ok := m.parked[token]
+	if ok && m.clock.Now().Sub(p.at) <= resumeTTL {
+		delete(m.parked, token)
+	} else {
+		ok = false
+	}
+	m.mu.Unlock()
+	if !ok {
+		reply.Reason = "unknown or expired token"
+		return reply
+	}
+
+	if p.all {
+		if c.SubscribeAll(len(m.symbols)) {
+			reply.Symbols = []string{"*"}
+		} else {
+			rejectAll(c)
+		}
+	} else {
+		rejected := c.Subscribe(p.locates)
+		reply.Symbols = tickersFor(m, withoutLocates(p.locates, rejected))
+	}
+
+	msgs, complete := m.ring.since(sinceSeq, c.IsSubscribed)
+	sent := m.sendStamped(c, msgs)
+	reply.OK = true
+	reply.Replayed = sent
+	reply.Complete = complete && sent == len(msgs)
+	reply.Seq = m.ring.lastSeq()
+	return reply
+}
+
+// historyReply follows the messages replayed for a history action. Replayed
+// can fall short of the count asked for when the ring holds fewer of the
+// symbol's messages or the replay did not fit the send buffer.
+type historyReply struct {
+	Type     string `json:"type"`
+	OK       bool   `json:"ok"`
+	Reason   string `json:"reason,omitempty"`
+	Symbol   string `json:"symbol"`
+	Replayed int    `json:"replayed"`
+	Seq      uint64 `json:"seq"`
+}
+
+// history replays to c alone the last count buffered messages for ticker,
+// oldest first and with their original sequence numbers, so a new client
+// gets recent context without subscribing or taking a book snapshot. count
+// is capped at maxHistory and the ring's size.
+func (m *Manager) history(c *Client, ticker string, count int) historyReply {
+	reply := historyReply{Type: "history", Symbol: ticker}
+	if m.ring == nil {
+		reply.Reason = "history disabled"
+		return reply
+	}
+	locate, ok := m.byTicker[ticker]
+	if !ok {
+		reply.Reason = "unknown symbol"
+		return reply
+	}
+	if count <= 0 {
+		reply.Reason = "count must be positive"
+		return reply
+	}
+
+	msgs := m.ring.recent(locate, min(count, maxHistory))
+	reply.OK = true
+	reply.Replayed = m.sendStamped(c, msgs)
+	reply.Seq = m.ring.lastSeq()
+	return reply
+}
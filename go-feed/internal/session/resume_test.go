@@ -0,0 +1,249 @@
+package session
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+func TestReplayRingSinceEvicted(t *testing.T) {
+	r := newReplayRing(4)
+	for i := range 6 {
+		msgs := []itch.Message{{Type: itch.MsgOrderDelete, OrderRef: uint64(i + 1)}}
+		r.append(uint16(1+i%2), msgs)
+		if msgs[0].Seq != uint64(i+1) {
+			t.Fatalf("message %d stamped seq %d", i+1, msgs[0].Seq)
+		}
+	}
+
+	all := func(uint16) bool { return true }
+	got, complete := r.since(1, all)
+	if complete {
+		t.Error("seq 2 was overwritten but replay reported complete")
+	}
+	if len(got) != 4 || got[0].Seq != 3 || got[3].Seq != 6 {
+		t.Errorf("since(1) = %d messages from seq %d, want seqs 3..6", len(got), got[0].Seq)
+	}
+
+	got, complete = r.since(4, func(loc uint16) bool { return loc == 2 })
+	if !complete || len(got) != 1 || got[0].Seq != 6 {
+		t.Errorf("since(4) for locate 2 = %+v (complete %v), want only seq 6", got, complete)
+	}
+}
+
+// TestResumeReplaysAfterSeq drops a subscribed connection, broadcasts while
+// it is away, and checks a new connection quoting the old token and a
+// sequence number gets exactly its symbol's messages after that sequence.
+func TestResumeReplaysAfterSeq(t *testing.T) {
+	mgr := newTestManager()
+	mgr.SetResumeBuffer(16)
+	srv := httptest.NewServer(Handler(mgr))
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	dial := func() *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+	read := func(conn *websocket.Conn, v any) {
+		t.Helper()
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("decode %s: %v", data, err)
+		}
+	}
+	send := func(conn *websocket.Conn, v any) {
+		t.Helper()
+		if err := conn.WriteJSON(v); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	first := dial()
+	var session sessionReply
+	read(first, &session)
+	if session.Type != "session" || session.Token == "" {
+		t.Fatalf("connect reply = %+v, want a session token", session)
+	}
+	token := session.Token
+	send(first, controlMessage{Action: "subscribe", Symbols: []string{"NEXO"}})
+	var dir map[string]any
+	read(first, &dir) // NEXO stock directory
+	first.Close()
+	for deadline := time.Now().Add(5 * time.Second); mgr.ClientCount() > 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("first client never unregistered")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	add := func(ref uint64) itch.Message {
+		return itch.Message{Type: itch.MsgAddOrder, OrderRef: ref, Side: 'B', Shares: 100, Price: 185}
+	}
+	mgr.Broadcast(1, "NEXO", []itch.Message{add(1)})         // seq 1
+	mgr.Broadcast(2, "QBIT", []itch.Message{add(2)})         // seq 2, not subscribed
+	mgr.Broadcast(1, "NEXO", []itch.Message{add(3), add(4)}) // seqs 3, 4
+
+	second := dial()
+	defer second.Close()
+	read(second, &session)
+	if session.Token == token || session.Seq != 4 {
+		t.Fatalf("second connect reply = %+v, want a fresh token at seq 4", session)
+	}
+	send(second, controlMessage{Action: "hello", Version: itch.JSONVersion3})
+	var hello helloReply
+	read(second, &hello)
+	send(second, controlMessage{Action: "resume", Token: token, SinceSeq: 1})
+
+	for _, want := range []float64{3, 4} { // orderRef matches seq here
+		var msg map[string]any
+		read(second, &msg)
+		if msg["orderRef"] != want || msg["seq"] != want {
+			t.Fatalf("replayed message = %v, want orderRef and seq %v", msg, want)
+		}
+	}
+	var reply resumeReply
+	read(second, &reply)
+	if !reply.OK || reply.Replayed != 2 || !reply.Complete || reply.Seq != 4 {
+		t.Errorf("resume reply = %+v, want ok, 2 replayed, complete, seq 4", reply)
+	}
+	if len(reply.Symbols) != 1 || reply.Symbols[0] != "NEXO" {
+		t.Errorf("resumed symbols = %v, want [NEXO]", reply.Symbols)
+	}
+
+	send(second, controlMessage{Action: "resume", Token: token, SinceSeq: 1})
+	read(second, &reply)
+	if reply.OK {
+		t.Error("token resumed twice, want it consumed by the first resume")
+	}
+}
+
+// TestConcurrentBroadcastsArriveInSeqOrder broadcasts from one goroutine per
+// symbol, as the runners do, and checks a client receives every message in
+// sequence order: a resume quotes the last seq received, so a lower seq
+// arriving later would be skipped.
+func TestConcurrentBroadcastsArriveInSeqOrder(t *testing.T) {
+	const runners, batches = 8, 200
+	mgr := newTestManager()
+	mgr.SetResumeBuffer(DefaultResumeBuffer)
+	c := newTestClient(runners * batches * 2)
+	c.SetVersion(itch.JSONVersion3)
+	c.SubscribeAll(len(mgr.symbols))
+	mgr.mu.Lock()
+	mgr.clients[c.ID] = c
+	mgr.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for i := range runners {
+		sym := mgr.symbols[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ref := range uint64(batches) {
+				mgr.Broadcast(sym.LocateCode, sym.Ticker, []itch.Message{
+					{Type: itch.MsgOrderDelete, OrderRef: 2 * ref},
+					{Type: itch.MsgOrderDelete, OrderRef: 2*ref + 1},
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	out := drain(c)
+	if len(out) != runners*batches*2 {
+		t.Fatalf("client got %d messages, want %d", len(out), runners*batches*2)
+	}
+	for i, o := range out {
+		var msg struct{ Seq uint64 }
+		if err := json.Unmarshal(o.data, &msg); err != nil {
+			t.Fatalf("decode %s: %v", o.data, err)
+		}
+		if msg.Seq != uint64(i+1) {
+			t.Fatalf("message %d on the wire has seq %d, want %d", i+1, msg.Seq, i+1)
+		}
+	}
+}
+
+func TestHistoryReplaysLastN(t *testing.T) {
+	mgr := newTestManager()
+	mgr.SetResumeBuffer(16)
+	for i := range 5 {
+		mgr.Broadcast(1, "NEXO", []itch.Message{{Type: itch.MsgOrderDelete, StockLocate: 1, OrderRef: uint64(i + 1)}})
+		mgr.Broadcast(2, "QBIT", []itch.Message{{Type: itch.MsgOrderDelete, StockLocate: 2, OrderRef: uint64(100 + i)}})
+	}
+
+	history := func(count int) (refs []uint64, reply historyReply) {
+		t.Helper()
+		c := newTestClient(100)
+		handleControl(c, mgr, &controlMessage{Action: "history", Symbol: "NEXO", Count: count})
+		out := drain(c)
+		if len(out) == 0 || json.Unmarshal(out[len(out)-1].data, &reply) != nil {
+			t.Fatalf("history %d: no reply in %d messages", count, len(out))
+		}
+		for _, o := range out[:len(out)-1] {
+			var m struct {
+				OrderRef    uint64 `json:"orderRef"`
+				StockLocate uint16 `json:"stockLocate"`
+			}
+			if err := json.Unmarshal(o.data, &m); err != nil || m.StockLocate != 1 {
+				t.Fatalf("history %d replayed %s, want a NEXO message", count, o.data)
+			}
+			refs = append(refs, m.OrderRef)
+		}
+		return refs, reply
+	}
+
+	refs, reply := history(3)
+	if len(refs) != 3 || refs[0] != 3 || refs[1] != 4 || refs[2] != 5 {
+		t.Errorf("history 3 replayed orders %v, want 3, 4, 5", refs)
+	}
+	if !reply.OK || reply.Replayed != 3 || reply.Seq != 10 {
+		t.Errorf("reply = %+v, want 3 replayed at seq 10", reply)
+	}
+
+	// More than the ring holds: everything buffered, still in order.
+	refs, reply = history(50)
+	if len(refs) != 5 || refs[0] != 1 || refs[4] != 5 || reply.Replayed != 5 {
+		t.Errorf("history 50 replayed orders %v (reply %+v), want 1 through 5", refs, reply)
+	}
+
+	if _, reply := history(0); reply.OK {
+		t.Error("history 0 accepted")
+	}
+}
+
+// TestResumeAllOverLimitIsRejected checks that resuming a parked "*" session
+// under a subscription limit below the symbol count restores nothing and
+// acks the refusal, as a "*" subscribe would.
+func TestResumeAllOverLimitIsRejected(t *testing.T) {
+	mgr := newTestManager()
+	mgr.SetResumeBuffer(16)
+	mgr.parked["tok"] = parkedSession{all: true, at: mgr.clock.Now()}
+	c := newTestClient(100)
+	c.SetMaxSubscriptions(len(mgr.symbols) - 1)
+
+	reply := mgr.resume(c, "tok", 0)
+	if !reply.OK || len(reply.Symbols) != 0 || c.IsAllSubscribed() {
+		t.Fatalf("reply = %+v, all = %v; want a resume restoring no symbols", reply, c.IsAllSubscribed())
+	}
+	var rejected subscribeRejected
+	out := drain(c)
+	if len(out) != 1 || json.Unmarshal(out[0].data, &rejected) != nil || !slices.Equal(rejected.Symbols, []string{"*"}) {
+		t.Fatalf("got %d frames, want a subscribe_rejected for [*]", len(out))
+	}
+}
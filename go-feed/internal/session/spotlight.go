@@ -0,0 +1,83 @@
+package session
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+)
+
+// spotlightReply acks a spotlight action. On false means the spotlight was
+// cleared.
+type spotlightReply struct {
+	Type   string `json:"type"`
+	Symbol string `json:"symbol"`
+	On     bool   `json:"on"`
+}
+
+// depthReply is a full-depth book snapshot sent to clients spotlighting a
+// symbol after each of its updates.
+type depthReply struct {
+	Type   string       `json:"type"`
+	Symbol string       `json:"symbol"`
+	Bids   []depthLevel `json:"bids"`
+	Asks   []depthLevel `json:"asks"`
+}
+
+// depthLevel is one aggregated price level of a depthReply.
+type depthLevel struct {
+	Price  float64 `json:"price"`
+	Orders int     `json:"orders"`
+	Shares int32   `json:"shares"`
+}
+
+// SetSpotlight marks locate as spotlighted for the client: each update to it
+// is followed by a full-depth snapshot of its book. on false clears it. The
+// symbol's messages still need a subscription.
+func (c *Client) SetSpotlight(locate uint16, on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !on {
+		delete(c.spotlights, locate)
+		return
+	}
+	c.spotlights[locate] = true
+}
+
+// spotlighted reports whether locate is spotlighted for the client.
+func (c *Client) spotlighted(locate uint16) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.spotlights[locate]
+}
+
+// encodeDepth returns locate's full book as an encoded "depth" reply, or nil
+// when no book is attached for it.
+func (m *Manager) encodeDepth(locate uint16, ticker string) []byte {
+	book, ok := m.books[locate]
+	if !ok {
+		return nil
+	}
+	snap := book.Depth()
+	data, err := json.Marshal(depthReply{
+		Type:   "depth",
+		Symbol: ticker,
+		Bids:   depthLevels(snap.Bids),
+		Asks:   depthLevels(snap.Asks),
+	})
+	if err != nil {
+		log.Printf("encode %s depth: %v", ticker, err)
+		return nil
+	}
+	return data
+}
+
+// depthLevels converts book levels to their reply form; an empty side is an
+// empty list, not null.
+func depthLevels(levels []orderbook.DepthLevel) []depthLevel {
+	out := make([]depthLevel, len(levels))
+	for i, l := range levels {
+		out[i] = depthLevel{Price: l.Price, Orders: l.Orders, Shares: l.TotalShares}
+	}
+	return out
+}
@@ -0,0 +1,76 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+)
+
+// TestSpotlightSendsDepth spotlights NEXO for one of two subscribed clients
+// and checks only that client gets a full-depth snapshot after each update,
+// while the other sees just the message stream.
+func TestSpotlightSendsDepth(t *testing.T) {
+	m := newTestManager()
+	locs, _ := m.ResolveTickers([]string{"NEXO"})
+	nexo := locs[0]
+	book := orderbook.NewBook(nexo, 0.01)
+	book.AddOrder(&orderbook.Order{ID: 1, Locate: nexo, Side: orderbook.SideBuy, Price: 10.00, Shares: 200})
+	book.AddOrder(&orderbook.Order{ID: 2, Locate: nexo, Side: orderbook.SideBuy, Price: 9.99, Shares: 100})
+	book.AddOrder(&orderbook.Order{ID: 3, Locate: nexo, Side: orderbook.SideBuy, Price: 9.99, Shares: 50})
+	book.AddOrder(&orderbook.Order{ID: 4, Locate: nexo, Side: orderbook.SideSell, Price: 10.02, Shares: 300})
+	m.SetBooks(map[uint16]*orderbook.Book{nexo: book})
+
+	spot, plain := newTestClient(100), newTestClient(100)
+	for _, c := range []*Client{spot, plain} {
+		c.Subscribe([]uint16{nexo})
+		m.mu.Lock()
+		m.clients[c.ID] = c
+		m.mu.Unlock()
+	}
+	handleControl(spot, m, &controlMessage{Action: "spotlight", Symbol: "NEXO"})
+	var ack spotlightReply
+	if out := drain(spot); len(out) != 1 || json.Unmarshal(out[0].data, &ack) != nil || !ack.On || ack.Symbol != "NEXO" {
+		t.Fatalf("spotlight ack = %+v, want NEXO on", ack)
+	}
+
+	del := []itch.Message{{Type: itch.MsgOrderDelete, StockLocate: nexo, OrderRef: 9}}
+	for range 3 {
+		m.Broadcast(nexo, "NEXO", append([]itch.Message(nil), del...))
+	}
+
+	out := drain(spot)
+	if len(out) != 6 {
+		t.Fatalf("spotlighted client got %d frames, want 3 messages each followed by a snapshot", len(out))
+	}
+	for i := 0; i < len(out); i += 2 {
+		if out[i].control {
+			t.Errorf("frame %d is a control frame, want the order delete", i)
+		}
+		var d depthReply
+		if !out[i+1].control || json.Unmarshal(out[i+1].data, &d) != nil || d.Type != "depth" {
+			t.Fatalf("frame %d = %s, want a depth snapshot", i+1, out[i+1].data)
+		}
+		wantBids := []depthLevel{{Price: 10.00, Orders: 1, Shares: 200}, {Price: 9.99, Orders: 2, Shares: 150}}
+		if d.Symbol != "NEXO" || len(d.Bids) != 2 || d.Bids[0] != wantBids[0] || d.Bids[1] != wantBids[1] ||
+			len(d.Asks) != 1 || d.Asks[0].Shares != 300 {
+			t.Errorf("snapshot = %+v, want NEXO's two bid levels and one ask", d)
+		}
+	}
+
+	for _, o := range drain(plain) {
+		if o.control {
+			t.Errorf("unspotlighted client got control frame %s", o.data)
+		}
+	}
+
+	// Cleared: back to the plain stream.
+	off := false
+	handleControl(spot, m, &controlMessage{Action: "spotlight", Symbol: "NEXO", On: &off})
+	drain(spot)
+	m.Broadcast(nexo, "NEXO", del)
+	if out := drain(spot); len(out) != 1 || out[0].control {
+		t.Errorf("after clearing: %d frames, want just the message", len(out))
+	}
+}
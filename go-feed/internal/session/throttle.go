@@ -0,0 +1,107 @@
+package session
+
+import (
+	"context"
+	"time"
+)
+
+// throttleFlush is how often RunThrottles looks for throttled symbols whose
+// interval has passed with updates still undelivered.
+const throttleFlush = 50 * time.Millisecond
+
+// throttle caps one symbol's update rate to a client. Updates within the
+// interval are conflated: the client gets the symbol's top of book once the
+// interval is up, instead of every message.
+type throttle struct {
+	interval time.Duration
+	last     time.Time // when the last snapshot was sent
+	pending  bool      // updates since the last snapshot
+}
+
+// throttleReply acks a throttle action. MaxPerSec 0 means the throttle was
+// cleared.
+type throttleReply struct {
+	Type      string  `json:"type"`
+	Symbol    string  `json:"symbol"`
+	MaxPerSec float64 `json:"maxPerSec"`
+}
+
+// SetThrottle limits locate's updates to the client to maxPerSec top-of-book
+// snapshots a second. maxPerSec <= 0 clears the throttle, restoring the full
+// message stream.
+func (c *Client) SetThrottle(locate uint16, maxPerSec float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if maxPerSec <= 0 {
+		delete(c.throttles, locate)
+		return
+	}
+	c.throttles[locate] = &throttle{interval: time.Duration(float64(time.Second) / maxPerSec)}
+}
+
+// throttled reports whether locate is throttled for the client, recording an
+// update at now if so. due is true when a snapshot should go out now, in
+// which case the update counts as delivered.
+func (c *Client) throttled(locate uint16, now time.Time) (throttled, due bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.throttles[locate]
+	if !ok {
+		return false, false
+	}
+	if now.Sub(t.last) < t.interval {
+		t.pending = true
+		return true, false
+	}
+	t.last, t.pending = now, false
+	return true, true
+}
+
+// dueThrottles returns the throttled symbols with undelivered updates whose
+// interval has passed at now, marking them delivered.
+func (c *Client) dueThrottles(now time.Time) []uint16 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var due []uint16
+	for loc, t := range c.throttles {
+		if t.pending && now.Sub(t.last) >= t.interval {
+			t.last, t.pending = now, false
+			due = append(due, loc)
+		}
+	}
+	return due
+}
+
+// RunThrottles delivers conflated updates for throttled symbols that went
+// quiet before their interval was up, until ctx is cancelled.
+func (m *Manager) RunThrottles(ctx context.Context) {
+	ticker := m.clock.NewTicker(throttleFlush)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			m.flushThrottles()
+		}
+	}
+}
+
+// flushThrottles sends every client the snapshots its throttles owe.
+func (m *Manager) flushThrottles() {
+	now := m.clock.Now()
+	for _, c := range m.clientList() {
+		due := c.dueThrottles(now)
+		for i, ticker := range tickersFor(m, due) {
+			m.sendThrottled(c, due[i], ticker)
+		}
+	}
+}
+
+// sendThrottled sends c locate's current top of book as a "bbo" reply, the
+// conflated form of a throttled symbol's updates.
+func (m *Manager) sendThrottled(c *Client, locate uint16, ticker string) {
+	r, _ := m.quote("bbo", locate, ticker)
+	sendReply(c, r)
+}
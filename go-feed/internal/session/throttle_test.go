@@ -0,0 +1,89 @@
+package session
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/engine"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/orderbook"
+	"github.com/ndrandal/feed-simulator/go-feed/internal/symbol"
+)
+
+// TestThrottleCapsOneSymbol throttles NEXO to one update a second while both
+// NEXO and QBIT broadcast ten times a second, and checks NEXO arrives as at
+// most one top-of-book snapshot a second while QBIT's stream is untouched.
+func TestThrottleCapsOneSymbol(t *testing.T) {
+	clock := engine.NewFakeClock(time.Date(2026, 1, 2, 14, 30, 0, 0, time.UTC))
+	m := NewManagerWithClock(symbol.AllSymbols(), 1000, clock)
+	locs, _ := m.ResolveTickers([]string{"NEXO", "QBIT"})
+	nexo, qbit := locs[0], locs[1]
+	book := orderbook.NewBook(nexo, 0.01)
+	book.AddOrder(&orderbook.Order{ID: 1, Locate: nexo, Side: orderbook.SideBuy, Price: 10.00, Shares: 200})
+	m.SetBooks(map[uint16]*orderbook.Book{nexo: book})
+
+	c := newTestClient(1000)
+	c.Subscribe([]uint16{nexo, qbit})
+	m.mu.Lock()
+	m.clients[c.ID] = c
+	m.mu.Unlock()
+	handleControl(c, m, &controlMessage{Action: "throttle", Symbol: "NEXO", MaxPerSec: 1})
+	var ack throttleReply
+	if out := drain(c); len(out) != 1 || json.Unmarshal(out[0].data, &ack) != nil || ack.MaxPerSec != 1 {
+		t.Fatalf("throttle ack = %+v, want NEXO at 1/sec", ack)
+	}
+
+	var snapshots, qbitMsgs int
+	count := func() {
+		for _, o := range drain(c) {
+			if !o.control {
+				var msg map[string]any
+				json.Unmarshal(o.data, &msg)
+				if msg["stockLocate"] != float64(qbit) {
+					t.Fatalf("unthrottled message for a throttled symbol: %s", o.data)
+				}
+				qbitMsgs++
+				continue
+			}
+			var r bboReply
+			if err := json.Unmarshal(o.data, &r); err != nil || r.Type != "bbo" || r.Symbol != "NEXO" {
+				t.Fatalf("control frame %s, want a NEXO bbo", o.data)
+			}
+			if r.BidPrice != 10.00 || r.BidSize != 200 {
+				t.Errorf("snapshot = %+v, want NEXO's bid 200 @ 10.00", r)
+			}
+			snapshots++
+		}
+	}
+
+	del := func(loc uint16) []itch.Message {
+		return []itch.Message{{Type: itch.MsgOrderDelete, StockLocate: loc, OrderRef: 9}}
+	}
+	for range 30 { // 3 seconds
+		clock.Advance(100 * time.Millisecond)
+		m.Broadcast(nexo, "NEXO", del(nexo))
+		m.Broadcast(qbit, "QBIT", del(qbit))
+		m.flushThrottles()
+		count()
+	}
+	if snapshots < 2 || snapshots > 3 {
+		t.Errorf("NEXO snapshots over 3s = %d, want at most 1/sec (2-3)", snapshots)
+	}
+	if qbitMsgs != 30 {
+		t.Errorf("QBIT messages = %d, want all 30", qbitMsgs)
+	}
+
+	// Updates held back by the throttle are delivered once the interval is up.
+	before := snapshots
+	clock.Advance(time.Second)
+	m.flushThrottles()
+	count()
+	if snapshots != before+1 {
+		t.Errorf("flush after quiet interval sent %d snapshots, want 1", snapshots-before)
+	}
+	m.flushThrottles()
+	if count(); snapshots != before+1 {
+		t.Error("flush with nothing pending sent another snapshot")
+	}
+}
@@ -1,5 +1,12 @@
 package symbol
 
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
 // Sector represents a market sector.
 type Sector string
 
@@ -24,56 +31,57 @@ type Symbol struct {
 	TickSize            float64
 	VolatilityMultiplier float64
 	IsStress            bool
+	PersistTrades       bool // trades are written to the trade store (broadcast either way)
 }
 
 // AllSymbols returns the 30 fake symbols across 7 sectors + ETFs.
 func AllSymbols() []Symbol {
 	return []Symbol{
 		// Tech (6) — mid-high volatility
-		{1, "NEXO", "Nexo Dynamics Inc", SectorTech, 185.00, 0.01, 1.4, false},
-		{2, "QBIT", "Qbit Quantum Corp", SectorTech, 92.50, 0.01, 1.6, false},
-		{3, "FLUX", "Flux Systems Ltd", SectorTech, 310.00, 0.01, 1.3, false},
-		{4, "SYNK", "Synk Networks Inc", SectorTech, 67.25, 0.01, 1.5, false},
-		{5, "PULS", "Puls Digital Corp", SectorTech, 145.00, 0.01, 1.2, false},
-		{6, "CYRA", "Cyra Robotics Inc", SectorTech, 220.00, 0.01, 1.7, false},
+		{1, "NEXO", "Nexo Dynamics Inc", SectorTech, 185.00, 0.01, 1.4, false, true},
+		{2, "QBIT", "Qbit Quantum Corp", SectorTech, 92.50, 0.01, 1.6, false, true},
+		{3, "FLUX", "Flux Systems Ltd", SectorTech, 310.00, 0.01, 1.3, false, true},
+		{4, "SYNK", "Synk Networks Inc", SectorTech, 67.25, 0.01, 1.5, false, true},
+		{5, "PULS", "Puls Digital Corp", SectorTech, 145.00, 0.01, 1.2, false, true},
+		{6, "CYRA", "Cyra Robotics Inc", SectorTech, 220.00, 0.01, 1.7, false, true},
 
 		// Finance (5) — low-mid volatility
-		{7, "LEDG", "Ledger Capital Group", SectorFinance, 78.50, 0.01, 0.8, false},
-		{8, "VALT", "Vault Securities Inc", SectorFinance, 125.00, 0.01, 0.7, false},
-		{9, "CRDT", "Credt Financial Corp", SectorFinance, 52.00, 0.01, 0.9, false},
-		{10, "MNTX", "Mintex Banking Corp", SectorFinance, 165.00, 0.01, 0.6, false},
-		{11, "FNDX", "Fundex Asset Mgmt", SectorFinance, 88.75, 0.01, 0.8, false},
+		{7, "LEDG", "Ledger Capital Group", SectorFinance, 78.50, 0.01, 0.8, false, true},
+		{8, "VALT", "Vault Securities Inc", SectorFinance, 125.00, 0.01, 0.7, false, true},
+		{9, "CRDT", "Credt Financial Corp", SectorFinance, 52.00, 0.01, 0.9, false, true},
+		{10, "MNTX", "Mintex Banking Corp", SectorFinance, 165.00, 0.01, 0.6, false, true},
+		{11, "FNDX", "Fundex Asset Mgmt", SectorFinance, 88.75, 0.01, 0.8, false, true},
 
 		// Healthcare (4) — low volatility
-		{12, "HELX", "Helix Biomedical Inc", SectorHealthcare, 195.00, 0.01, 0.5, false},
-		{13, "CURA", "Cura Therapeutics", SectorHealthcare, 72.00, 0.01, 0.6, false},
-		{14, "GENX", "GenX Genomics Corp", SectorHealthcare, 148.50, 0.01, 0.7, false},
-		{15, "BIOS", "Bios Pharma Ltd", SectorHealthcare, 55.25, 0.01, 0.5, false},
+		{12, "HELX", "Helix Biomedical Inc", SectorHealthcare, 195.00, 0.01, 0.5, false, true},
+		{13, "CURA", "Cura Therapeutics", SectorHealthcare, 72.00, 0.01, 0.6, false, true},
+		{14, "GENX", "GenX Genomics Corp", SectorHealthcare, 148.50, 0.01, 0.7, false, true},
+		{15, "BIOS", "Bios Pharma Ltd", SectorHealthcare, 55.25, 0.01, 0.5, false, true},
 
 		// Energy (4) — mid volatility
-		{16, "VOLT", "Volt Energy Corp", SectorEnergy, 98.00, 0.01, 1.1, false},
-		{17, "SOLR", "Solaris Power Inc", SectorEnergy, 42.50, 0.01, 1.0, false},
-		{18, "FUSE", "Fuse Petroleum Ltd", SectorEnergy, 175.00, 0.01, 1.2, false},
-		{19, "WATT", "Watt Grid Systems", SectorEnergy, 63.00, 0.01, 1.0, false},
+		{16, "VOLT", "Volt Energy Corp", SectorEnergy, 98.00, 0.01, 1.1, false, true},
+		{17, "SOLR", "Solaris Power Inc", SectorEnergy, 42.50, 0.01, 1.0, false, true},
+		{18, "FUSE", "Fuse Petroleum Ltd", SectorEnergy, 175.00, 0.01, 1.2, false, true},
+		{19, "WATT", "Watt Grid Systems", SectorEnergy, 63.00, 0.01, 1.0, false, true},
 
 		// Consumer (4) — low-mid volatility
-		{20, "BRND", "Brand Global Inc", SectorConsumer, 112.00, 0.01, 0.8, false},
-		{21, "LUXE", "Luxe Retail Corp", SectorConsumer, 285.00, 0.01, 0.7, false},
-		{22, "DLVR", "Deliver Express Inc", SectorConsumer, 78.00, 0.01, 0.9, false},
-		{23, "RSTK", "Restock Supply Corp", SectorConsumer, 45.50, 0.01, 0.8, false},
+		{20, "BRND", "Brand Global Inc", SectorConsumer, 112.00, 0.01, 0.8, false, true},
+		{21, "LUXE", "Luxe Retail Corp", SectorConsumer, 285.00, 0.01, 0.7, false, true},
+		{22, "DLVR", "Deliver Express Inc", SectorConsumer, 78.00, 0.01, 0.9, false, true},
+		{23, "RSTK", "Restock Supply Corp", SectorConsumer, 45.50, 0.01, 0.8, false, true},
 
 		// Industrial (4) — mid volatility
-		{24, "FORG", "Forge Manufacturing", SectorIndustrial, 132.00, 0.01, 1.0, false},
-		{25, "BLDR", "Builder Heavy Ind", SectorIndustrial, 88.00, 0.01, 1.1, false},
-		{26, "MACH", "Mach Precision Corp", SectorIndustrial, 205.00, 0.01, 1.0, false},
-		{27, "ALOY", "Aloy Materials Inc", SectorIndustrial, 56.75, 0.01, 1.2, false},
+		{24, "FORG", "Forge Manufacturing", SectorIndustrial, 132.00, 0.01, 1.0, false, true},
+		{25, "BLDR", "Builder Heavy Ind", SectorIndustrial, 88.00, 0.01, 1.1, false, true},
+		{26, "MACH", "Mach Precision Corp", SectorIndustrial, 205.00, 0.01, 1.0, false, true},
+		{27, "ALOY", "Aloy Materials Inc", SectorIndustrial, 56.75, 0.01, 1.2, false, true},
 
 		// Stress (1) — always hot
-		{28, "BLITZ", "Blitz Trading Corp", SectorStress, 125.00, 0.01, 2.0, true},
+		{28, "BLITZ", "Blitz Trading Corp", SectorStress, 125.00, 0.01, 2.0, true, true},
 
 		// ETFs (2) — low volatility
-		{29, "MKTS", "Markets Broad ETF", SectorETF, 350.00, 0.01, 0.4, false},
-		{30, "GRWT", "Growth Select ETF", SectorETF, 180.00, 0.01, 0.5, false},
+		{29, "MKTS", "Markets Broad ETF", SectorETF, 350.00, 0.01, 0.4, false, true},
+		{30, "GRWT", "Growth Select ETF", SectorETF, 180.00, 0.01, 0.5, false, true},
 	}
 }
 
@@ -115,3 +123,33 @@ func SymbolsBySector() map[Sector][]Symbol {
 	}
 	return m
 }
+
+// Select returns the symbols named by spec, a comma-separated ticker list, in
+// universe order. An empty spec or "*" selects every symbol; an unknown or
+// empty ticker is an error.
+func Select(syms []Symbol, spec string) ([]Symbol, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "*" {
+		return syms, nil
+	}
+	want := make(map[string]bool)
+	for _, part := range strings.Split(spec, ",") {
+		t := strings.TrimSpace(part)
+		if t == "" {
+			return nil, fmt.Errorf("symbol selection %q contains an empty ticker", spec)
+		}
+		want[t] = true
+	}
+	var out []Symbol
+	for _, s := range syms {
+		if want[s.Ticker] {
+			out = append(out, s)
+			delete(want, s.Ticker)
+		}
+	}
+	if len(want) > 0 {
+		unknown := slices.Sorted(maps.Keys(want))
+		return nil, fmt.Errorf("symbol selection: unknown ticker(s) %s", strings.Join(unknown, ","))
+	}
+	return out, nil
+}
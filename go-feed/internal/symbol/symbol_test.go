@@ -134,3 +134,50 @@ func TestNonStressSymbols(t *testing.T) {
 		}
 	}
 }
+
+func TestUniverseHashStable(t *testing.T) {
+	if UniverseHash() != UniverseHash() {
+		t.Fatal("UniverseHash should be stable across calls")
+	}
+	syms := AllSymbols()
+	reversed := make([]Symbol, len(syms))
+	for i := range syms {
+		reversed[len(syms)-1-i] = syms[i]
+	}
+	if HashSymbols(reversed) != UniverseHash() {
+		t.Fatal("HashSymbols should not depend on slice order")
+	}
+}
+
+func TestUniverseHashChanges(t *testing.T) {
+	syms := AllSymbols()
+	if HashSymbols(syms[:len(syms)-1]) == UniverseHash() {
+		t.Fatal("removing a symbol should change the hash")
+	}
+	syms[0].BasePrice += 1
+	if HashSymbols(syms) == UniverseHash() {
+		t.Fatal("editing a symbol should change the hash")
+	}
+}
+
+func TestSelectOnlyListedSymbols(t *testing.T) {
+	syms := AllSymbols()
+	got, err := Select(syms, "BLITZ, NEXO")
+	if err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+	if len(got) != 2 || got[0].Ticker != "NEXO" || got[1].Ticker != "BLITZ" {
+		t.Errorf("Select(BLITZ,NEXO) = %v, want NEXO and BLITZ in universe order", got)
+	}
+
+	for _, spec := range []string{"", "*"} {
+		if all, err := Select(syms, spec); err != nil || len(all) != len(syms) {
+			t.Errorf("Select(%q) = %d symbols, %v; want all %d", spec, len(all), err, len(syms))
+		}
+	}
+	for _, spec := range []string{"NOPE", "NEXO,,BLITZ"} {
+		if _, err := Select(syms, spec); err == nil {
+			t.Errorf("Select(%q) succeeded, want error", spec)
+		}
+	}
+}
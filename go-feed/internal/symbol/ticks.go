@@ -0,0 +1,107 @@
+package symbol
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TickBand sets the tick size for prices at or above From, up to the next
+// band's From.
+type TickBand struct {
+	From float64
+	Tick float64
+}
+
+// TickSchedule maps a price to its tick size by price band, the way venues
+// use finer ticks for sub-dollar names and coarser ones for high-priced names.
+// Bands are sorted ascending by From. An empty schedule, or a price below the
+// first band, falls back to the symbol's fixed TickSize.
+type TickSchedule []TickBand
+
+// ParseTickSchedule parses "from:tick" pairs separated by commas, e.g.
+// "0:0.0001,1:0.01,1000:0.05". Bands must be given in ascending order of
+// from, with non-negative bounds and positive ticks. An empty string yields
+// an empty schedule.
+func ParseTickSchedule(s string) (TickSchedule, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+	var ts TickSchedule
+	for _, part := range strings.Split(s, ",") {
+		from, tick, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("tick band %q: want from:tick", part)
+		}
+		f, err := strconv.ParseFloat(from, 64)
+		if err != nil || f < 0 {
+			return nil, fmt.Errorf("tick band %q: invalid lower bound %q", part, from)
+		}
+		t, err := strconv.ParseFloat(tick, 64)
+		if err != nil || !(t > 0) {
+			return nil, fmt.Errorf("tick band %q: invalid tick %q", part, tick)
+		}
+		if n := len(ts); n > 0 && f <= ts[n-1].From {
+			return nil, fmt.Errorf("tick band %q: bounds must be ascending", part)
+		}
+		ts = append(ts, TickBand{From: f, Tick: t})
+	}
+	return ts, nil
+}
+
+// TickAt returns the tick size for price, or fallback when no band covers it.
+func (ts TickSchedule) TickAt(price, fallback float64) float64 {
+	i := sort.Search(len(ts), func(i int) bool { return ts[i].From > price })
+	if i == 0 {
+		return fallback
+	}
+	return ts[i-1].Tick
+}
+
+// Rounding selects how a price snaps to its tick.
+type Rounding int
+
+const (
+	// RoundNearest snaps to the nearest tick (the default).
+	RoundNearest Rounding = iota
+	// RoundFloor truncates down to the tick at or below the price.
+	RoundFloor
+	// RoundCeil rounds up to the tick at or above the price.
+	RoundCeil
+)
+
+// roundEpsilon absorbs float error in price/tick, so a price already on a
+// tick (e.g. 185.01 / 0.01 = 18500.999...) stays on it under floor and ceil.
+const roundEpsilon = 1e-9
+
+// ParseRounding maps "round", "floor" or "ceil" to its mode. An empty string
+// is round.
+func ParseRounding(s string) (Rounding, error) {
+	switch s {
+	case "round", "":
+		return RoundNearest, nil
+	case "floor":
+		return RoundFloor, nil
+	case "ceil":
+		return RoundCeil, nil
+	default:
+		return 0, fmt.Errorf("unknown rounding mode %q (want round, floor or ceil)", s)
+	}
+}
+
+// Snap returns price on a multiple of tick, rounded per r.
+func (r Rounding) Snap(price, tick float64) float64 {
+	n := price / tick
+	switch r {
+	case RoundFloor:
+		n = math.Floor(n + roundEpsilon)
+	case RoundCeil:
+		n = math.Ceil(n - roundEpsilon)
+	default:
+		n = math.Round(n)
+	}
+	return n * tick
+}
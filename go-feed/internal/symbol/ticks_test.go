@@ -0,0 +1,74 @@
+package symbol
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTickScheduleBands(t *testing.T) {
+	ts, err := ParseTickSchedule("0:0.0001, 1:0.01, 1000:0.05")
+	if err != nil {
+		t.Fatalf("ParseTickSchedule: %v", err)
+	}
+	for _, c := range []struct {
+		price, want float64
+	}{
+		{0.4321, 0.0001}, // sub-dollar: finer tick
+		{0.9999, 0.0001},
+		{1.00, 0.01}, // band lower bound is inclusive
+		{185.00, 0.01},
+		{1000.00, 0.05}, // high price: coarser tick
+		{2500.00, 0.05},
+	} {
+		if got := ts.TickAt(c.price, 0.01); got != c.want {
+			t.Errorf("TickAt(%v) = %v, want %v", c.price, got, c.want)
+		}
+	}
+}
+
+func TestTickScheduleFallback(t *testing.T) {
+	var empty TickSchedule
+	if got := empty.TickAt(0.5, 0.01); got != 0.01 {
+		t.Errorf("empty schedule: TickAt = %v, want fallback 0.01", got)
+	}
+	ts, _ := ParseTickSchedule("5:0.05")
+	if got := ts.TickAt(4.99, 0.01); got != 0.01 {
+		t.Errorf("below first band: TickAt = %v, want fallback 0.01", got)
+	}
+}
+
+func TestParseTickScheduleRejects(t *testing.T) {
+	for _, s := range []string{"1", "a:0.01", "1:b", "1:0", "-1:0.01", "1:0.01,1:0.05", "10:0.05,1:0.01"} {
+		if _, err := ParseTickSchedule(s); err == nil {
+			t.Errorf("ParseTickSchedule(%q) succeeded, want error", s)
+		}
+	}
+	if ts, err := ParseTickSchedule(""); err != nil || len(ts) != 0 {
+		t.Errorf("empty string: %v, %v", ts, err)
+	}
+}
+
+func TestRoundingSnap(t *testing.T) {
+	for _, c := range []struct {
+		mode        string
+		price, want float64
+	}{
+		{"round", 10.004, 10.00},
+		{"round", 10.006, 10.01},
+		{"floor", 10.009, 10.00},
+		{"floor", 10.01, 10.01}, // already on the tick despite float error
+		{"ceil", 10.001, 10.01},
+		{"ceil", 10.01, 10.01},
+	} {
+		r, err := ParseRounding(c.mode)
+		if err != nil {
+			t.Fatalf("ParseRounding(%q): %v", c.mode, err)
+		}
+		if got := r.Snap(c.price, 0.01); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("%s Snap(%v) = %v, want %v", c.mode, c.price, got, c.want)
+		}
+	}
+	if _, err := ParseRounding("bankers"); err == nil {
+		t.Error("ParseRounding(bankers) succeeded, want error")
+	}
+}
@@ -0,0 +1,31 @@
+package symbol
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// UniverseHash returns a stable hash of the full symbol universe. It changes
+// only when a symbol is added, removed, or has its metadata edited.
+func UniverseHash() string {
+	return HashSymbols(AllSymbols())
+}
+
+// HashSymbols returns a stable hex hash of syms. The result is independent of
+// slice order (symbols are hashed in locate-code order) and covers every
+// metadata field, so clients can use it to detect a changed universe.
+func HashSymbols(syms []Symbol) string {
+	sorted := make([]Symbol, len(syms))
+	copy(sorted, syms)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].LocateCode < sorted[j].LocateCode })
+
+	h := sha256.New()
+	for _, s := range sorted {
+		fmt.Fprintf(h, "%d|%s|%s|%s|%.4f|%.4f|%.4f|%t\n",
+			s.LocateCode, s.Ticker, s.Name, s.Sector,
+			s.BasePrice, s.TickSize, s.VolatilityMultiplier, s.IsStress)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
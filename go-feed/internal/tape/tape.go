@@ -0,0 +1,148 @@
+// Package tape keeps a bounded in-memory record of recent trades per symbol,
+// so the API can serve the latest prints without a database round trip.
+package tape
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+// DefaultSize is the number of trades retained per symbol when none is
+// configured.
+const DefaultSize = 1000
+
+// Trade is one print on the tape. It mirrors persist.Trade's JSON shape so
+// clients can treat /api/tape and /api/trades results alike.
+type Trade struct {
+	MatchNumber int64     `json:"matchNumber"`
+	Ticker      string    `json:"ticker"`
+	Price       float64   `json:"price"`
+	Shares      int32     `json:"shares"`
+	Aggressor   string    `json:"aggressor"`
+	ExecutedAt  time.Time `json:"executedAt"`
+}
+
+// ring is a fixed-capacity circular buffer of one symbol's trades.
+type ring struct {
+	buf   []Trade
+	next  int    // index the next trade is written to
+	count uint64 // trades recorded since start, including overwritten ones
+}
+
+// Tape records trades per symbol locate. It is safe for concurrent use.
+type Tape struct {
+	mu    sync.RWMutex
+	size  int
+	rings map[uint16]*ring
+}
+
+// New creates a tape retaining the last size trades per symbol. size <= 0
+// uses DefaultSize.
+func New(size int) *Tape {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Tape{size: size, rings: make(map[uint16]*ring)}
+}
+
+// Size returns the per-symbol capacity.
+func (t *Tape) Size() int { return t.size }
+
+// Record appends every trade message in msgs to locate's ring, stamped with
+// at. Non-trade messages are ignored.
+func (t *Tape) Record(locate uint16, at time.Time, msgs []itch.Message) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range msgs {
+		m := &msgs[i]
+		if m.Type != itch.MsgTrade {
+			continue
+		}
+		r, ok := t.rings[locate]
+		if !ok {
+			r = &ring{buf: make([]Trade, 0, t.size)}
+			t.rings[locate] = r
+		}
+		tr := Trade{
+			MatchNumber: int64(m.MatchNumber),
+			Ticker:      strings.TrimSpace(m.Stock),
+			Price:       m.Price,
+			Shares:      m.Shares,
+			Aggressor:   string([]byte{m.AggressorSide()}),
+			ExecutedAt:  at,
+		}
+		if len(r.buf) < t.size {
+			r.buf = append(r.buf, tr)
+		} else {
+			r.buf[r.next] = tr
+		}
+		r.next = (r.next + 1) % t.size
+		r.count++
+	}
+}
+
+// Recent returns up to n of locate's most recent trades, newest first.
+// n <= 0 returns everything retained.
+func (t *Tape) Recent(locate uint16, n int) []Trade {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	r, ok := t.rings[locate]
+	if !ok {
+		return []Trade{}
+	}
+	if n <= 0 || n > len(r.buf) {
+		n = len(r.buf)
+	}
+	out := make([]Trade, 0, n)
+	for i := 1; i <= n; i++ {
+		out = append(out, r.buf[(r.next-i+len(r.buf))%len(r.buf)])
+	}
+	return out
+}
+
+// Since returns locate's retained trades executed at or after t, oldest
+// first. complete reports whether the tape still reaches back to t: false
+// when trades from that window have already been overwritten.
+func (t *Tape) Since(locate uint16, from time.Time) (trades []Trade, complete bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	r, ok := t.rings[locate]
+	if !ok {
+		return []Trade{}, true
+	}
+	n := len(r.buf)
+	oldest := r.buf[r.next%n] // next wraps onto the oldest once the ring is full
+	complete = r.count <= uint64(n) || oldest.ExecutedAt.Before(from)
+
+	trades = []Trade{}
+	for i := n; i >= 1; i-- {
+		tr := r.buf[(r.next-i+n)%n]
+		if !tr.ExecutedAt.Before(from) {
+			trades = append(trades, tr)
+		}
+	}
+	return trades, complete
+}
+
+// Count returns the number of trades recorded for locate since start,
+// including those no longer retained.
+func (t *Tape) Count(locate uint16) uint64 {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if r, ok := t.rings[locate]; ok {
+		return r.count
+	}
+	return 0
+}
+
+// Last returns locate's most recent trade, if any.
+func (t *Tape) Last(locate uint16) (Trade, bool) {
+	recent := t.Recent(locate, 1)
+	if len(recent) == 0 {
+		return Trade{}, false
+	}
+	return recent[0], true
+}
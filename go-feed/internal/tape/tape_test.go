@@ -0,0 +1,75 @@
+package tape
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ndrandal/feed-simulator/go-feed/internal/itch"
+)
+
+func trade(match uint64) itch.Message {
+	return itch.Message{Type: itch.MsgTrade, Stock: "NEXO", MatchNumber: match, Side: 'B', Shares: 100, Price: 10}
+}
+
+func TestRecordIgnoresNonTrades(t *testing.T) {
+	tp := New(10)
+	tp.Record(1, time.Now(), []itch.Message{
+		{Type: itch.MsgAddOrder, OrderRef: 1},
+		trade(1),
+		{Type: itch.MsgOrderExecuted, MatchNumber: 1},
+	})
+	if n := tp.Count(1); n != 1 {
+		t.Fatalf("Count = %d, want 1", n)
+	}
+	if got := tp.Recent(2, 0); len(got) != 0 {
+		t.Errorf("unrelated locate has %d trades", len(got))
+	}
+}
+
+func TestRingWrapsNewestFirst(t *testing.T) {
+	tp := New(3)
+	for m := uint64(1); m <= 5; m++ {
+		tp.Record(1, time.Now(), []itch.Message{trade(m)})
+	}
+
+	got := tp.Recent(1, 0)
+	if len(got) != 3 {
+		t.Fatalf("retained %d trades, want 3", len(got))
+	}
+	for i, want := range []int64{5, 4, 3} {
+		if got[i].MatchNumber != want {
+			t.Errorf("Recent[%d] = match %d, want %d", i, got[i].MatchNumber, want)
+		}
+	}
+	if two := tp.Recent(1, 2); len(two) != 2 || two[1].MatchNumber != 4 {
+		t.Errorf("Recent(2) = %+v", two)
+	}
+	if tp.Count(1) != 5 {
+		t.Errorf("Count = %d, want 5", tp.Count(1))
+	}
+	if last, ok := tp.Last(1); !ok || last.MatchNumber != 5 || last.Aggressor != "B" {
+		t.Errorf("Last = %+v, %v", last, ok)
+	}
+}
+
+func TestSinceReportsCoverage(t *testing.T) {
+	tp := New(3)
+	base := time.Date(2026, 6, 18, 14, 30, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		tp.Record(1, base.Add(time.Duration(i)*time.Second), []itch.Message{trade(uint64(i + 1))})
+	}
+
+	got, complete := tp.Since(1, base.Add(time.Second))
+	if !complete || len(got) != 2 || got[0].MatchNumber != 2 || got[1].MatchNumber != 3 {
+		t.Fatalf("Since = %+v, %v; want matches 2, 3 oldest first, complete", got, complete)
+	}
+
+	// Overwriting match 1 loses the start of a window opening at base.
+	tp.Record(1, base.Add(3*time.Second), []itch.Message{trade(4)})
+	if _, complete := tp.Since(1, base); complete {
+		t.Error("window at base reported complete after its first trade was overwritten")
+	}
+	if got, complete := tp.Since(1, base.Add(2*time.Second)); !complete || len(got) != 2 {
+		t.Errorf("Since(+2s) = %+v, %v; want 2 trades, complete", got, complete)
+	}
+}
@@ -0,0 +1,29 @@
+// Package version holds build metadata stamped in at link time:
+//
+//	go build -ldflags "-X github.com/ndrandal/feed-simulator/go-feed/internal/version.Version=v1.2.0 \
+//	  -X github.com/ndrandal/feed-simulator/go-feed/internal/version.Commit=$(git rev-parse HEAD)" ./cmd/feedsim
+package version
+
+import "runtime/debug"
+
+// Version is the release version; "dev" for unstamped builds.
+var Version = "dev"
+
+// Commit is the git commit the binary was built from. When not stamped it
+// falls back to the VCS revision the Go toolchain embeds, if any.
+var Commit = ""
+
+// GitCommit returns Commit, or the embedded VCS revision, or "unknown".
+func GitCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return "unknown"
+}